@@ -117,6 +117,7 @@ func DefaultConfig() SsmagentConfig {
 		HealthFrequencyMinutes:                DefaultSsmHealthFrequencyMinutes,
 		AssociationFrequencyMinutes:           DefaultSsmAssociationFrequencyMinutes,
 		AssociationRetryLimit:                 5,
+		AssociationWorkersLimit:               DefaultAssociationWorkersLimit,
 		CustomInventoryDefaultLocation:        DefaultCustomInventoryFolder,
 		AssociationLogsRetentionDurationHours: DefaultAssociationLogsRetentionDurationHours,
 		RunCommandLogsRetentionDurationHours:  DefaultRunCommandLogsRetentionDurationHours,
@@ -132,6 +133,16 @@ func DefaultConfig() SsmagentConfig {
 	}
 	var birdwatcher BirdwatcherCfg
 	var kms KmsConfig
+	var output = OutputCfg{
+		MaxLocalArchiveDocuments: DefaultMaxLocalArchiveDocuments,
+		HttpMaxRetries:           DefaultHttpOutputMaxRetries,
+	}
+	var inventory = InventoryCfg{
+		ExportFormat:                  DefaultInventoryExportFormat,
+		MaxExportedSnapshots:          DefaultMaxInventoryExportSnapshots,
+		CustomCollectorTimeoutSeconds: DefaultCustomCollectorTimeoutSeconds,
+		CustomCollectorUser:           DefaultCustomCollectorUser,
+	}
 
 	var ssmagentCfg = SsmagentConfig{
 		Profile:     credsProfile,
@@ -143,6 +154,8 @@ func DefaultConfig() SsmagentConfig {
 		S3:          s3,
 		Birdwatcher: birdwatcher,
 		Kms:         kms,
+		Output:      output,
+		Inventory:   inventory,
 	}
 
 	return ssmagentCfg
@@ -59,6 +59,21 @@ func parser(config *SsmagentConfig) {
 		DefaultSsmAssociationFrequencyMinutesMin,
 		DefaultSsmAssociationFrequencyMinutesMax,
 		DefaultSsmAssociationFrequencyMinutes)
+	config.Ssm.AssociationWorkersLimit = getNumericValue(
+		config.Ssm.AssociationWorkersLimit,
+		DefaultAssociationWorkersLimitMin,
+		DefaultAssociationWorkersLimitMax,
+		DefaultAssociationWorkersLimit)
+	config.Ssm.AssociationSplayWindowSeconds = getNumericValue(
+		config.Ssm.AssociationSplayWindowSeconds,
+		DefaultAssociationSplaySecondsMin,
+		DefaultAssociationSplaySecondsMax,
+		DefaultAssociationSplaySeconds)
+	config.Ssm.AssociationJitterSeconds = getNumericValue(
+		config.Ssm.AssociationJitterSeconds,
+		DefaultAssociationSplaySecondsMin,
+		DefaultAssociationSplaySecondsMax,
+		DefaultAssociationSplaySeconds)
 	config.Ssm.AssociationLogsRetentionDurationHours = getNumericValueAboveMin(
 		config.Ssm.AssociationLogsRetentionDurationHours,
 		DefaultStateOrchestrationLogsRetentionDurationHoursMin,
@@ -68,6 +83,31 @@ func parser(config *SsmagentConfig) {
 		DefaultStateOrchestrationLogsRetentionDurationHoursMin,
 		DefaultRunCommandLogsRetentionDurationHours)
 
+	// Output config
+	config.Output.LocalArchiveDirectory = getStringValue(config.Output.LocalArchiveDirectory, "")
+	config.Output.MaxLocalArchiveDocuments = getNumericValueAboveMin(
+		config.Output.MaxLocalArchiveDocuments,
+		DefaultMaxLocalArchiveDocumentsMin,
+		DefaultMaxLocalArchiveDocuments)
+	config.Output.HttpEndpoint = getStringValue(config.Output.HttpEndpoint, "")
+	config.Output.HttpMaxRetries = getNumericValue(
+		config.Output.HttpMaxRetries,
+		DefaultHttpOutputMaxRetriesMin,
+		DefaultHttpOutputMaxRetriesMax,
+		DefaultHttpOutputMaxRetries)
+
+	// Inventory config
+	config.Inventory.ExportDirectory = getStringValue(config.Inventory.ExportDirectory, "")
+	config.Inventory.ExportFormat = getStringValue(config.Inventory.ExportFormat, DefaultInventoryExportFormat)
+	config.Inventory.MaxExportedSnapshots = getNumericValueAboveMin(
+		config.Inventory.MaxExportedSnapshots,
+		DefaultMaxInventoryExportSnapshotsMin,
+		DefaultMaxInventoryExportSnapshots)
+	config.Inventory.CustomCollectorTimeoutSeconds = getNumericValueAboveMin(
+		config.Inventory.CustomCollectorTimeoutSeconds,
+		DefaultCustomCollectorTimeoutSecondsMin,
+		DefaultCustomCollectorTimeoutSeconds)
+	config.Inventory.CustomCollectorUser = getStringValue(config.Inventory.CustomCollectorUser, DefaultCustomCollectorUser)
 }
 
 // TODO https://sim.amazon.com/issues/SSM-3439
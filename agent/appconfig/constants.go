@@ -31,6 +31,26 @@ const (
 	DefaultStopTimeoutMillisMin = 10000
 	DefaultStopTimeoutMillisMax = 1000000
 
+	// Output defaults
+	DefaultMaxLocalArchiveDocuments    = 500
+	DefaultMaxLocalArchiveDocumentsMin = 1
+
+	DefaultHttpOutputMaxRetries    = 3
+	DefaultHttpOutputMaxRetriesMin = 0
+	DefaultHttpOutputMaxRetriesMax = 10
+
+	// Inventory export defaults
+	InventoryExportFormatJSON             = "JSON"
+	InventoryExportFormatCSV              = "CSV"
+	DefaultInventoryExportFormat          = InventoryExportFormatJSON
+	DefaultMaxInventoryExportSnapshots    = 10
+	DefaultMaxInventoryExportSnapshotsMin = 1
+
+	// Custom inventory collector script defaults
+	DefaultCustomCollectorTimeoutSeconds    = 60
+	DefaultCustomCollectorTimeoutSecondsMin = 1
+	DefaultCustomCollectorUser              = "nobody"
+
 	// SSM defaults
 	DefaultSsmHealthFrequencyMinutes    = 5
 	DefaultSsmHealthFrequencyMinutesMin = 5
@@ -40,6 +60,14 @@ const (
 	DefaultSsmAssociationFrequencyMinutesMin = 5
 	DefaultSsmAssociationFrequencyMinutesMax = 60
 
+	DefaultAssociationWorkersLimit    = 1
+	DefaultAssociationWorkersLimitMin = 1
+	DefaultAssociationWorkersLimitMax = 10
+
+	DefaultAssociationSplaySeconds    = 0
+	DefaultAssociationSplaySecondsMin = 0
+	DefaultAssociationSplaySecondsMax = 3600
+
 	//aws-ssm-agent bookkeeping constants
 	DefaultLocationOfPending     = "pending"
 	DefaultLocationOfCurrent     = "current"
@@ -47,6 +75,7 @@ const (
 	DefaultLocationOfCorrupt     = "corrupt"
 	DefaultLocationOfState       = "state"
 	DefaultLocationOfAssociation = "association"
+	DefaultLocationOfQueue       = "queue"
 
 	//aws-ssm-agent state and orchestration logs duration for Run Command and Association
 	DefaultAssociationLogsRetentionDurationHours           = 24  // 1 day default retention
@@ -67,6 +96,9 @@ const (
 	FileInventoryRootDirName     = "file"
 	RoleInventoryRootDirName     = "role"
 	InventoryContentHashFileName = "contentHash"
+	InventorySnapshotDirName     = "snapshot"
+	InventoryChangeLogFileName   = "changes.jsonl"
+	InventoryChangeLogMaxBytes   = 5 * 1024 * 1024
 
 	//aws-ssm-agent bookkeeping constants for failed sent replies
 	RepliesRootDirName = "replies"
@@ -74,6 +106,22 @@ const (
 	//aws-ssm-agent bookkeeping constants for compliance
 	ComplianceRootDirName         = "compliance"
 	ComplianceContentHashFileName = "contentHash"
+	ComplianceQueuedFileName      = "queuedCompliance.json"
+
+	//aws-ssm-agent bookkeeping constants for associations kept for offline operation
+	AssociationRootDirName               = "association"
+	AssociationScheduleFileName          = "schedules.json"
+	AssociationPendingStatusFileName     = "pendingStatus.json"
+	AssociationPendingStatusMaxQueueSize = 100
+	AssociationRunHistoryDirName         = "runhistory"
+	AssociationRerunDirName              = "rerun"
+	AssociationRunHistoryLength          = 20
+	AssociationRunOutputExcerptLength    = 1000
+
+	//aws-ssm-agent bookkeeping constants for the local execution history
+	HistoryRootDirName  = "history"
+	HistoryFileName     = "executions.jsonl"
+	HistoryMaxFileBytes = 10 * 1024 * 1024
 
 	// DefaultDocumentRootDirName is the root directory for storing command states
 	DefaultDocumentRootDirName = "document"
@@ -156,6 +204,9 @@ const (
 	// PluginNameAwsApplications is the name of the Applications plugin
 	PluginNameAwsApplications = "aws:applications"
 
+	// PluginNameAwsCheckDesiredState is the name of the desired state checks plugin
+	PluginNameAwsCheckDesiredState = "aws:checkDesiredState"
+
 	AppConfigFileName    = "amazon-ssm-agent.json"
 	SeelogConfigFileName = "seelog.xml"
 
@@ -107,9 +107,9 @@ func init() {
 	/*
 	   Powershell command used to be poweshell in alpha versions, now it's pwsh in prod versions
 	*/
-	PowerShellPluginCommandName = "/usr/bin/powershell"
-	if _, err := os.Stat(PowerShellPluginCommandName); err != nil {
-		PowerShellPluginCommandName = "/usr/bin/pwsh"
+	PowerShellPluginCommandName = "/usr/bin/pwsh"
+	if path, err := FindPowerShell(nil); err == nil {
+		PowerShellPluginCommandName = path
 	}
 
 	// Find current directory path for amazon-ssm-agent, DefaultDocumentWorker should exist in same directory
@@ -36,6 +36,13 @@ type SsmCfg struct {
 	HealthFrequencyMinutes      int
 	AssociationFrequencyMinutes int
 	AssociationRetryLimit       int
+	// AssociationWorkersLimit is the number of associations that can run at the same time
+	AssociationWorkersLimit int
+	// AssociationSplayWindowSeconds caps the per instance delay of scheduled association runs
+	AssociationSplayWindowSeconds int
+	// AssociationJitterSeconds caps the delay added to each scheduled association run, it varies from run to run
+	// and stays below the time to the following run of the association
+	AssociationJitterSeconds int
 	// TODO: test hook, can be removed before release
 	// this is to skip ssl verification for the beta self signed certs
 	InsecureSkipVerify                    bool
@@ -43,6 +50,11 @@ type SsmCfg struct {
 	AssociationLogsRetentionDurationHours int
 	RunCommandLogsRetentionDurationHours  int
 	SessionLogsRetentionDurationHours     int
+	// PowerShellSearchPaths are the directories or executables tried first when looking for PowerShell on Linux
+	PowerShellSearchPaths []string
+	// LocalAssociationsDirectory is the directory with association definitions applied without the service,
+	// on Linux it and its files must be owned by root and not writable by group or others
+	LocalAssociationsDirectory string
 }
 
 // AgentInfo represents metadata for amazon-ssm-agent
@@ -82,6 +94,41 @@ type S3Cfg struct {
 	LogKey    string
 }
 
+// OutputCfg represents configuration for the command output destinations that don't depend on AWS services
+type OutputCfg struct {
+	LocalArchiveDirectory    string
+	MaxLocalArchiveDocuments int
+	HttpEndpoint             string
+	HttpHeaders              map[string]string
+	HttpMaxRetries           int
+}
+
+// InventoryCfg represents configuration for exporting collected inventory to the local file system
+type InventoryCfg struct {
+	// ExportDirectory receives a folder per collection holding a file per inventory type
+	ExportDirectory      string
+	ExportFormat         string
+	MaxExportedSnapshots int
+	// SkipUpload keeps the collected inventory local instead of sending it to Systems Manager
+	SkipUpload bool
+	// CustomCollectorDirectory holds executable scripts whose JSON output is collected as custom inventory,
+	// collector scripts are not run when it is empty
+	CustomCollectorDirectory      string
+	CustomCollectorTimeoutSeconds int
+	// CustomCollectorUser is the unprivileged user collector scripts run as
+	CustomCollectorUser string
+}
+
+// ScriptSigningCfg represents the policy requiring scripts to carry a detached signature from a trusted key before they run
+type ScriptSigningCfg struct {
+	RequireSignature bool
+	// TrustedEd25519Keys are base64 encoded Ed25519 public keys
+	TrustedEd25519Keys []string
+	// TrustedCertificates are paths of PEM files with the certificates trusted to sign PKCS7 signatures, the signer
+	// certificate must allow code signing
+	TrustedCertificates []string
+}
+
 // BirdwatcherCfg represents configuration related to ConfigurePackage Birdwatcher integration
 type BirdwatcherCfg struct {
 	ForceEnable bool
@@ -89,15 +136,43 @@ type BirdwatcherCfg struct {
 
 // SsmagentConfig stores agent configuration values.
 type SsmagentConfig struct {
-	Profile     CredentialProfile
-	Mds         MdsCfg
-	Ssm         SsmCfg
-	Mgs         MgsConfig
-	Agent       AgentInfo
-	Os          OsInfo
-	S3          S3Cfg
-	Birdwatcher BirdwatcherCfg
-	Kms         KmsConfig
+	Profile       CredentialProfile
+	Mds           MdsCfg
+	Ssm           SsmCfg
+	Mgs           MgsConfig
+	Agent         AgentInfo
+	Os            OsInfo
+	S3            S3Cfg
+	Birdwatcher   BirdwatcherCfg
+	Kms           KmsConfig
+	Output        OutputCfg
+	ScriptSigning ScriptSigningCfg
+	Inventory     InventoryCfg
+	Maintenance   MaintenanceCfg
+}
+
+// MaintenanceCfg represents when associations are allowed to run
+type MaintenanceCfg struct {
+	// Windows are the periods associations may run in, they may run at any time when there is none
+	Windows []TimeWindowCfg
+	// Blackouts are the periods associations must not run in, they take precedence over the windows
+	Blackouts []TimeWindowCfg
+	// ExemptDocuments are the names of the documents that don't change the instance and may run at any time
+	ExemptDocuments []string
+}
+
+// TimeWindowCfg represents a recurring or a one-off period of time
+type TimeWindowCfg struct {
+	Name string
+	// Cron is the cron expression of the start of a recurring window, e.g. cron(0 22 ? * MON-FRI *)
+	Cron string
+	// DurationMinutes is the duration of each occurrence of a recurring window
+	DurationMinutes int
+	// TimeZone is the IANA time zone the cron expression is evaluated in, UTC when empty
+	TimeZone string
+	// Start and End are the RFC 3339 bounds of a one-off window
+	Start string
+	End   string
 }
 
 // AppConstants represents some run time constant variable for various module.
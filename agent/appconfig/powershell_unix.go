@@ -0,0 +1,84 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package appconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// PowerShellCommandNames are the names of the PowerShell executable in order of preference,
+// PowerShell Core installs pwsh while the alpha releases installed powershell
+var PowerShellCommandNames = []string{"pwsh", "powershell"}
+
+// DefaultPowerShellInstallPrefixes are the directories searched for PowerShell after the PATH
+var DefaultPowerShellInstallPrefixes = []string{
+	"/usr/bin",
+	"/usr/local/bin",
+	"/opt/microsoft/powershell/7",
+	"/opt/microsoft/powershell/6",
+	"/snap/bin",
+}
+
+// FindPowerShell returns the path of the PowerShell executable. The search paths, which can be
+// either directories or executables, are tried first, then the PATH and the default install prefixes.
+func FindPowerShell(searchPaths []string) (string, error) {
+	candidates := []string{}
+	for _, searchPath := range searchPaths {
+		if searchPath == "" {
+			continue
+		}
+		if info, err := os.Stat(searchPath); err == nil && !info.IsDir() {
+			candidates = append(candidates, searchPath)
+			continue
+		}
+		for _, name := range PowerShellCommandNames {
+			candidates = append(candidates, filepath.Join(searchPath, name))
+		}
+	}
+	dirs := append(filepath.SplitList(os.Getenv("PATH")), DefaultPowerShellInstallPrefixes...)
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		for _, name := range PowerShellCommandNames {
+			candidates = append(candidates, filepath.Join(dir, name))
+		}
+	}
+	for _, candidate := range candidates {
+		if isExecutable(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("PowerShell was not found in %v, PATH or %v", searchPaths, DefaultPowerShellInstallPrefixes)
+}
+
+// IsPowerShell returns true if the path refers to a PowerShell executable
+func IsPowerShell(path string) bool {
+	name := filepath.Base(path)
+	for _, candidate := range PowerShellCommandNames {
+		if name == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && info.Mode()&0111 != 0
+}
@@ -0,0 +1,216 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package driftdetector evaluates the desired state checks of associations between their scheduled executions
+// and reports the drifted checks as configuration drift compliance.
+package driftdetector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/schedulemanager"
+	"github.com/aws/amazon-ssm-agent/agent/compliance/drift"
+	complianceModel "github.com/aws/amazon-ssm-agent/agent/compliance/model"
+	complianceUploader "github.com/aws/amazon-ssm-agent/agent/compliance/uploader"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// detection is the periodic evaluation of the checks of an association
+type detection struct {
+	ticker *time.Ticker
+	stop   chan struct{}
+}
+
+type DriftDetector struct {
+	detections map[string]*detection
+	mutex      sync.Mutex
+}
+
+var driftDetector *DriftDetector
+var once sync.Once
+
+// associationExists is replaced in tests
+var associationExists = schedulemanager.AssociationExists
+
+func init() {
+	once.Do(func() {
+		driftDetector = &DriftDetector{detections: map[string]*detection{}}
+	})
+}
+
+// GetDriftDetector returns a singleton instance of DriftDetector
+func GetDriftDetector() *DriftDetector {
+	return driftDetector
+}
+
+//IsDriftDetectionAssociation returns true if the association declares desired state checks
+func (detector *DriftDetector) IsDriftDetectionAssociation(docState *contracts.DocumentState) bool {
+	for _, plugin := range docState.InstancePluginsInformation {
+		if plugin.Name == appconfig.PluginNameAwsCheckDesiredState {
+			return true
+		}
+	}
+	return false
+}
+
+//StartDriftDetector evaluates the checks of the association now, and then periodically until the association is removed
+func (detector *DriftDetector) StartDriftDetector(context context.T, docState *contracts.DocumentState, uploader complianceUploader.T) {
+	log := context.Log()
+	associationID := docState.DocumentInformation.AssociationID
+	instanceID := docState.DocumentInformation.InstanceID
+
+	checks, interval, err := getDesiredStateChecks(docState)
+	if err != nil {
+		log.Errorf("drift detector is not started for association %v, %v", associationID, err)
+		detector.StopDriftDetector(associationID)
+		return
+	}
+
+	log.Infof("start drift detector for association %v, interval: %v", associationID, interval)
+	current := detector.resetDetection(associationID, interval)
+
+	go detector.run(log, current, associationID, instanceID, checks, uploader)
+}
+
+//run evaluates the checks now and on every tick until the detection is stopped or the association is removed
+func (detector *DriftDetector) run(log log.T, current *detection, associationID string, instanceID string, checks []drift.Check, uploader complianceUploader.T) {
+	defer func() {
+		if msg := recover(); msg != nil {
+			log.Errorf("something is wrong in DriftDetector %v", msg)
+		}
+	}()
+
+	detector.detect(log, associationID, instanceID, checks, uploader)
+	for {
+		select {
+		case <-current.stop:
+			return
+		case <-current.ticker.C:
+			if !associationExists(associationID) {
+				log.Infof("stop drift detector, association %v was removed", associationID)
+				detector.StopDriftDetector(associationID)
+				return
+			}
+			detector.detect(log, associationID, instanceID, checks, uploader)
+		}
+	}
+}
+
+//IsDriftDetectorStarted returns true if the checks of the association are evaluated periodically
+func (detector *DriftDetector) IsDriftDetectorStarted(associationID string) bool {
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+
+	_, exists := detector.detections[associationID]
+	return exists
+}
+
+//StopDriftDetector stops the periodic evaluation of the checks of the association
+func (detector *DriftDetector) StopDriftDetector(associationID string) {
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+
+	detector.stopDetection(associationID)
+}
+
+//ClearDriftDetectors stops the periodic evaluation of the checks of all associations
+func (detector *DriftDetector) ClearDriftDetectors() {
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+
+	for associationID := range detector.detections {
+		detector.stopDetection(associationID)
+	}
+}
+
+//resetDetection replaces the detection of the association with a new one
+func (detector *DriftDetector) resetDetection(associationID string, interval time.Duration) *detection {
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+
+	detector.stopDetection(associationID)
+	current := &detection{ticker: time.NewTicker(interval), stop: make(chan struct{})}
+	detector.detections[associationID] = current
+	return current
+}
+
+//stopDetection stops the detection of the association, the caller holds the mutex
+func (detector *DriftDetector) stopDetection(associationID string) {
+	if current, exists := detector.detections[associationID]; exists {
+		current.ticker.Stop()
+		close(current.stop)
+		delete(detector.detections, associationID)
+	}
+}
+
+//detect evaluates the checks and uploads the drifted ones
+func (detector *DriftDetector) detect(log log.T, associationID string, instanceID string, checks []drift.Check, uploader complianceUploader.T) {
+	items := []*complianceModel.DriftComplianceItem{}
+	for _, result := range drift.Evaluate(log, checks) {
+		if !result.Drifted {
+			continue
+		}
+		items = append(items, &complianceModel.DriftComplianceItem{
+			AssociationId:      associationID,
+			CheckId:            result.Check.ID,
+			CheckType:          result.Check.Type,
+			Target:             result.Check.Target,
+			Expected:           result.Check.Expected,
+			Actual:             result.Actual,
+			Message:            result.Message,
+			ComplianceSeverity: result.Check.Severity,
+		})
+	}
+
+	log.Infof("Drift detector found %v of %v checks drifted for association %v", len(items), len(checks), associationID)
+	if err := uploader.UpdateDriftCompliance(associationID, instanceID, items, time.Now().UTC()); err != nil {
+		log.Errorf("Unable to upload drift compliance of association %v, %v", associationID, err)
+	}
+}
+
+//getDesiredStateChecks returns the checks of all desired state plugins of the document and the shortest interval
+func getDesiredStateChecks(docState *contracts.DocumentState) (checks []drift.Check, interval time.Duration, err error) {
+	ids := map[string]bool{}
+	for _, plugin := range docState.InstancePluginsInformation {
+		if plugin.Name != appconfig.PluginNameAwsCheckDesiredState {
+			continue
+		}
+
+		input, err := drift.ParseInput(plugin.Configuration.Properties)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, check := range input.Checks {
+			if ids[check.ID] {
+				return nil, 0, fmt.Errorf("check id %v is not unique", check.ID)
+			}
+			ids[check.ID] = true
+			checks = append(checks, check)
+		}
+
+		pluginInterval := time.Duration(input.EvaluationIntervalMinutes) * time.Minute
+		if interval == 0 || pluginInterval < interval {
+			interval = pluginInterval
+		}
+	}
+
+	if len(checks) == 0 {
+		return nil, 0, fmt.Errorf("no desired state check is declared")
+	}
+	return checks, interval, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package driftdetector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	complianceModel "github.com/aws/amazon-ssm-agent/agent/compliance/model"
+	complianceUploader "github.com/aws/amazon-ssm-agent/agent/compliance/uploader"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func desiredStatePlugin(interval int, checks ...map[string]interface{}) contracts.PluginState {
+	var checkList []interface{}
+	for _, check := range checks {
+		checkList = append(checkList, check)
+	}
+	properties := map[string]interface{}{"checks": checkList}
+	if interval != 0 {
+		properties["evaluationIntervalMinutes"] = interval
+	}
+	return contracts.PluginState{
+		Name:          appconfig.PluginNameAwsCheckDesiredState,
+		Configuration: contracts.Configuration{Properties: properties},
+	}
+}
+
+func missingFileCheck(id string) map[string]interface{} {
+	return map[string]interface{}{"id": id, "type": "fileHash", "target": "/missing/" + id, "expected": "abc", "severity": "Critical"}
+}
+
+func TestIsDriftDetectionAssociation(t *testing.T) {
+	docState := &contracts.DocumentState{
+		InstancePluginsInformation: []contracts.PluginState{{Name: appconfig.PluginNameAwsRunShellScript}},
+	}
+	assert.False(t, GetDriftDetector().IsDriftDetectionAssociation(docState))
+
+	docState.InstancePluginsInformation = append(docState.InstancePluginsInformation, desiredStatePlugin(0, missingFileCheck("motd")))
+	assert.True(t, GetDriftDetector().IsDriftDetectionAssociation(docState))
+}
+
+func TestGetDesiredStateChecksMergesPlugins(t *testing.T) {
+	docState := &contracts.DocumentState{
+		InstancePluginsInformation: []contracts.PluginState{
+			desiredStatePlugin(60, missingFileCheck("motd")),
+			desiredStatePlugin(15, missingFileCheck("issue")),
+		},
+	}
+
+	checks, interval, err := getDesiredStateChecks(docState)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(checks))
+	assert.Equal(t, 15*time.Minute, interval)
+
+	docState.InstancePluginsInformation = append(docState.InstancePluginsInformation, desiredStatePlugin(0, missingFileCheck("motd")))
+	_, _, err = getDesiredStateChecks(docState)
+	assert.Error(t, err)
+}
+
+func TestStartDriftDetectorUploadsDriftedChecks(t *testing.T) {
+	uploader := complianceUploader.NewMockDefault()
+	uploaded := make(chan []*complianceModel.DriftComplianceItem, 1)
+	uploader.On("UpdateDriftCompliance", "association_1", "i-123", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		uploaded <- args.Get(2).([]*complianceModel.DriftComplianceItem)
+	})
+	docState := &contracts.DocumentState{
+		DocumentInformation:        contracts.DocumentInfo{AssociationID: "association_1", InstanceID: "i-123"},
+		InstancePluginsInformation: []contracts.PluginState{desiredStatePlugin(0, missingFileCheck("motd"))},
+	}
+
+	detector := GetDriftDetector()
+	detector.StartDriftDetector(context.NewMockDefault(), docState, uploader)
+	defer detector.ClearDriftDetectors()
+
+	select {
+	case items := <-uploaded:
+		assert.Equal(t, 1, len(items))
+		assert.Equal(t, "motd", items[0].CheckId)
+		assert.Equal(t, "CRITICAL", items[0].ComplianceSeverity)
+		assert.Equal(t, "absent", items[0].Actual)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "drifted checks were not uploaded")
+	}
+	assert.Equal(t, 1, len(detector.detections))
+}
+
+func TestDriftDetectorStopsWhenAssociationIsRemoved(t *testing.T) {
+	defer func(original func(string) bool) { associationExists = original }(associationExists)
+	associationExists = func(associationID string) bool { return false }
+	uploader := complianceUploader.NewMockDefault()
+	uploader.On("UpdateDriftCompliance", "association_2", "i-123", mock.Anything, mock.Anything).Return(nil)
+
+	detector := &DriftDetector{detections: map[string]*detection{}}
+	current := detector.resetDetection("association_2", time.Millisecond)
+
+	// run returns on the first tick as the association doesn't exist anymore
+	detector.run(log.NewMockLog(), current, "association_2", "i-123", nil, uploader)
+
+	assert.Equal(t, 0, len(detector.detections))
+	uploader.AssertNumberOfCalls(t, "UpdateDriftCompliance", 1)
+}
+
+func TestClearDriftDetectors(t *testing.T) {
+	detector := &DriftDetector{detections: map[string]*detection{}}
+	first := detector.resetDetection("association_1", time.Hour)
+	detector.resetDetection("association_2", time.Hour)
+
+	// restarting the detection of an association stops the previous one
+	detector.resetDetection("association_1", time.Hour)
+	_, open := <-first.stop
+	assert.False(t, open)
+	assert.Equal(t, 2, len(detector.detections))
+	assert.True(t, detector.IsDriftDetectorStarted("association_1"))
+
+	detector.ClearDriftDetectors()
+	assert.Equal(t, 0, len(detector.detections))
+	assert.False(t, detector.IsDriftDetectorStarted("association_1"))
+}
+
+func TestDetectUploadsEmptyListWhenNoCheckDrifted(t *testing.T) {
+	uploader := complianceUploader.NewMockDefault()
+	uploader.On("UpdateDriftCompliance", "association_1", "i-123", []*complianceModel.DriftComplianceItem{}, mock.Anything).Return(nil)
+
+	GetDriftDetector().detect(log.NewMockLog(), "association_1", "i-123", nil, uploader)
+
+	uploader.AssertExpectations(t)
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/awscomponent"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/file"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/instancedetailedinformation"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/localuser"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/network"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/registry"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/role"
@@ -310,8 +311,9 @@ func (collector *FrequentCollector) getValidInventoryGathererConfigMap(context c
 //getSupportedGathererNames returns a map of gatherer names, using the all lower case as key, the normal gatherer name as value. This is to allow customer to input gatherer name ignoring case.
 func (collector *FrequentCollector) getSupportedGathererNames() map[string]string {
 	var gathererNameMap = make(map[string]string)
-	//only "AWS:Applications" is supported for now
+	//only "AWS:Applications" and "Custom:LocalUser" are supported for now
 	gathererNameMap[strings.ToLower(application.GathererName)] = application.GathererName
+	gathererNameMap[strings.ToLower(localuser.GathererName)] = localuser.GathererName
 	return gathererNameMap
 }
 
@@ -327,5 +329,6 @@ func (collector *FrequentCollector) getGathererParameterMap() map[string]string
 	paramGathererMap[strings.ToLower(registry.GathererName)] = "windowsRegistry"
 	paramGathererMap[strings.ToLower(role.GathererName)] = "windowsRoles"
 	paramGathererMap[strings.ToLower(instancedetailedinformation.GathererName)] = "instanceDetailedInformation"
+	paramGathererMap[strings.ToLower(localuser.GathererName)] = "localUsers"
 	return paramGathererMap
 }
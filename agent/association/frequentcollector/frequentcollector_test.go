@@ -50,6 +50,27 @@ func TestIsFrequentCollectorEnabled_True(t *testing.T) {
 	assert.Equal(t, result, true, "frequent collector enabled")
 }
 
+func TestIsFrequentCollectorEnabled_True_LocalUsers(t *testing.T) {
+	properties := make(map[string]interface{})
+	properties["localUsers"] = "Enabled"
+	properties["changeDetectionFrequency"] = "2"
+
+	strTypes := []string{"Custom:LocalUser"}
+	types := make([]interface{}, len(strTypes))
+	for i, s := range strTypes {
+		types[i] = s
+	}
+	properties["changeDetectionTypes"] = types
+
+	docState := buildInventoryDocumentState(properties)
+	assocRawData := buildRatedInstanceAssociation(30)
+
+	frequentCollector := frequentcollector.GetFrequentCollector()
+	result := frequentCollector.IsFrequentCollectorEnabled(&docState, &assocRawData)
+
+	assert.Equal(t, result, true, "frequent collector enabled for local users")
+}
+
 func TestIsFrequentCollectorEnabled_False_AbsenceOfDetectionTypes(t *testing.T) {
 	properties := make(map[string]interface{})
 	properties["applications"] = "Enabled"
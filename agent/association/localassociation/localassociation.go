@@ -0,0 +1,324 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package localassociation loads associations defined in a directory on the instance,
+// so that a baseline can be applied without the associations being created in the service.
+package localassociation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/association/model"
+	"github.com/aws/amazon-ssm-agent/agent/association/scheduleexpression"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/go-yaml/yaml"
+)
+
+const (
+	// DocumentVersion is the document version reported for local associations
+	DocumentVersion = "local"
+
+	jsonExtension = ".json"
+	yamlExtension = ".yaml"
+	ymlExtension  = ".yml"
+)
+
+var invalidIDCharacters = regexp.MustCompile(`[^a-zA-Z0-9_.\-]`)
+
+// definition is the content of a local association file, in JSON or YAML
+type definition struct {
+	// Name is the name of the document, defaults to the file name
+	Name string `json:"name"`
+	// DocumentPath is the path of the document file, relative paths are resolved against the directory
+	DocumentPath string `json:"documentPath"`
+	// Content is the document itself, used when DocumentPath is not set
+	Content interface{} `json:"content"`
+	// Parameters are the document parameters, each value is a string or a list of strings
+	Parameters map[string]interface{} `json:"parameters"`
+	// Schedule is a cron or rate expression, the association runs once when it is empty
+	Schedule string `json:"schedule"`
+}
+
+// Load returns the associations defined by the JSON and YAML files in the given directory.
+// Files referenced as document by another definition are not definitions themselves.
+// Invalid definitions are logged and skipped so they don't prevent the other ones from running.
+// The directory, definitions and documents are run as root, so they must be owned by root and not writable by others.
+func Load(log log.T, dir string, instanceID string) (assocs []*model.InstanceAssociation, err error) {
+	if err = checkOwnership(dir); err != nil {
+		return nil, fmt.Errorf("refusing local associations directory %v, %v", dir, err)
+	}
+
+	var files []string
+	if files, err = definitionFiles(dir); err != nil {
+		return nil, fmt.Errorf("failed to read local associations directory %v, %v", dir, err)
+	}
+
+	definitions := make(map[string]*definition)
+	referenced := make(map[string]bool)
+	for _, file := range files {
+		var def *definition
+		if err = checkOwnership(file); err != nil {
+			log.Errorf("Skipping local association %v, %v", file, err)
+			continue
+		}
+		if def, err = readDefinition(file); err != nil {
+			log.Errorf("Skipping local association %v, %v", file, err)
+			continue
+		}
+		definitions[file] = def
+		if def.DocumentPath != "" {
+			referenced[resolvePath(dir, def.DocumentPath)] = true
+		}
+	}
+
+	ids := make(map[string]string)
+	for _, file := range files {
+		def, ok := definitions[file]
+		if !ok || referenced[file] {
+			continue
+		}
+
+		var assoc *model.InstanceAssociation
+		if assoc, err = newInstanceAssociation(log, dir, file, def, instanceID); err != nil {
+			log.Errorf("Skipping local association %v, %v", file, err)
+			continue
+		}
+
+		id := *assoc.Association.AssociationId
+		if other, exists := ids[id]; exists {
+			log.Errorf("Skipping local association %v, association ID %v is already used by %v", file, id, other)
+			continue
+		}
+		ids[id] = file
+		assocs = append(assocs, assoc)
+	}
+
+	log.Debugf("Loaded %v local associations from %v", len(assocs), dir)
+	return assocs, nil
+}
+
+// IsDefinitionFile returns true if the file has the extension of a local association definition
+func IsDefinitionFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case jsonExtension, yamlExtension, ymlExtension:
+		return true
+	}
+	return false
+}
+
+// definitionFiles returns the sorted paths of the definition files in the directory
+func definitionFiles(dir string) (files []string, err error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range infos {
+		if info.IsDir() || !IsDefinitionFile(info.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, info.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readDefinition parses a definition file
+func readDefinition(file string) (def *definition, err error) {
+	var content []byte
+	if content, err = ioutil.ReadFile(file); err != nil {
+		return nil, err
+	}
+	if content, err = toJSON(content); err != nil {
+		return nil, fmt.Errorf("definition is neither valid JSON nor YAML, %v", err)
+	}
+
+	def = &definition{}
+	if err = json.Unmarshal(content, def); err != nil {
+		return nil, fmt.Errorf("invalid definition, %v", err)
+	}
+	return def, nil
+}
+
+// newInstanceAssociation builds the association of a definition
+func newInstanceAssociation(log log.T, dir string, file string, def *definition, instanceID string) (*model.InstanceAssociation, error) {
+	document, err := loadDocument(dir, def)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters, err := convertParameters(def.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	if def.Schedule != "" {
+		if _, err = scheduleexpression.CreateScheduleExpression(log, def.Schedule); err != nil {
+			return nil, fmt.Errorf("invalid schedule %v, %v", def.Schedule, err)
+		}
+	}
+
+	base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	name := def.Name
+	if name == "" {
+		name = base
+	}
+
+	// the checksum covers everything that changes what the association runs
+	hash := sha256.New()
+	hash.Write([]byte(name))
+	hash.Write([]byte(document))
+	if parametersContent, err := json.Marshal(parameters); err == nil {
+		hash.Write(parametersContent)
+	}
+	hash.Write([]byte(def.Schedule))
+
+	assoc := &model.InstanceAssociation{
+		CreateDate: time.Now(),
+		Association: &ssm.InstanceAssociationSummary{
+			AssociationId:   aws.String(model.LocalAssociationIDPrefix + invalidIDCharacters.ReplaceAllString(base, "_")),
+			Name:            aws.String(name),
+			InstanceId:      aws.String(instanceID),
+			DocumentVersion: aws.String(DocumentVersion),
+			Checksum:        aws.String(hex.EncodeToString(hash.Sum(nil))),
+			DetailedStatus:  aws.String(contracts.AssociationStatusAssociated),
+			Parameters:      parameters,
+		},
+		Document: aws.String(document),
+	}
+	if def.Schedule != "" {
+		assoc.Association.ScheduleExpression = aws.String(def.Schedule)
+	}
+	return assoc, nil
+}
+
+// loadDocument returns the JSON content of the document of a definition
+func loadDocument(dir string, def *definition) (document string, err error) {
+	var content []byte
+	switch {
+	case def.DocumentPath != "" && def.Content != nil:
+		return "", fmt.Errorf("only one of documentPath and content can be set")
+	case def.DocumentPath != "":
+		path := resolvePath(dir, def.DocumentPath)
+		// a document outside the directory could be replaced through its own directory
+		for _, p := range []string{filepath.Dir(path), path} {
+			if err = checkOwnership(p); err != nil {
+				return "", fmt.Errorf("refusing document, %v", err)
+			}
+		}
+		if content, err = ioutil.ReadFile(path); err != nil {
+			return "", fmt.Errorf("failed to read document, %v", err)
+		}
+	case def.Content != nil:
+		if inline, ok := def.Content.(string); ok {
+			content = []byte(inline)
+		} else if content, err = json.Marshal(def.Content); err != nil {
+			return "", fmt.Errorf("invalid document content, %v", err)
+		}
+	default:
+		return "", fmt.Errorf("either documentPath or content must be set")
+	}
+
+	if content, err = toJSON(content); err != nil {
+		return "", fmt.Errorf("document is neither valid JSON nor YAML, %v", err)
+	}
+	return string(content), nil
+}
+
+// convertParameters converts the parameter values to the format of the service
+func convertParameters(parameters map[string]interface{}) (map[string][]*string, error) {
+	converted := make(map[string][]*string)
+	for name, value := range parameters {
+		switch value := value.(type) {
+		case []interface{}:
+			values := []*string{}
+			for _, item := range value {
+				if !isScalar(item) {
+					return nil, fmt.Errorf("parameter %v must be a string or a list of strings", name)
+				}
+				values = append(values, aws.String(fmt.Sprint(item)))
+			}
+			converted[name] = values
+		default:
+			if !isScalar(value) {
+				return nil, fmt.Errorf("parameter %v must be a string or a list of strings", name)
+			}
+			converted[name] = []*string{aws.String(fmt.Sprint(value))}
+		}
+	}
+	return converted, nil
+}
+
+// isScalar returns true for the values that can be used as string parameter
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, bool, float64:
+		return true
+	}
+	return false
+}
+
+// resolvePath resolves a path relative to the directory
+func resolvePath(dir string, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(dir, path)
+}
+
+// toJSON returns the content as JSON, converting it if it is YAML
+func toJSON(content []byte) ([]byte, error) {
+	if json.Valid(content) {
+		return content, nil
+	}
+
+	var value interface{}
+	if err := yaml.Unmarshal(content, &value); err != nil {
+		return nil, err
+	}
+	if _, ok := value.(map[interface{}]interface{}); !ok {
+		return nil, fmt.Errorf("content is not an object")
+	}
+	return json.Marshal(jsonCompatible(value))
+}
+
+// jsonCompatible converts the maps produced by the YAML parser to maps with string keys
+func jsonCompatible(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{})
+		for k, v := range value {
+			out[fmt.Sprint(k)] = jsonCompatible(v)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, v := range value {
+			out[i] = jsonCompatible(v)
+		}
+		return out
+	}
+	return value
+}
@@ -0,0 +1,142 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package localassociation
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+const testDocument = `{
+  "schemaVersion": "2.2",
+  "parameters": {"commands": {"type": "StringList"}},
+  "mainSteps": [{"action": "aws:runShellScript", "name": "run", "inputs": {"runCommand": "{{ commands }}"}}]
+}`
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "localassociation")
+	assert.NoError(t, err)
+	for name, content := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+	return dir
+}
+
+func TestLoadJSONDefinitionWithDocumentPath(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"baseline.json":          `{"name": "Baseline", "documentPath": "baseline-document.json", "parameters": {"commands": ["echo 1", "echo 2"]}, "schedule": "rate(30 minutes)"}`,
+		"baseline-document.json": testDocument,
+		"readme.txt":             "not a definition",
+	})
+	defer os.RemoveAll(dir)
+
+	assocs, err := Load(log.NewMockLog(), dir, "i-123")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(assocs), "the referenced document is not a definition")
+
+	assoc := assocs[0].Association
+	assert.Equal(t, "local-baseline", *assoc.AssociationId)
+	assert.Equal(t, "Baseline", *assoc.Name)
+	assert.Equal(t, "i-123", *assoc.InstanceId)
+	assert.Equal(t, DocumentVersion, *assoc.DocumentVersion)
+	assert.Equal(t, contracts.AssociationStatusAssociated, *assoc.DetailedStatus)
+	assert.Equal(t, "rate(30 minutes)", *assoc.ScheduleExpression)
+	assert.Equal(t, 2, len(assoc.Parameters["commands"]))
+	assert.Equal(t, "echo 2", *assoc.Parameters["commands"][1])
+	assert.True(t, assocs[0].IsLocalAssociation())
+	assert.JSONEq(t, testDocument, *assocs[0].Document)
+}
+
+func TestLoadYAMLDefinitionWithInlineContent(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"ntp config.yaml": `
+content:
+  schemaVersion: "2.2"
+  mainSteps:
+    - action: aws:runShellScript
+      name: run
+      inputs:
+        runCommand:
+          - "{{ commands }}"
+parameters:
+  commands: systemctl restart chronyd
+  retries: 3
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	assocs, err := Load(log.NewMockLog(), dir, "i-123")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(assocs))
+
+	assoc := assocs[0].Association
+	assert.Equal(t, "local-ntp_config", *assoc.AssociationId)
+	assert.Equal(t, "ntp config", *assoc.Name, "name defaults to the file name")
+	assert.Nil(t, assoc.ScheduleExpression, "association without schedule runs once")
+	assert.Equal(t, "systemctl restart chronyd", *assoc.Parameters["commands"][0])
+	assert.Equal(t, "3", *assoc.Parameters["retries"][0])
+
+	var document map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(*assocs[0].Document), &document))
+	assert.Equal(t, "2.2", document["schemaVersion"])
+}
+
+func TestLoadSkipsInvalidDefinitions(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a-valid.json":          `{"content": {"schemaVersion": "2.2"}}`,
+		"bad-schedule.json":     `{"content": {"schemaVersion": "2.2"}, "schedule": "every day"}`,
+		"missing-document.yml":  `documentPath: missing.json`,
+		"no-document.json":      `{"name": "Baseline"}`,
+		"nested-parameter.json": `{"content": {"schemaVersion": "2.2"}, "parameters": {"p": {"a": "b"}}}`,
+		"not-parsable.yaml":     `: [`,
+		"a-valid.yaml":          `content: {schemaVersion: "2.2"}`,
+	})
+	defer os.RemoveAll(dir)
+
+	assocs, err := Load(log.NewMockLog(), dir, "i-123")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(assocs), "invalid definitions and duplicated IDs are skipped")
+	assert.Equal(t, "local-a-valid", *assocs[0].Association.AssociationId)
+}
+
+func TestLoadChecksumChangesWithDefinition(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"baseline.json": `{"content": {"schemaVersion": "2.2"}, "schedule": "rate(30 minutes)"}`,
+	})
+	defer os.RemoveAll(dir)
+
+	first, err := Load(log.NewMockLog(), dir, "i-123")
+	assert.NoError(t, err)
+	second, err := Load(log.NewMockLog(), dir, "i-123")
+	assert.NoError(t, err)
+	assert.Equal(t, *first[0].Association.Checksum, *second[0].Association.Checksum)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "baseline.json"),
+		[]byte(`{"content": {"schemaVersion": "2.2"}, "schedule": "rate(60 minutes)"}`), 0600))
+	changed, err := Load(log.NewMockLog(), dir, "i-123")
+	assert.NoError(t, err)
+	assert.NotEqual(t, *first[0].Association.Checksum, *changed[0].Association.Checksum)
+}
+
+func TestLoadMissingDirectory(t *testing.T) {
+	_, err := Load(log.NewMockLog(), filepath.Join(os.TempDir(), "localassociation-missing"), "i-123")
+	assert.Error(t, err)
+}
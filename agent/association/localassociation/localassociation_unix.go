@@ -0,0 +1,40 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package localassociation
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// trustedUID is the owner required for local association files, they are run as root
+var trustedUID uint32 = 0
+
+// checkOwnership returns an error if the path is not owned by root or can be written by group or others
+func checkOwnership(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if stat, ok := fi.Sys().(*syscall.Stat_t); !ok || stat.Uid != trustedUID {
+		return fmt.Errorf("%v is not owned by root", path)
+	}
+	if fi.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%v is writable by group or others", path)
+	}
+	return nil
+}
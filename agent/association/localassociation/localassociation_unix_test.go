@@ -0,0 +1,99 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package localassociation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	// the test files are owned by the user running the tests
+	trustedUID = uint32(os.Getuid())
+}
+
+func TestLoadRefusesWritableDirectory(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"baseline.json": `{"content": {"schemaVersion": "2.2"}}`,
+	})
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, os.Chmod(dir, 0777))
+	_, err := Load(log.NewMockLog(), dir, "i-123")
+	assert.Error(t, err)
+
+	assert.NoError(t, os.Chmod(dir, 0755))
+	assocs, err := Load(log.NewMockLog(), dir, "i-123")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(assocs))
+}
+
+func TestLoadSkipsWritableFiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a-valid.json":           `{"content": {"schemaVersion": "2.2"}}`,
+		"group-writable.json":    `{"content": {"schemaVersion": "2.2"}}`,
+		"writable-document.json": `{"documentPath": "document.json"}`,
+		"document.json":          testDocument,
+	})
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, os.Chmod(filepath.Join(dir, "group-writable.json"), 0620))
+	assert.NoError(t, os.Chmod(filepath.Join(dir, "document.json"), 0606))
+
+	assocs, err := Load(log.NewMockLog(), dir, "i-123")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(assocs))
+	assert.Equal(t, "local-a-valid", *assocs[0].Association.AssociationId)
+}
+
+func TestLoadSkipsDocumentInWritableDirectory(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"baseline.json": "",
+	})
+	defer os.RemoveAll(dir)
+	documentDir, err := ioutil.TempDir("", "localassociation-document")
+	assert.NoError(t, err)
+	defer os.RemoveAll(documentDir)
+
+	document := filepath.Join(documentDir, "document.json")
+	assert.NoError(t, ioutil.WriteFile(document, []byte(testDocument), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "baseline.json"), []byte(`{"documentPath": "`+document+`"}`), 0600))
+
+	assocs, err := Load(log.NewMockLog(), dir, "i-123")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(assocs))
+
+	assert.NoError(t, os.Chmod(documentDir, 0777))
+	assocs, err = Load(log.NewMockLog(), dir, "i-123")
+	assert.NoError(t, err)
+	assert.Empty(t, assocs)
+}
+
+func TestCheckOwnershipRequiresTrustedOwner(t *testing.T) {
+	dir := writeFiles(t, map[string]string{})
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, checkOwnership(dir))
+
+	defer func(uid uint32) { trustedUID = uid }(trustedUID)
+	trustedUID++
+	assert.Error(t, checkOwnership(dir))
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package localassociation
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay groups the events of a single change, editors usually write a file in several steps
+var reloadDelay = 2 * time.Second
+
+// Watcher calls a function when the files in a directory change, such as the local association definitions
+type Watcher struct {
+	log     log.T
+	dir     string
+	reload  func()
+	watcher *fsnotify.Watcher
+	timer   *time.Timer
+	lock    sync.Mutex
+}
+
+// NewWatcher creates a watcher on the directory calling reload after its files change
+func NewWatcher(log log.T, dir string, reload func()) *Watcher {
+	return &Watcher{
+		log:    log,
+		dir:    dir,
+		reload: reload,
+	}
+}
+
+// Start starts watching the directory
+func (w *Watcher) Start() (err error) {
+	if w.watcher, err = fsnotify.NewWatcher(); err != nil {
+		return err
+	}
+	if err = w.watcher.Add(w.dir); err != nil {
+		w.watcher.Close()
+		return err
+	}
+
+	w.log.Infof("Watching directory %v", w.dir)
+	go w.eventHandler()
+	return nil
+}
+
+// eventHandler schedules a reload for the events on files in the directory
+func (w *Watcher) eventHandler() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.log.Debugf("Event on file %v : %v", event.Name, event)
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			w.scheduleReload()
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.log.Errorf("Error watching directory %v, %v", w.dir, err)
+		}
+	}
+}
+
+// scheduleReload calls reload once no event happened for reloadDelay
+func (w *Watcher) scheduleReload() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timer = time.AfterFunc(reloadDelay, func() {
+		w.log.Infof("Files in %v changed, reloading", w.dir)
+		w.reload()
+	})
+}
+
+// Stop stops watching the directory
+func (w *Watcher) Stop() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	if w.watcher != nil {
+		if err := w.watcher.Close(); err != nil {
+			w.log.Debugf("Error closing the watcher of directory %v, %v", w.dir, err)
+		}
+	}
+}
@@ -16,6 +16,9 @@ package model
 
 import (
 	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/association/scheduleexpression"
@@ -26,6 +29,68 @@ import (
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
+// LocalAssociationIDPrefix is the prefix of the IDs of associations defined in the local associations directory
+const LocalAssociationIDPrefix = "local-"
+
+// IsLocalAssociation returns true if the association ID belongs to an association defined on the instance
+func IsLocalAssociation(associationID string) bool {
+	return strings.HasPrefix(associationID, LocalAssociationIDPrefix)
+}
+
+// ScheduleSplay spreads the scheduled executions of the same association on different instances
+type ScheduleSplay struct {
+	// Window caps the delay derived from the instance ID, the same instance always gets the same delay
+	Window time.Duration
+	// Jitter caps the delay added to each execution, it differs from one execution to the other
+	Jitter time.Duration
+}
+
+var (
+	splay     ScheduleSplay
+	splayLock sync.RWMutex
+)
+
+// SetScheduleSplay sets the splay applied to the next scheduled dates
+func SetScheduleSplay(scheduleSplay ScheduleSplay) {
+	splayLock.Lock()
+	defer splayLock.Unlock()
+	splay = scheduleSplay
+}
+
+// splayOffset returns the delay of the given instance within the splay window
+func splayOffset(instanceID string) time.Duration {
+	splayLock.RLock()
+	defer splayLock.RUnlock()
+
+	if splay.Window <= 0 {
+		return 0
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(instanceID))
+	return time.Duration(hash.Sum64() % uint64(splay.Window/time.Second) * uint64(time.Second))
+}
+
+// jitter returns the delay of the given execution within the jitter. It is derived from the instance, the association
+// and the scheduled date, so the execution keeps the same date when the association is refreshed.
+// A positive period caps the jitter, so that the delayed execution still runs before the following one is due.
+func jitter(instanceID string, associationID string, scheduledDate time.Time, period time.Duration) time.Duration {
+	splayLock.RLock()
+	defer splayLock.RUnlock()
+
+	window := splay.Jitter
+	if period > 0 && period < window {
+		window = period
+	}
+	if window <= 0 {
+		return 0
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(instanceID))
+	hash.Write([]byte(associationID))
+	hash.Write([]byte(scheduledDate.UTC().Format(time.RFC3339)))
+	return time.Duration(hash.Sum64() % uint64(window))
+}
+
 // InstanceAssociation represents detail information of an association
 type InstanceAssociation struct {
 	DocumentID        string
@@ -35,6 +100,16 @@ type InstanceAssociation struct {
 	ParsedExpression  scheduleexpression.ScheduleExpression
 	Document          *string
 	Errors            []error
+	// Rerun is set when the next execution repeats a previous run instead of following the schedule
+	Rerun *Rerun
+	// DeferredUntil is set when the association was not allowed to run, it doesn't run before that date
+	DeferredUntil *time.Time
+}
+
+// Rerun repeats a previous run of the association with the parameters of that run
+type Rerun struct {
+	RunID      string
+	Parameters map[string][]*string
 }
 
 // ParseExpression parses the expression with the given association
@@ -50,6 +125,11 @@ func (newAssoc *InstanceAssociation) ParseExpression(log log.T) error {
 	return nil
 }
 
+// IsLocalAssociation returns true for the association defined in the local associations directory
+func (assoc *InstanceAssociation) IsLocalAssociation() bool {
+	return assoc.Association.AssociationId != nil && IsLocalAssociation(*assoc.Association.AssociationId)
+}
+
 // IsRunOnceAssociation return true for the association that doesn't have schedule expression and will run only once
 func (assoc *InstanceAssociation) IsRunOnceAssociation() bool {
 	return assoc.Association.ScheduleExpression == nil || *assoc.Association.ScheduleExpression == ""
@@ -60,8 +140,36 @@ func (newAssoc *InstanceAssociation) RunNow() {
 	newAssoc.NextScheduledDate = aws.Time(time.Now().UTC())
 }
 
-// SetNextScheduledDate sets next scheduled date for the given association
+// runAfterSplay sets the NextScheduledDate of an association that has not run yet to its creation, delayed by the
+// splay of the instance so that a new association doesn't run on all the instances at once
+func (newAssoc *InstanceAssociation) runAfterSplay() {
+	createDate := newAssoc.CreateDate
+	if createDate.IsZero() {
+		createDate = time.Now()
+	}
+	createDate = createDate.UTC()
+
+	delay := time.Duration(0)
+	if newAssoc.Association.InstanceId != nil && newAssoc.Association.AssociationId != nil {
+		delay = splayOffset(*newAssoc.Association.InstanceId) +
+			jitter(*newAssoc.Association.InstanceId, *newAssoc.Association.AssociationId, createDate, 0)
+	}
+	newAssoc.NextScheduledDate = aws.Time(createDate.Add(delay))
+}
+
+// SetNextScheduledDate sets next scheduled date for the given association, not before the date it is deferred to
 func (newAssoc *InstanceAssociation) SetNextScheduledDate(log log.T) {
+	newAssoc.setScheduledDate(log)
+
+	if newAssoc.DeferredUntil != nil && newAssoc.NextScheduledDate != nil && newAssoc.NextScheduledDate.Before(*newAssoc.DeferredUntil) {
+		newAssoc.NextScheduledDate = aws.Time(newAssoc.DeferredUntil.UTC())
+		log.Infof("Association %v is deferred, next scheduled date is %v",
+			*newAssoc.Association.AssociationId, times.ToIsoDashUTC(*newAssoc.NextScheduledDate))
+	}
+}
+
+// setScheduledDate sets next scheduled date for the given association according to its status and schedule
+func (newAssoc *InstanceAssociation) setScheduledDate(log log.T) {
 	// Run association immediately if DetailedStatus is Pending
 	if newAssoc.Association.DetailedStatus != nil &&
 		*newAssoc.Association.DetailedStatus == contracts.AssociationStatusPending {
@@ -72,8 +180,8 @@ func (newAssoc *InstanceAssociation) SetNextScheduledDate(log log.T) {
 	if newAssoc.IsRunOnceAssociation() {
 		if newAssoc.Association.DetailedStatus != nil &&
 			*newAssoc.Association.DetailedStatus == contracts.AssociationStatusAssociated {
-			// Run association immediately if RunOnceAssociation has not been run before
-			newAssoc.RunNow()
+			// Run association after the splay if RunOnceAssociation has not been run before
+			newAssoc.runAfterSplay()
 		} else {
 			log.Infof("Skipping association %v as it has been processed", *newAssoc.Association.Name)
 			newAssoc.NextScheduledDate = nil
@@ -81,9 +189,10 @@ func (newAssoc *InstanceAssociation) SetNextScheduledDate(log log.T) {
 		return
 	}
 
-	// Run association immediately if association has not been run before
-	if newAssoc.Association.LastExecutionDate == nil {
-		newAssoc.RunNow()
+	// Run association after the splay if association has not been run before, unless it runs once at a given time
+	isOneTime := scheduleexpression.IsOneTimeExpression(*newAssoc.Association.ScheduleExpression)
+	if newAssoc.Association.LastExecutionDate == nil && !isOneTime {
+		newAssoc.runAfterSplay()
 		return
 	}
 
@@ -98,10 +207,33 @@ func (newAssoc *InstanceAssociation) SetNextScheduledDate(log log.T) {
 		}
 	}
 
-	// Set next schedule date of association according to it's schedule
-	newAssoc.NextScheduledDate = aws.Time(
-		newAssoc.ParsedExpression.Next(newAssoc.Association.LastExecutionDate.UTC()).UTC())
+	// Set next schedule date of association according to it's schedule, delayed by the splay of the instance.
+	// The last execution was delayed by the same splay, remove it so that it doesn't add up. It was also delayed by
+	// its jitter, which is kept below the time to the following execution so that no execution is skipped.
+	offset := time.Duration(0)
+	instanceID := ""
+	if newAssoc.Association.InstanceId != nil {
+		instanceID = *newAssoc.Association.InstanceId
+		offset = splayOffset(instanceID)
+	}
+	lastExecutionDate := time.Time{}
+	if newAssoc.Association.LastExecutionDate != nil {
+		lastExecutionDate = newAssoc.Association.LastExecutionDate.UTC()
+	}
+	scheduledDate := newAssoc.ParsedExpression.Next(lastExecutionDate.Add(-offset))
+	if scheduledDate.IsZero() {
+		log.Infof("Skipping association %v as expression %v has no scheduled date after %v",
+			*newAssoc.Association.AssociationId, *newAssoc.Association.ScheduleExpression, times.ToIsoDashUTC(lastExecutionDate))
+		newAssoc.NextScheduledDate = nil
+		return
+	}
+	period := time.Duration(0)
+	if followingDate := newAssoc.ParsedExpression.Next(scheduledDate); !followingDate.IsZero() {
+		period = followingDate.Sub(scheduledDate)
+	}
+	delay := offset + jitter(instanceID, *newAssoc.Association.AssociationId, scheduledDate, period)
+	newAssoc.NextScheduledDate = aws.Time(scheduledDate.Add(delay).UTC())
 	log.Infof("Based upon expression %v and last execution date %v, next scheduled date for association %v is %v",
-		*newAssoc.Association.ScheduleExpression, times.ToIsoDashUTC(*newAssoc.Association.LastExecutionDate),
+		*newAssoc.Association.ScheduleExpression, times.ToIsoDashUTC(lastExecutionDate),
 		*newAssoc.Association.AssociationId, times.ToIsoDashUTC(*newAssoc.NextScheduledDate))
 }
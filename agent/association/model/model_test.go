@@ -19,6 +19,7 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/association/scheduleexpression"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/stretchr/testify/assert"
 )
@@ -384,3 +385,252 @@ func TestNextScheduledDateIsNilWhenAssociationExpressionTypeIsUnknown(t *testing
 	// Assert
 	assert.Nil(t, assocRawData.NextScheduledDate)
 }
+
+func createSplayTestAssociation(instanceID, expression string, lastExecutionDate time.Time) *InstanceAssociation {
+	logger := log.DefaultLogger()
+	assocName := "Test"
+	assocID := "b2f71a28-cbe1-4429-b848-26c7e1f5ad0d"
+	assoc := &InstanceAssociation{}
+	assoc.Association = &ssm.InstanceAssociationSummary{
+		Name:               &assocName,
+		AssociationId:      &assocID,
+		InstanceId:         &instanceID,
+		ScheduleExpression: &expression,
+		LastExecutionDate:  &lastExecutionDate,
+	}
+	assoc.ParsedExpression, _ = scheduleexpression.CreateScheduleExpression(logger, expression)
+	return assoc
+}
+
+func TestNextScheduledDateIsDelayedBySplayOfInstance(t *testing.T) {
+	// Assemble
+	logger := log.DefaultLogger()
+	SetScheduleSplay(ScheduleSplay{Window: 10 * time.Minute})
+	defer SetScheduleSplay(ScheduleSplay{})
+
+	offset := splayOffset("i-1234567890")
+	lastExecutionDate := time.Date(2009, 11, 17, 20, 0, 0, 0, time.UTC).Add(offset)
+	first := createSplayTestAssociation("i-1234567890", "cron(0 0 */1 * * ? *)", lastExecutionDate)
+	second := createSplayTestAssociation("i-1234567890", "cron(0 0 */1 * * ? *)", lastExecutionDate)
+
+	// Act
+	first.SetNextScheduledDate(logger)
+	second.SetNextScheduledDate(logger)
+
+	// Assert
+	assert.True(t, offset >= 0 && offset < 10*time.Minute)
+	assert.Equal(t, time.Date(2009, 11, 17, 21, 0, 0, 0, time.UTC).Add(offset), *first.NextScheduledDate)
+	assert.Equal(t, *first.NextScheduledDate, *second.NextScheduledDate)
+}
+
+func TestSplayOfInstancesIsSpreadWithinWindow(t *testing.T) {
+	// Assemble
+	SetScheduleSplay(ScheduleSplay{Window: time.Minute})
+	defer SetScheduleSplay(ScheduleSplay{})
+
+	offsets := map[time.Duration]bool{}
+	// Act
+	for _, instanceID := range []string{"i-0001", "i-0002", "i-0003", "i-0004", "i-0005", "mi-0006"} {
+		offset := splayOffset(instanceID)
+		// Assert
+		assert.True(t, offset >= 0 && offset < time.Minute)
+		assert.Equal(t, time.Duration(0), offset%time.Second)
+		offsets[offset] = true
+	}
+	assert.True(t, len(offsets) > 1)
+}
+
+func TestSplayDoesNotAddUpForRateExpression(t *testing.T) {
+	// Assemble
+	logger := log.DefaultLogger()
+	SetScheduleSplay(ScheduleSplay{Window: 30 * time.Minute})
+	defer SetScheduleSplay(ScheduleSplay{})
+
+	offset := splayOffset("i-1234567890")
+	lastExecutionDate := time.Date(2009, 11, 17, 20, 0, 0, 0, time.UTC).Add(offset)
+	assoc := createSplayTestAssociation("i-1234567890", "rate(1 hour)", lastExecutionDate)
+
+	// Act
+	assoc.SetNextScheduledDate(logger)
+
+	// Assert
+	assert.Equal(t, lastExecutionDate.Add(time.Hour), *assoc.NextScheduledDate)
+}
+
+func TestNextScheduledDateIsDelayedByJitter(t *testing.T) {
+	// Assemble
+	logger := log.DefaultLogger()
+	SetScheduleSplay(ScheduleSplay{Jitter: 5 * time.Minute})
+	defer SetScheduleSplay(ScheduleSplay{})
+
+	lastExecutionDate := time.Date(2009, 11, 17, 20, 0, 0, 0, time.UTC)
+	assoc := createSplayTestAssociation("i-1234567890", "cron(0 0 */1 * * ? *)", lastExecutionDate)
+	scheduledDate := time.Date(2009, 11, 17, 21, 0, 0, 0, time.UTC)
+	delay := jitter("i-1234567890", *assoc.Association.AssociationId, scheduledDate, time.Hour)
+
+	// Act
+	assoc.SetNextScheduledDate(logger)
+
+	// Assert
+	assert.Equal(t, scheduledDate.Add(delay), *assoc.NextScheduledDate)
+
+	// the execution keeps its date when the association is refreshed
+	assoc.SetNextScheduledDate(logger)
+	assert.Equal(t, scheduledDate.Add(delay), *assoc.NextScheduledDate)
+}
+
+func TestJitterIsWithinRange(t *testing.T) {
+	// Assemble
+	SetScheduleSplay(ScheduleSplay{Jitter: time.Minute})
+	defer SetScheduleSplay(ScheduleSplay{})
+
+	delays := map[time.Duration]bool{}
+	scheduledDate := time.Date(2009, 11, 17, 20, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		// Act
+		delay := jitter("i-1234567890", "assoc-1", scheduledDate.Add(time.Duration(i)*time.Hour), 0)
+
+		// Assert
+		assert.True(t, delay >= 0 && delay < time.Minute)
+		delays[delay] = true
+	}
+	assert.True(t, len(delays) > 1, "the jitter differs from one execution to the other")
+	assert.Equal(t, jitter("i-1234567890", "assoc-1", scheduledDate, 0), jitter("i-1234567890", "assoc-1", scheduledDate, 0))
+}
+
+func TestJitterIsCappedBelowRatePeriod(t *testing.T) {
+	// Assemble
+	logger := log.DefaultLogger()
+	SetScheduleSplay(ScheduleSplay{Window: time.Hour, Jitter: 600 * time.Second})
+	defer SetScheduleSplay(ScheduleSplay{})
+
+	lastExecutionDate := time.Date(2009, 11, 17, 20, 0, 0, 0, time.UTC)
+	assoc := createSplayTestAssociation("i-1234567890", "rate(5 minutes)", lastExecutionDate)
+
+	for i := 0; i < 100; i++ {
+		// Act
+		assoc.SetNextScheduledDate(logger)
+
+		// Assert
+		// the splay of the last run is removed, the run is only delayed by its jitter
+		scheduledDate := lastExecutionDate.Add(5 * time.Minute)
+		delay := assoc.NextScheduledDate.Sub(scheduledDate)
+		assert.True(t, delay >= 0 && delay < 5*time.Minute, "run %v is delayed by %v", i, delay)
+
+		lastExecutionDate = *assoc.NextScheduledDate
+		assoc.Association.LastExecutionDate = &lastExecutionDate
+	}
+}
+
+func TestJitterDoesNotSkipCronOccurrences(t *testing.T) {
+	// Assemble
+	logger := log.DefaultLogger()
+	SetScheduleSplay(ScheduleSplay{Window: time.Hour, Jitter: 600 * time.Second})
+	defer SetScheduleSplay(ScheduleSplay{})
+
+	offset := splayOffset("i-1234567890")
+	lastExecutionDate := time.Date(2009, 11, 17, 20, 0, 0, 0, time.UTC).Add(offset)
+	assoc := createSplayTestAssociation("i-1234567890", "cron(0 0/5 * * * ? *)", lastExecutionDate)
+
+	for i := 1; i <= 100; i++ {
+		// Act
+		assoc.SetNextScheduledDate(logger)
+
+		// Assert
+		scheduledDate := time.Date(2009, 11, 17, 20, 0, 0, 0, time.UTC).Add(offset + time.Duration(i)*5*time.Minute)
+		delay := assoc.NextScheduledDate.Sub(scheduledDate)
+		assert.True(t, delay >= 0 && delay < 5*time.Minute, "run %v is delayed by %v", i, delay)
+
+		lastExecutionDate = *assoc.NextScheduledDate
+		assoc.Association.LastExecutionDate = &lastExecutionDate
+	}
+}
+
+func TestNewAssociationIsDelayedBySplay(t *testing.T) {
+	// Assemble
+	logger := log.DefaultLogger()
+	SetScheduleSplay(ScheduleSplay{Window: time.Hour, Jitter: time.Minute})
+	defer SetScheduleSplay(ScheduleSplay{})
+
+	createDate := time.Date(2009, 11, 17, 20, 0, 0, 0, time.UTC)
+	scheduled := createSplayTestAssociation("i-1234567890", "rate(1 hour)", time.Time{})
+	scheduled.Association.LastExecutionDate = nil
+	scheduled.CreateDate = createDate
+	runOnce := createSplayTestAssociation("i-1234567890", "", time.Time{})
+	runOnce.Association.ScheduleExpression = nil
+	runOnce.Association.DetailedStatus = aws.String("Associated")
+	runOnce.CreateDate = createDate
+	delay := splayOffset("i-1234567890") + jitter("i-1234567890", *scheduled.Association.AssociationId, createDate, 0)
+
+	// Act
+	scheduled.SetNextScheduledDate(logger)
+	runOnce.SetNextScheduledDate(logger)
+
+	// Assert
+	assert.Equal(t, createDate.Add(delay), *scheduled.NextScheduledDate)
+	assert.Equal(t, createDate.Add(delay), *runOnce.NextScheduledDate)
+}
+
+func TestPendingAssociationIsNotDelayedBySplay(t *testing.T) {
+	// Assemble
+	logger := log.DefaultLogger()
+	SetScheduleSplay(ScheduleSplay{Window: time.Hour, Jitter: time.Hour})
+	defer SetScheduleSplay(ScheduleSplay{})
+
+	assoc := createSplayTestAssociation("i-1234567890", "rate(1 hour)", time.Now().UTC())
+	status := "Pending"
+	assoc.Association.DetailedStatus = &status
+	before := time.Now().UTC()
+
+	// Act
+	assoc.SetNextScheduledDate(logger)
+
+	// Assert
+	assert.False(t, assoc.NextScheduledDate.After(time.Now().UTC()))
+	assert.False(t, assoc.NextScheduledDate.Before(before.Add(-time.Second)))
+}
+
+func TestNextScheduledDateIsNotBeforeDeferral(t *testing.T) {
+	// Assemble
+	logger := log.DefaultLogger()
+	lastExecutionDate := time.Date(2009, 11, 17, 20, 0, 0, 0, time.UTC)
+	assoc := createSplayTestAssociation("i-1234567890", "rate(1 hour)", lastExecutionDate)
+	deferredUntil := time.Date(2009, 11, 17, 22, 30, 0, 0, time.UTC)
+	assoc.DeferredUntil = &deferredUntil
+
+	// Act
+	assoc.SetNextScheduledDate(logger)
+
+	// Assert
+	assert.Equal(t, deferredUntil, *assoc.NextScheduledDate)
+
+	// a deferral that is over doesn't delay the schedule
+	deferredUntil = time.Date(2009, 11, 17, 20, 30, 0, 0, time.UTC)
+	assoc.SetNextScheduledDate(logger)
+	assert.Equal(t, time.Date(2009, 11, 17, 21, 0, 0, 0, time.UTC), *assoc.NextScheduledDate)
+}
+
+func TestNextScheduledDateOfAtExpressionThatHasNotRunIsItsTime(t *testing.T) {
+	// Assemble
+	logger := log.DefaultLogger()
+	assoc := createSplayTestAssociation("i-1234567890", "at(2030-03-11T02:30:00)", time.Time{})
+	assoc.Association.LastExecutionDate = nil
+
+	// Act
+	assoc.SetNextScheduledDate(logger)
+
+	// Assert
+	assert.Equal(t, time.Date(2030, 3, 11, 2, 30, 0, 0, time.UTC), *assoc.NextScheduledDate)
+}
+
+func TestNextScheduledDateOfAtExpressionThatHasRunIsNil(t *testing.T) {
+	// Assemble
+	logger := log.DefaultLogger()
+	assoc := createSplayTestAssociation("i-1234567890", "at(2018-03-11T02:30:00)", time.Date(2018, 3, 11, 2, 30, 5, 0, time.UTC))
+
+	// Act
+	assoc.SetNextScheduledDate(logger)
+
+	// Assert
+	assert.Nil(t, assoc.NextScheduledDate)
+}
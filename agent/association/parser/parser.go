@@ -88,12 +88,15 @@ func InitializeDocumentState(context context.T,
 
 	orchestrationDir := filepath.Join(orchestrationRootDir, documentInfo.AssociationID, documentInfo.RunID)
 
+	localArchive, httpOutput := docparser.NewLocalOutputConfig(context.AppConfig(), documentInfo.DocumentID)
 	parserInfo := docparser.DocumentParserInfo{
 		OrchestrationDir: orchestrationDir,
 		S3Bucket:         payload.OutputS3BucketName,
 		S3Prefix:         s3KeyPrefix,
 		MessageId:        documentInfo.MessageID,
 		DocumentId:       documentInfo.DocumentID,
+		LocalArchive:     localArchive,
+		HTTPOutput:       httpOutput,
 	}
 
 	docContent := &docparser.DocContent{
@@ -15,8 +15,11 @@
 package processor
 
 import (
+	"github.com/aws/amazon-ssm-agent/agent/association/localassociation"
 	"github.com/aws/amazon-ssm-agent/agent/association/model"
 	"github.com/aws/amazon-ssm-agent/agent/association/parser"
+	"github.com/aws/amazon-ssm-agent/agent/association/runhistory"
+	"github.com/aws/amazon-ssm-agent/agent/association/schedulemanager"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
@@ -51,6 +54,66 @@ func (assocBookkeepingService) DeleteOldOrchestrationDirectories(log log.T, inst
 	docmanager.DeleteOldOrchestrationDirectories(log, instanceID, orchestrationRootDirName, retentionDurationHours, associationRetentionDurationHours)
 }
 
+var assocStore scheduleStore = &assocScheduleStore{}
+
+// scheduleStore represents the dependency for keeping scheduled associations on disk
+type scheduleStore interface {
+	Persist(log log.T, instanceID string) error
+	LoadPersisted(log log.T, instanceID string) ([]*model.InstanceAssociation, error)
+}
+
+type assocScheduleStore struct{}
+
+// Persist wraps schedulemanager Persist
+func (assocScheduleStore) Persist(log log.T, instanceID string) error {
+	return schedulemanager.Persist(log, instanceID)
+}
+
+// LoadPersisted wraps schedulemanager LoadPersisted
+func (assocScheduleStore) LoadPersisted(log log.T, instanceID string) ([]*model.InstanceAssociation, error) {
+	return schedulemanager.LoadPersisted(log, instanceID)
+}
+
+var localAssocLoader localAssociationLoader = &localAssociationLoaderImp{}
+
+// localAssociationLoader represents the dependency for localassociation
+type localAssociationLoader interface {
+	Load(log log.T, dir string, instanceID string) ([]*model.InstanceAssociation, error)
+}
+
+type localAssociationLoaderImp struct{}
+
+// Load wraps localassociation Load
+func (localAssociationLoaderImp) Load(log log.T, dir string, instanceID string) ([]*model.InstanceAssociation, error) {
+	return localassociation.Load(log, dir, instanceID)
+}
+
+var runHistory runHistoryStore = &runHistoryStoreImp{}
+
+// runHistoryStore represents the dependency for runhistory
+type runHistoryStore interface {
+	Append(log log.T, instanceID string, run runhistory.Run) error
+	FindRun(instanceID string, associationID string, runID string) (runhistory.Run, bool)
+	TakeRerunRequests(log log.T, instanceID string) []runhistory.RerunRequest
+}
+
+type runHistoryStoreImp struct{}
+
+// Append wraps runhistory Append
+func (runHistoryStoreImp) Append(log log.T, instanceID string, run runhistory.Run) error {
+	return runhistory.Append(log, instanceID, run)
+}
+
+// FindRun wraps runhistory FindRun
+func (runHistoryStoreImp) FindRun(instanceID string, associationID string, runID string) (runhistory.Run, bool) {
+	return runhistory.FindRun(instanceID, associationID, runID)
+}
+
+// TakeRerunRequests wraps runhistory TakeRerunRequests
+func (runHistoryStoreImp) TakeRerunRequests(log log.T, instanceID string) []runhistory.RerunRequest {
+	return runhistory.TakeRerunRequests(log, instanceID)
+}
+
 // system represents the dependency for platform
 type system interface {
 	InstanceID() (string, error)
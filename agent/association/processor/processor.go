@@ -22,8 +22,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/association/cache"
+	"github.com/aws/amazon-ssm-agent/agent/association/driftdetector"
 	"github.com/aws/amazon-ssm-agent/agent/association/frequentcollector"
+	"github.com/aws/amazon-ssm-agent/agent/association/localassociation"
 	"github.com/aws/amazon-ssm-agent/agent/association/model"
 	"github.com/aws/amazon-ssm-agent/agent/association/schedulemanager"
 	"github.com/aws/amazon-ssm-agent/agent/association/schedulemanager/signal"
@@ -35,6 +38,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/maintenancewindow"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/times"
 	"github.com/carlescere/scheduler"
@@ -59,10 +63,19 @@ type Processor struct {
 	proc               processor.Processor
 	resChan            chan contracts.DocumentResult
 	onBoot             bool
+	localWatcher       *localassociation.Watcher
+	rerunWatcher       *localassociation.Watcher
+	workersLimit       int
+	maintenance        *maintenancewindow.Policy
+	running            map[string]runningAssociation
+	runningLock        sync.Mutex
 }
 
 var lock sync.RWMutex
 
+// refreshLock prevents the poll and the reload of local associations from refreshing the schedules concurrently
+var refreshLock sync.Mutex
+
 // NewAssociationProcessor returns a new Processor with the given context.
 func NewAssociationProcessor(context context.T) *Processor {
 	assocContext := context.With("[" + name + "]")
@@ -82,8 +95,13 @@ func NewAssociationProcessor(context context.T) *Processor {
 	uploader := complianceUploader.NewComplianceUploader(context)
 
 	//TODO Rename everything to service and move package to framework
-	//association has no cancel worker
-	proc := processor.NewEngineProcessor(assocContext, documentWorkersLimit, documentWorkersLimit, []contracts.DocumentType{contracts.Association})
+	//association has no cancel worker, each association worker runs one association at a time
+	model.SetScheduleSplay(model.ScheduleSplay{
+		Window: time.Duration(config.Ssm.AssociationSplayWindowSeconds) * time.Second,
+		Jitter: time.Duration(config.Ssm.AssociationJitterSeconds) * time.Second,
+	})
+
+	proc := processor.NewEngineProcessor(assocContext, config.Ssm.AssociationWorkersLimit, documentWorkersLimit, []contracts.DocumentType{contracts.Association})
 	return &Processor{
 		context:            assocContext,
 		assocSvc:           assocSvc,
@@ -91,6 +109,8 @@ func NewAssociationProcessor(context context.T) *Processor {
 		agentInfo:          &agentInfo,
 		proc:               proc,
 		onBoot:             true,
+		workersLimit:       config.Ssm.AssociationWorkersLimit,
+		maintenance:        maintenancewindow.NewPolicy(assocContext.Log(), config.Maintenance),
 	}
 }
 
@@ -112,7 +132,14 @@ func (p *Processor) ModuleExecute(context context.T) {
 }
 func (p *Processor) ModuleRequestStop(stopType contracts.StopType) (err error) {
 	assocScheduler.Stop(p.pollJob)
+	if p.localWatcher != nil {
+		p.localWatcher.Stop()
+	}
+	if p.rerunWatcher != nil {
+		p.rerunWatcher.Stop()
+	}
 	signal.Stop()
+	driftdetector.GetDriftDetector().ClearDriftDetectors()
 	p.proc.Stop(stopType)
 	return nil
 }
@@ -138,6 +165,19 @@ func (p *Processor) InitializeAssociationProcessor() {
 	log.Info("Initializing association scheduling service")
 	signal.InitializeAssociationSignalService(log, p.runScheduledAssociation)
 	log.Info("Association scheduling service initialized")
+
+	if dir := p.context.AppConfig().Ssm.LocalAssociationsDirectory; dir != "" {
+		p.localWatcher = localassociation.NewWatcher(log, dir, p.reloadLocalAssociations)
+		if err := p.localWatcher.Start(); err != nil {
+			log.Errorf("Unable to watch local associations directory %v, changes will be applied at the next poll, %v", dir, err)
+		}
+	}
+
+	if instanceID, err := sys.InstanceID(); err != nil {
+		log.Errorf("Unable to retrieve instance id, rerun requests will be applied at the next poll, %v", err)
+	} else {
+		p.watchRerunRequests(log, instanceID)
+	}
 }
 
 // SetPollJob represents setter for PollJob
@@ -152,6 +192,9 @@ func (p *Processor) ProcessAssociation() {
 
 	log.Debug("running ProcessAssociation")
 
+	refreshLock.Lock()
+	defer refreshLock.Unlock()
+
 	instanceID, err := sys.InstanceID()
 	if err != nil {
 		log.Error("Unable to retrieve instance id", err)
@@ -163,6 +206,7 @@ func (p *Processor) ProcessAssociation() {
 
 	if associations, err = p.assocSvc.ListInstanceAssociations(log, instanceID); err != nil {
 		log.Errorf("Unable to load instance associations, %v", err)
+		p.scheduleKnownAssociations(log, instanceID)
 		return
 	}
 
@@ -249,7 +293,24 @@ func (p *Processor) ProcessAssociation() {
 		}
 	}
 
+	// nothing is scheduled after an agent restart, the associations saved during the last refresh are known instead
+	known := schedulemanager.Schedules()
+	restarted := len(known) == 0
+	if restarted {
+		known = loadPersistedSchedules(log, instanceID)
+	}
+	associations = append(associations, p.loadLocalAssociations(log, instanceID, known)...)
+	if restarted {
+		restorePersistedSchedules(associations, known)
+	}
 	schedulemanager.Refresh(log, associations)
+	persistSchedules(log, instanceID)
+	p.restartDriftDetectors(log)
+	p.applyRerunRequests(log, instanceID)
+
+	// the service is reachable, deliver what was queued while it was not
+	p.assocSvc.SendQueuedStatusUpdates(log, instanceID)
+	p.complianceUploader.SendQueuedCompliance(log, instanceID)
 
 	log.Debug("ProcessAssociation is triggering execution")
 
@@ -258,7 +319,110 @@ func (p *Processor) ProcessAssociation() {
 	log.Debug("ProcessAssociation completed")
 }
 
-// runScheduledAssociation runs the next scheduled association
+// reloadLocalAssociations schedules the local associations again after their definitions changed
+func (p *Processor) reloadLocalAssociations() {
+	log := p.context.Log()
+
+	instanceID, err := sys.InstanceID()
+	if err != nil {
+		log.Error("Unable to retrieve instance id", err)
+		return
+	}
+
+	refreshLock.Lock()
+	defer refreshLock.Unlock()
+	p.scheduleKnownAssociations(log, instanceID)
+}
+
+// scheduleKnownAssociations schedules the associations known without asking the service: the scheduled service
+// associations, or the ones saved during the last successful refresh when none is scheduled, e.g. after the agent
+// restarted during a network outage, together with the local associations
+func (p *Processor) scheduleKnownAssociations(log log.T, instanceID string) {
+	known := schedulemanager.Schedules()
+	if len(known) == 0 {
+		known = loadPersistedSchedules(log, instanceID)
+	}
+
+	associations := []*model.InstanceAssociation{}
+	for _, assoc := range known {
+		if !assoc.IsLocalAssociation() {
+			associations = append(associations, assoc)
+		}
+	}
+	associations = append(associations, p.loadLocalAssociations(log, instanceID, known)...)
+	if len(known) == 0 && len(associations) == 0 {
+		return
+	}
+
+	for _, assoc := range associations {
+		if assoc.ParsedExpression == nil && !assoc.IsRunOnceAssociation() {
+			if err := assoc.ParseExpression(log); err != nil {
+				log.Errorf("Encountered error while parsing expression for association %v, %v", *assoc.Association.AssociationId, err)
+				assoc.Errors = append(assoc.Errors, err)
+			}
+		}
+	}
+
+	log.Infof("Scheduling %v associations without the service", len(associations))
+	schedulemanager.Refresh(log, associations)
+	persistSchedules(log, instanceID)
+	p.restartDriftDetectors(log)
+	signal.ExecuteAssociation(log)
+}
+
+// loadLocalAssociations returns the associations defined in the local associations directory.
+// An association whose definition is unchanged is replaced by the known one, so its last execution and status carry over.
+func (p *Processor) loadLocalAssociations(log log.T, instanceID string, known []*model.InstanceAssociation) []*model.InstanceAssociation {
+	dir := p.context.AppConfig().Ssm.LocalAssociationsDirectory
+	if dir == "" {
+		return nil
+	}
+
+	local, err := localAssocLoader.Load(log, dir, instanceID)
+	if err != nil {
+		log.Errorf("Unable to load local associations, %v", err)
+		return nil
+	}
+
+	knownByID := make(map[string]*model.InstanceAssociation)
+	for _, assoc := range known {
+		knownByID[*assoc.Association.AssociationId] = assoc
+	}
+	for i, assoc := range local {
+		previous, exists := knownByID[*assoc.Association.AssociationId]
+		if exists && previous.Association.Checksum != nil && *previous.Association.Checksum == *assoc.Association.Checksum {
+			local[i] = previous
+		}
+	}
+	return local
+}
+
+// loadPersistedSchedules returns the associations saved during the last refresh
+func loadPersistedSchedules(log log.T, instanceID string) []*model.InstanceAssociation {
+	persisted, err := assocStore.LoadPersisted(log, instanceID)
+	if err != nil {
+		log.Errorf("Unable to load saved associations, %v", err)
+	}
+	return persisted
+}
+
+// restorePersistedSchedules defers the associations as they were deferred before the agent restarted, and keeps
+// the creation date of the ones which have not run yet. Once associations are scheduled, the schedule manager
+// carries them over itself.
+func restorePersistedSchedules(associations []*model.InstanceAssociation, persisted []*model.InstanceAssociation) {
+	schedulemanager.CarryOverDeferrals(associations, persisted)
+	schedulemanager.CarryOverCreateDates(associations, persisted)
+}
+
+// persistSchedules saves the scheduled associations so they can run while the service is unreachable
+func persistSchedules(log log.T, instanceID string) {
+	if err := assocStore.Persist(log, instanceID); err != nil {
+		log.Errorf("Unable to save scheduled associations, %v", err)
+	}
+}
+
+// runScheduledAssociation runs the associations due to run, as long as association workers are available.
+// The association waiting the longest runs first and an association never overlaps its own execution.
 func (p *Processor) runScheduledAssociation(log log.T) {
 	log.Debug("runScheduledAssociation starting")
 
@@ -272,58 +436,132 @@ func (p *Processor) runScheduledAssociation(log log.T) {
 		}
 	}()
 
-	var (
-		scheduledAssociation *model.InstanceAssociation
-		err                  error
-	)
+	currentTime := time.Now().UTC()
+	for _, scheduledAssociation := range schedulemanager.LoadScheduledAssociations(log) {
+		associationID := *scheduledAssociation.Association.AssociationId
+		if p.isAssociationRunning(associationID) || schedulemanager.IsAssociationInProgress(associationID) {
+			log.Debugf("Association %v is InProgress", associationID)
+			p.failTimedOutAssociation(log, scheduledAssociation)
+			continue
+		}
 
-	if scheduledAssociation, err = schedulemanager.LoadNextScheduledAssociation(log); err != nil {
-		log.Errorf("Unable to get next scheduled association, %v, system will retry later", err)
-		return
-	}
+		if !p.isAssociationAllowedToRun(log, scheduledAssociation, currentTime) {
+			continue
+		}
 
-	if scheduledAssociation == nil {
-		// if no scheduled association found at given time, get the next scheduled time and wait
-		nextScheduledDate := schedulemanager.LoadNextScheduledDate(log)
-		if nextScheduledDate != nil {
-			signal.ResetWaitTimerForNextScheduledAssociation(log, *nextScheduledDate)
-		} else {
-			log.Debug("No association scheduled at this time, system will retry later")
+		if p.runningAssociations() >= p.associationWorkersLimit() {
+			log.Infof("All %v association workers are busy, association %v waits for the next available worker",
+				p.associationWorkersLimit(), associationID)
+			break
 		}
-		return
+
+		p.runAssociation(log, scheduledAssociation, currentTime.Sub(*scheduledAssociation.NextScheduledDate))
 	}
 
-	// stop previous wait timer if there is scheduled association
-	signal.StopWaitTimerForNextScheduledAssociation()
+	// the associations due to run are started when a worker becomes available, wait for the next scheduled one
+	if nextScheduledDate := schedulemanager.LoadNextScheduledDateAfter(log, currentTime); nextScheduledDate != nil {
+		signal.ResetWaitTimerForNextScheduledAssociation(log, *nextScheduledDate)
+	} else {
+		log.Debug("No association scheduled at this time, system will retry later")
+	}
+}
 
-	if schedulemanager.IsAssociationInProgress(*scheduledAssociation.Association.AssociationId) {
-		log.Debug("runScheduledAssociation is InProgress")
-		if isAssociationTimedOut(scheduledAssociation) {
-			err = fmt.Errorf("Association stuck at InProgress for longer than %v hours", documentLevelTimeOutDurationHour)
-			log.Error(err)
-			p.assocSvc.UpdateInstanceAssociationStatus(
-				log,
-				*scheduledAssociation.Association.AssociationId,
-				*scheduledAssociation.Association.Name,
-				*scheduledAssociation.Association.InstanceId,
-				contracts.AssociationStatusFailed,
-				contracts.AssociationErrorCodeStuckAtInProgressError,
-				times.ToIso8601UTC(time.Now()),
-				err.Error(),
-				service.NoOutputUrl)
-			p.complianceUploader.UpdateAssociationCompliance(
-				*scheduledAssociation.Association.AssociationId,
-				*scheduledAssociation.Association.InstanceId,
-				*scheduledAssociation.Association.Name,
-				*scheduledAssociation.Association.DocumentVersion,
-				contracts.AssociationStatusFailed,
-				time.Now().UTC())
+// isAssociationAllowedToRun checks the association against the maintenance windows and blackouts.
+// An association not allowed to run is deferred to the next allowed time, or skipped when it would run again
+// by its schedule before that time.
+func (p *Processor) isAssociationAllowedToRun(log log.T, scheduledAssociation *model.InstanceAssociation, currentTime time.Time) bool {
+	decision := p.maintenance.Check(*scheduledAssociation.Association.Name, currentTime)
+	if decision.Allowed {
+		return true
+	}
 
-		}
+	associationID := *scheduledAssociation.Association.AssociationId
+	var nextScheduledDate time.Time
+	if !scheduledAssociation.IsRunOnceAssociation() && scheduledAssociation.ParsedExpression != nil {
+		nextScheduledDate = scheduledAssociation.ParsedExpression.Next(currentTime)
+	}
+
+	if decision.NextAllowed.IsZero() || (!nextScheduledDate.IsZero() && !decision.NextAllowed.Before(nextScheduledDate)) {
+		p.skipAssociation(
+			log,
+			associationID,
+			*scheduledAssociation.Association.Name,
+			*scheduledAssociation.Association.DocumentVersion,
+			decision.Reason)
+		schedulemanager.UpdateNextScheduledDate(log, associationID)
+		persistSchedules(log, *scheduledAssociation.Association.InstanceId)
+		return false
+	}
+
+	log.Infof("Association %v is not allowed to run now, %v", associationID, decision.Reason)
+	schedulemanager.DeferAssociation(log, associationID, decision.NextAllowed)
+	persistSchedules(log, *scheduledAssociation.Association.InstanceId)
+	return false
+}
+
+// skipAssociation reports the association as skipped for the given reason
+func (p *Processor) skipAssociation(log log.T, associationID, documentName, documentVersion, reason string) {
+	message := fmt.Sprintf("Association skipped, %v", reason)
+	log.Info(message)
+
+	instanceID, _ := sys.InstanceID()
+	p.assocSvc.UpdateInstanceAssociationStatus(
+		log,
+		associationID,
+		documentName,
+		instanceID,
+		string(contracts.ResultStatusSkipped),
+		contracts.AssociationErrorCodeOutsideMaintenanceWindow,
+		times.ToIso8601UTC(time.Now()),
+		message,
+		service.NoOutputUrl)
+
+	p.complianceUploader.UpdateSkippedAssociationCompliance(
+		associationID,
+		instanceID,
+		documentName,
+		documentVersion,
+		reason,
+		time.Now().UTC())
+}
 
+// failTimedOutAssociation fails the association stuck at InProgress
+func (p *Processor) failTimedOutAssociation(log log.T, scheduledAssociation *model.InstanceAssociation) {
+	associationID := *scheduledAssociation.Association.AssociationId
+	timedOut := isAssociationTimedOut(scheduledAssociation)
+	if startTime, running := p.associationStartTime(associationID); running {
+		timedOut = time.Now().Sub(startTime) > documentLevelTimeOutDurationHour*time.Hour
+	}
+	if !timedOut {
 		return
 	}
 
+	err := fmt.Errorf("Association stuck at InProgress for longer than %v hours", documentLevelTimeOutDurationHour)
+	log.Error(err)
+	p.finishRunningAssociation(associationID)
+	p.assocSvc.UpdateInstanceAssociationStatus(
+		log,
+		associationID,
+		*scheduledAssociation.Association.Name,
+		*scheduledAssociation.Association.InstanceId,
+		contracts.AssociationStatusFailed,
+		contracts.AssociationErrorCodeStuckAtInProgressError,
+		times.ToIso8601UTC(time.Now()),
+		err.Error(),
+		service.NoOutputUrl)
+	p.complianceUploader.UpdateAssociationCompliance(
+		associationID,
+		*scheduledAssociation.Association.InstanceId,
+		*scheduledAssociation.Association.Name,
+		*scheduledAssociation.Association.DocumentVersion,
+		contracts.AssociationStatusFailed,
+		time.Now().UTC())
+}
+
+// runAssociation submits the association to the association workers
+func (p *Processor) runAssociation(log log.T, scheduledAssociation *model.InstanceAssociation, queueWait time.Duration) {
+	var err error
+
 	log.Debugf("Update association %v to pending ", *scheduledAssociation.Association.AssociationId)
 	// Update association status to pending
 	p.assocSvc.UpdateInstanceAssociationStatus(
@@ -337,8 +575,21 @@ func (p *Processor) runScheduledAssociation(log log.T) {
 		contracts.AssociationPendingMessage,
 		service.NoOutputUrl)
 
+	running := runningAssociation{
+		queueWait:  queueWait,
+		runID:      newRunID(),
+		parameters: scheduledAssociation.Association.Parameters,
+	}
+	assoc := scheduledAssociation
+	if rerun := schedulemanager.TakeRerun(scheduledAssociation); rerun != nil {
+		log.Infof("Association %v repeats run %v", *scheduledAssociation.Association.AssociationId, rerun.RunID)
+		assoc = rerunAssociation(scheduledAssociation, rerun)
+		running.parameters = assoc.Association.Parameters
+		running.rerunOf = rerun.RunID
+	}
+
 	var docState *contracts.DocumentState
-	if docState, err = p.parseAssociation(scheduledAssociation); err != nil {
+	if docState, err = p.parseAssociation(assoc); err != nil {
 		err = fmt.Errorf("Encountered error while parsing association %v, %v",
 			docState.DocumentInformation.AssociationID,
 			err)
@@ -374,11 +625,12 @@ func (p *Processor) runScheduledAssociation(log log.T) {
 		contracts.AssociationStatusInProgress,
 		contracts.AssociationErrorCodeNoError,
 		times.ToIso8601UTC(time.Now()),
-		contracts.AssociationInProgressMessage,
+		fmt.Sprintf("%v. %v", contracts.AssociationInProgressMessage, queueWaitMessage(queueWait)),
 		service.NoOutputUrl)
 
 	log.Debug("runScheduledAssociation submitting document")
 
+	p.startRunningAssociation(docState.DocumentInformation.AssociationID, running)
 	p.proc.Submit(*docState)
 
 	log.Debug("runScheduledAssociation submitted document")
@@ -392,6 +644,48 @@ func (p *Processor) runScheduledAssociation(log log.T) {
 			frequentCollector.StartFrequentCollector(p.context, docState, scheduledAssociation)
 		}
 	}
+
+	driftDetector := driftdetector.GetDriftDetector()
+	if driftDetector.IsDriftDetectionAssociation(docState) {
+		// Keep evaluating the desired state checks of the association between its executions
+		driftDetector.StartDriftDetector(p.context, docState, p.complianceUploader)
+	} else {
+		driftDetector.StopDriftDetector(docState.DocumentInformation.AssociationID)
+	}
+}
+
+// restartDriftDetectors starts the drift detectors of the scheduled associations that ran before and declare
+// desired state checks, so the checks are evaluated after the agent restarts without waiting for the next execution
+func (p *Processor) restartDriftDetectors(log log.T) {
+	driftDetector := driftdetector.GetDriftDetector()
+	for _, scheduledAssociation := range schedulemanager.Schedules() {
+		associationID := *scheduledAssociation.Association.AssociationId
+		if scheduledAssociation.Association.LastExecutionDate == nil || scheduledAssociation.Document == nil ||
+			driftDetector.IsDriftDetectorStarted(associationID) {
+			continue
+		}
+		// only parse the documents which may declare checks
+		if !strings.Contains(*scheduledAssociation.Document, appconfig.PluginNameAwsCheckDesiredState) {
+			continue
+		}
+
+		document, err := assocParser.ParseDocumentForPayload(log, scheduledAssociation)
+		if err != nil {
+			log.Debugf("Unable to parse association %v for drift detection, %v", associationID, err)
+			continue
+		}
+		// the document state is only used for its checks, the scheduled association keeps its document ID
+		assoc := *scheduledAssociation
+		var docState contracts.DocumentState
+		if docState, err = assocParser.InitializeDocumentState(p.context, document, &assoc); err != nil {
+			log.Debugf("Unable to parse association %v for drift detection, %v", associationID, err)
+			continue
+		}
+
+		if driftDetector.IsDriftDetectionAssociation(&docState) {
+			driftDetector.StartDriftDetector(p.context, &docState, p.complianceUploader)
+		}
+	}
 }
 
 func isAssociationTimedOut(assoc *model.InstanceAssociation) bool {
@@ -498,6 +792,7 @@ func (r *Processor) associationExecutionReport(
 	documentVersion string,
 	outputs map[string]*contracts.PluginResult,
 	totalNumberOfPlugins int,
+	queueWait time.Duration,
 	errorCode string,
 	associationStatus string) {
 
@@ -510,6 +805,8 @@ func (r *Processor) associationExecutionReport(
 	log.Info("Update instance association status with results ", jsonutil.Indent(runtimeStatusesContent))
 
 	executionSummary, outputUrl := buildOutput(runtimeStatuses, totalNumberOfPlugins)
+	// the queue wait goes first as long summaries get truncated
+	executionSummary = fmt.Sprintf("%v %v", queueWaitMessage(queueWait), executionSummary)
 	instanceID, _ := sys.InstanceID()
 	r.assocSvc.UpdateInstanceAssociationStatus(
 		log,
@@ -546,6 +843,8 @@ func (r *Processor) listenToResponses() {
 		if res.LastPlugin == "" {
 			log.Debug("Association execution completion: ", res.AssociationID)
 			log.Debug("Association execution status is ", res.Status)
+			running, _ := r.finishRunningAssociation(res.AssociationID)
+			queueWait := running.queueWait
 			if res.Status == contracts.ResultStatusFailed {
 				r.associationExecutionReport(
 					log,
@@ -554,9 +853,13 @@ func (r *Processor) listenToResponses() {
 					res.DocumentVersion,
 					res.PluginResults,
 					res.NPlugins,
+					queueWait,
 					contracts.AssociationErrorCodeExecutionError,
 					contracts.AssociationStatusFailed)
 
+			} else if res.Status == contracts.ResultStatusSkipped && res.SkippedReason != "" {
+				// the document reached a worker while not allowed to run
+				r.skipAssociation(log, res.AssociationID, res.DocumentName, res.DocumentVersion, res.SkippedReason)
 			} else if res.Status == contracts.ResultStatusSuccess ||
 				res.Status == contracts.AssociationStatusTimedOut ||
 				res.Status == contracts.ResultStatusSkipped {
@@ -568,6 +871,7 @@ func (r *Processor) listenToResponses() {
 					res.DocumentVersion,
 					res.PluginResults,
 					res.NPlugins,
+					queueWait,
 					contracts.AssociationErrorCodeNoError,
 					string(res.Status))
 			} else if res.Status == contracts.ResultStatusInProgress {
@@ -579,11 +883,13 @@ func (r *Processor) listenToResponses() {
 					res.DocumentVersion,
 					res.PluginResults,
 					res.NPlugins,
+					queueWait,
 					contracts.AssociationErrorCodeNoError,
 					contracts.AssociationStatusPending,
 				)
 			}
 			instanceID, _ := sys.InstanceID()
+			r.recordRun(log, instanceID, res, running)
 			//clean association logs once the document state is moved to completed
 			//clean completed document state files and orchestration dirs. Takes care of only files generated by association in the folder
 			go assocBookkeeping.DeleteOldOrchestrationDirectories(log,
@@ -593,6 +899,7 @@ func (r *Processor) listenToResponses() {
 				r.context.AppConfig().Ssm.AssociationLogsRetentionDurationHours)
 			//TODO move this part to service
 			schedulemanager.UpdateNextScheduledDate(log, res.AssociationID)
+			persistSchedules(log, instanceID)
 			signal.ExecuteAssociation(log)
 
 		}
@@ -47,6 +47,8 @@ func TestParseAssociationWithAssociationVersion1_2(t *testing.T) {
 		context: context,
 	}
 	sys = &systemStub{}
+	assocStore = &scheduleStoreStub{}
+	runHistory = &runHistoryStub{}
 
 	sampleFile := readFile(FILE_VERSION_1_2)
 
@@ -120,6 +122,8 @@ func TestParseAssociationWithAssociationVersion2_0(t *testing.T) {
 		context: context,
 	}
 	sys = &systemStub{}
+	assocStore = &scheduleStoreStub{}
+	runHistory = &runHistoryStub{}
 
 	sampleFile := readFile(FILE_VERSION_2_0)
 
@@ -210,6 +214,8 @@ func TestParseAssociationWithAssociationVersion2_0_StringMapParams(t *testing.T)
 		context: context,
 	}
 	sys = &systemStub{}
+	assocStore = &scheduleStoreStub{}
+	runHistory = &runHistoryStub{}
 
 	sampleFile := readFile(FILE_PARAM_2_0)
 
@@ -16,9 +16,12 @@ package processor
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/association/model"
 	"github.com/aws/amazon-ssm-agent/agent/association/schedulemanager"
 	"github.com/aws/amazon-ssm-agent/agent/association/service"
@@ -105,7 +108,9 @@ func TestProcessAssociationUnableToLoadAssociationDetail(t *testing.T) {
 		mock.AnythingOfType("string"),
 		mock.AnythingOfType("string"),
 		mock.AnythingOfType("*ssm.InstanceAssociationExecutionResult"))
+	svcMock.On("SendQueuedStatusUpdates", mock.AnythingOfType("*log.Mock"), mock.AnythingOfType("string"))
 	complianceUploader.On("CreateNewServiceIfUnHealthy", mock.AnythingOfType("*log.Mock"))
+	complianceUploader.On("SendQueuedCompliance", mock.AnythingOfType("*log.Mock"), mock.AnythingOfType("string"))
 	complianceUploader.On(
 		"UpdateAssociationCompliance",
 		mock.AnythingOfType("string"),
@@ -151,6 +156,7 @@ func TestProcessAssociationUnableToParseAssociation(t *testing.T) {
 	processorMock.On("InitialProcessing").Return(nil)
 
 	complianceUploader.On("CreateNewServiceIfUnHealthy", mock.AnythingOfType("*log.Mock"))
+	complianceUploader.On("SendQueuedCompliance", mock.AnythingOfType("*log.Mock"), mock.AnythingOfType("string"))
 
 	// Act
 	processor.InitializeAssociationProcessor()
@@ -180,6 +186,7 @@ func mockService(svcMock *service.AssociationServiceMock, assocRawData []*model.
 		mock.AnythingOfType("string"),
 		mock.AnythingOfType("string"),
 		mock.AnythingOfType("*ssm.InstanceAssociationExecutionResult"))
+	svcMock.On("SendQueuedStatusUpdates", mock.AnythingOfType("*log.Mock"), mock.AnythingOfType("string"))
 }
 
 func TestProcessAssociationSuccessful(t *testing.T) {
@@ -212,6 +219,7 @@ func TestProcessAssociationSuccessful(t *testing.T) {
 	processorMock.On("Start").Return(ch, nil)
 	processorMock.On("InitialProcessing").Return(nil)
 	complianceUploader.On("CreateNewServiceIfUnHealthy", mock.AnythingOfType("*log.Mock"))
+	complianceUploader.On("SendQueuedCompliance", mock.AnythingOfType("*log.Mock"), mock.AnythingOfType("string"))
 
 	// Act
 	processor.InitializeAssociationProcessor()
@@ -227,6 +235,221 @@ func TestProcessAssociationSuccessful(t *testing.T) {
 	assert.True(t, complianceUploader.AssertNumberOfCalls(t, "UpdateAssociationCompliance", 0))
 }
 
+func TestProcessAssociationServiceUnreachableSchedulesPersistedAssociations(t *testing.T) {
+	processor := createProcessor()
+	svcMock := service.NewMockDefault()
+	persisted := createAssociationRawData()
+	persisted[0].Document = aws.String("{}")
+	sys = &systemStub{}
+	complianceUploader := complianceUploader.NewMockDefault()
+
+	processor.assocSvc = svcMock
+	processor.complianceUploader = complianceUploader
+	assocStore = &scheduleStoreStub{persisted: persisted}
+	schedulemanager.Refresh(log.NewMockLog(), []*model.InstanceAssociation{})
+
+	svcMock.On("CreateNewServiceIfUnHealthy", mock.AnythingOfType("*log.Mock"))
+	svcMock.On(
+		"ListInstanceAssociations",
+		mock.AnythingOfType("*log.Mock"),
+		mock.AnythingOfType("string")).Return([]*model.InstanceAssociation{}, errors.New("endpoint unreachable"))
+	complianceUploader.On("CreateNewServiceIfUnHealthy", mock.AnythingOfType("*log.Mock"))
+
+	processorMock := &processormock.MockedProcessor{}
+	processor.proc = processorMock
+	ch := make(chan contracts.DocumentResult)
+	processorMock.On("Start").Return(ch, nil)
+	processorMock.On("InitialProcessing").Return(nil)
+
+	processor.InitializeAssociationProcessor()
+	processor.ProcessAssociation()
+	close(ch)
+
+	schedules := schedulemanager.Schedules()
+	assert.Equal(t, 1, len(schedules), "saved associations are scheduled when the service is unreachable")
+	assert.Equal(t, "Id-Test", *schedules[0].Association.AssociationId)
+	assert.NotNil(t, schedules[0].ParsedExpression)
+	assert.True(t, svcMock.AssertNumberOfCalls(t, "LoadAssociationDetail", 0))
+
+	// the scheduled associations are kept as long as the service stays unreachable
+	assocStore = &scheduleStoreStub{}
+	processor.ProcessAssociation()
+	assert.Equal(t, 1, len(schedulemanager.Schedules()))
+	schedulemanager.Refresh(log.NewMockLog(), []*model.InstanceAssociation{})
+}
+
+func TestProcessAssociationSchedulesLocalAssociations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localassociations")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	processor := createProcessor()
+	config := appconfig.SsmagentConfig{}
+	config.Ssm.LocalAssociationsDirectory = dir
+	ctx := new(context.Mock)
+	ctx.On("Log").Return(log.NewMockLog())
+	ctx.On("AppConfig").Return(config)
+	processor.context = ctx
+
+	svcMock := service.NewMockDefault()
+	sys = &systemStub{}
+	complianceUploader := complianceUploader.NewMockDefault()
+	processor.assocSvc = svcMock
+	processor.complianceUploader = complianceUploader
+	schedulemanager.Refresh(log.NewMockLog(), []*model.InstanceAssociation{})
+
+	svcMock.On("CreateNewServiceIfUnHealthy", mock.AnythingOfType("*log.Mock"))
+	svcMock.On(
+		"ListInstanceAssociations",
+		mock.AnythingOfType("*log.Mock"),
+		mock.AnythingOfType("string")).Return([]*model.InstanceAssociation{}, errors.New("instance is not registered"))
+	complianceUploader.On("CreateNewServiceIfUnHealthy", mock.AnythingOfType("*log.Mock"))
+
+	processorMock := &processormock.MockedProcessor{}
+	processor.proc = processorMock
+	ch := make(chan contracts.DocumentResult)
+	processorMock.On("Start").Return(ch, nil)
+	processorMock.On("InitialProcessing").Return(nil)
+	processor.InitializeAssociationProcessor()
+	defer processor.localWatcher.Stop()
+
+	localAssociation := func(checksum string) *model.InstanceAssociation {
+		return &model.InstanceAssociation{
+			Association: &ssm.InstanceAssociationSummary{
+				Name:               aws.String("Baseline"),
+				DocumentVersion:    aws.String("local"),
+				AssociationId:      aws.String("local-baseline"),
+				InstanceId:         aws.String("i-123"),
+				Checksum:           aws.String(checksum),
+				DetailedStatus:     aws.String(contracts.AssociationStatusAssociated),
+				ScheduleExpression: aws.String("rate(30 minutes)"),
+			},
+			Document: aws.String("{}"),
+		}
+	}
+	localAssocLoader = &localAssociationLoaderStub{local: []*model.InstanceAssociation{localAssociation("v1")}}
+	defer func() { localAssocLoader = &localAssociationLoaderImp{} }()
+
+	processor.ProcessAssociation()
+	schedules := schedulemanager.Schedules()
+	assert.Equal(t, 1, len(schedules), "local associations are scheduled when the instance is not registered")
+	assert.Equal(t, "local-baseline", *schedules[0].Association.AssociationId)
+	assert.NotNil(t, schedules[0].ParsedExpression)
+
+	// an unchanged definition keeps its last execution
+	schedulemanager.UpdateNextScheduledDate(log.NewMockLog(), "local-baseline")
+	localAssocLoader = &localAssociationLoaderStub{local: []*model.InstanceAssociation{localAssociation("v1")}}
+	processor.reloadLocalAssociations()
+	schedules = schedulemanager.Schedules()
+	assert.Equal(t, 1, len(schedules))
+	assert.NotNil(t, schedules[0].Association.LastExecutionDate)
+
+	// a changed definition is scheduled as a new association
+	localAssocLoader = &localAssociationLoaderStub{local: []*model.InstanceAssociation{localAssociation("v2")}}
+	processor.reloadLocalAssociations()
+	schedules = schedulemanager.Schedules()
+	assert.Equal(t, 1, len(schedules))
+	assert.Equal(t, "v2", *schedules[0].Association.Checksum)
+	assert.Nil(t, schedules[0].Association.LastExecutionDate)
+
+	// a removed definition is no longer scheduled
+	localAssocLoader = &localAssociationLoaderStub{}
+	processor.reloadLocalAssociations()
+	assert.Equal(t, 0, len(schedulemanager.Schedules()))
+	assert.True(t, svcMock.AssertNumberOfCalls(t, "LoadAssociationDetail", 0))
+	close(ch)
+}
+
+func TestProcessAssociationAfterRestartKeepsLocalAssociationsStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localassociations")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	processor := createProcessor()
+	processor.onBoot = false
+	config := appconfig.SsmagentConfig{}
+	config.Ssm.LocalAssociationsDirectory = dir
+	ctx := new(context.Mock)
+	ctx.On("Log").Return(log.NewMockLog())
+	ctx.On("AppConfig").Return(config)
+	processor.context = ctx
+
+	svcMock := service.NewMockDefault()
+	sys = &systemStub{}
+	complianceUploader := complianceUploader.NewMockDefault()
+	processor.assocSvc = svcMock
+	processor.complianceUploader = complianceUploader
+	schedulemanager.Refresh(log.NewMockLog(), []*model.InstanceAssociation{})
+
+	svcMock.On("CreateNewServiceIfUnHealthy", mock.AnythingOfType("*log.Mock"))
+	svcMock.On(
+		"ListInstanceAssociations",
+		mock.AnythingOfType("*log.Mock"),
+		mock.AnythingOfType("string")).Return([]*model.InstanceAssociation{}, nil)
+	svcMock.On("SendQueuedStatusUpdates", mock.AnythingOfType("*log.Mock"), mock.AnythingOfType("string"))
+	complianceUploader.On("CreateNewServiceIfUnHealthy", mock.AnythingOfType("*log.Mock"))
+	complianceUploader.On("SendQueuedCompliance", mock.AnythingOfType("*log.Mock"), mock.AnythingOfType("string"))
+
+	processorMock := &processormock.MockedProcessor{}
+	processor.proc = processorMock
+	ch := make(chan contracts.DocumentResult)
+	processorMock.On("Start").Return(ch, nil)
+	processorMock.On("InitialProcessing").Return(nil)
+	processor.InitializeAssociationProcessor()
+	defer processor.localWatcher.Stop()
+
+	localAssociation := func(id string, scheduleExpression *string) *model.InstanceAssociation {
+		return &model.InstanceAssociation{
+			Association: &ssm.InstanceAssociationSummary{
+				Name:               aws.String("Baseline"),
+				DocumentVersion:    aws.String("local"),
+				AssociationId:      aws.String(id),
+				InstanceId:         aws.String("i-123"),
+				Checksum:           aws.String("v1"),
+				DetailedStatus:     aws.String(contracts.AssociationStatusAssociated),
+				ScheduleExpression: scheduleExpression,
+			},
+			Document:   aws.String("{}"),
+			CreateDate: time.Now().UTC(),
+		}
+	}
+	// the definitions are read again from disk as new associations
+	localAssocLoader = &localAssociationLoaderStub{local: []*model.InstanceAssociation{
+		localAssociation("local-scheduled", aws.String("rate(30 minutes)")),
+		localAssociation("local-once", nil),
+	}}
+	defer func() { localAssocLoader = &localAssociationLoaderImp{} }()
+
+	// the agent saved them after they ran, before it restarted
+	lastExecutionDate := time.Now().UTC().Add(-time.Minute)
+	scheduled := localAssociation("local-scheduled", aws.String("rate(30 minutes)"))
+	once := localAssociation("local-once", nil)
+	for _, assoc := range []*model.InstanceAssociation{scheduled, once} {
+		assoc.Association.DetailedStatus = aws.String(contracts.AssociationStatusSuccess)
+		assoc.Association.LastExecutionDate = aws.Time(lastExecutionDate)
+		assoc.CreateDate = lastExecutionDate.Add(-time.Hour)
+	}
+	assocStore = &scheduleStoreStub{persisted: []*model.InstanceAssociation{scheduled, once}}
+	defer func() { assocStore = &scheduleStoreStub{} }()
+
+	processor.ProcessAssociation()
+	close(ch)
+
+	schedules := schedulemanager.Schedules()
+	assert.Equal(t, 2, len(schedules))
+	for _, assoc := range schedules {
+		assert.Equal(t, contracts.AssociationStatusSuccess, *assoc.Association.DetailedStatus)
+		assert.Equal(t, lastExecutionDate, *assoc.Association.LastExecutionDate)
+		switch *assoc.Association.AssociationId {
+		case "local-scheduled":
+			assert.True(t, assoc.NextScheduledDate.After(lastExecutionDate.Add(29*time.Minute)), "the scheduled association waits for its next schedule")
+		case "local-once":
+			assert.Nil(t, assoc.NextScheduledDate, "the association which ran once does not run again")
+		}
+	}
+	schedulemanager.Refresh(log.NewMockLog(), []*model.InstanceAssociation{})
+}
+
 //make sure this operation is thread safe
 func TestUpdatePluginAssociationInstances(t *testing.T) {
 	testAssociationID := "testAssociationID"
@@ -295,6 +518,7 @@ func mockParser(parserMock *parserMock, payload *messageContracts.SendCommandPay
 func createProcessor() *Processor {
 	processor := Processor{}
 	processor.context = context.NewMockDefault()
+	assocStore = &scheduleStoreStub{}
 	return &processor
 }
 
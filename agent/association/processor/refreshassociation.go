@@ -78,6 +78,9 @@ func (p *Processor) refreshAssociation(log log.T, associationIds []string, orche
 	var instanceID string
 	associations := []*model.InstanceAssociation{}
 
+	refreshLock.Lock()
+	defer refreshLock.Unlock()
+
 	if instanceID, err = platform.InstanceID(); err != nil {
 		out.MarkAsFailed(fmt.Errorf("failed to load instance ID, %v", err))
 		return
@@ -163,7 +166,16 @@ func (p *Processor) refreshAssociation(log log.T, associationIds []string, orche
 		}
 	}
 
+	for _, assoc := range p.loadLocalAssociations(log, instanceID, schedulemanager.Schedules()) {
+		if (applyAll || isAssociationQualifiedToRunNow(associationIds, assoc)) &&
+			!schedulemanager.IsAssociationInProgress(*assoc.Association.AssociationId) {
+			assoc.Association.DetailedStatus = aws.String(contracts.AssociationStatusPending)
+		}
+		associations = append(associations, assoc)
+	}
+
 	schedulemanager.Refresh(log, associations)
+	persistSchedules(log, instanceID)
 
 	if applyAll {
 		out.AppendInfo("All associations have been requested to execute immediately")
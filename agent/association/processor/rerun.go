@@ -0,0 +1,115 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/association/localassociation"
+	"github.com/aws/amazon-ssm-agent/agent/association/model"
+	"github.com/aws/amazon-ssm-agent/agent/association/runhistory"
+	"github.com/aws/amazon-ssm-agent/agent/association/schedulemanager"
+	"github.com/aws/amazon-ssm-agent/agent/association/schedulemanager/signal"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/twinj/uuid"
+)
+
+// watchRerunRequests applies the rerun requests of ssm-cli as soon as they are made
+func (p *Processor) watchRerunRequests(log log.T, instanceID string) {
+	dir := runhistory.RerunDir(instanceID)
+	if err := fileutil.MakeDirs(dir); err != nil {
+		log.Errorf("Unable to create rerun requests directory %v, requests will be applied at the next poll, %v", dir, err)
+		return
+	}
+
+	p.rerunWatcher = localassociation.NewWatcher(log, dir, p.rerunRequestedAssociations)
+	if err := p.rerunWatcher.Start(); err != nil {
+		log.Errorf("Unable to watch rerun requests directory %v, requests will be applied at the next poll, %v", dir, err)
+	}
+}
+
+// rerunRequestedAssociations runs the associations for which a rerun was requested
+func (p *Processor) rerunRequestedAssociations() {
+	log := p.context.Log()
+
+	instanceID, err := sys.InstanceID()
+	if err != nil {
+		log.Error("Unable to retrieve instance id", err)
+		return
+	}
+
+	refreshLock.Lock()
+	defer refreshLock.Unlock()
+	if p.applyRerunRequests(log, instanceID) > 0 {
+		signal.ExecuteAssociation(log)
+	}
+}
+
+// applyRerunRequests schedules the requested reruns with the parameters of the repeated runs and returns their number
+func (p *Processor) applyRerunRequests(log log.T, instanceID string) (applied int) {
+	for _, request := range runHistory.TakeRerunRequests(log, instanceID) {
+		run, found := runHistory.FindRun(instanceID, request.AssociationID, request.RunID)
+		if !found {
+			log.Errorf("Unable to rerun association %v, run %v is not in its history", request.AssociationID, request.RunID)
+			continue
+		}
+
+		if !schedulemanager.RerunAssociation(log, request.AssociationID, &model.Rerun{RunID: run.RunID, Parameters: run.Parameters}) {
+			log.Errorf("Unable to rerun association %v, it is not associated with this instance anymore", request.AssociationID)
+			continue
+		}
+		applied++
+	}
+	return applied
+}
+
+// rerunAssociation returns the association to run for the rerun, with the parameters of the repeated run.
+// The values redacted from the run history are taken from the scheduled association.
+func rerunAssociation(scheduledAssociation *model.InstanceAssociation, rerun *model.Rerun) *model.InstanceAssociation {
+	assoc := *scheduledAssociation
+	summary := *scheduledAssociation.Association
+	if rerun.Parameters != nil {
+		summary.Parameters = make(map[string][]*string, len(rerun.Parameters))
+		for name, values := range rerun.Parameters {
+			if runhistory.IsRedacted(values) {
+				if values, exists := scheduledAssociation.Association.Parameters[name]; exists {
+					summary.Parameters[name] = values
+				}
+				continue
+			}
+			summary.Parameters[name] = values
+		}
+	}
+	assoc.Association = &summary
+	return &assoc
+}
+
+// recordRun adds the completed run of the association to its run history
+func (p *Processor) recordRun(log log.T, instanceID string, res contracts.DocumentResult, assoc runningAssociation) {
+	if assoc.runID == "" {
+		// the association was submitted before the agent restarted
+		assoc.runID = newRunID()
+	}
+
+	run := runhistory.NewRun(res.AssociationID, assoc.runID, assoc.parameters, assoc.startTime, res)
+	run.RerunOf = assoc.rerunOf
+	if err := runHistory.Append(log, instanceID, run); err != nil {
+		log.Errorf("Unable to record run %v of association %v, %v", run.RunID, res.AssociationID, err)
+	}
+}
+
+// newRunID returns a unique id for a run of an association
+func newRunID() string {
+	return uuid.NewV4().String()
+}
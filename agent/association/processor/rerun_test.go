@@ -0,0 +1,105 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/association/model"
+	"github.com/aws/amazon-ssm-agent/agent/association/runhistory"
+	"github.com/aws/amazon-ssm-agent/agent/association/schedulemanager"
+	"github.com/aws/amazon-ssm-agent/agent/association/service"
+	complianceUploader "github.com/aws/amazon-ssm-agent/agent/compliance/uploader"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/history"
+	processormock "github.com/aws/amazon-ssm-agent/agent/framework/processor/mock"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	messageContracts "github.com/aws/amazon-ssm-agent/agent/runcommand/contracts"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRerunRequestedAssociationUsesParametersOfRun(t *testing.T) {
+	processor := createProcessor()
+	svcMock := service.NewMockDefault()
+	processorMock := &processormock.MockedProcessor{}
+	parserMock := &parserMock{}
+	historyStub := &runHistoryStub{
+		runs: []runhistory.Run{
+			{AssociationID: "assoc-1", RunID: "run-1", Parameters: map[string][]*string{
+				"commands": {aws.String("previous")},
+				"password": {aws.String(history.RedactedValue)},
+			}},
+		},
+		requests: []runhistory.RerunRequest{
+			{AssociationID: "assoc-1", RunID: "run-1"},
+			{AssociationID: "assoc-1", RunID: "unknown-run"},
+			{AssociationID: "unknown-association", RunID: "run-1"},
+		},
+	}
+	processor.assocSvc = svcMock
+	processor.complianceUploader = complianceUploader.NewMockDefault()
+	processor.proc = processorMock
+	assocParser = parserMock
+	runHistory = historyStub
+	sys = &systemStub{}
+	defer func() {
+		assocParser = &assocParserService{}
+		runHistory = &runHistoryStoreImp{}
+	}()
+
+	// the association is not due before an hour
+	assoc := createScheduledAssociation("assoc-1", time.Now().UTC().Add(time.Hour))
+	assoc.Association.Parameters = map[string][]*string{"commands": {aws.String("current")}, "password": {aws.String("s3cr3t")}}
+	schedulemanager.Refresh(log.NewMockLog(), []*model.InstanceAssociation{})
+	schedulemanager.Refresh(log.NewMockLog(), []*model.InstanceAssociation{assoc})
+	assoc.NextScheduledDate = aws.Time(time.Now().UTC().Add(time.Hour))
+	defer schedulemanager.Refresh(log.NewMockLog(), []*model.InstanceAssociation{})
+
+	assert.Equal(t, 1, processor.applyRerunRequests(log.NewMockLog(), "i-123"))
+	assert.Empty(t, historyStub.requests)
+
+	svcMock.On("UpdateInstanceAssociationStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	usesPreviousParameters := mock.MatchedBy(func(rawData *model.InstanceAssociation) bool {
+		// the redacted values are taken from the association
+		return *rawData.Association.Parameters["commands"][0] == "previous" &&
+			*rawData.Association.Parameters["password"][0] == "s3cr3t"
+	})
+	parserMock.On("ParseDocumentForPayload", mock.Anything, usesPreviousParameters).Return(&messageContracts.SendCommandPayload{}, nil)
+	docState := contracts.DocumentState{}
+	docState.DocumentInformation.AssociationID = "assoc-1"
+	parserMock.On("InitializeDocumentState", mock.Anything, mock.Anything, usesPreviousParameters).Return(docState, nil)
+	processorMock.On("Submit", mock.Anything)
+
+	processor.runScheduledAssociation(log.NewMockLog())
+
+	processorMock.AssertNumberOfCalls(t, "Submit", 1)
+	assert.Nil(t, assoc.Rerun, "the rerun happens once")
+	assert.Equal(t, "current", *assoc.Association.Parameters["commands"][0], "the association keeps its parameters")
+
+	running, _ := processor.finishRunningAssociation("assoc-1")
+	processor.recordRun(log.NewMockLog(), "i-123", contracts.DocumentResult{AssociationID: "assoc-1", Status: contracts.ResultStatusSuccess}, running)
+
+	assert.Len(t, historyStub.runs, 2)
+	rerun := historyStub.runs[1]
+	assert.Equal(t, "run-1", rerun.RerunOf)
+	assert.NotEmpty(t, rerun.RunID)
+	assert.NotEqual(t, "run-1", rerun.RunID)
+	assert.Equal(t, "previous", *rerun.Parameters["commands"][0])
+	assert.Equal(t, history.RedactedValue, *rerun.Parameters["password"][0])
+	assert.Equal(t, contracts.ResultStatusSuccess, rerun.Status)
+}
@@ -16,6 +16,7 @@ package processor
 
 import (
 	"github.com/aws/amazon-ssm-agent/agent/association/model"
+	"github.com/aws/amazon-ssm-agent/agent/association/runhistory"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
@@ -67,3 +68,54 @@ func (m *parserMock) InitializeDocumentState(
 
 	return args.Get(0).(contracts.DocumentState), args.Error(1)
 }
+
+type scheduleStoreStub struct {
+	persisted []*model.InstanceAssociation
+}
+
+// Persist mocks implementation for Persist
+func (m *scheduleStoreStub) Persist(log log.T, instanceID string) error {
+	return nil
+}
+
+// LoadPersisted mocks implementation for LoadPersisted
+func (m *scheduleStoreStub) LoadPersisted(log log.T, instanceID string) ([]*model.InstanceAssociation, error) {
+	return m.persisted, nil
+}
+
+type localAssociationLoaderStub struct {
+	local []*model.InstanceAssociation
+}
+
+// Load mocks implementation for Load
+func (m *localAssociationLoaderStub) Load(log log.T, dir string, instanceID string) ([]*model.InstanceAssociation, error) {
+	return m.local, nil
+}
+
+type runHistoryStub struct {
+	runs     []runhistory.Run
+	requests []runhistory.RerunRequest
+}
+
+// Append mocks implementation for Append
+func (m *runHistoryStub) Append(log log.T, instanceID string, run runhistory.Run) error {
+	m.runs = append(m.runs, run)
+	return nil
+}
+
+// FindRun mocks implementation for FindRun
+func (m *runHistoryStub) FindRun(instanceID string, associationID string, runID string) (runhistory.Run, bool) {
+	for _, run := range m.runs {
+		if run.AssociationID == associationID && run.RunID == runID {
+			return run, true
+		}
+	}
+	return runhistory.Run{}, false
+}
+
+// TakeRerunRequests mocks implementation for TakeRerunRequests
+func (m *runHistoryStub) TakeRerunRequests(log log.T, instanceID string) []runhistory.RerunRequest {
+	requests := m.requests
+	m.requests = nil
+	return requests
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"fmt"
+	"time"
+)
+
+// runningAssociation keeps track of an association submitted to the association workers
+type runningAssociation struct {
+	startTime time.Time
+	queueWait time.Duration
+	// runID, parameters and rerunOf describe the run for the run history
+	runID      string
+	parameters map[string][]*string
+	rerunOf    string
+}
+
+// associationWorkersLimit returns the number of associations that can run at the same time
+func (p *Processor) associationWorkersLimit() int {
+	if p.workersLimit < documentWorkersLimit {
+		return documentWorkersLimit
+	}
+	return p.workersLimit
+}
+
+// startRunningAssociation records that the association was submitted after waiting for a worker
+func (p *Processor) startRunningAssociation(associationID string, assoc runningAssociation) {
+	p.runningLock.Lock()
+	defer p.runningLock.Unlock()
+
+	if p.running == nil {
+		p.running = make(map[string]runningAssociation)
+	}
+	assoc.startTime = time.Now()
+	p.running[associationID] = assoc
+}
+
+// finishRunningAssociation records that the association completed and returns its running details
+func (p *Processor) finishRunningAssociation(associationID string) (assoc runningAssociation, running bool) {
+	p.runningLock.Lock()
+	defer p.runningLock.Unlock()
+
+	if assoc, running = p.running[associationID]; running {
+		delete(p.running, associationID)
+	}
+	return assoc, running
+}
+
+// isAssociationRunning returns true if the association is submitted and not completed yet
+func (p *Processor) isAssociationRunning(associationID string) bool {
+	_, running := p.associationStartTime(associationID)
+	return running
+}
+
+// associationStartTime returns when the running association was submitted
+func (p *Processor) associationStartTime(associationID string) (time.Time, bool) {
+	p.runningLock.Lock()
+	defer p.runningLock.Unlock()
+
+	assoc, running := p.running[associationID]
+	return assoc.startTime, running
+}
+
+// runningAssociations returns the number of associations using a worker
+func (p *Processor) runningAssociations() int {
+	p.runningLock.Lock()
+	defer p.runningLock.Unlock()
+
+	return len(p.running)
+}
+
+// queueWaitMessage describes how long an association waited for a worker
+func queueWaitMessage(queueWait time.Duration) string {
+	if queueWait < 0 {
+		queueWait = 0
+	}
+	return fmt.Sprintf("Waited %v for an association worker.", queueWait.Round(time.Second))
+}
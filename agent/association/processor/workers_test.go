@@ -0,0 +1,238 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/driftdetector"
+	"github.com/aws/amazon-ssm-agent/agent/association/model"
+	"github.com/aws/amazon-ssm-agent/agent/association/schedulemanager"
+	"github.com/aws/amazon-ssm-agent/agent/association/service"
+	complianceUploader "github.com/aws/amazon-ssm-agent/agent/compliance/uploader"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	processormock "github.com/aws/amazon-ssm-agent/agent/framework/processor/mock"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/maintenancewindow"
+	messageContracts "github.com/aws/amazon-ssm-agent/agent/runcommand/contracts"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func createScheduledAssociation(associationID string, nextScheduledDate time.Time) *model.InstanceAssociation {
+	return &model.InstanceAssociation{
+		Association: &ssm.InstanceAssociationSummary{
+			Name:               aws.String("Test-Document"),
+			DocumentVersion:    aws.String("1"),
+			AssociationId:      aws.String(associationID),
+			InstanceId:         aws.String("i-123"),
+			LastExecutionDate:  aws.Time(nextScheduledDate),
+			ScheduleExpression: aws.String("rate(30 minutes)"),
+		},
+		NextScheduledDate: aws.Time(nextScheduledDate),
+	}
+}
+
+func TestRunScheduledAssociationUsesAvailableWorkers(t *testing.T) {
+	processor := createProcessor()
+	processor.workersLimit = 2
+	svcMock := service.NewMockDefault()
+	complianceMock := complianceUploader.NewMockDefault()
+	processorMock := &processormock.MockedProcessor{}
+	parserMock := &parserMock{}
+	processor.assocSvc = svcMock
+	processor.complianceUploader = complianceMock
+	processor.proc = processorMock
+	assocParser = parserMock
+	sys = &systemStub{}
+	defer func() { assocParser = &assocParserService{} }()
+
+	now := time.Now().UTC()
+	// the association refreshed last is the one waiting the longest
+	assocs := []*model.InstanceAssociation{
+		createScheduledAssociation("assoc-recent", now.Add(-1*time.Minute)),
+		createScheduledAssociation("assoc-later", now.Add(time.Hour)),
+		createScheduledAssociation("assoc-oldest", now.Add(-10*time.Minute)),
+		createScheduledAssociation("assoc-old", now.Add(-5*time.Minute)),
+	}
+	schedulemanager.Refresh(log.NewMockLog(), []*model.InstanceAssociation{})
+	schedulemanager.Refresh(log.NewMockLog(), assocs)
+	for _, assoc := range assocs {
+		assoc.NextScheduledDate = assoc.Association.LastExecutionDate
+	}
+	defer schedulemanager.Refresh(log.NewMockLog(), []*model.InstanceAssociation{})
+
+	svcMock.On("UpdateInstanceAssociationStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	parserMock.On("ParseDocumentForPayload", mock.Anything, mock.Anything).Return(&messageContracts.SendCommandPayload{}, nil)
+	for _, assoc := range assocs {
+		docState := contracts.DocumentState{}
+		docState.DocumentInformation.AssociationID = *assoc.Association.AssociationId
+		parserMock.On("InitializeDocumentState", mock.Anything, mock.Anything, assoc).Return(docState, nil)
+	}
+	processorMock.On("Submit", mock.Anything)
+
+	processor.runScheduledAssociation(log.NewMockLog())
+
+	processorMock.AssertNumberOfCalls(t, "Submit", 2)
+	assert.Equal(t, "assoc-oldest", processorMock.Calls[0].Arguments.Get(0).(contracts.DocumentState).DocumentInformation.AssociationID)
+	assert.Equal(t, "assoc-old", processorMock.Calls[1].Arguments.Get(0).(contracts.DocumentState).DocumentInformation.AssociationID)
+	assert.Equal(t, 2, processor.runningAssociations())
+
+	// running associations don't overlap themselves and the waiting one gets the freed worker
+	finished, running := processor.finishRunningAssociation("assoc-oldest")
+	assert.True(t, running)
+	assert.True(t, finished.queueWait >= 10*time.Minute, "queue wait is measured from the scheduled date")
+	schedulemanager.UpdateNextScheduledDate(log.NewMockLog(), "assoc-oldest")
+	processor.runScheduledAssociation(log.NewMockLog())
+
+	processorMock.AssertNumberOfCalls(t, "Submit", 3)
+	assert.Equal(t, "assoc-recent", processorMock.Calls[2].Arguments.Get(0).(contracts.DocumentState).DocumentInformation.AssociationID)
+	assert.Equal(t, 2, processor.runningAssociations())
+	assert.True(t, processor.isAssociationRunning("assoc-old"))
+	assert.False(t, processor.isAssociationRunning("assoc-later"))
+}
+
+func TestRunScheduledAssociationOutsideMaintenanceWindow(t *testing.T) {
+	processor := createProcessor()
+	svcMock := service.NewMockDefault()
+	complianceMock := complianceUploader.NewMockDefault()
+	processorMock := &processormock.MockedProcessor{}
+	processor.assocSvc = svcMock
+	processor.complianceUploader = complianceMock
+	processor.proc = processorMock
+	sys = &systemStub{}
+
+	now := time.Now().UTC()
+	processor.maintenance = maintenancewindow.NewPolicy(log.NewMockLog(), appconfig.MaintenanceCfg{
+		Blackouts: []appconfig.TimeWindowCfg{{
+			Name:  "freeze",
+			Start: now.Add(-time.Hour).Format(time.RFC3339),
+			End:   now.Add(time.Hour).Format(time.RFC3339),
+		}},
+	})
+
+	// the frequent association runs again by its schedule before the end of the blackout, the daily one is deferred
+	frequent := createScheduledAssociation("assoc-frequent", now.Add(-time.Minute))
+	daily := createScheduledAssociation("assoc-daily", now.Add(-time.Minute))
+	daily.Association.ScheduleExpression = aws.String("rate(1 day)")
+	assocs := []*model.InstanceAssociation{frequent, daily}
+	for _, assoc := range assocs {
+		assert.NoError(t, assoc.ParseExpression(log.NewMockLog()))
+	}
+	schedulemanager.Refresh(log.NewMockLog(), assocs)
+	for _, assoc := range assocs {
+		assoc.NextScheduledDate = aws.Time(now.Add(-time.Minute))
+	}
+	defer schedulemanager.Refresh(log.NewMockLog(), []*model.InstanceAssociation{})
+
+	svcMock.On("UpdateInstanceAssociationStatus", mock.Anything, "assoc-frequent", mock.Anything, mock.Anything, mock.Anything)
+	complianceMock.On("UpdateSkippedAssociationCompliance", "assoc-frequent", mock.Anything, "Test-Document", "1",
+		mock.AnythingOfType("string"), mock.Anything).Return(nil)
+
+	processor.runScheduledAssociation(log.NewMockLog())
+
+	processorMock.AssertNotCalled(t, "Submit", mock.Anything)
+	svcMock.AssertNumberOfCalls(t, "UpdateInstanceAssociationStatus", 1)
+	complianceMock.AssertNumberOfCalls(t, "UpdateSkippedAssociationCompliance", 1)
+	assert.Contains(t, complianceMock.Calls[0].Arguments.Get(4), "freeze is in effect")
+	assert.True(t, frequent.NextScheduledDate.After(now), "the skipped association waits for its next schedule")
+	assert.False(t, daily.NextScheduledDate.Before(now.Add(time.Hour).Truncate(time.Second)), "the deferred association runs after the blackout")
+	assert.True(t, daily.NextScheduledDate.Before(now.Add(2*time.Hour)))
+
+	// the deferral is kept when the associations are loaded again by the next poll
+	refreshed := createScheduledAssociation("assoc-daily", now.Add(-24*time.Hour-time.Minute))
+	refreshed.Association.ScheduleExpression = aws.String("rate(1 day)")
+	assert.NoError(t, refreshed.ParseExpression(log.NewMockLog()))
+	schedulemanager.Refresh(log.NewMockLog(), []*model.InstanceAssociation{refreshed})
+	assert.Equal(t, *daily.NextScheduledDate, *refreshed.NextScheduledDate)
+
+	// until the deferred execution is over
+	schedulemanager.UpdateNextScheduledDate(log.NewMockLog(), "assoc-daily")
+	assert.Nil(t, refreshed.DeferredUntil)
+}
+
+func TestRestorePersistedSchedules(t *testing.T) {
+	now := time.Now().UTC()
+	persisted := createScheduledAssociation("assoc-deferred", now)
+	persisted.DeferredUntil = aws.Time(now.Add(time.Hour))
+	persisted.CreateDate = now.Add(-time.Hour)
+
+	assocs := []*model.InstanceAssociation{
+		createScheduledAssociation("assoc-deferred", now),
+		createScheduledAssociation("assoc-other", now),
+	}
+	for _, assoc := range assocs {
+		assoc.CreateDate = now
+	}
+	restorePersistedSchedules(assocs, []*model.InstanceAssociation{persisted})
+
+	assert.Equal(t, now.Add(time.Hour), *assocs[0].DeferredUntil)
+	assert.Nil(t, assocs[1].DeferredUntil)
+	assert.Equal(t, now.Add(-time.Hour), assocs[0].CreateDate, "an association keeps the date it was first seen")
+	assert.Equal(t, now, assocs[1].CreateDate)
+}
+
+func TestRestartDriftDetectors(t *testing.T) {
+	processor := createProcessor()
+	complianceMock := complianceUploader.NewMockDefault()
+	parserMock := &parserMock{}
+	processor.complianceUploader = complianceMock
+	assocParser = parserMock
+	defer func() { assocParser = &assocParserService{} }()
+	driftDetector := driftdetector.GetDriftDetector()
+	defer driftDetector.ClearDriftDetectors()
+
+	now := time.Now().UTC()
+	checked := createScheduledAssociation("assoc-checked", now)
+	checked.Document = aws.String(`{"mainSteps": [{"action": "aws:checkDesiredState"}]}`)
+	notRun := createScheduledAssociation("assoc-not-run", now)
+	notRun.Document = checked.Document
+	notRun.Association.LastExecutionDate = nil
+	other := createScheduledAssociation("assoc-other", now)
+	other.Document = aws.String(`{"mainSteps": [{"action": "aws:runShellScript"}]}`)
+	schedulemanager.Refresh(log.NewMockLog(), []*model.InstanceAssociation{checked, notRun, other})
+	defer schedulemanager.Refresh(log.NewMockLog(), []*model.InstanceAssociation{})
+
+	docState := contracts.DocumentState{}
+	docState.DocumentInformation.AssociationID = "assoc-checked"
+	docState.InstancePluginsInformation = []contracts.PluginState{{
+		Name: appconfig.PluginNameAwsCheckDesiredState,
+		Configuration: contracts.Configuration{Properties: map[string]interface{}{
+			"checks": []interface{}{map[string]interface{}{"id": "motd", "type": "fileHash", "target": "/missing/motd", "expected": "abc"}},
+		}},
+	}}
+	parserMock.On("ParseDocumentForPayload", mock.Anything, checked).Return(&messageContracts.SendCommandPayload{}, nil)
+	parserMock.On("InitializeDocumentState", mock.Anything, mock.Anything, mock.Anything).Return(docState, nil)
+	complianceMock.On("UpdateDriftCompliance", "assoc-checked", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	processor.restartDriftDetectors(log.NewMockLog())
+	assert.True(t, driftDetector.IsDriftDetectorStarted("assoc-checked"))
+	assert.False(t, driftDetector.IsDriftDetectorStarted("assoc-not-run"), "the detector starts with the first execution")
+	assert.False(t, driftDetector.IsDriftDetectorStarted("assoc-other"))
+	assert.Equal(t, "", checked.DocumentID, "the scheduled association keeps its document ID")
+
+	// a started detector is not restarted by the next refresh
+	processor.restartDriftDetectors(log.NewMockLog())
+	parserMock.AssertNumberOfCalls(t, "ParseDocumentForPayload", 1)
+}
+
+func TestQueueWaitMessage(t *testing.T) {
+	assert.Equal(t, "Waited 1m30s for an association worker.", queueWaitMessage(90*time.Second+200*time.Millisecond))
+	assert.Equal(t, "Waited 0s for an association worker.", queueWaitMessage(-time.Second))
+}
@@ -0,0 +1,357 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runhistory keeps the recent runs of every association, so that failing associations can be
+// diagnosed after their orchestration directories are cleaned up, and lets ssm-cli request a run again.
+package runhistory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/history"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+const (
+	fileExtension = ".json"
+	// truncatedPrefix marks an output excerpt which only keeps the end of the output
+	truncatedPrefix = "..."
+	// minSecretLength is the length from which a redacted value is replaced in the outputs,
+	// shorter values would redact unrelated output
+	minSecretLength = 4
+)
+
+var lock sync.Mutex
+
+// Run is a completed run of an association
+type Run struct {
+	RunID           string
+	AssociationID   string
+	DocumentName    string
+	DocumentVersion string `json:",omitempty"`
+	// Parameters are the association parameters of the run, a rerun uses the same parameters.
+	// Sensitive values are redacted, a rerun takes them from the association.
+	Parameters    map[string][]*string `json:",omitempty"`
+	Status        contracts.ResultStatus
+	StartDateTime time.Time
+	EndDateTime   time.Time
+	// RerunOf is the ID of the run repeated by this run
+	RerunOf string `json:",omitempty"`
+	Steps   []Step `json:",omitempty"`
+}
+
+// Step is the outcome of a single plugin of a run
+type Step struct {
+	Name       string
+	PluginName string
+	Status     contracts.ResultStatus
+	ExitCode   int
+	Error      string `json:",omitempty"`
+	// Output and ErrorOutput keep the end of the standard output and standard error
+	Output      string `json:",omitempty"`
+	ErrorOutput string `json:",omitempty"`
+}
+
+// RerunRequest asks the agent to run an association again with the parameters of one of its runs
+type RerunRequest struct {
+	AssociationID     string
+	RunID             string
+	RequestedDateTime time.Time
+}
+
+// associationDir returns the directory of the association bookkeeping of the instance
+var associationDir = func(instanceID string) string {
+	return filepath.Join(appconfig.DefaultDataStorePath, instanceID, appconfig.AssociationRootDirName)
+}
+
+// HistoryDir returns the directory keeping the run history of the associations of the instance
+func HistoryDir(instanceID string) string {
+	return filepath.Join(associationDir(instanceID), appconfig.AssociationRunHistoryDirName)
+}
+
+// RerunDir returns the directory where ssm-cli drops the rerun requests of the instance
+func RerunDir(instanceID string) string {
+	return filepath.Join(associationDir(instanceID), appconfig.AssociationRerunDirName)
+}
+
+// fileName returns the file name for the association, association ids are safe file names
+func fileName(associationID string) string {
+	return filepath.Base(associationID) + fileExtension
+}
+
+// NewRun builds the run of the association from the result of its document.
+// Sensitive parameters are redacted, as well as their values in the outputs.
+func NewRun(associationID string, runID string, parameters map[string][]*string, startDateTime time.Time, result contracts.DocumentResult) Run {
+	redacted, secrets := redactParameters(parameters)
+	run := Run{
+		RunID:           runID,
+		AssociationID:   associationID,
+		DocumentName:    result.DocumentName,
+		DocumentVersion: result.DocumentVersion,
+		Parameters:      redacted,
+		Status:          result.Status,
+		StartDateTime:   startDateTime,
+		EndDateTime:     time.Now().UTC(),
+	}
+	if result.SkippedReason != "" {
+		run.Steps = append(run.Steps, Step{Status: contracts.ResultStatusSkipped, Error: result.SkippedReason})
+	}
+
+	for id, pluginResult := range result.PluginResults {
+		if pluginResult == nil {
+			continue
+		}
+		run.Steps = append(run.Steps, Step{
+			Name:        id,
+			PluginName:  pluginResult.PluginName,
+			Status:      pluginResult.Status,
+			ExitCode:    pluginResult.Code,
+			Error:       excerpt(redactSecrets(pluginResult.Error, secrets)),
+			Output:      excerpt(redactSecrets(pluginResult.StandardOutput, secrets)),
+			ErrorOutput: excerpt(redactSecrets(pluginResult.StandardError, secrets)),
+		})
+		if run.StartDateTime.IsZero() || (!pluginResult.StartDateTime.IsZero() && pluginResult.StartDateTime.Before(run.StartDateTime)) {
+			run.StartDateTime = pluginResult.StartDateTime
+		}
+	}
+	// plugin results come in a map, order the steps by name for a stable output
+	sort.SliceStable(run.Steps, func(i, j int) bool {
+		return run.Steps[i].Name < run.Steps[j].Name
+	})
+	return run
+}
+
+// IsRedacted returns true if the parameter values were redacted from the run
+func IsRedacted(values []*string) bool {
+	return len(values) == 1 && values[0] != nil && *values[0] == history.RedactedValue
+}
+
+// redactParameters returns a copy of the parameters where the sensitive values are redacted the same way as in the
+// document history, together with the redacted values
+func redactParameters(parameters map[string][]*string) (redacted map[string][]*string, secrets []string) {
+	if parameters == nil {
+		return nil, nil
+	}
+	values := make(map[string]interface{}, len(parameters))
+	for name, list := range parameters {
+		items := []string{}
+		for _, item := range list {
+			if item != nil {
+				items = append(items, *item)
+			}
+		}
+		values[name] = items
+	}
+
+	kept := history.RedactParameters(values)
+	redacted = make(map[string][]*string, len(parameters))
+	for name, list := range parameters {
+		if value, ok := kept[name].(string); !ok || value != history.RedactedValue {
+			redacted[name] = list
+			continue
+		}
+		redacted[name] = []*string{aws.String(history.RedactedValue)}
+		secrets = append(secrets, values[name].([]string)...)
+	}
+	return redacted, secrets
+}
+
+// redactSecrets replaces the secret values in the output, before the output is cut so that no part of them is kept
+func redactSecrets(output string, secrets []string) string {
+	for _, secret := range secrets {
+		if len(strings.TrimSpace(secret)) >= minSecretLength {
+			output = strings.Replace(output, secret, history.RedactedValue, -1)
+		}
+	}
+	return output
+}
+
+// excerpt keeps the end of the output, where the errors usually are
+func excerpt(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) <= appconfig.AssociationRunOutputExcerptLength {
+		return output
+	}
+	return truncatedPrefix + output[len(output)-appconfig.AssociationRunOutputExcerptLength:]
+}
+
+// Append adds the run to the history of its association, only the last appconfig.AssociationRunHistoryLength runs are kept
+func Append(log log.T, instanceID string, run Run) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	runs, err := load(instanceID, run.AssociationID)
+	if err != nil {
+		log.Warnf("Discarding unreadable run history of association %v, %v", run.AssociationID, err)
+		runs = nil
+	}
+	runs = append(runs, run)
+	if len(runs) > appconfig.AssociationRunHistoryLength {
+		runs = runs[len(runs)-appconfig.AssociationRunHistoryLength:]
+	}
+
+	content, err := json.Marshal(runs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal run history of association %v, %v", run.AssociationID, err)
+	}
+	return writeFile(filepath.Join(HistoryDir(instanceID), fileName(run.AssociationID)), content)
+}
+
+// Load returns the runs of the association, oldest first
+func Load(instanceID string, associationID string) ([]Run, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return load(instanceID, associationID)
+}
+
+// load reads the runs of the association, the caller holds the lock
+func load(instanceID string, associationID string) (runs []Run, err error) {
+	content, err := ioutil.ReadFile(filepath.Join(HistoryDir(instanceID), fileName(associationID)))
+	if os.IsNotExist(err) {
+		return []Run{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(content, &runs); err != nil {
+		return nil, fmt.Errorf("failed to parse run history of association %v, %v", associationID, err)
+	}
+	return runs, nil
+}
+
+// AssociationIDs returns the associations of the instance with a run history
+func AssociationIDs(instanceID string) ([]string, error) {
+	files, err := ioutil.ReadDir(HistoryDir(instanceID))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	associationIDs := []string{}
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), fileExtension) {
+			associationIDs = append(associationIDs, strings.TrimSuffix(file.Name(), fileExtension))
+		}
+	}
+	return associationIDs, nil
+}
+
+// RequestRerun asks the agent to run the association again with the parameters of the given run,
+// or of its last run when runID is empty. It returns the request.
+func RequestRerun(instanceID string, associationID string, runID string) (request RerunRequest, err error) {
+	runs, err := Load(instanceID, associationID)
+	if err != nil {
+		return request, err
+	}
+	if len(runs) == 0 {
+		return request, fmt.Errorf("association %v has no run history", associationID)
+	}
+
+	var run *Run
+	for i := len(runs) - 1; i >= 0 && run == nil; i-- {
+		if runID == "" || runs[i].RunID == runID {
+			run = &runs[i]
+		}
+	}
+	if run == nil {
+		return request, fmt.Errorf("association %v has no run %v", associationID, runID)
+	}
+
+	request = RerunRequest{
+		AssociationID:     associationID,
+		RunID:             run.RunID,
+		RequestedDateTime: time.Now().UTC(),
+	}
+	content, err := json.Marshal(request)
+	if err != nil {
+		return request, err
+	}
+	return request, writeFile(filepath.Join(RerunDir(instanceID), fileName(associationID)), content)
+}
+
+// TakeRerunRequests returns the pending rerun requests and removes them
+func TakeRerunRequests(log log.T, instanceID string) []RerunRequest {
+	dir := RerunDir(instanceID)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("Unable to read rerun requests from %v, %v", dir, err)
+		}
+		return nil
+	}
+
+	var requests []RerunRequest
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), fileExtension) {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		content, err := ioutil.ReadFile(path)
+		if removeErr := os.Remove(path); removeErr != nil {
+			log.Errorf("Unable to remove rerun request %v, %v", path, removeErr)
+			continue
+		}
+
+		var request RerunRequest
+		if err == nil {
+			err = json.Unmarshal(content, &request)
+		}
+		if err != nil || request.AssociationID == "" {
+			log.Errorf("Ignoring invalid rerun request %v, %v", path, err)
+			continue
+		}
+		requests = append(requests, request)
+	}
+	return requests
+}
+
+// FindRun returns the run of the association with the given id
+func FindRun(instanceID string, associationID string, runID string) (Run, bool) {
+	runs, err := Load(instanceID, associationID)
+	if err != nil {
+		return Run{}, false
+	}
+	for _, run := range runs {
+		if run.RunID == runID {
+			return run, true
+		}
+	}
+	return Run{}, false
+}
+
+// writeFile replaces the content of the file, writing to a temporary file first so a crash never leaves a truncated file
+func writeFile(path string, content []byte) error {
+	if err := fileutil.MakeDirs(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create directory %v, %v", filepath.Dir(path), err)
+	}
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, content, appconfig.ReadWriteAccess); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,218 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runhistory
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/history"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+const instanceID = "i-123"
+
+// useTempDir points the association bookkeeping to a temporary directory for the test
+func useTempDir(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "runhistory")
+	assert.Nil(t, err)
+	original := associationDir
+	associationDir = func(instanceID string) string {
+		return filepath.Join(dir, instanceID)
+	}
+	return func() {
+		associationDir = original
+		os.RemoveAll(dir)
+	}
+}
+
+func TestNewRun(t *testing.T) {
+	start := time.Date(2018, 5, 14, 10, 1, 2, 0, time.UTC)
+	longOutput := strings.Repeat("a", appconfig.AssociationRunOutputExcerptLength) + "the end"
+	result := contracts.DocumentResult{
+		DocumentName:    "AWS-RunShellScript",
+		DocumentVersion: "2",
+		Status:          contracts.ResultStatusFailed,
+		PluginResults: map[string]*contracts.PluginResult{
+			"second": {
+				PluginName:     "aws:runShellScript",
+				Status:         contracts.ResultStatusFailed,
+				Code:           1,
+				StandardOutput: longOutput,
+				StandardError:  "permission denied\n",
+			},
+			"first": {
+				PluginName: "aws:runShellScript",
+				Status:     contracts.ResultStatusSuccess,
+			},
+		},
+	}
+	parameters := map[string][]*string{"commands": {aws.String("ls")}}
+
+	run := NewRun("assoc-1", "run-1", parameters, start, result)
+
+	assert.Equal(t, "assoc-1", run.AssociationID)
+	assert.Equal(t, "run-1", run.RunID)
+	assert.Equal(t, "2", run.DocumentVersion)
+	assert.Equal(t, contracts.ResultStatusFailed, run.Status)
+	assert.Equal(t, parameters, run.Parameters)
+	assert.Equal(t, start, run.StartDateTime)
+	assert.False(t, run.EndDateTime.IsZero())
+	assert.Len(t, run.Steps, 2)
+	assert.Equal(t, "first", run.Steps[0].Name)
+	assert.Equal(t, "second", run.Steps[1].Name)
+	assert.Equal(t, 1, run.Steps[1].ExitCode)
+	assert.Equal(t, "permission denied", run.Steps[1].ErrorOutput)
+	assert.Len(t, run.Steps[1].Output, len(truncatedPrefix)+appconfig.AssociationRunOutputExcerptLength)
+	assert.True(t, strings.HasPrefix(run.Steps[1].Output, truncatedPrefix))
+	assert.True(t, strings.HasSuffix(run.Steps[1].Output, "the end"))
+}
+
+func TestNewRunRedactsSensitiveParameters(t *testing.T) {
+	result := contracts.DocumentResult{
+		Status: contracts.ResultStatusFailed,
+		PluginResults: map[string]*contracts.PluginResult{
+			"login": {
+				StandardOutput: "logging in with s3cr3t-value\n",
+				StandardError:  "invalid password s3cr3t-value",
+				Error:          "login failed for s3cr3t-value",
+			},
+		},
+	}
+	parameters := map[string][]*string{
+		"commands":    {aws.String("login")},
+		"dbPassword":  {aws.String("s3cr3t-value")},
+		"sourceToken": {aws.String("{{ssm-secure:token}}")},
+	}
+
+	run := NewRun("assoc-1", "run-1", parameters, time.Now(), result)
+
+	assert.Equal(t, "login", *run.Parameters["commands"][0])
+	assert.True(t, IsRedacted(run.Parameters["dbPassword"]))
+	assert.True(t, IsRedacted(run.Parameters["sourceToken"]))
+	assert.False(t, IsRedacted(run.Parameters["commands"]))
+	assert.Equal(t, "s3cr3t-value", *parameters["dbPassword"][0], "the parameters of the association are not changed")
+	for _, output := range []string{run.Steps[0].Output, run.Steps[0].ErrorOutput, run.Steps[0].Error} {
+		assert.NotContains(t, output, "s3cr3t")
+		assert.Contains(t, output, history.RedactedValue)
+	}
+}
+
+func TestNewRunSkipped(t *testing.T) {
+	result := contracts.DocumentResult{
+		DocumentName:  "AWS-RunShellScript",
+		Status:        contracts.ResultStatusSkipped,
+		SkippedReason: "blackout",
+	}
+
+	run := NewRun("assoc-1", "run-1", nil, time.Now(), result)
+
+	assert.Len(t, run.Steps, 1)
+	assert.Equal(t, "blackout", run.Steps[0].Error)
+}
+
+func TestAppendKeepsLastRuns(t *testing.T) {
+	defer useTempDir(t)()
+	logger := log.NewMockLog()
+
+	runs, err := Load(instanceID, "assoc-1")
+	assert.Nil(t, err)
+	assert.Empty(t, runs)
+
+	for i := 0; i < appconfig.AssociationRunHistoryLength+5; i++ {
+		assert.Nil(t, Append(logger, instanceID, Run{AssociationID: "assoc-1", RunID: fmt.Sprintf("run-%v", i)}))
+	}
+	assert.Nil(t, Append(logger, instanceID, Run{AssociationID: "assoc-2", RunID: "other"}))
+
+	runs, err = Load(instanceID, "assoc-1")
+	assert.Nil(t, err)
+	assert.Len(t, runs, appconfig.AssociationRunHistoryLength)
+	assert.Equal(t, "run-5", runs[0].RunID)
+	assert.Equal(t, fmt.Sprintf("run-%v", appconfig.AssociationRunHistoryLength+4), runs[len(runs)-1].RunID)
+
+	associationIDs, err := AssociationIDs(instanceID)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"assoc-1", "assoc-2"}, associationIDs)
+
+	run, found := FindRun(instanceID, "assoc-2", "other")
+	assert.True(t, found)
+	assert.Equal(t, "other", run.RunID)
+	_, found = FindRun(instanceID, "assoc-2", "run-1")
+	assert.False(t, found)
+}
+
+func TestAppendReplacesUnreadableHistory(t *testing.T) {
+	defer useTempDir(t)()
+	logger := log.NewMockLog()
+	path := filepath.Join(HistoryDir(instanceID), fileName("assoc-1"))
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0700))
+	assert.Nil(t, ioutil.WriteFile(path, []byte("{not json"), 0600))
+
+	_, err := Load(instanceID, "assoc-1")
+	assert.NotNil(t, err)
+
+	assert.Nil(t, Append(logger, instanceID, Run{AssociationID: "assoc-1", RunID: "run-1"}))
+	runs, err := Load(instanceID, "assoc-1")
+	assert.Nil(t, err)
+	assert.Len(t, runs, 1)
+}
+
+func TestRerunRequests(t *testing.T) {
+	defer useTempDir(t)()
+	logger := log.NewMockLog()
+
+	_, err := RequestRerun(instanceID, "assoc-1", "")
+	assert.NotNil(t, err, "no history to rerun")
+
+	assert.Nil(t, Append(logger, instanceID, Run{AssociationID: "assoc-1", RunID: "run-1"}))
+	assert.Nil(t, Append(logger, instanceID, Run{AssociationID: "assoc-1", RunID: "run-2"}))
+
+	_, err = RequestRerun(instanceID, "assoc-1", "unknown")
+	assert.NotNil(t, err)
+
+	request, err := RequestRerun(instanceID, "assoc-1", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "run-2", request.RunID, "the last run is repeated by default")
+
+	request, err = RequestRerun(instanceID, "assoc-1", "run-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "run-1", request.RunID)
+
+	// the last request for an association wins and requests are taken only once
+	requests := TakeRerunRequests(logger, instanceID)
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "assoc-1", requests[0].AssociationID)
+	assert.Equal(t, "run-1", requests[0].RunID)
+	assert.Empty(t, TakeRerunRequests(logger, instanceID))
+}
+
+func TestTakeRerunRequestsIgnoresInvalidRequests(t *testing.T) {
+	defer useTempDir(t)()
+	logger := log.NewMockLog()
+	assert.Nil(t, os.MkdirAll(RerunDir(instanceID), 0700))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(RerunDir(instanceID), "invalid.json"), []byte("{}"), 0600))
+
+	assert.Empty(t, TakeRerunRequests(logger, instanceID))
+	files, _ := ioutil.ReadDir(RerunDir(instanceID))
+	assert.Empty(t, files)
+}
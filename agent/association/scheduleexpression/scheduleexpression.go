@@ -29,6 +29,7 @@ import (
 const (
 	expressionTypeCron = "cron"
 	expressionTypeRate = "rate"
+	expressionTypeAt   = "at"
 )
 
 //ScheduleExpression defines operations of a valid schedule expression which association/model makes use of
@@ -36,20 +37,33 @@ type ScheduleExpression interface {
 	Next(fromTime time.Time) time.Time
 }
 
+// CreateScheduleExpression parses cron, rate and at expressions.
+// Cron expressions are evaluated in UTC unless a time zone is appended, e.g. cron(0 2 * * ? *) tz(America/New_York),
+// and can be offset by a number of days, e.g. cron(0 2 ? * TUE#2 *) offset(3) for 3 days after the second Tuesday.
+// At expressions run once, e.g. at(2018-03-11T02:30:00) tz(America/New_York).
 func CreateScheduleExpression(log log.T, scheduleExpression string) (ScheduleExpression, error) {
 
 	lowerCasedScheduledExpression := strings.ToLower(scheduleExpression)
 
 	if strings.HasPrefix(lowerCasedScheduledExpression, expressionTypeCron) {
-		err := validateCronExpression(log, scheduleExpression)
+		expression, mods, err := splitModifiers(scheduleExpression)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+
+		err = validateCronExpression(log, expression)
 		if err != nil {
 			return nil, fmt.Errorf(err.Error())
 		}
 
-		cronExpression := scheduleExpression[len(expressionTypeCron)+1 : len(scheduleExpression)-1]
+		cronExpression := expression[len(expressionTypeCron)+1 : len(expression)-1]
 		parsedCronExpression, err := cronexpr.Parse(cronExpression)
 
 		if err == nil {
+			if mods.present {
+				return &zonedExpression{schedule: parsedCronExpression, location: mods.location, offsetDays: mods.offsetDays}, nil
+			}
 			return parsedCronExpression, nil
 		} else {
 			message := fmt.Sprintf("Error %v received while parsing cron expression %v", err, scheduleExpression)
@@ -70,6 +84,15 @@ func CreateScheduleExpression(log log.T, scheduleExpression string) (ScheduleExp
 		}
 	}
 
+	if IsOneTimeExpression(scheduleExpression) {
+		parsedAtExpression, err := parseAtExpression(scheduleExpression)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		return parsedAtExpression, nil
+	}
+
 	return nil, fmt.Errorf("Unknown expression type detected in expression %v", scheduleExpression)
 }
 
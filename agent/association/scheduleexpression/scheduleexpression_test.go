@@ -124,10 +124,10 @@ func TestParseReturnsErrorWhenScheduleExpressionIsOfUnknownType(t *testing.T) {
 	logger := log.DefaultLogger()
 
 	// Act
-	parsedExpression, err := CreateScheduleExpression(logger, "at(12:00)")
+	parsedExpression, err := CreateScheduleExpression(logger, "every(12:00)")
 
 	// Assert
 	assert.Nil(t, parsedExpression)
 	assert.NotNil(t, err)
-	assert.Equal(t, "Unknown expression type detected in expression at(12:00)", err.Error())
+	assert.Equal(t, "Unknown expression type detected in expression every(12:00)", err.Error())
 }
@@ -0,0 +1,178 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package scheduleexpression
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	modifierTimeZone = "tz"
+	modifierOffset   = "offset"
+
+	// atTimeLayout is the layout of the time of an at expression, e.g. at(2018-03-11T02:30:00)
+	atTimeLayout = "2006-01-02T15:04:05"
+
+	minOffsetDays = 1
+	maxOffsetDays = 6
+)
+
+// modifierRegularExpression matches a modifier appended to an expression, e.g. cron(0 2 * * ? *) tz(America/New_York)
+var modifierRegularExpression = regexp.MustCompile("(?i)\\s+(tz|offset)\\(([^()]*)\\)$")
+
+// modifiers are the options appended to a cron or at expression
+type modifiers struct {
+	location   *time.Location
+	offsetDays int
+	present    bool
+}
+
+// splitModifiers removes the modifiers appended to the schedule expression and parses them
+func splitModifiers(scheduleExpression string) (expression string, mods modifiers, err error) {
+	expression = scheduleExpression
+	mods.location = time.UTC
+	seen := map[string]bool{}
+
+	for {
+		match := modifierRegularExpression.FindStringSubmatchIndex(expression)
+		if match == nil {
+			return
+		}
+		name := strings.ToLower(expression[match[2]:match[3]])
+		value := strings.TrimSpace(expression[match[4]:match[5]])
+		expression = expression[:match[0]]
+
+		if seen[name] {
+			err = fmt.Errorf("Modifier %v is specified more than once in expression %v", name, scheduleExpression)
+			return
+		}
+		seen[name] = true
+		mods.present = true
+
+		switch name {
+		case modifierTimeZone:
+			if mods.location, err = time.LoadLocation(value); err != nil || value == "" {
+				err = fmt.Errorf("Time zone %v is invalid in expression %v", value, scheduleExpression)
+				return
+			}
+		case modifierOffset:
+			if mods.offsetDays, err = strconv.Atoi(value); err != nil || mods.offsetDays < minOffsetDays || mods.offsetDays > maxOffsetDays {
+				err = fmt.Errorf("Offset %v is invalid in expression %v, it must be a number of days between %v and %v",
+					value, scheduleExpression, minOffsetDays, maxOffsetDays)
+				return
+			}
+		}
+	}
+}
+
+// cronExpression is implemented by the parsed cron expression
+type cronExpression interface {
+	Next(fromTime time.Time) time.Time
+}
+
+// zonedExpression evaluates a cron expression on the wall clock of a time zone, optionally offset by a number of days.
+// A wall clock time skipped by a daylight saving change runs once the clock moves past it,
+// a wall clock time repeated by a daylight saving change runs only on its first occurrence.
+type zonedExpression struct {
+	schedule   cronExpression
+	location   *time.Location
+	offsetDays int
+}
+
+// Next returns the next time of the schedule after the given time, or the zero time when there is none
+func (expr *zonedExpression) Next(fromTime time.Time) time.Time {
+	wallTime := toWallTime(fromTime.In(expr.location)).AddDate(0, 0, -expr.offsetDays)
+	for {
+		next := expr.schedule.Next(wallTime)
+		if next.IsZero() {
+			return next
+		}
+		scheduled := fromWallTime(next.AddDate(0, 0, expr.offsetDays), expr.location)
+		// several wall clock times can map to the same time around a daylight saving change
+		if scheduled.After(fromTime) {
+			return scheduled.UTC()
+		}
+		wallTime = next
+	}
+}
+
+// atExpression runs once at the given time
+type atExpression struct {
+	scheduledTime time.Time
+}
+
+// Next returns the time of the expression if it is after the given time, or the zero time otherwise
+func (expr *atExpression) Next(fromTime time.Time) time.Time {
+	if expr.scheduledTime.After(fromTime) {
+		return expr.scheduledTime
+	}
+	return time.Time{}
+}
+
+// IsOneTimeExpression returns true for the expression that runs only once at a given time
+func IsOneTimeExpression(scheduleExpression string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(scheduleExpression)), expressionTypeAt+"(")
+}
+
+// parseAtExpression parses an expression such as at(2018-03-11T02:30:00) tz(America/New_York)
+func parseAtExpression(scheduleExpression string) (ScheduleExpression, error) {
+	expression, mods, err := splitModifiers(strings.TrimSpace(scheduleExpression))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(expression, ")") {
+		return nil, fmt.Errorf("At expression %v is invalid.", scheduleExpression)
+	}
+	if mods.offsetDays != 0 {
+		return nil, fmt.Errorf("Modifier %v is not supported in at expression %v", modifierOffset, scheduleExpression)
+	}
+
+	value := strings.TrimSpace(expression[len(expressionTypeAt)+1 : len(expression)-1])
+	wallTime, err := time.Parse(atTimeLayout, value)
+	if err != nil {
+		return nil, fmt.Errorf("At expression %v is invalid, the time must be in the format %v.", scheduleExpression, atTimeLayout)
+	}
+	return &atExpression{scheduledTime: fromWallTime(wallTime, mods.location).UTC()}, nil
+}
+
+// toWallTime returns the wall clock time of the given time in UTC
+func toWallTime(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// fromWallTime returns the time of the wall clock time in the given location.
+// A skipped wall clock time moves forward by the length of the daylight saving change,
+// a repeated wall clock time resolves to its first occurrence.
+func fromWallTime(wallTime time.Time, location *time.Location) time.Time {
+	t := time.Date(wallTime.Year(), wallTime.Month(), wallTime.Day(),
+		wallTime.Hour(), wallTime.Minute(), wallTime.Second(), wallTime.Nanosecond(), location)
+
+	if shift := wallTime.Sub(toWallTime(t)); shift > 0 {
+		return t.Add(shift)
+	}
+
+	_, offset := t.Zone()
+	_, previousOffset := t.Add(-24 * time.Hour).Zone()
+	if previousOffset > offset {
+		earlier := t.Add(-time.Duration(previousOffset-offset) * time.Second)
+		if toWallTime(earlier).Equal(wallTime) {
+			return earlier
+		}
+	}
+	return t
+}
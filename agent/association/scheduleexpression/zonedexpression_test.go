@@ -0,0 +1,294 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package scheduleexpression
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %v is not available: %v", name, err)
+	}
+	return location
+}
+
+func nextTimes(expression ScheduleExpression, from time.Time, count int) []time.Time {
+	var times []time.Time
+	for i := 0; i < count; i++ {
+		from = expression.Next(from)
+		times = append(times, from)
+	}
+	return times
+}
+
+func TestCronExpressionWithoutModifiersIsEvaluatedInUTC(t *testing.T) {
+	// Act
+	parsedExpression, err := CreateScheduleExpression(log.DefaultLogger(), "cron(0 2 * * ? *)")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2018, 1, 2, 2, 0, 0, 0, time.UTC),
+		parsedExpression.Next(time.Date(2018, 1, 1, 3, 0, 0, 0, time.UTC)))
+}
+
+func TestCronExpressionIsEvaluatedInTimeZone(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	// Act
+	parsedExpression, err := CreateScheduleExpression(log.DefaultLogger(), "cron(0 2 * * ? *) tz(America/New_York)")
+
+	// Assert
+	assert.Nil(t, err)
+	next := parsedExpression.Next(time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2018, 1, 2, 2, 0, 0, 0, newYork).UTC(), next)
+	assert.Equal(t, time.UTC, next.Location())
+	// summer time moves the run by an hour in UTC
+	assert.Equal(t, time.Date(2018, 7, 2, 6, 0, 0, 0, time.UTC),
+		parsedExpression.Next(time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC)))
+}
+
+func TestUpperCasedModifiersAreParsed(t *testing.T) {
+	mustLoadLocation(t, "Europe/Berlin")
+
+	// Act
+	parsedExpression, err := CreateScheduleExpression(log.DefaultLogger(), "CRON(0 2 ? * TUE#2 *)  TZ(Europe/Berlin) OFFSET(3)")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.NotNil(t, parsedExpression)
+}
+
+func TestDailyCronRunsOnceWhenWallClockTimeIsSkipped(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	parsedExpression, _ := CreateScheduleExpression(log.DefaultLogger(), "cron(30 2 * * ? *) tz(America/New_York)")
+
+	// Act
+	times := nextTimes(parsedExpression, time.Date(2018, 3, 9, 12, 0, 0, 0, newYork), 3)
+
+	// Assert
+	// 02:30 doesn't exist on March 11th, the run moves forward to 03:30 daylight time
+	assert.Equal(t, []time.Time{
+		time.Date(2018, 3, 10, 2, 30, 0, 0, newYork).UTC(),
+		time.Date(2018, 3, 11, 3, 30, 0, 0, newYork).UTC(),
+		time.Date(2018, 3, 12, 2, 30, 0, 0, newYork).UTC(),
+	}, times)
+}
+
+func TestDailyCronRunsOnceWhenWallClockTimeIsRepeated(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	parsedExpression, _ := CreateScheduleExpression(log.DefaultLogger(), "cron(30 1 * * ? *) tz(America/New_York)")
+
+	// Act
+	times := nextTimes(parsedExpression, time.Date(2018, 11, 3, 12, 0, 0, 0, newYork), 2)
+
+	// Assert
+	// 01:30 happens twice on November 4th, the run happens on the first one in daylight time
+	assert.Equal(t, []time.Time{
+		time.Date(2018, 11, 4, 5, 30, 0, 0, time.UTC),
+		time.Date(2018, 11, 5, 6, 30, 0, 0, time.UTC),
+	}, times)
+}
+
+func TestHourlyCronAcrossSpringForward(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	parsedExpression, _ := CreateScheduleExpression(log.DefaultLogger(), "cron(0 * * * ? *) tz(America/New_York)")
+
+	// Act
+	times := nextTimes(parsedExpression, time.Date(2018, 3, 11, 0, 30, 0, 0, newYork), 3)
+
+	// Assert
+	// 02:00 and 03:00 are the same time on March 11th, it runs only once
+	assert.Equal(t, []time.Time{
+		time.Date(2018, 3, 11, 6, 0, 0, 0, time.UTC),
+		time.Date(2018, 3, 11, 7, 0, 0, 0, time.UTC),
+		time.Date(2018, 3, 11, 8, 0, 0, 0, time.UTC),
+	}, times)
+}
+
+func TestHourlyCronAcrossFallBack(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	parsedExpression, _ := CreateScheduleExpression(log.DefaultLogger(), "cron(0 * * * ? *) tz(America/New_York)")
+
+	// Act
+	times := nextTimes(parsedExpression, time.Date(2018, 11, 4, 0, 30, 0, 0, newYork), 3)
+
+	// Assert
+	// 01:00 happens twice on November 4th, it runs only on the first one
+	assert.Equal(t, []time.Time{
+		time.Date(2018, 11, 4, 5, 0, 0, 0, time.UTC),
+		time.Date(2018, 11, 4, 7, 0, 0, 0, time.UTC),
+		time.Date(2018, 11, 4, 8, 0, 0, 0, time.UTC),
+	}, times)
+}
+
+func TestNextFromRepeatedWallClockTimeMovesForward(t *testing.T) {
+	mustLoadLocation(t, "America/New_York")
+	parsedExpression, _ := CreateScheduleExpression(log.DefaultLogger(), "cron(0/15 * * * ? *) tz(America/New_York)")
+
+	// Act
+	// 01:50 standard time, after the clock moved back from 02:00 daylight time
+	next := parsedExpression.Next(time.Date(2018, 11, 4, 6, 50, 0, 0, time.UTC))
+
+	// Assert
+	assert.Equal(t, time.Date(2018, 11, 4, 7, 0, 0, 0, time.UTC), next)
+}
+
+func TestCronInSouthernHemisphereTimeZone(t *testing.T) {
+	sydney := mustLoadLocation(t, "Australia/Sydney")
+	parsedExpression, _ := CreateScheduleExpression(log.DefaultLogger(), "cron(30 2 * * ? *) tz(Australia/Sydney)")
+
+	// Act
+	springForward := nextTimes(parsedExpression, time.Date(2018, 10, 6, 12, 0, 0, 0, sydney), 2)
+	fallBack := nextTimes(parsedExpression, time.Date(2018, 3, 31, 12, 0, 0, 0, sydney), 2)
+
+	// Assert
+	// 02:30 doesn't exist on October 7th and happens twice on April 1st
+	assert.Equal(t, []time.Time{
+		time.Date(2018, 10, 6, 16, 30, 0, 0, time.UTC),
+		time.Date(2018, 10, 7, 15, 30, 0, 0, time.UTC),
+	}, springForward)
+	assert.Equal(t, []time.Time{
+		time.Date(2018, 3, 31, 15, 30, 0, 0, time.UTC),
+		time.Date(2018, 4, 1, 16, 30, 0, 0, time.UTC),
+	}, fallBack)
+}
+
+func TestCronInHalfHourTimeZone(t *testing.T) {
+	mustLoadLocation(t, "Asia/Kolkata")
+	parsedExpression, _ := CreateScheduleExpression(log.DefaultLogger(), "cron(0 9 * * ? *) tz(Asia/Kolkata)")
+
+	// Act
+	next := parsedExpression.Next(time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	// Assert
+	assert.Equal(t, time.Date(2018, 6, 1, 3, 30, 0, 0, time.UTC), next)
+}
+
+func TestCronWithOffsetRunsDaysAfterOccurrence(t *testing.T) {
+	// Assemble
+	// the second Tuesday of 2018 months: January 9th, February 13th, March 13th
+	parsedExpression, err := CreateScheduleExpression(log.DefaultLogger(), "cron(0 2 ? * TUE#2 *) offset(3)")
+
+	// Act
+	times := nextTimes(parsedExpression, time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), 3)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2018, 1, 12, 2, 0, 0, 0, time.UTC),
+		time.Date(2018, 2, 16, 2, 0, 0, 0, time.UTC),
+		time.Date(2018, 3, 16, 2, 0, 0, 0, time.UTC),
+	}, times)
+}
+
+func TestCronWithOffsetCrossingMonthAndDaylightSavingChange(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	// the last Friday of October 2018 is October 26th, daylight saving time ends on November 4th
+	parsedExpression, _ := CreateScheduleExpression(log.DefaultLogger(), "cron(0 2 ? * FRIL *) tz(America/New_York) offset(6)")
+
+	// Act
+	next := parsedExpression.Next(time.Date(2018, 10, 20, 0, 0, 0, 0, newYork))
+
+	// Assert
+	assert.Equal(t, time.Date(2018, 11, 1, 2, 0, 0, 0, newYork).UTC(), next)
+}
+
+func TestCronWithOffsetWhenFromTimeIsWithinOffset(t *testing.T) {
+	// Act
+	parsedExpression, _ := CreateScheduleExpression(log.DefaultLogger(), "cron(0 2 ? * TUE#2 *) offset(3)")
+
+	// Assert
+	// January 10th is after the second Tuesday but before the offset run
+	assert.Equal(t, time.Date(2018, 1, 12, 2, 0, 0, 0, time.UTC),
+		parsedExpression.Next(time.Date(2018, 1, 10, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2018, 2, 16, 2, 0, 0, 0, time.UTC),
+		parsedExpression.Next(time.Date(2018, 1, 12, 2, 0, 0, 0, time.UTC)))
+}
+
+func TestAtExpressionRunsOnce(t *testing.T) {
+	// Act
+	parsedExpression, err := CreateScheduleExpression(log.DefaultLogger(), "at(2018-03-11T02:30:00)")
+
+	// Assert
+	assert.Nil(t, err)
+	scheduledTime := time.Date(2018, 3, 11, 2, 30, 0, 0, time.UTC)
+	assert.Equal(t, scheduledTime, parsedExpression.Next(time.Time{}))
+	assert.Equal(t, scheduledTime, parsedExpression.Next(scheduledTime.Add(-time.Second)))
+	assert.True(t, parsedExpression.Next(scheduledTime).IsZero())
+}
+
+func TestAtExpressionInTimeZone(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	// Act
+	parsedExpression, err := CreateScheduleExpression(log.DefaultLogger(), "AT(2018-07-04T09:00:00) tz(America/New_York)")
+	skipped, skippedErr := CreateScheduleExpression(log.DefaultLogger(), "at(2018-03-11T02:30:00) tz(America/New_York)")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2018, 7, 4, 13, 0, 0, 0, time.UTC), parsedExpression.Next(time.Time{}))
+	assert.Nil(t, skippedErr)
+	assert.Equal(t, time.Date(2018, 3, 11, 3, 30, 0, 0, newYork).UTC(), skipped.Next(time.Time{}))
+}
+
+func TestIsOneTimeExpression(t *testing.T) {
+	assert.True(t, IsOneTimeExpression("at(2018-03-11T02:30:00)"))
+	assert.True(t, IsOneTimeExpression("AT(2018-03-11T02:30:00) tz(UTC)"))
+	assert.False(t, IsOneTimeExpression("cron(0 2 * * ? *)"))
+	assert.False(t, IsOneTimeExpression("rate(30 minutes)"))
+	assert.False(t, IsOneTimeExpression(""))
+}
+
+func TestInvalidExtendedExpressionsReturnError(t *testing.T) {
+	logger := log.DefaultLogger()
+	expressions := map[string]string{
+		"cron(0 2 * * ? *) tz(Mars/Olympus_Mons)":     "Time zone Mars/Olympus_Mons is invalid in expression cron(0 2 * * ? *) tz(Mars/Olympus_Mons)",
+		"cron(0 2 * * ? *) tz()":                      "Time zone  is invalid in expression cron(0 2 * * ? *) tz()",
+		"cron(0 2 * * ? *) offset(7)":                 "Offset 7 is invalid in expression cron(0 2 * * ? *) offset(7), it must be a number of days between 1 and 6",
+		"cron(0 2 * * ? *) offset(two)":               "Offset two is invalid in expression cron(0 2 * * ? *) offset(two), it must be a number of days between 1 and 6",
+		"cron(0 2 * * ? *) tz(UTC) tz(UTC)":           "Modifier tz is specified more than once in expression cron(0 2 * * ? *) tz(UTC) tz(UTC)",
+		"cron(0 2 * * ? *) every(2)":                  "",
+		"cron(0 2 * * ? *)tz(UTC)":                    "",
+		"at(12:00)":                                   "At expression at(12:00) is invalid, the time must be in the format 2006-01-02T15:04:05.",
+		"at(2018-03-11T02:30:00) offset(2)":           "Modifier offset is not supported in at expression at(2018-03-11T02:30:00) offset(2)",
+		"at(2018-03-11T02:30:00":                      "At expression at(2018-03-11T02:30:00 is invalid.",
+		"at(2018-03-11T02:30:00) tz(America/Nowhere)": "Time zone America/Nowhere is invalid in expression at(2018-03-11T02:30:00) tz(America/Nowhere)",
+	}
+
+	for expression, message := range expressions {
+		// Act
+		parsedExpression, err := CreateScheduleExpression(logger, expression)
+
+		// Assert
+		assert.Nil(t, parsedExpression, expression)
+		if assert.NotNil(t, err, expression) && message != "" {
+			assert.Equal(t, message, err.Error(), expression)
+		}
+	}
+}
+
+func TestRateExpressionWithModifierReturnsError(t *testing.T) {
+	// Act
+	parsedExpression, err := CreateScheduleExpression(log.DefaultLogger(), "rate(30 minutes) tz(UTC)")
+
+	// Assert
+	assert.Nil(t, parsedExpression)
+	assert.NotNil(t, err)
+}
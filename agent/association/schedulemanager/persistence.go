@@ -0,0 +1,120 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package schedulemanager schedules association and submits the association to the task pool
+package schedulemanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/model"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// persistedAssociation represents the part of a scheduled association which is saved to disk
+type persistedAssociation struct {
+	Association *ssm.InstanceAssociationSummary
+	Document    *string
+	CreateDate  time.Time
+	// DeferredUntil is the date the association was deferred to, if any
+	DeferredUntil *time.Time
+}
+
+// decoupling for easy testability
+var scheduleFilePath = func(instanceID string) string {
+	return filepath.Join(appconfig.DefaultDataStorePath,
+		instanceID,
+		appconfig.AssociationRootDirName,
+		appconfig.AssociationScheduleFileName)
+}
+
+// Persist saves the scheduled associations and their documents, so they keep running
+// after an agent restart while the service is unreachable
+func Persist(log log.T, instanceID string) (err error) {
+	lock.RLock()
+	persisted := make([]persistedAssociation, 0, len(associations))
+	for _, assoc := range associations {
+		persisted = append(persisted, persistedAssociation{
+			Association:   assoc.Association,
+			Document:      assoc.Document,
+			CreateDate:    assoc.CreateDate,
+			DeferredUntil: assoc.DeferredUntil,
+		})
+	}
+	var content []byte
+	content, err = json.Marshal(persisted)
+	lock.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal scheduled associations, %v", err)
+	}
+
+	path := scheduleFilePath(instanceID)
+	if err = fileutil.MakeDirs(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create directory for scheduled associations, %v", err)
+	}
+
+	// write to a temporary file first so a crash never leaves a truncated schedule behind
+	tmpPath := path + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, content, appconfig.ReadWriteAccess); err != nil {
+		return fmt.Errorf("failed to save scheduled associations, %v", err)
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to save scheduled associations, %v", err)
+	}
+	log.Debugf("Saved %v scheduled associations to %v", len(persisted), path)
+	return nil
+}
+
+// LoadPersisted returns the associations saved by the last Persist, the associations are not scheduled
+func LoadPersisted(log log.T, instanceID string) (assocs []*model.InstanceAssociation, err error) {
+	path := scheduleFilePath(instanceID)
+	var content []byte
+	if content, err = ioutil.ReadFile(path); err != nil {
+		if os.IsNotExist(err) {
+			return []*model.InstanceAssociation{}, nil
+		}
+		return nil, fmt.Errorf("failed to read scheduled associations, %v", err)
+	}
+
+	var persisted []persistedAssociation
+	if err = json.Unmarshal(content, &persisted); err != nil {
+		return nil, fmt.Errorf("failed to parse scheduled associations from %v, %v", path, err)
+	}
+
+	for _, p := range persisted {
+		if p.Association == nil || p.Association.AssociationId == nil || p.Document == nil {
+			continue
+		}
+		// the execution which left the association InProgress did not survive the restart
+		if p.Association.DetailedStatus != nil && *p.Association.DetailedStatus == contracts.AssociationStatusInProgress {
+			p.Association.DetailedStatus = nil
+		}
+		assocs = append(assocs, &model.InstanceAssociation{
+			Association:   p.Association,
+			Document:      p.Document,
+			CreateDate:    p.CreateDate,
+			DeferredUntil: p.DeferredUntil,
+		})
+	}
+	log.Infof("Loaded %v scheduled associations from %v", len(assocs), path)
+	return assocs, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package schedulemanager schedules association and submits the association to the task pool
+package schedulemanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/association/model"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersistAndLoadPersisted(t *testing.T) {
+	logMock := log.NewMockLog()
+	dir, err := ioutil.TempDir("", "schedulemanager")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	defer func(path func(string) string) { scheduleFilePath = path }(scheduleFilePath)
+	scheduleFilePath = func(instanceID string) string {
+		return filepath.Join(dir, instanceID, "schedules.json")
+	}
+
+	// nothing saved yet
+	assocs, err := LoadPersisted(logMock, "i-123")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(assocs))
+
+	Refresh(logMock, []*model.InstanceAssociation{
+		{
+			Association: &ssm.InstanceAssociationSummary{
+				AssociationId:      aws.String("assoc-1"),
+				Name:               aws.String("doc"),
+				ScheduleExpression: aws.String("rate(30 minutes)"),
+				LastExecutionDate:  aws.Time(time.Now().UTC()),
+				DetailedStatus:     aws.String(contracts.AssociationStatusInProgress),
+			},
+			Document:   aws.String("{\"schemaVersion\": \"2.2\"}"),
+			CreateDate: time.Now().UTC(),
+		},
+	})
+	deferredUntil := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	DeferAssociation(logMock, "assoc-1", deferredUntil)
+	defer Refresh(logMock, []*model.InstanceAssociation{})
+
+	assert.Nil(t, Persist(logMock, "i-123"))
+
+	assocs, err = LoadPersisted(logMock, "i-123")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(assocs))
+	assert.Equal(t, "assoc-1", *assocs[0].Association.AssociationId)
+	assert.Equal(t, "{\"schemaVersion\": \"2.2\"}", *assocs[0].Document)
+	assert.Nil(t, assocs[0].Association.DetailedStatus, "an execution in progress does not survive a restart")
+	assert.Nil(t, assocs[0].ParsedExpression)
+	assert.True(t, deferredUntil.Equal(*assocs[0].DeferredUntil), "a deferral survives a restart")
+}
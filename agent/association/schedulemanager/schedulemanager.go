@@ -17,6 +17,7 @@ package schedulemanager
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -37,6 +38,8 @@ func Refresh(log log.T, assocs []*model.InstanceAssociation) {
 	lock.Lock()
 	defer lock.Unlock()
 
+	CarryOverDeferrals(assocs, associations)
+	CarryOverCreateDates(assocs, associations)
 	associations = []*model.InstanceAssociation{}
 	log.Debugf("Refreshing schedule manager with %v associations", len(assocs))
 
@@ -71,6 +74,40 @@ func Refresh(log log.T, assocs []*model.InstanceAssociation) {
 	log.Infof("Schedule manager refreshed with %v associations, %v new associations associated", len(associations), numberOfNewAssoc)
 }
 
+// CarryOverDeferrals defers the associations as the previous associations with the same ID were deferred,
+// so that a deferral survives the associations being loaded again
+func CarryOverDeferrals(assocs []*model.InstanceAssociation, previous []*model.InstanceAssociation) {
+	deferred := make(map[string]*time.Time)
+	for _, assoc := range previous {
+		if assoc.DeferredUntil != nil {
+			deferred[*assoc.Association.AssociationId] = assoc.DeferredUntil
+		}
+	}
+	for _, assoc := range assocs {
+		if date, exists := deferred[*assoc.Association.AssociationId]; exists && assoc.DeferredUntil == nil {
+			assoc.DeferredUntil = date
+		}
+	}
+}
+
+// CarryOverCreateDates keeps the creation date of the previous associations with the same ID and checksum,
+// so that an association which has not run yet keeps the date it runs at after the splay
+func CarryOverCreateDates(assocs []*model.InstanceAssociation, previous []*model.InstanceAssociation) {
+	created := make(map[string]*model.InstanceAssociation)
+	for _, assoc := range previous {
+		if !assoc.CreateDate.IsZero() {
+			created[*assoc.Association.AssociationId] = assoc
+		}
+	}
+	for _, assoc := range assocs {
+		if prev, exists := created[*assoc.Association.AssociationId]; exists &&
+			aws.StringValue(prev.Association.Checksum) == aws.StringValue(assoc.Association.Checksum) &&
+			prev.CreateDate.Before(assoc.CreateDate) {
+			assoc.CreateDate = prev.CreateDate
+		}
+	}
+}
+
 // LoadNextScheduledAssociation returns next scheduled association
 func LoadNextScheduledAssociation(log log.T) (*model.InstanceAssociation, error) {
 	lock.Lock()
@@ -123,6 +160,45 @@ func LoadNextScheduledDate(log log.T) *time.Time {
 	return nextScheduleDate
 }
 
+// LoadScheduledAssociations returns the associations due to run, the one waiting the longest first
+func LoadScheduledAssociations(log log.T) []*model.InstanceAssociation {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	currentTime := time.Now().UTC()
+	scheduled := []*model.InstanceAssociation{}
+	for _, assoc := range associations {
+		if assoc.NextScheduledDate != nil && !assoc.NextScheduledDate.After(currentTime) {
+			scheduled = append(scheduled, assoc)
+		}
+	}
+
+	sort.SliceStable(scheduled, func(i, j int) bool {
+		return scheduled[i].NextScheduledDate.Before(*scheduled[j].NextScheduledDate)
+	})
+	log.Debugf("%v associations are due to run", len(scheduled))
+	return scheduled
+}
+
+// LoadNextScheduledDateAfter returns the earliest scheduled date after the given date
+func LoadNextScheduledDateAfter(log log.T, date time.Time) *time.Time {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	var nextScheduleDate *time.Time
+	for _, assoc := range associations {
+		if assoc.NextScheduledDate == nil || !assoc.NextScheduledDate.After(date) {
+			continue
+		}
+
+		if nextScheduleDate == nil || nextScheduleDate.After(*assoc.NextScheduledDate) {
+			nextScheduleDate = assoc.NextScheduledDate
+		}
+	}
+
+	return nextScheduleDate
+}
+
 // UpdateNextScheduledDate sets next scheduled date for the given association
 func UpdateNextScheduledDate(log log.T, associationID string) {
 	lock.Lock()
@@ -131,6 +207,8 @@ func UpdateNextScheduledDate(log log.T, associationID string) {
 	for _, assoc := range associations {
 		if *assoc.Association.AssociationId == associationID {
 			assoc.Association.LastExecutionDate = aws.Time(time.Now().UTC())
+			// the execution the association was deferred for is over
+			assoc.DeferredUntil = nil
 			assoc.SetNextScheduledDate(log)
 			if assoc.NextScheduledDate != nil {
 				log.Infof("Scheduling association %v, setting next ScheduledDate to %v", *assoc.Association.AssociationId, times.ToIsoDashUTC(*assoc.NextScheduledDate))
@@ -140,6 +218,49 @@ func UpdateNextScheduledDate(log log.T, associationID string) {
 	}
 }
 
+// DeferAssociation postpones the next execution of the given association to the given date,
+// the association keeps the deferral when it is refreshed
+func DeferAssociation(log log.T, associationID string, date time.Time) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, assoc := range associations {
+		if *assoc.Association.AssociationId == associationID {
+			assoc.DeferredUntil = aws.Time(date.UTC())
+			assoc.NextScheduledDate = aws.Time(date.UTC())
+			log.Infof("Deferring association %v, setting next ScheduledDate to %v", associationID, times.ToIsoDashUTC(*assoc.NextScheduledDate))
+			break
+		}
+	}
+}
+
+// RerunAssociation runs the given association as soon as possible, repeating the given run.
+// It returns false when the association is not scheduled.
+func RerunAssociation(log log.T, associationID string, rerun *model.Rerun) bool {
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, assoc := range associations {
+		if *assoc.Association.AssociationId == associationID {
+			assoc.Rerun = rerun
+			assoc.RunNow()
+			log.Infof("Rerunning association %v with the parameters of run %v", associationID, rerun.RunID)
+			return true
+		}
+	}
+	return false
+}
+
+// TakeRerun returns the run the association repeats on its next execution, if any, and clears it
+func TakeRerun(assoc *model.InstanceAssociation) *model.Rerun {
+	lock.Lock()
+	defer lock.Unlock()
+
+	rerun := assoc.Rerun
+	assoc.Rerun = nil
+	return rerun
+}
+
 // UpdateAssociationStatus sets detailed status for the given association
 func UpdateAssociationStatus(associationID string, status string) {
 	lock.Lock()
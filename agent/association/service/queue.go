@@ -0,0 +1,158 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package service wraps SSM service
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// queuedStatus represents an association status update which could not be delivered
+type queuedStatus struct {
+	AssociationID   string
+	InstanceID      string
+	ExecutionResult *ssm.InstanceAssociationExecutionResult
+}
+
+// statusQueue keeps undelivered association status updates in order, on disk so they survive agent restarts
+type statusQueue struct {
+	mutex      sync.Mutex
+	instanceID string
+	entries    []queuedStatus
+}
+
+var pendingStatus = &statusQueue{}
+
+// decoupling for easy testability
+var pendingStatusFilePath = func(instanceID string) string {
+	return filepath.Join(appconfig.DefaultDataStorePath,
+		instanceID,
+		appconfig.AssociationRootDirName,
+		appconfig.AssociationPendingStatusFileName)
+}
+
+// isRejected returns true if the service refused the status update for good, e.g. because the association was
+// deleted. Such an update would never be delivered and is not queued. Throttling, expired credentials and
+// access errors which can be fixed by the administrator are expected to go away.
+func isRejected(err error) bool {
+	failure, ok := err.(awserr.RequestFailure)
+	if !ok || failure.StatusCode() < http.StatusBadRequest || failure.StatusCode() >= http.StatusInternalServerError {
+		return false
+	}
+	if failure.StatusCode() == http.StatusUnauthorized || failure.StatusCode() == http.StatusForbidden {
+		return false
+	}
+	return !request.IsErrorRetryable(err) && !request.IsErrorThrottle(err) && !request.IsErrorExpiredCreds(err)
+}
+
+// load reads the queue of the given instance from disk unless it is already loaded
+func (q *statusQueue) load(log log.T, instanceID string) {
+	if q.instanceID == instanceID {
+		return
+	}
+	q.instanceID = instanceID
+	q.entries = nil
+
+	content, err := ioutil.ReadFile(pendingStatusFilePath(instanceID))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("unable to read queued association status updates, %v", err)
+		}
+		return
+	}
+	if err = json.Unmarshal(content, &q.entries); err != nil {
+		log.Errorf("unable to parse queued association status updates, %v", err)
+		q.entries = nil
+	}
+}
+
+// save writes the queue to disk
+func (q *statusQueue) save(log log.T) {
+	path := pendingStatusFilePath(q.instanceID)
+	if len(q.entries) == 0 {
+		if err := fileutil.DeleteFile(path); err != nil && !os.IsNotExist(err) {
+			log.Errorf("unable to delete queued association status updates, %v", err)
+		}
+		return
+	}
+
+	content, err := json.Marshal(q.entries)
+	if err != nil {
+		log.Errorf("unable to marshal queued association status updates, %v", err)
+		return
+	}
+	if err = fileutil.MakeDirs(filepath.Dir(path)); err == nil {
+		err = ioutil.WriteFile(path, content, appconfig.ReadWriteAccess)
+	}
+	if err != nil {
+		log.Errorf("unable to save queued association status updates, %v", err)
+	}
+}
+
+// enqueue adds a status update to the end of the queue, dropping the oldest updates when the queue is full
+func (q *statusQueue) enqueue(log log.T, status queuedStatus) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.load(log, status.InstanceID)
+	q.entries = append(q.entries, status)
+	if dropped := len(q.entries) - appconfig.AssociationPendingStatusMaxQueueSize; dropped > 0 {
+		log.Warnf("dropping %v oldest queued association status updates", dropped)
+		q.entries = q.entries[dropped:]
+	}
+	log.Infof("queued status update of association %v, %v updates waiting for delivery", status.AssociationID, len(q.entries))
+	q.save(log)
+}
+
+// deliver sends the queued updates in order with the given send function and stops at the first failure.
+// Updates rejected by the service are dropped. It returns false if updates remain in the queue.
+func (q *statusQueue) deliver(log log.T, instanceID string, send func(status queuedStatus) error) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.load(log, instanceID)
+	if len(q.entries) == 0 {
+		return true
+	}
+
+	delivered, sent := 0, 0
+	for _, status := range q.entries {
+		if err := send(status); err != nil {
+			if !isRejected(err) {
+				log.Errorf("unable to deliver queued status update of association %v, %v", status.AssociationID, err)
+				break
+			}
+			log.Warnf("dropping queued status update of association %v rejected by the service, %v", status.AssociationID, err)
+		} else {
+			delivered++
+		}
+		sent++
+	}
+	q.entries = q.entries[sent:]
+	log.Infof("delivered %v queued association status updates, %v remaining", delivered, len(q.entries))
+	q.save(log)
+	return len(q.entries) == 0
+}
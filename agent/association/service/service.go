@@ -77,6 +77,7 @@ type T interface {
 		outputUrl string)
 	IsInstanceAssociationApiMode() bool
 	DescribeAssociation(log log.T, instanceID string, docName string) (response *ssm.DescribeAssociationOutput, err error)
+	SendQueuedStatusUpdates(log log.T, instanceID string)
 }
 
 // AssociationService wraps the Ssm Service
@@ -222,6 +223,12 @@ func (s *AssociationService) UpdateInstanceAssociationStatus(
 	// Update status in schedulemanager to ensure state matches with the one on the service
 	schedulemanager.UpdateAssociationStatus(associationID, status)
 
+	// associations defined on the instance are unknown to the service
+	if model.IsLocalAssociation(associationID) {
+		log.Infof("Local association %v status is %v", associationID, status)
+		return
+	}
+
 	if s.IsInstanceAssociationApiMode() {
 		date := times.ParseIso8601UTC(executionDate)
 
@@ -248,6 +255,17 @@ func (s *AssociationService) UpdateInstanceAssociationStatus(
 		}
 		log.Info("Updating association status ", jsonutil.Indent(executionResultContent))
 
+		// Updates which could not be delivered earlier go first to keep the order of the updates
+		queued := queuedStatus{
+			AssociationID:   associationID,
+			InstanceID:      instanceID,
+			ExecutionResult: &executionResult,
+		}
+		if !pendingStatus.deliver(log, instanceID, s.queuedStatusSender(log)) {
+			pendingStatus.enqueue(log, queued)
+			return
+		}
+
 		var response *ssm.UpdateInstanceAssociationStatusOutput
 		if response, err = s.ssmSvc.UpdateInstanceAssociationStatus(log, associationID, instanceID, &executionResult); err != nil {
 			log.Errorf("unable to update association status, %v", err)
@@ -256,10 +274,12 @@ func (s *AssociationService) UpdateInstanceAssociationStatus(
 			// instead of using legacy UpdateAssociationStatus api
 			// When we get error during update, run UpdateAssociationStatus if associationName is equal to associationId
 			// which indicates legacy association loaded from ListAssociation api
-			// Otherwise log the error and return.
+			// Otherwise queue the update until the service is reachable again, unless the service rejected it.
 
 			if associationID == associationName {
 				s.UpdateAssociationStatus(log, associationName, instanceID, status, executionSummary)
+			} else if !isRejected(err) {
+				pendingStatus.enqueue(log, queued)
 			}
 
 			return
@@ -279,6 +299,19 @@ func (s *AssociationService) UpdateInstanceAssociationStatus(
 	return
 }
 
+// SendQueuedStatusUpdates delivers the association status updates queued while the service was unreachable
+func (s *AssociationService) SendQueuedStatusUpdates(log log.T, instanceID string) {
+	pendingStatus.deliver(log, instanceID, s.queuedStatusSender(log))
+}
+
+// queuedStatusSender returns the function sending one queued association status update
+func (s *AssociationService) queuedStatusSender(log log.T) func(status queuedStatus) error {
+	return func(status queuedStatus) (err error) {
+		_, err = s.ssmSvc.UpdateInstanceAssociationStatus(log, status.AssociationID, status.InstanceID, status.ExecutionResult)
+		return
+	}
+}
+
 // UsingInstanceAssociationApi represents if the agent is using new InstanceAssociationApi for listing and updating
 func (s *AssociationService) IsInstanceAssociationApiMode() bool {
 	lock.Lock()
@@ -15,14 +15,21 @@
 package service
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/association/model"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	ssmSvc "github.com/aws/amazon-ssm-agent/agent/ssm"
+	"github.com/aws/amazon-ssm-agent/agent/times"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -127,3 +134,137 @@ func TestUpdateAssociationStatus(t *testing.T) {
 		status,
 		"TestMessage")
 }
+
+func TestUpdateInstanceAssociationStatusSkipsLocalAssociations(t *testing.T) {
+	ssmMock := ssmSvc.NewMockDefault()
+	service := AssociationService{
+		ssmSvc:     ssmMock,
+		stopPolicy: &sdkutil.StopPolicy{},
+	}
+	service.setAssociationApiMode(instanceAssociationMode)
+	pendingStatus = &statusQueue{}
+
+	service.UpdateInstanceAssociationStatus(logMock, "local-baseline", "doc", instanceID, contracts.AssociationStatusSuccess,
+		contracts.AssociationErrorCodeNoError, times.ToIso8601UTC(time.Now()), "success", NoOutputUrl)
+
+	assert.True(t, ssmMock.AssertNumberOfCalls(t, "UpdateInstanceAssociationStatus", 0))
+	assert.Equal(t, 0, len(pendingStatus.entries))
+}
+
+func TestUpdateInstanceAssociationStatusQueuedWhileServiceUnreachable(t *testing.T) {
+	failingSsmMock := ssmSvc.NewMockDefault()
+	service := AssociationService{
+		ssmSvc:     failingSsmMock,
+		stopPolicy: &sdkutil.StopPolicy{},
+	}
+	service.setAssociationApiMode(instanceAssociationMode)
+
+	dir, err := ioutil.TempDir("", "association")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(path func(string) string) { pendingStatusFilePath = path }(pendingStatusFilePath)
+	pendingStatusFilePath = func(instanceID string) string {
+		return filepath.Join(dir, instanceID, "pendingStatus.json")
+	}
+	pendingStatus = &statusQueue{}
+
+	failingSsmMock.On("UpdateInstanceAssociationStatus",
+		mock.AnythingOfType("*log.Mock"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("*ssm.InstanceAssociationExecutionResult")).Return(&ssm.UpdateInstanceAssociationStatusOutput{}, errors.New("endpoint unreachable"))
+
+	service.UpdateInstanceAssociationStatus(logMock, "assoc-1", "doc", instanceID, contracts.AssociationStatusInProgress,
+		contracts.AssociationErrorCodeNoError, times.ToIso8601UTC(time.Now()), "in progress", NoOutputUrl)
+	service.UpdateInstanceAssociationStatus(logMock, "assoc-1", "doc", instanceID, contracts.AssociationStatusSuccess,
+		contracts.AssociationErrorCodeNoError, times.ToIso8601UTC(time.Now()), "success", NoOutputUrl)
+
+	// the second update is queued behind the first without trying to send it
+	assert.True(t, failingSsmMock.AssertNumberOfCalls(t, "UpdateInstanceAssociationStatus", 2))
+	assert.Equal(t, 2, len(pendingStatus.entries))
+
+	// the queue survives an agent restart
+	pendingStatus = &statusQueue{}
+	pendingStatus.load(logMock, instanceID)
+	assert.Equal(t, 2, len(pendingStatus.entries))
+	assert.Equal(t, contracts.AssociationStatusSuccess, *pendingStatus.entries[1].ExecutionResult.Status)
+
+	// queued updates are delivered in order once the service is reachable again
+	ssmMock := ssmSvc.NewMockDefault()
+	service.ssmSvc = ssmMock
+	ssmMock.On("UpdateInstanceAssociationStatus",
+		mock.AnythingOfType("*log.Mock"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("*ssm.InstanceAssociationExecutionResult")).Return(&ssm.UpdateInstanceAssociationStatusOutput{}, nil)
+
+	service.SendQueuedStatusUpdates(logMock, instanceID)
+	assert.True(t, ssmMock.AssertNumberOfCalls(t, "UpdateInstanceAssociationStatus", 2))
+	assert.Equal(t, contracts.AssociationStatusInProgress, *ssmMock.Calls[0].Arguments.Get(3).(*ssm.InstanceAssociationExecutionResult).Status)
+	assert.Equal(t, contracts.AssociationStatusSuccess, *ssmMock.Calls[1].Arguments.Get(3).(*ssm.InstanceAssociationExecutionResult).Status)
+	assert.Equal(t, 0, len(pendingStatus.entries))
+	_, err = os.Stat(pendingStatusFilePath(instanceID))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUpdateInstanceAssociationStatusDropsRejectedUpdates(t *testing.T) {
+	ssmMock := ssmSvc.NewMockDefault()
+	service := AssociationService{
+		ssmSvc:     ssmMock,
+		stopPolicy: &sdkutil.StopPolicy{},
+	}
+	service.setAssociationApiMode(instanceAssociationMode)
+
+	dir, err := ioutil.TempDir("", "association")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(path func(string) string) { pendingStatusFilePath = path }(pendingStatusFilePath)
+	pendingStatusFilePath = func(instanceID string) string {
+		return filepath.Join(dir, instanceID, "pendingStatus.json")
+	}
+
+	// the association of the update at the head of the queue was deleted while the service was unreachable
+	newStatus := func(associationID string) queuedStatus {
+		return queuedStatus{
+			AssociationID:   associationID,
+			InstanceID:      instanceID,
+			ExecutionResult: &ssm.InstanceAssociationExecutionResult{Status: aws.String(contracts.AssociationStatusSuccess)},
+		}
+	}
+	pendingStatus = &statusQueue{}
+	pendingStatus.enqueue(logMock, newStatus("assoc-deleted"))
+	pendingStatus.enqueue(logMock, newStatus("assoc-1"))
+
+	rejected := awserr.NewRequestFailure(awserr.New(ssm.ErrCodeAssociationDoesNotExist, "association does not exist", nil), 400, "request-id")
+	sendArguments := []interface{}{
+		mock.AnythingOfType("*log.Mock"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("*ssm.InstanceAssociationExecutionResult")}
+	ssmMock.On("UpdateInstanceAssociationStatus", append([]interface{}{sendArguments[0], "assoc-deleted"}, sendArguments[2:]...)...).Return(&ssm.UpdateInstanceAssociationStatusOutput{}, rejected)
+	ssmMock.On("UpdateInstanceAssociationStatus", sendArguments...).Return(&ssm.UpdateInstanceAssociationStatusOutput{}, nil)
+
+	// the rejected update does not block the ones queued behind it
+	service.SendQueuedStatusUpdates(logMock, instanceID)
+	assert.True(t, ssmMock.AssertNumberOfCalls(t, "UpdateInstanceAssociationStatus", 2))
+	assert.Equal(t, "assoc-1", ssmMock.Calls[1].Arguments.String(1))
+	assert.Equal(t, 0, len(pendingStatus.entries))
+
+	// nor is a rejected update queued
+	service.UpdateInstanceAssociationStatus(logMock, "assoc-deleted", "doc", instanceID, contracts.AssociationStatusSuccess,
+		contracts.AssociationErrorCodeNoError, times.ToIso8601UTC(time.Now()), "success", NoOutputUrl)
+	assert.Equal(t, 0, len(pendingStatus.entries))
+}
+
+func TestIsRejected(t *testing.T) {
+	newFailure := func(code string, statusCode int) error {
+		return awserr.NewRequestFailure(awserr.New(code, "message", nil), statusCode, "request-id")
+	}
+	assert.True(t, isRejected(newFailure(ssm.ErrCodeAssociationDoesNotExist, 400)))
+	assert.True(t, isRejected(newFailure(ssm.ErrCodeInvalidInstanceId, 400)))
+	assert.False(t, isRejected(newFailure("ThrottlingException", 400)))
+	assert.False(t, isRejected(newFailure("ExpiredTokenException", 400)))
+	assert.False(t, isRejected(newFailure("AccessDeniedException", 403)))
+	assert.False(t, isRejected(newFailure(ssm.ErrCodeInternalServerError, 500)))
+	assert.False(t, isRejected(errors.New("endpoint unreachable")))
+}
@@ -87,3 +87,8 @@ func (m *AssociationServiceMock) DescribeAssociation(log log.T, instanceID strin
 	args := m.Called(log, instanceID, docName)
 	return args.Get(0).(*ssm.DescribeAssociationOutput), args.Error(1)
 }
+
+// SendQueuedStatusUpdates mocks implementation for SendQueuedStatusUpdates
+func (m *AssociationServiceMock) SendQueuedStatusUpdates(log log.T, instanceID string) {
+	m.Called(log, instanceID)
+}
@@ -0,0 +1,259 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/runhistory"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/history"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+)
+
+const (
+	listAssociationHistory              = "list-association-history"
+	listAssociationHistoryAssociationID = "association-id"
+	listAssociationHistoryMaxResults    = "max-results"
+	listAssociationHistoryDetails       = "details"
+)
+
+const listAssociationHistoryHelp = `NAME:
+    {{.ListAssociationHistoryName}}
+
+DESCRIPTION
+    Lists the recent runs of the associations of the local amazon-ssm-agent service,
+    with the status, errors and end of the output of each step.
+
+SYNOPSIS
+    {{.ListAssociationHistoryName}}
+    [{{.AssociationIdFlag}} <value>]
+    [{{.MaxResultsFlag}} <value>]
+    [{{.DetailsFlag}}]
+
+PARAMETERS
+    {{.AssociationIdFlag}} (string) Only list the runs of this association.
+
+    {{.MaxResultsFlag}} (integer) Maximum number of runs to list per association, the most recent ones are kept.
+
+    {{.DetailsFlag}} (boolean) Include parameters, with secure values redacted, and step results.
+
+EXAMPLES
+    This example lists the last run of an association.
+
+    Command:
+
+      {{.SsmCliName}} {{.ListAssociationHistoryName}} {{.AssociationIdFlag}} 01234567-890a-bcde-f012-34567890abcd {{.MaxResultsFlag}} 1
+
+    Output:
+
+      [
+        {
+          "RunID": "89abcdef-0123-4567-89ab-cdef01234567",
+          "AssociationID": "01234567-890a-bcde-f012-34567890abcd",
+          "DocumentName": "AWS-RunShellScript",
+          "DocumentVersion": "1",
+          "Status": "Failed",
+          "StartDateTime": "2018-05-14T10:01:02Z",
+          "EndDateTime": "2018-05-14T10:01:07Z"
+        }
+      ]
+
+OUTPUT
+    Runs in JSON format, oldest first
+`
+
+type listAssociationHistoryHelpParams struct {
+	SsmCliName                 string
+	ListAssociationHistoryName string
+	AssociationIdFlag          string
+	MaxResultsFlag             string
+	DetailsFlag                string
+}
+
+// associationHistoryFilter selects the runs to list
+type associationHistoryFilter struct {
+	AssociationID string
+	MaxResults    int
+}
+
+// associationRun is a run as listed by the cli, with redacted parameters
+type associationRun struct {
+	runhistory.Run
+	Parameters map[string]interface{} `json:",omitempty"`
+}
+
+func init() {
+	cliutil.Register(&ListAssociationHistory{})
+}
+
+type ListAssociationHistory struct {
+	helpText string
+}
+
+// Execute validates and executes the list-association-history cli command
+func (c *ListAssociationHistory) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation, filter, showDetails := c.validateListAssociationHistoryInput(subcommands, parameters)
+	// return validation errors if any were found
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	runs, err := c.queryRuns(filter)
+	if err != nil {
+		return err, ""
+	}
+
+	listed := make([]associationRun, 0, len(runs))
+	for _, run := range runs {
+		if showDetails {
+			listed = append(listed, associationRun{Run: run, Parameters: redactAssociationParameters(run.Parameters)})
+		} else {
+			run.Steps = nil
+			listed = append(listed, associationRun{Run: run})
+		}
+	}
+	result, err := jsonutil.MarshalIndent(listed)
+	if err != nil {
+		return err, ""
+	}
+	return nil, result
+}
+
+// Help prints help for the list-association-history cli command
+func (c *ListAssociationHistory) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("ListAssociationHistoryHelp").Parse(listAssociationHistoryHelp)
+		params := listAssociationHistoryHelpParams{
+			cliutil.SsmCliName,
+			listAssociationHistory,
+			cliutil.FormatFlag(listAssociationHistoryAssociationID),
+			cliutil.FormatFlag(listAssociationHistoryMaxResults),
+			cliutil.FormatFlag(listAssociationHistoryDetails),
+		}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (ListAssociationHistory) Name() string {
+	return listAssociationHistory
+}
+
+// validateListAssociationHistoryInput checks the subcommands and parameters for required values, format, and unsupported values
+func (ListAssociationHistory) validateListAssociationHistoryInput(subcommands []string, parameters map[string][]string) (validation []string, filter associationHistoryFilter, showDetails bool) {
+	validation = make([]string, 0)
+
+	if subcommands != nil && len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", listAssociationHistory, subcommands), "")
+		return validation, filter, false // invalid subcommand is an attempt to execute something that really isn't this command, so the rest of the validation is skipped in this case
+	}
+
+	for key, values := range parameters {
+		switch key {
+		case listAssociationHistoryDetails:
+			showDetails = true
+			if len(values) > 0 {
+				validation = append(validation, fmt.Sprintf("flag %v should not have any values", cliutil.FormatFlag(key)))
+			}
+			continue
+		case listAssociationHistoryAssociationID, listAssociationHistoryMaxResults:
+		default:
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+			continue
+		}
+		if len(values) != 1 {
+			validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(key)))
+			continue
+		}
+		value := values[0]
+		switch key {
+		case listAssociationHistoryAssociationID:
+			filter.AssociationID = value
+		case listAssociationHistoryMaxResults:
+			maxResults, err := strconv.Atoi(value)
+			if err != nil || maxResults <= 0 {
+				validation = append(validation, fmt.Sprintf("parameter %v should be a positive integer", cliutil.FormatFlag(key)))
+			}
+			filter.MaxResults = maxResults
+		}
+	}
+	return validation, filter, showDetails
+}
+
+// queryRuns reads the run history of the associations of every instance known to the agent on this host
+func (ListAssociationHistory) queryRuns(filter associationHistoryFilter) ([]runhistory.Run, error) {
+	runs := []runhistory.Run{}
+	for _, instanceID := range associationHistoryInstanceIDs() {
+		associationIDs, err := runhistory.AssociationIDs(instanceID)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(associationIDs)
+		for _, associationID := range associationIDs {
+			if filter.AssociationID != "" && associationID != filter.AssociationID {
+				continue
+			}
+			associationRuns, err := runhistory.Load(instanceID, associationID)
+			if err != nil {
+				return nil, err
+			}
+			if filter.MaxResults > 0 && len(associationRuns) > filter.MaxResults {
+				associationRuns = associationRuns[len(associationRuns)-filter.MaxResults:]
+			}
+			runs = append(runs, associationRuns...)
+		}
+	}
+	return runs, nil
+}
+
+// associationHistoryInstanceIDs returns the instances with an association run history on this host
+func associationHistoryInstanceIDs() []string {
+	// TODO:MF: Find a way to get the current instanceID instead of trying all possible folders
+	dirs, _ := fileutil.GetDirectoryNames(appconfig.DefaultDataStorePath)
+
+	instanceIDs := []string{}
+	for _, dir := range dirs {
+		if fileutil.Exists(runhistory.HistoryDir(dir)) {
+			instanceIDs = append(instanceIDs, dir)
+		}
+	}
+	return instanceIDs
+}
+
+// redactAssociationParameters returns the association parameters with secure values redacted
+func redactAssociationParameters(parameters map[string][]*string) map[string]interface{} {
+	values := make(map[string]interface{}, len(parameters))
+	for name, parameterValues := range parameters {
+		strValues := make([]string, 0, len(parameterValues))
+		for _, value := range parameterValues {
+			if value != nil {
+				strValues = append(strValues, *value)
+			}
+		}
+		values[name] = strValues
+	}
+	return history.RedactParameters(values)
+}
@@ -0,0 +1,246 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/history"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+)
+
+const (
+	listCommandHistory             = "list-command-history"
+	listCommandHistorySince        = "since"
+	listCommandHistoryCommandID    = "command-id"
+	listCommandHistoryDocumentName = "document-name"
+	listCommandHistoryStatus       = "status"
+	listCommandHistoryMaxResults   = "max-results"
+	listCommandHistoryDetails      = "details"
+
+	defaultHistorySince = 7 * 24 * time.Hour
+)
+
+const listCommandHistoryHelp = `NAME:
+    {{.ListCommandHistoryName}}
+
+DESCRIPTION
+    Lists the documents executed by the local amazon-ssm-agent service, including
+    commands, associations and offline commands, with their final status.
+
+SYNOPSIS
+    {{.ListCommandHistoryName}}
+    [{{.SinceFlag}} <value>]
+    [{{.CommandIdFlag}} <value>]
+    [{{.DocumentNameFlag}} <value>]
+    [{{.StatusFlag}} <value>]
+    [{{.MaxResultsFlag}} <value>]
+    [{{.DetailsFlag}}]
+
+PARAMETERS
+    {{.SinceFlag}} (string) Only list executions that ended within this duration, for example 24h or 90m.
+    Defaults to 168h (one week).
+
+    {{.CommandIdFlag}} (string) Only list the executions of this command or document ID.
+
+    {{.DocumentNameFlag}} (string) Only list the executions of this document.
+
+    {{.StatusFlag}} (string) Only list the executions with this status, for example Success or Failed.
+
+    {{.MaxResultsFlag}} (integer) Maximum number of executions to list, the most recent ones are kept.
+
+    {{.DetailsFlag}} (boolean) Include parameters, with secure values redacted, and step results if provided.
+
+EXAMPLES
+    This example lists the failed executions of the last day.
+
+    Command:
+
+      {{.SsmCliName}} {{.ListCommandHistoryName}} {{.SinceFlag}} 24h {{.StatusFlag}} Failed
+
+    Output:
+
+      [
+        {
+          "DocumentID": "01234567-890a-bcde-f012-34567890abcd",
+          "CommandID": "01234567-890a-bcde-f012-34567890abcd",
+          "DocumentType": "SendCommand",
+          "DocumentName": "AWS-RunShellScript",
+          "Status": "Failed",
+          "StartDateTime": "2018-05-14T10:01:02Z",
+          "EndDateTime": "2018-05-14T10:01:07Z"
+        }
+      ]
+
+OUTPUT
+    Executions matching the filters in JSON format, oldest first
+`
+
+type listCommandHistoryHelpParams struct {
+	SsmCliName             string
+	ListCommandHistoryName string
+	SinceFlag              string
+	CommandIdFlag          string
+	DocumentNameFlag       string
+	StatusFlag             string
+	MaxResultsFlag         string
+	DetailsFlag            string
+}
+
+func init() {
+	cliutil.Register(&ListCommandHistory{})
+}
+
+type ListCommandHistory struct {
+	helpText string
+}
+
+// Execute validates and executes the list-command-history cli command
+func (c *ListCommandHistory) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation, filter, showDetails := c.validateListCommandHistoryInput(subcommands, parameters)
+	// return validation errors if any were found
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	records, err := c.queryHistory(filter)
+	if err != nil {
+		return err, ""
+	}
+	if !showDetails {
+		for i := range records {
+			records[i].Parameters = nil
+			records[i].Steps = nil
+		}
+	}
+	result, err := jsonutil.MarshalIndent(records)
+	if err != nil {
+		return err, ""
+	}
+	return nil, result
+}
+
+// Help prints help for the list-command-history cli command
+func (c *ListCommandHistory) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("ListCommandHistoryHelp").Parse(listCommandHistoryHelp)
+		params := listCommandHistoryHelpParams{
+			cliutil.SsmCliName,
+			listCommandHistory,
+			cliutil.FormatFlag(listCommandHistorySince),
+			cliutil.FormatFlag(listCommandHistoryCommandID),
+			cliutil.FormatFlag(listCommandHistoryDocumentName),
+			cliutil.FormatFlag(listCommandHistoryStatus),
+			cliutil.FormatFlag(listCommandHistoryMaxResults),
+			cliutil.FormatFlag(listCommandHistoryDetails),
+		}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (ListCommandHistory) Name() string {
+	return listCommandHistory
+}
+
+// validateListCommandHistoryInput checks the subcommands and parameters for required values, format, and unsupported values
+func (ListCommandHistory) validateListCommandHistoryInput(subcommands []string, parameters map[string][]string) (validation []string, filter history.Filter, showDetails bool) {
+	validation = make([]string, 0)
+
+	if subcommands != nil && len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", listCommandHistory, subcommands), "")
+		return validation, filter, false // invalid subcommand is an attempt to execute something that really isn't this command, so the rest of the validation is skipped in this case
+	}
+
+	since := defaultHistorySince
+	for key, values := range parameters {
+		switch key {
+		case listCommandHistoryDetails:
+			showDetails = true
+			if len(values) > 0 {
+				validation = append(validation, fmt.Sprintf("flag %v should not have any values", cliutil.FormatFlag(key)))
+			}
+			continue
+		case listCommandHistorySince, listCommandHistoryCommandID, listCommandHistoryDocumentName, listCommandHistoryStatus, listCommandHistoryMaxResults:
+		default:
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+			continue
+		}
+		if len(values) != 1 {
+			validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(key)))
+			continue
+		}
+		value := values[0]
+		switch key {
+		case listCommandHistorySince:
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration <= 0 {
+				validation = append(validation, fmt.Sprintf("invalid duration %v for parameter %v", value, cliutil.FormatFlag(key)))
+			}
+			since = duration
+		case listCommandHistoryCommandID:
+			filter.CommandID = value
+		case listCommandHistoryDocumentName:
+			filter.DocumentName = value
+		case listCommandHistoryStatus:
+			filter.Status = contracts.ResultStatus(value)
+		case listCommandHistoryMaxResults:
+			maxResults, err := strconv.Atoi(value)
+			if err != nil || maxResults <= 0 {
+				validation = append(validation, fmt.Sprintf("parameter %v should be a positive integer", cliutil.FormatFlag(key)))
+			}
+			filter.MaxResults = maxResults
+		}
+	}
+	filter.Since = time.Now().Add(-since)
+	return validation, filter, showDetails
+}
+
+// queryHistory reads the execution history of every instance known to the agent on this host
+func (ListCommandHistory) queryHistory(filter history.Filter) ([]history.Record, error) {
+	// TODO:MF: Find a way to get the current instanceID instead of trying all possible folders
+	dirs, _ := fileutil.GetDirectoryNames(appconfig.DefaultDataStorePath)
+
+	records := []history.Record{}
+	for _, dir := range dirs {
+		journalPath := history.JournalPath(dir)
+		if !fileutil.Exists(filepath.Dir(journalPath)) {
+			continue
+		}
+		instanceRecords, err := history.Query(journalPath, filter)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, instanceRecords...)
+	}
+	if filter.MaxResults > 0 && len(records) > filter.MaxResults {
+		records = records[len(records)-filter.MaxResults:]
+	}
+	return records, nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/association/runhistory"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+)
+
+const (
+	rerunAssociation              = "rerun-association"
+	rerunAssociationAssociationID = "association-id"
+	rerunAssociationRunID         = "run-id"
+)
+
+const rerunAssociationHelp = `NAME:
+    {{.RerunAssociationName}}
+
+DESCRIPTION
+    Asks the local amazon-ssm-agent service to run an association now, with the parameters
+    of one of its recent runs. The association runs through the association workers like
+    a scheduled run and its schedule is unchanged.
+
+SYNOPSIS
+    {{.RerunAssociationName}}
+    {{.AssociationIdFlag}} <value>
+    [{{.RunIdFlag}} <value>]
+
+PARAMETERS
+    {{.AssociationIdFlag}} (string) The association to run.
+
+    {{.RunIdFlag}} (string) The run to repeat, as listed by {{.ListAssociationHistoryName}}.
+    Defaults to the last run of the association.
+
+EXAMPLES
+    This example runs an association again with the parameters of its last run.
+
+    Command:
+
+      {{.SsmCliName}} {{.RerunAssociationName}} {{.AssociationIdFlag}} 01234567-890a-bcde-f012-34567890abcd
+
+    Output:
+
+      {
+        "AssociationID": "01234567-890a-bcde-f012-34567890abcd",
+        "RunID": "89abcdef-0123-4567-89ab-cdef01234567",
+        "RequestedDateTime": "2018-05-14T10:05:00Z"
+      }
+
+OUTPUT
+    The rerun request in JSON format, the run appears in {{.ListAssociationHistoryName}} once completed
+`
+
+type rerunAssociationHelpParams struct {
+	SsmCliName                 string
+	RerunAssociationName       string
+	AssociationIdFlag          string
+	RunIdFlag                  string
+	ListAssociationHistoryName string
+}
+
+func init() {
+	cliutil.Register(&RerunAssociation{})
+}
+
+type RerunAssociation struct {
+	helpText string
+}
+
+// Execute validates and executes the rerun-association cli command
+func (c *RerunAssociation) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation, associationID, runID := c.validateRerunAssociationInput(subcommands, parameters)
+	// return validation errors if any were found
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	var lastErr error
+	for _, instanceID := range associationHistoryInstanceIDs() {
+		request, err := runhistory.RequestRerun(instanceID, associationID, runID)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		result, err := jsonutil.MarshalIndent(request)
+		if err != nil {
+			return err, ""
+		}
+		return nil, result
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("association %v has no run history", associationID)
+	}
+	return lastErr, ""
+}
+
+// Help prints help for the rerun-association cli command
+func (c *RerunAssociation) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("RerunAssociationHelp").Parse(rerunAssociationHelp)
+		params := rerunAssociationHelpParams{
+			cliutil.SsmCliName,
+			rerunAssociation,
+			cliutil.FormatFlag(rerunAssociationAssociationID),
+			cliutil.FormatFlag(rerunAssociationRunID),
+			listAssociationHistory,
+		}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (RerunAssociation) Name() string {
+	return rerunAssociation
+}
+
+// validateRerunAssociationInput checks the subcommands and parameters for required values, format, and unsupported values
+func (RerunAssociation) validateRerunAssociationInput(subcommands []string, parameters map[string][]string) (validation []string, associationID string, runID string) {
+	validation = make([]string, 0)
+
+	if subcommands != nil && len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", rerunAssociation, subcommands), "")
+		return validation, "", "" // invalid subcommand is an attempt to execute something that really isn't this command, so the rest of the validation is skipped in this case
+	}
+
+	for key, values := range parameters {
+		switch key {
+		case rerunAssociationAssociationID, rerunAssociationRunID:
+		default:
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+			continue
+		}
+		if len(values) != 1 {
+			validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(key)))
+			continue
+		}
+		if key == rerunAssociationAssociationID {
+			associationID = values[0]
+		} else {
+			runID = values[0]
+		}
+	}
+	if associationID == "" {
+		validation = append(validation, fmt.Sprintf("parameter %v is required", cliutil.FormatFlag(rerunAssociationAssociationID)))
+	}
+	return validation, associationID, runID
+}
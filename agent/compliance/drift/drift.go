@@ -0,0 +1,194 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package drift evaluates the desired state checks declared by documents to detect configuration drift.
+package drift
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+const (
+	// CheckTypeFileHash compares the sha256 hash of the content of a file
+	CheckTypeFileHash = "fileHash"
+	// CheckTypePackageVersion compares the version of an installed package
+	CheckTypePackageVersion = "packageVersion"
+	// CheckTypeServiceState compares the state of a service, e.g. active or running
+	CheckTypeServiceState = "serviceState"
+	// CheckTypeSysctl compares the value of a kernel parameter
+	CheckTypeSysctl = "sysctl"
+
+	// ValueAbsent is the actual value of a file or package that doesn't exist
+	ValueAbsent = "absent"
+
+	DefaultEvaluationIntervalMinutes = 30
+	MinEvaluationIntervalMinutes     = 5
+	MaxEvaluationIntervalMinutes     = 1440
+)
+
+// Check is a desired state check declared by a document
+type Check struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Target   string `json:"target"`
+	Expected string `json:"expected"`
+	Severity string `json:"severity"`
+}
+
+// Input is the input of the desired state plugin
+type Input struct {
+	contracts.PluginInput
+	Checks                    []Check `json:"checks"`
+	EvaluationIntervalMinutes int     `json:"evaluationIntervalMinutes"`
+}
+
+// Result is the result of the evaluation of a check
+type Result struct {
+	Check   Check
+	Actual  string
+	Drifted bool
+	// Message explains the drift
+	Message string
+}
+
+// evaluator returns the actual value of the target of a check
+type evaluator func(target string) (string, error)
+
+var evaluators = map[string]evaluator{
+	CheckTypeFileHash:       fileHash,
+	CheckTypePackageVersion: packageVersion,
+	CheckTypeServiceState:   serviceState,
+	CheckTypeSysctl:         sysctl,
+}
+
+var severities = map[string]bool{
+	ssm.ComplianceSeverityCritical:      true,
+	ssm.ComplianceSeverityHigh:          true,
+	ssm.ComplianceSeverityMedium:        true,
+	ssm.ComplianceSeverityLow:           true,
+	ssm.ComplianceSeverityInformational: true,
+	ssm.ComplianceSeverityUnspecified:   true,
+}
+
+var cmdExecutor = executeCommand
+
+func executeCommand(command string, args ...string) ([]byte, error) {
+	return exec.Command(command, args...).CombinedOutput()
+}
+
+// ParseInput parses and validates the properties of the desired state plugin
+func ParseInput(properties interface{}) (input Input, err error) {
+	if err = jsonutil.Remarshal(properties, &input); err != nil {
+		return input, fmt.Errorf("Invalid format in plugin properties %v;\nerror %v", properties, err)
+	}
+
+	if input.EvaluationIntervalMinutes == 0 {
+		input.EvaluationIntervalMinutes = DefaultEvaluationIntervalMinutes
+	}
+	if input.EvaluationIntervalMinutes < MinEvaluationIntervalMinutes || input.EvaluationIntervalMinutes > MaxEvaluationIntervalMinutes {
+		return input, fmt.Errorf("evaluationIntervalMinutes %v must be between %v and %v",
+			input.EvaluationIntervalMinutes, MinEvaluationIntervalMinutes, MaxEvaluationIntervalMinutes)
+	}
+
+	ids := map[string]bool{}
+	for i := range input.Checks {
+		check := &input.Checks[i]
+		if check.ID == "" {
+			return input, fmt.Errorf("check %v has no id", i)
+		}
+		if ids[check.ID] {
+			return input, fmt.Errorf("check id %v is not unique", check.ID)
+		}
+		ids[check.ID] = true
+		if _, known := evaluators[check.Type]; !known {
+			return input, fmt.Errorf("check %v has unknown type %v", check.ID, check.Type)
+		}
+		if check.Target == "" {
+			return input, fmt.Errorf("check %v has no target", check.ID)
+		}
+		if check.Severity == "" {
+			check.Severity = ssm.ComplianceSeverityUnspecified
+		}
+		check.Severity = strings.ToUpper(check.Severity)
+		if !severities[check.Severity] {
+			return input, fmt.Errorf("check %v has invalid severity %v", check.ID, check.Severity)
+		}
+	}
+	return input, nil
+}
+
+// Evaluate evaluates the checks and returns their results in the same order
+func Evaluate(log log.T, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		result := Result{Check: check}
+		actual, err := evaluators[check.Type](check.Target)
+		if err != nil {
+			result.Drifted = true
+			result.Message = fmt.Sprintf("unable to evaluate %v %v, %v", check.Type, check.Target, err)
+		} else {
+			result.Actual = actual
+			if !matches(check.Type, check.Expected, actual) {
+				result.Drifted = true
+				result.Message = fmt.Sprintf("%v %v is %v instead of %v", check.Type, check.Target, actual, check.Expected)
+			}
+		}
+		if result.Drifted {
+			log.Infof("Check %v drifted, %v", check.ID, result.Message)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// matches compares the expected value to the actual value of a check
+func matches(checkType, expected, actual string) bool {
+	switch checkType {
+	case CheckTypeFileHash, CheckTypeServiceState:
+		return strings.EqualFold(strings.TrimSpace(expected), actual)
+	case CheckTypeSysctl:
+		// multi value kernel parameters are separated by tabs
+		return strings.Join(strings.Fields(expected), " ") == strings.Join(strings.Fields(actual), " ")
+	default:
+		return strings.TrimSpace(expected) == actual
+	}
+}
+
+// fileHash returns the hex encoded sha256 hash of the content of the file
+func fileHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ValueAbsent, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package drift
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInputSetsDefaults(t *testing.T) {
+	properties := map[string]interface{}{
+		"checks": []interface{}{
+			map[string]interface{}{"id": "motd", "type": "fileHash", "target": "/etc/motd", "expected": "abc"},
+			map[string]interface{}{"id": "sshd", "type": "serviceState", "target": "sshd", "expected": "active", "severity": "high"},
+		},
+	}
+
+	input, err := ParseInput(properties)
+
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultEvaluationIntervalMinutes, input.EvaluationIntervalMinutes)
+	assert.Equal(t, "UNSPECIFIED", input.Checks[0].Severity)
+	assert.Equal(t, "HIGH", input.Checks[1].Severity)
+}
+
+func TestParseInputReturnsErrorForInvalidChecks(t *testing.T) {
+	invalidInputs := []map[string]interface{}{
+		{"checks": []interface{}{map[string]interface{}{"type": "fileHash", "target": "/etc/motd"}}},
+		{"checks": []interface{}{map[string]interface{}{"id": "a", "type": "registry", "target": "HKLM"}}},
+		{"checks": []interface{}{map[string]interface{}{"id": "a", "type": "sysctl"}}},
+		{"checks": []interface{}{map[string]interface{}{"id": "a", "type": "sysctl", "target": "vm.swappiness", "severity": "urgent"}}},
+		{"checks": []interface{}{
+			map[string]interface{}{"id": "a", "type": "sysctl", "target": "vm.swappiness"},
+			map[string]interface{}{"id": "a", "type": "sysctl", "target": "vm.overcommit_memory"},
+		}},
+		{"evaluationIntervalMinutes": 1},
+		{"checks": "none"},
+	}
+
+	for _, properties := range invalidInputs {
+		_, err := ParseInput(properties)
+		assert.Error(t, err, "%v", properties)
+	}
+}
+
+func TestEvaluateReportsDriftedChecks(t *testing.T) {
+	defer func(original map[string]evaluator) { evaluators = original }(evaluators)
+	evaluators = map[string]evaluator{
+		CheckTypeServiceState: func(target string) (string, error) { return "inactive", nil },
+		CheckTypeSysctl:       func(target string) (string, error) { return "4096\t87380\t6291456", nil },
+		CheckTypePackageVersion: func(target string) (string, error) {
+			return "", errors.New("no package manager")
+		},
+	}
+	checks := []Check{
+		{ID: "service", Type: CheckTypeServiceState, Target: "sshd", Expected: "active"},
+		{ID: "sysctl", Type: CheckTypeSysctl, Target: "net.ipv4.tcp_rmem", Expected: "4096 87380 6291456"},
+		{ID: "package", Type: CheckTypePackageVersion, Target: "openssl", Expected: "1.1.1"},
+	}
+
+	results := Evaluate(log.NewMockLog(), checks)
+
+	assert.Equal(t, 3, len(results))
+	assert.True(t, results[0].Drifted)
+	assert.Equal(t, "inactive", results[0].Actual)
+	assert.Equal(t, "serviceState sshd is inactive instead of active", results[0].Message)
+	assert.False(t, results[1].Drifted)
+	assert.True(t, results[2].Drifted)
+	assert.Equal(t, "unable to evaluate packageVersion openssl, no package manager", results[2].Message)
+}
+
+func TestFileHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drift")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "motd")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("hello\n"), 0644))
+
+	results := Evaluate(log.NewMockLog(), []Check{
+		{ID: "same", Type: CheckTypeFileHash, Target: path, Expected: "5891B5B522D5DF086D0FF0B110FBD9D21BB4FC7163AF34D08286A2E846F6BE03"},
+		{ID: "changed", Type: CheckTypeFileHash, Target: path, Expected: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{ID: "missing", Type: CheckTypeFileHash, Target: filepath.Join(dir, "missing"), Expected: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	})
+
+	assert.False(t, results[0].Drifted)
+	assert.True(t, results[1].Drifted)
+	assert.Equal(t, "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03", results[1].Actual)
+	assert.True(t, results[2].Drifted)
+	assert.Equal(t, ValueAbsent, results[2].Actual)
+}
@@ -0,0 +1,70 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build darwin freebsd linux netbsd openbsd
+
+package drift
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const procSysDir = "/proc/sys"
+
+var lookPath = exec.LookPath
+
+// packageVersion returns the version of the package installed by dpkg or rpm
+func packageVersion(name string) (string, error) {
+	var output []byte
+	var err error
+	if _, lookErr := lookPath("dpkg-query"); lookErr == nil {
+		output, err = cmdExecutor("dpkg-query", "-W", "-f=${Version}", name)
+	} else if _, lookErr := lookPath("rpm"); lookErr == nil {
+		output, err = cmdExecutor("rpm", "-q", "--qf", "%{VERSION}-%{RELEASE}", name)
+	} else {
+		return "", fmt.Errorf("neither dpkg nor rpm is available")
+	}
+
+	// both exit with an error when the package is not installed
+	if err != nil {
+		return ValueAbsent, nil
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// serviceState returns the state of the systemd unit, e.g. active or inactive
+func serviceState(name string) (string, error) {
+	// is-active exits with an error for any state other than active
+	output, err := cmdExecutor("systemctl", "is-active", name)
+	state := strings.TrimSpace(string(output))
+	if state == "" && err != nil {
+		return "", err
+	}
+	return state, nil
+}
+
+// sysctl returns the value of the kernel parameter, e.g. net.ipv4.ip_forward
+func sysctl(key string) (string, error) {
+	if strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid kernel parameter %v", key)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(procSysDir, strings.Replace(key, ".", "/", -1)))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
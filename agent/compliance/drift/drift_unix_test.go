@@ -0,0 +1,75 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build darwin freebsd linux netbsd openbsd
+
+package drift
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var originalLookPath = lookPath
+
+func TestPackageVersionUsesAvailablePackageManager(t *testing.T) {
+	defer func() { lookPath, cmdExecutor = originalLookPath, executeCommand }()
+	var commands []string
+	cmdExecutor = func(command string, args ...string) ([]byte, error) {
+		commands = append(commands, command)
+		if args[len(args)-1] == "missing" {
+			return []byte("package missing is not installed"), errors.New("exit status 1")
+		}
+		return []byte("1.0.2k-16.el7\n"), nil
+	}
+	lookPath = func(file string) (string, error) {
+		if file == "rpm" {
+			return "/usr/bin/rpm", nil
+		}
+		return "", errors.New("not found")
+	}
+
+	version, err := packageVersion("openssl")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.2k-16.el7", version)
+
+	version, err = packageVersion("missing")
+	assert.NoError(t, err)
+	assert.Equal(t, ValueAbsent, version)
+	assert.Equal(t, []string{"rpm", "rpm"}, commands)
+
+	lookPath = func(file string) (string, error) { return "", errors.New("not found") }
+	_, err = packageVersion("openssl")
+	assert.Error(t, err)
+}
+
+func TestServiceStateReturnsInactiveState(t *testing.T) {
+	defer func() { cmdExecutor = executeCommand }()
+	cmdExecutor = func(command string, args ...string) ([]byte, error) {
+		assert.Equal(t, "systemctl", command)
+		assert.Equal(t, []string{"is-active", "sshd"}, args)
+		return []byte("inactive\n"), errors.New("exit status 3")
+	}
+
+	state, err := serviceState("sshd")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "inactive", state)
+}
+
+func TestSysctlRejectsPathTraversal(t *testing.T) {
+	_, err := sysctl("../../etc/shadow")
+	assert.Error(t, err)
+}
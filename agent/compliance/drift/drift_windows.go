@@ -0,0 +1,57 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build windows
+
+package drift
+
+import (
+	"fmt"
+	"strings"
+)
+
+// packageVersion returns the version of the installed package
+func packageVersion(name string) (string, error) {
+	output, err := cmdExecutor("powershell", "-NoProfile", "-NonInteractive", "-Command",
+		fmt.Sprintf("Get-Package -Name '%v' -ErrorAction SilentlyContinue | Select-Object -First 1 -ExpandProperty Version", quote(name)))
+	if err != nil {
+		return "", err
+	}
+	if version := strings.TrimSpace(string(output)); version != "" {
+		return version, nil
+	}
+	return ValueAbsent, nil
+}
+
+// serviceState returns the status of the service, e.g. Running or Stopped
+func serviceState(name string) (string, error) {
+	output, err := cmdExecutor("powershell", "-NoProfile", "-NonInteractive", "-Command",
+		fmt.Sprintf("(Get-Service -Name '%v' -ErrorAction SilentlyContinue).Status", quote(name)))
+	if err != nil {
+		return "", err
+	}
+	if state := strings.TrimSpace(string(output)); state != "" {
+		return state, nil
+	}
+	return ValueAbsent, nil
+}
+
+// sysctl is not supported on windows
+func sysctl(key string) (string, error) {
+	return "", fmt.Errorf("kernel parameters are not supported on windows")
+}
+
+// quote escapes the value for a single quoted powershell string
+func quote(value string) string {
+	return strings.Replace(value, "'", "''", -1)
+}
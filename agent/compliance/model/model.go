@@ -14,6 +14,7 @@
 package model
 
 import (
+	"sort"
 	"sync"
 	"time"
 
@@ -37,12 +38,29 @@ type AssociationComplianceItem struct {
 	Title              string
 	ComplianceSeverity string
 	ComplianceStatus   string
+	// Reason explains why the association did not run
+	Reason string `json:",omitempty"`
+}
+
+// DriftComplianceItem represents a desired state check of an association that drifted
+type DriftComplianceItem struct {
+	AssociationId      string
+	CheckId            string
+	CheckType          string
+	Target             string
+	Expected           string
+	Actual             string
+	Message            string
+	ComplianceSeverity string
 }
 
 // Association compliance status is Unspecified by default
 var associationComplianceItems = []*AssociationComplianceItem{}
 var lock = sync.RWMutex{}
 
+// driftComplianceItems stores the drifted checks by association
+var driftComplianceItems = map[string][]*DriftComplianceItem{}
+
 /**
  * Update compliance item based on the executed instance association and update timestamp.
  */
@@ -53,46 +71,61 @@ func UpdateAssociationComplianceItem(associationId string, documentName string,
 		return
 	}
 
-	lock.Lock()
-	defer lock.Unlock()
-
 	var compliantStatus = COMPLIANT
 	if contracts.AssociationStatusSuccess != associationStatus {
 		compliantStatus = NON_COMPLIANT
 	}
 
-	var statusFound = false
+	updateAssociationComplianceItem(&AssociationComplianceItem{
+		AssociationId:      associationId,
+		ExecutionTime:      executionTime,
+		DocumentName:       documentName,
+		DocumentVersion:    documentVersion,
+		Title:              ASSOCIATION_COMPLIANCE_TITLE,
+		ComplianceSeverity: UNSPECIFIED,
+		ComplianceStatus:   compliantStatus,
+	})
+}
+
+/**
+ * Update compliance item of the association skipped at the given time, for the given reason.
+ */
+func UpdateSkippedAssociationComplianceItem(associationId string, documentName string, documentVersion string, reason string, executionTime time.Time) {
+	updateAssociationComplianceItem(&AssociationComplianceItem{
+		AssociationId:      associationId,
+		ExecutionTime:      executionTime,
+		DocumentName:       documentName,
+		DocumentVersion:    documentVersion,
+		Title:              ASSOCIATION_COMPLIANCE_TITLE,
+		ComplianceSeverity: UNSPECIFIED,
+		ComplianceStatus:   NON_COMPLIANT,
+		Reason:             reason,
+	})
+}
+
+// updateAssociationComplianceItem replaces the compliance item of the association unless it is more recent
+func updateAssociationComplianceItem(item *AssociationComplianceItem) {
+	lock.Lock()
+	defer lock.Unlock()
+
 	for i, status := range associationComplianceItems {
-		if status.AssociationId == associationId {
-			if status.ExecutionTime.Before(executionTime) {
-				associationComplianceItems[i] = &AssociationComplianceItem{
-					associationId,
-					executionTime,
-					documentName,
-					documentVersion,
-					ASSOCIATION_COMPLIANCE_TITLE,
-					UNSPECIFIED,
-					compliantStatus,
-				}
+		if status.AssociationId == item.AssociationId {
+			if status.ExecutionTime.Before(item.ExecutionTime) {
+				associationComplianceItems[i] = item
 			}
-
-			statusFound = true
-			break
+			return
 		}
 	}
 
-	if !statusFound {
-		var newStatus = &AssociationComplianceItem{
-			associationId,
-			executionTime,
-			documentName,
-			documentVersion,
-			ASSOCIATION_COMPLIANCE_TITLE,
-			UNSPECIFIED,
-			compliantStatus,
-		}
+	associationComplianceItems = append(associationComplianceItems, item)
+}
 
-		associationComplianceItems = append(associationComplianceItems, newStatus)
+/**
+ * Restore association compliance items kept from before an agent restart, items updated since then are kept.
+ */
+func RestoreAssociationComplianceItems(items []*AssociationComplianceItem) {
+	for _, item := range items {
+		updateAssociationComplianceItem(item)
 	}
 }
 
@@ -117,6 +150,12 @@ func RefreshAssociationComplianceItems(associations []*model.InstanceAssociation
 	}
 
 	associationComplianceItems = newComplianceItems
+
+	for associationId := range driftComplianceItems {
+		if _, exist := associationMap[associationId]; !exist {
+			delete(driftComplianceItems, associationId)
+		}
+	}
 }
 
 func GetAssociationComplianceEntries() []*AssociationComplianceItem {
@@ -125,3 +164,35 @@ func GetAssociationComplianceEntries() []*AssociationComplianceItem {
 
 	return associationComplianceItems
 }
+
+/**
+ * Replace the drifted checks of the association with the drifted checks of its latest evaluation.
+ */
+func UpdateDriftComplianceItems(associationId string, items []*DriftComplianceItem) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(items) == 0 {
+		delete(driftComplianceItems, associationId)
+		return
+	}
+	driftComplianceItems[associationId] = items
+}
+
+// GetDriftComplianceEntries returns the drifted checks of all associations ordered by association and check
+func GetDriftComplianceEntries() []*DriftComplianceItem {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	var associationIds []string
+	for associationId := range driftComplianceItems {
+		associationIds = append(associationIds, associationId)
+	}
+	sort.Strings(associationIds)
+
+	var entries = []*DriftComplianceItem{}
+	for _, associationId := range associationIds {
+		entries = append(entries, driftComplianceItems[associationId]...)
+	}
+	return entries
+}
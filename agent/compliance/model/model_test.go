@@ -120,6 +120,33 @@ func TestUpdateAssociationComplianceItemIgnoreStaleUpdate(t *testing.T) {
 	assert.Equal(t, item1.Title, ASSOCIATION_COMPLIANCE_TITLE)
 }
 
+func TestUpdateSkippedAssociationComplianceItem(t *testing.T) {
+	association := &model.InstanceAssociation{
+		Association: &ssm.InstanceAssociationSummary{
+			Name:            aws.String("AWS-RunPatchBaseline"),
+			AssociationId:   aws.String("association_skipped"),
+			DocumentVersion: aws.String("1"),
+		},
+	}
+	RefreshAssociationComplianceItems([]*model.InstanceAssociation{association})
+
+	executionTime := time.Now()
+	UpdateAssociationComplianceItem("association_skipped", "AWS-RunPatchBaseline", "1", contracts.AssociationStatusSuccess, executionTime)
+	UpdateSkippedAssociationComplianceItem("association_skipped", "AWS-RunPatchBaseline", "1", "blackout 1 is in effect", executionTime.Add(time.Minute))
+
+	items := GetAssociationComplianceEntries()
+	assert.Equal(t, 1, len(items))
+	assert.Equal(t, NON_COMPLIANT, items[0].ComplianceStatus)
+	assert.Equal(t, "blackout 1 is in effect", items[0].Reason)
+
+	// a successful execution clears the reason
+	UpdateAssociationComplianceItem("association_skipped", "AWS-RunPatchBaseline", "1", contracts.AssociationStatusSuccess, executionTime.Add(time.Hour))
+	items = GetAssociationComplianceEntries()
+	assert.Equal(t, COMPLIANT, items[0].ComplianceStatus)
+	assert.Equal(t, "", items[0].Reason)
+	RefreshAssociationComplianceItems([]*model.InstanceAssociation{})
+}
+
 func TestRefreshAssociationComplianceItems(t *testing.T) {
 	RefreshAssociationComplianceItems([]*model.InstanceAssociation{})
 	association1 := &model.InstanceAssociation{
@@ -156,3 +183,29 @@ func TestRefreshAssociationComplianceItems(t *testing.T) {
 	assert.Equal(t, 1, len(complianceItems))
 
 }
+
+func TestUpdateDriftComplianceItems(t *testing.T) {
+	UpdateDriftComplianceItems("association_2", []*DriftComplianceItem{
+		{AssociationId: "association_2", CheckId: "sshd"},
+	})
+	UpdateDriftComplianceItems("association_1", []*DriftComplianceItem{
+		{AssociationId: "association_1", CheckId: "motd"},
+		{AssociationId: "association_1", CheckId: "swappiness"},
+	})
+
+	entries := GetDriftComplianceEntries()
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, "motd", entries[0].CheckId)
+	assert.Equal(t, "swappiness", entries[1].CheckId)
+	assert.Equal(t, "sshd", entries[2].CheckId)
+
+	// the checks of association_1 are back to the desired state
+	UpdateDriftComplianceItems("association_1", nil)
+	entries = GetDriftComplianceEntries()
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "sshd", entries[0].CheckId)
+
+	// association_2 is removed
+	RefreshAssociationComplianceItems([]*model.InstanceAssociation{})
+	assert.Equal(t, 0, len(GetDriftComplianceEntries()))
+}
@@ -16,6 +16,7 @@ package compliance
 import (
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/compliance/model"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/stretchr/testify/mock"
 )
@@ -38,3 +39,17 @@ func (m *ComplianceUploaderMock) UpdateAssociationCompliance(associationId strin
 	args := m.Called(associationId, instanceId, documentName, documentVersion, associationStatus, executionTime)
 	return args.Error(0)
 }
+
+func (m *ComplianceUploaderMock) UpdateSkippedAssociationCompliance(associationId string, instanceId string, documentName string, documentVersion string, reason string, executionTime time.Time) error {
+	args := m.Called(associationId, instanceId, documentName, documentVersion, reason, executionTime)
+	return args.Error(0)
+}
+
+func (m *ComplianceUploaderMock) UpdateDriftCompliance(associationId string, instanceId string, items []*model.DriftComplianceItem, executionTime time.Time) error {
+	args := m.Called(associationId, instanceId, items, executionTime)
+	return args.Error(0)
+}
+
+func (m *ComplianceUploaderMock) SendQueuedCompliance(log log.T, instanceID string) {
+	m.Called(log, instanceID)
+}
@@ -17,15 +17,20 @@ import (
 	"crypto/md5"
 	"encoding/base64"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"encoding/json"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	associationModel "github.com/aws/amazon-ssm-agent/agent/association/model"
 	"github.com/aws/amazon-ssm-agent/agent/compliance/model"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/datauploader"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
@@ -37,8 +42,11 @@ import (
 const (
 	stopPolicyErrorThreshold      = 10
 	associationComplianceType     = "Association"
+	driftComplianceType           = "Custom:ConfigurationDrift"
+	driftComplianceTitle          = "Configuration drift"
 	Name                          = "ComplianceUploader"
 	AssociationComplianceItemName = "AssociationComplianceItem"
+	DriftComplianceItemName       = "DriftComplianceItem"
 )
 
 var (
@@ -49,6 +57,25 @@ var (
 type T interface {
 	CreateNewServiceIfUnHealthy(log log.T)
 	UpdateAssociationCompliance(associationId string, instanceId string, documentName string, documentVersion string, associationStatus string, executionTime time.Time) error
+	UpdateSkippedAssociationCompliance(associationId string, instanceId string, documentName string, documentVersion string, reason string, executionTime time.Time) error
+	UpdateDriftCompliance(associationId string, instanceId string, items []*model.DriftComplianceItem, executionTime time.Time) error
+	SendQueuedCompliance(log log.T, instanceID string)
+}
+
+// queuedCompliance represents association compliance which could not be delivered,
+// it is kept on disk with its compliance items so it survives agent restarts
+type queuedCompliance struct {
+	InstanceID    string
+	ExecutionTime time.Time
+	Items         []*model.AssociationComplianceItem
+}
+
+// decoupling for easy testability
+var queuedComplianceFilePath = func(instanceID string) string {
+	return filepath.Join(appconfig.DefaultDataStorePath,
+		instanceID,
+		appconfig.ComplianceRootDirName,
+		appconfig.ComplianceQueuedFileName)
 }
 
 // ComplianceService wraps the Ssm Service
@@ -58,6 +85,10 @@ type ComplianceUploader struct {
 	name       string
 	context    context.T
 	optimizer  datauploader.Optimizer
+	// queued is set while the latest association compliance has not been delivered
+	queued *queuedCompliance
+	// loadedInstanceID is the instance whose queued compliance was read from disk
+	loadedInstanceID string
 }
 
 // NewComplianceService returns a new compliance service
@@ -116,9 +147,120 @@ func (u *ComplianceUploader) UpdateAssociationCompliance(associationID string, i
 		return nil
 	}
 
-	log := u.context.Log()
+	// associations defined on the instance are not reported to the service
+	if associationModel.IsLocalAssociation(associationID) {
+		return nil
+	}
 
 	model.UpdateAssociationComplianceItem(associationID, documentName, documentVersion, associationStatus, executionTime)
+	return u.uploadAssociationCompliance(instanceID, executionTime)
+}
+
+/**
+ * Update the compliance of the association skipped for the given reason as non compliant and upload it.
+ */
+func (u *ComplianceUploader) UpdateSkippedAssociationCompliance(associationID string, instanceID string, documentName string, documentVersion string, reason string, executionTime time.Time) error {
+	// associations defined on the instance are not reported to the service
+	if associationModel.IsLocalAssociation(associationID) {
+		return nil
+	}
+
+	model.UpdateSkippedAssociationComplianceItem(associationID, documentName, documentVersion, reason, executionTime)
+	return u.uploadAssociationCompliance(instanceID, executionTime)
+}
+
+// uploadAssociationCompliance uploads the association compliance items, queuing them while the service is unreachable
+func (u *ComplianceUploader) uploadAssociationCompliance(instanceID string, executionTime time.Time) error {
+	log := u.context.Log()
+
+	// every upload carries all association compliance items, so only the latest upload needs to be queued
+	lock.Lock()
+	defer lock.Unlock()
+	u.loadQueuedCompliance(log, instanceID)
+	if err := u.putAssociationCompliance(log, instanceID, executionTime); err != nil {
+		u.queued = &queuedCompliance{
+			InstanceID:    instanceID,
+			ExecutionTime: executionTime,
+			Items:         model.GetAssociationComplianceEntries(),
+		}
+		u.saveQueuedCompliance(log)
+		log.Infof("Queued association compliance until the service is reachable")
+		return err
+	}
+	u.queued = nil
+	u.saveQueuedCompliance(log)
+	return nil
+}
+
+// SendQueuedCompliance delivers the association compliance queued while the service was unreachable
+func (u *ComplianceUploader) SendQueuedCompliance(log log.T, instanceID string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	u.loadQueuedCompliance(log, instanceID)
+	if u.queued == nil {
+		return
+	}
+	if err := u.putAssociationCompliance(log, u.queued.InstanceID, u.queued.ExecutionTime); err != nil {
+		log.Errorf("Unable to deliver queued association compliance, %v", err)
+		return
+	}
+	log.Infof("Delivered queued association compliance")
+	u.queued = nil
+	u.saveQueuedCompliance(log)
+}
+
+// loadQueuedCompliance reads the compliance queued for the given instance from disk unless it is already loaded,
+// the queued compliance items are restored so they are part of the next upload
+func (u *ComplianceUploader) loadQueuedCompliance(log log.T, instanceID string) {
+	if u.loadedInstanceID == instanceID {
+		return
+	}
+	u.loadedInstanceID = instanceID
+
+	content, err := ioutil.ReadFile(queuedComplianceFilePath(instanceID))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("Unable to read queued association compliance, %v", err)
+		}
+		return
+	}
+	var queued queuedCompliance
+	if err = json.Unmarshal(content, &queued); err != nil {
+		log.Errorf("Unable to parse queued association compliance, %v", err)
+		return
+	}
+	model.RestoreAssociationComplianceItems(queued.Items)
+	if u.queued == nil || u.queued.ExecutionTime.Before(queued.ExecutionTime) {
+		u.queued = &queued
+	}
+}
+
+// saveQueuedCompliance writes the queued compliance to disk, or deletes it once nothing is queued
+func (u *ComplianceUploader) saveQueuedCompliance(log log.T) {
+	path := queuedComplianceFilePath(u.loadedInstanceID)
+	if u.queued == nil {
+		if err := fileutil.DeleteFile(path); err != nil && !os.IsNotExist(err) {
+			log.Errorf("Unable to delete queued association compliance, %v", err)
+		}
+		return
+	}
+
+	content, err := json.Marshal(u.queued)
+	if err != nil {
+		log.Errorf("Unable to marshal queued association compliance, %v", err)
+		return
+	}
+	if err = fileutil.MakeDirs(filepath.Dir(path)); err == nil {
+		err = ioutil.WriteFile(path, content, appconfig.ReadWriteAccess)
+	}
+	if err != nil {
+		log.Errorf("Unable to save queued association compliance, %v", err)
+	}
+}
+
+// putAssociationCompliance uploads all association compliance items
+func (u *ComplianceUploader) putAssociationCompliance(log log.T, instanceID string, executionTime time.Time) error {
 	var associationComplianceEntries = model.GetAssociationComplianceEntries()
 
 	oldHash := u.optimizer.GetContentHash(AssociationComplianceItemName)
@@ -188,8 +330,87 @@ func (u *ComplianceUploader) ConvertToSsmAssociationComplianceItems(log log.T, a
 				"DocumentVersion": aws.String(item.DocumentVersion),
 			},
 		}
+		if item.Reason != "" {
+			complianceItem.Details["Reason"] = aws.String(item.Reason)
+		}
 		associationComplianceItems = append(associationComplianceItems, complianceItem)
 	}
 	return associationComplianceItems, newHash, nil
 
 }
+
+/**
+ * Replace the drifted checks of the association and upload the drifted checks of all associations as non compliant.
+ */
+func (u *ComplianceUploader) UpdateDriftCompliance(associationID string, instanceID string, items []*model.DriftComplianceItem, executionTime time.Time) error {
+	log := u.context.Log()
+	model.UpdateDriftComplianceItems(associationID, items)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	oldHash := u.optimizer.GetContentHash(DriftComplianceItemName)
+	newComplianceItems, itemContentHash, err := u.ConvertToSsmDriftComplianceItems(log, model.GetDriftComplianceEntries(), oldHash)
+	if err != nil {
+		return fmt.Errorf("Unable to convert drift compliance %v", err)
+	}
+
+	response, err := u.ssmSvc.PutComplianceItems(
+		log,
+		&executionTime,
+		"",
+		"",
+		instanceID,
+		driftComplianceType,
+		itemContentHash,
+		newComplianceItems)
+
+	if err != nil {
+		return fmt.Errorf("Unable to update drift compliance %v", err)
+	}
+
+	if itemContentHash != oldHash {
+		u.optimizer.UpdateContentHash(DriftComplianceItemName, itemContentHash)
+	}
+
+	log.Debugf("Put drift compliance item %v return response %v", newComplianceItems, response)
+	return nil
+}
+
+// ConvertToSsmDriftComplianceItems converts the drifted checks into non compliant *ssm.ComplianceItemEntry, one per check.
+// Like ConvertToSsmAssociationComplianceItems it only returns the content hash when the drifted checks haven't changed.
+func (u *ComplianceUploader) ConvertToSsmDriftComplianceItems(log log.T, driftComplianceEntries []*model.DriftComplianceItem, oldHash string) (
+	driftComplianceItems []*ssm.ComplianceItemEntry, contentHash string, err error) {
+
+	var dataB []byte
+	if dataB, err = json.Marshal(driftComplianceEntries); err != nil {
+		return
+	}
+
+	newHash := calculateCheckSum(dataB)
+	if newHash == oldHash {
+		log.Debugf("Compliance data for %v is same as before - we can just send content hash", DriftComplianceItemName)
+		return []*ssm.ComplianceItemEntry{}, newHash, nil
+	}
+
+	driftComplianceItems = []*ssm.ComplianceItemEntry{}
+	for _, item := range driftComplianceEntries {
+		driftComplianceItems = append(driftComplianceItems, &ssm.ComplianceItemEntry{
+			// check ids are only unique within an association
+			Id:       aws.String(item.AssociationId + "/" + item.CheckId),
+			Status:   aws.String(model.NON_COMPLIANT),
+			Severity: aws.String(item.ComplianceSeverity),
+			Title:    aws.String(driftComplianceTitle),
+			Details: map[string]*string{
+				"AssociationId": aws.String(item.AssociationId),
+				"CheckId":       aws.String(item.CheckId),
+				"CheckType":     aws.String(item.CheckType),
+				"Target":        aws.String(item.Target),
+				"Expected":      aws.String(item.Expected),
+				"Actual":        aws.String(item.Actual),
+				"Message":       aws.String(item.Message),
+			},
+		})
+	}
+	return driftComplianceItems, newHash, nil
+}
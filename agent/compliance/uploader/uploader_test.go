@@ -15,6 +15,10 @@ package compliance
 
 import (
 	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
@@ -82,6 +86,24 @@ func FakeComplianceItems(count int) (items []*model.AssociationComplianceItem) {
 	return
 }
 
+func TestConvertToSsmComplianceItemWithReason(t *testing.T) {
+	c := context.NewMockDefault()
+	u := MockComplianceUploader()
+
+	item := AssociationComplianceItem()
+	item.ComplianceStatus = model.NON_COMPLIANT
+	item.Reason = "outside of the maintenance windows"
+	complianceItems, _, err := u.ConvertToSsmAssociationComplianceItems(c.Log(), []*model.AssociationComplianceItem{item}, "RandomHash")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "outside of the maintenance windows", *complianceItems[0].Details["Reason"])
+
+	complianceItems, _, err = u.ConvertToSsmAssociationComplianceItems(c.Log(), []*model.AssociationComplianceItem{AssociationComplianceItem()}, "RandomHash")
+	assert.Nil(t, err)
+	_, found := complianceItems[0].Details["Reason"]
+	assert.False(t, found)
+}
+
 func TestConvertToSsmComplianceItemAreEqual(t *testing.T) {
 
 	var items []*model.AssociationComplianceItem
@@ -125,6 +147,7 @@ func TestConvertToSsmComplianceItems(t *testing.T) {
 }
 
 func TestUpdateAssociationCompliance(t *testing.T) {
+	defer useTempQueuedComplianceFile(t)()
 	u := MockComplianceUploader()
 
 	association1 := &associationModel.InstanceAssociation{
@@ -219,3 +242,147 @@ func TestChecksumSameForSameContent(t *testing.T) {
 
 	assert.Equal(t, calculateCheckSum(dataB1), calculateCheckSum(dataB2))
 }
+
+// useTempQueuedComplianceFile points the queued compliance to a temporary directory and returns a function restoring it
+func useTempQueuedComplianceFile(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "compliance")
+	assert.Nil(t, err)
+	original := queuedComplianceFilePath
+	queuedComplianceFilePath = func(instanceID string) string {
+		return filepath.Join(dir, instanceID, "queuedCompliance.json")
+	}
+	return func() {
+		queuedComplianceFilePath = original
+		os.RemoveAll(dir)
+	}
+}
+
+func TestUpdateAssociationComplianceQueuedWhileServiceUnreachable(t *testing.T) {
+	defer useTempQueuedComplianceFile(t)()
+	u := MockComplianceUploader()
+	serviceMock := ssmSvc.NewMockDefault()
+	u.ssmSvc = serviceMock
+
+	putArguments := []interface{}{
+		mock.AnythingOfType("*log.Mock"),
+		mock.AnythingOfType("*time.Time"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("[]*ssm.ComplianceItemEntry")}
+	serviceMock.On("PutComplianceItems", putArguments...).Return(&ssm.PutComplianceItemsOutput{}, errors.New("endpoint unreachable")).Once()
+	serviceMock.On("PutComplianceItems", putArguments...).Return(&ssm.PutComplianceItemsOutput{}, nil)
+
+	err := u.UpdateAssociationCompliance("association_1", "i-123", "testDoc", "1", "Failed", time.Now())
+	assert.NotNil(t, err)
+	assert.NotNil(t, u.queued)
+
+	_, err = os.Stat(queuedComplianceFilePath("i-123"))
+	assert.Nil(t, err)
+
+	u.SendQueuedCompliance(u.context.Log(), "i-123")
+	assert.Nil(t, u.queued)
+	_, err = os.Stat(queuedComplianceFilePath("i-123"))
+	assert.True(t, os.IsNotExist(err))
+	assert.True(t, serviceMock.AssertNumberOfCalls(t, "PutComplianceItems", 2))
+	assert.Equal(t, "i-123", serviceMock.Calls[1].Arguments.String(4))
+
+	// nothing is sent when nothing is queued
+	u.SendQueuedCompliance(u.context.Log(), "i-123")
+	assert.True(t, serviceMock.AssertNumberOfCalls(t, "PutComplianceItems", 2))
+}
+
+func TestQueuedComplianceIsDeliveredAfterRestart(t *testing.T) {
+	defer useTempQueuedComplianceFile(t)()
+	model.RefreshAssociationComplianceItems([]*associationModel.InstanceAssociation{})
+	defer model.RefreshAssociationComplianceItems([]*associationModel.InstanceAssociation{})
+
+	putArguments := []interface{}{
+		mock.AnythingOfType("*log.Mock"),
+		mock.AnythingOfType("*time.Time"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("[]*ssm.ComplianceItemEntry")}
+
+	u := MockComplianceUploader()
+	serviceMock := ssmSvc.NewMockDefault()
+	u.ssmSvc = serviceMock
+	serviceMock.On("PutComplianceItems", putArguments...).Return(&ssm.PutComplianceItemsOutput{}, errors.New("endpoint unreachable"))
+
+	executionTime := time.Now()
+	err := u.UpdateAssociationCompliance("association_restart", "i-123", "testDoc", "1", "Failed", executionTime)
+	assert.NotNil(t, err)
+
+	// the agent restarts, the compliance items kept in memory are lost
+	model.RefreshAssociationComplianceItems([]*associationModel.InstanceAssociation{})
+	assert.Empty(t, model.GetAssociationComplianceEntries())
+
+	restarted := MockComplianceUploader()
+	restartedServiceMock := ssmSvc.NewMockDefault()
+	restarted.ssmSvc = restartedServiceMock
+	restartedServiceMock.On("PutComplianceItems", putArguments...).Return(&ssm.PutComplianceItemsOutput{}, nil)
+
+	restarted.SendQueuedCompliance(restarted.context.Log(), "i-123")
+	assert.True(t, restartedServiceMock.AssertNumberOfCalls(t, "PutComplianceItems", 1))
+	assert.True(t, executionTime.Equal(*restartedServiceMock.Calls[0].Arguments.Get(1).(*time.Time)))
+	entries := restartedServiceMock.Calls[0].Arguments.Get(7).([]*ssm.ComplianceItemEntry)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "association_restart", *entries[0].Id)
+	assert.Nil(t, restarted.queued)
+	_, err = os.Stat(queuedComplianceFilePath("i-123"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUpdateDriftComplianceUploadsEachDriftedCheck(t *testing.T) {
+	u := MockComplianceUploader()
+	serviceMock := ssmSvc.NewMockDefault()
+	u.ssmSvc = serviceMock
+	defer model.UpdateDriftComplianceItems("association_1", nil)
+
+	serviceMock.On(
+		"PutComplianceItems",
+		mock.AnythingOfType("*log.Mock"),
+		mock.AnythingOfType("*time.Time"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("string"),
+		mock.AnythingOfType("[]*ssm.ComplianceItemEntry")).Return(&ssm.PutComplianceItemsOutput{}, nil)
+
+	executionTime := time.Now()
+	err := u.UpdateDriftCompliance("association_1", "i-123", []*model.DriftComplianceItem{
+		{AssociationId: "association_1", CheckId: "sshd", CheckType: "serviceState", Target: "sshd", Expected: "active", Actual: "inactive", ComplianceSeverity: "HIGH"},
+		{AssociationId: "association_1", CheckId: "motd", CheckType: "fileHash", Target: "/etc/motd", Expected: "abc", Actual: "absent", ComplianceSeverity: "LOW"},
+	}, executionTime)
+
+	assert.NoError(t, err)
+	arguments := serviceMock.Calls[0].Arguments
+	assert.Equal(t, "i-123", arguments.String(4))
+	assert.Equal(t, "Custom:ConfigurationDrift", arguments.String(5))
+	items := arguments.Get(7).([]*ssm.ComplianceItemEntry)
+	assert.Equal(t, 2, len(items))
+	assert.Equal(t, "association_1/sshd", *items[0].Id)
+	assert.Equal(t, "NON_COMPLIANT", *items[0].Status)
+	assert.Equal(t, "HIGH", *items[0].Severity)
+	assert.Equal(t, "inactive", *items[0].Details["Actual"])
+	assert.Equal(t, "LOW", *items[1].Severity)
+}
+
+func TestConvertToSsmDriftComplianceItemsReturnEmptyForHashMatch(t *testing.T) {
+	u := MockComplianceUploader()
+	items := []*model.DriftComplianceItem{{AssociationId: "association_1", CheckId: "sshd"}}
+	dataB, _ := json.Marshal(items)
+	hash := calculateCheckSum(dataB)
+
+	complianceItems, newHash, err := u.ConvertToSsmDriftComplianceItems(u.context.Log(), items, hash)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(complianceItems))
+	assert.Equal(t, hash, newHash)
+}
@@ -83,12 +83,32 @@ type CloudWatchConfiguration struct {
 	LogGroupEncryptionEnabled bool
 }
 
+// LocalArchiveConfiguration represents information relevant to command output archived on the local file system
+type LocalArchiveConfiguration struct {
+	ArchiveDirectory string
+	// DocumentID names the directory of the document in the archive
+	DocumentID string
+	// MaxArchivedDocuments is the number of documents kept in the archive, the oldest ones are removed first
+	MaxArchivedDocuments int
+}
+
+// HTTPOutputConfiguration represents information relevant to command output posted to an HTTP(S) endpoint
+type HTTPOutputConfiguration struct {
+	Url        string
+	Headers    map[string]string
+	MaxRetries int
+	// DocumentID is sent along with the output to identify the document it belongs to
+	DocumentID string
+}
+
 // IOConfiguration represents information relevant to the output sources of a command
 type IOConfiguration struct {
 	OrchestrationDirectory string
 	OutputS3BucketName     string
 	OutputS3KeyPrefix      string
 	CloudWatchConfig       CloudWatchConfiguration
+	LocalArchiveConfig     LocalArchiveConfiguration
+	HTTPOutputConfig       HTTPOutputConfiguration
 }
 
 // DocumentState represents information relevant to a command that gets executed by agent
@@ -99,6 +119,8 @@ type DocumentState struct {
 	InstancePluginsInformation []PluginState
 	CancelInformation          CancelCommandInfo
 	IOConfig                   IOConfiguration
+	// Parameters holds the document parameters as received, before they are replaced in the plugins
+	Parameters map[string]interface{} `json:",omitempty"`
 }
 
 // IsRebootRequired returns if reboot is needed
@@ -128,6 +128,8 @@ const (
 	AssociationErrorCodeSubmitAssociationError = "SubmitAssocError"
 	// AssociationErrorCodeStuckAtInProgressError represents association stuck in InProgress Error
 	AssociationErrorCodeStuckAtInProgressError = "StuckAtInProgress"
+	// AssociationErrorCodeOutsideMaintenanceWindow represents association skipped outside of the maintenance windows
+	AssociationErrorCodeOutsideMaintenanceWindow = "OutsideMaintenanceWindow"
 	// AssociationErrorCodeNoError represents no error
 	AssociationErrorCodeNoError = ""
 )
@@ -266,4 +268,6 @@ type DocumentResult struct {
 	Status          ResultStatus
 	LastPlugin      string
 	NPlugins        int
+	// SkippedReason explains why the document was skipped without running
+	SkippedReason string `json:",omitempty"`
 }
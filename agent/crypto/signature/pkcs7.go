@@ -0,0 +1,199 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+
+	digestAlgorithms = map[string]crypto.Hash{
+		"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+		"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+		"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+	}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     issuerAndSerialNumber
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+// verifyPKCS7 verifies a detached PKCS7 signature of the content. One of the signers must hold a certificate
+// that chains to the trusted certificates and signed the content or the digest of the content in its signed attributes.
+func verifyPKCS7(content []byte, signature []byte, roots *x509.CertPool) error {
+	var info contentInfo
+	if rest, err := asn1.Unmarshal(signature, &info); err != nil || len(rest) > 0 {
+		return fmt.Errorf("invalid PKCS7 signature: %v", err)
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return fmt.Errorf("unsupported PKCS7 content type %v", info.ContentType)
+	}
+	var signed signedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &signed); err != nil {
+		return fmt.Errorf("invalid PKCS7 signed data: %v", err)
+	}
+	if len(signed.ContentInfo.Content.Bytes) > 0 {
+		return errors.New("the PKCS7 signature embeds its content, a detached signature is expected")
+	}
+	certificates, err := x509.ParseCertificates(signed.Certificates.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid certificates in PKCS7 signature: %v", err)
+	}
+	if len(signed.SignerInfos) == 0 {
+		return errors.New("the PKCS7 signature has no signer")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates {
+		intermediates.AddCert(certificate)
+	}
+	var lastErr error
+	for _, signer := range signed.SignerInfos {
+		if lastErr = verifySigner(content, signer, certificates, intermediates, roots); lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+// verifySigner verifies the signature of a single signer and its certificate chain
+func verifySigner(content []byte, signer signerInfo, certificates []*x509.Certificate, intermediates, roots *x509.CertPool) error {
+	certificate := findCertificate(certificates, signer.IssuerAndSerialNumber)
+	if certificate == nil {
+		return errors.New("the certificate of the PKCS7 signer is not included in the signature")
+	}
+	hash, ok := digestAlgorithms[signer.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return fmt.Errorf("unsupported PKCS7 digest algorithm %v, SHA-256 or stronger is required", signer.DigestAlgorithm.Algorithm)
+	}
+	algorithm, err := signatureAlgorithm(certificate, hash)
+	if err != nil {
+		return err
+	}
+
+	signedContent := content
+	if len(signer.AuthenticatedAttributes.Bytes) > 0 {
+		digest, err := parseMessageDigest(signer.AuthenticatedAttributes.Bytes)
+		if err != nil {
+			return err
+		}
+		h := hash.New()
+		h.Write(content)
+		if !bytes.Equal(h.Sum(nil), digest) {
+			return errors.New("the PKCS7 signature doesn't match the content")
+		}
+		// the signature covers the DER encoding of the attributes as a SET, not the implicit tag of the signer info
+		signedContent = append([]byte{0x31}, signer.AuthenticatedAttributes.FullBytes[1:]...)
+	}
+	if err = certificate.CheckSignature(algorithm, signedContent, signer.EncryptedDigest); err != nil {
+		return fmt.Errorf("the PKCS7 signature doesn't match the content: %v", err)
+	}
+	_, err = certificate.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return fmt.Errorf("the PKCS7 signer %v is not trusted: %v", certificate.Subject, err)
+	}
+	return nil
+}
+
+func findCertificate(certificates []*x509.Certificate, id issuerAndSerialNumber) *x509.Certificate {
+	for _, certificate := range certificates {
+		if certificate.SerialNumber.Cmp(id.SerialNumber) == 0 && bytes.Equal(certificate.RawIssuer, id.Issuer.FullBytes) {
+			return certificate
+		}
+	}
+	return nil
+}
+
+// signatureAlgorithm returns the algorithm of a signature made with the key of the certificate over a digest of the given hash
+func signatureAlgorithm(certificate *x509.Certificate, hash crypto.Hash) (x509.SignatureAlgorithm, error) {
+	algorithms := map[x509.PublicKeyAlgorithm]map[crypto.Hash]x509.SignatureAlgorithm{
+		x509.RSA: {
+			crypto.SHA256: x509.SHA256WithRSA,
+			crypto.SHA384: x509.SHA384WithRSA,
+			crypto.SHA512: x509.SHA512WithRSA,
+		},
+		x509.ECDSA: {
+			crypto.SHA256: x509.ECDSAWithSHA256,
+			crypto.SHA384: x509.ECDSAWithSHA384,
+			crypto.SHA512: x509.ECDSAWithSHA512,
+		},
+	}
+	if algorithm, ok := algorithms[certificate.PublicKeyAlgorithm][hash]; ok {
+		return algorithm, nil
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported PKCS7 signer key %v", certificate.PublicKeyAlgorithm)
+}
+
+// parseMessageDigest returns the message digest of the signed attributes
+func parseMessageDigest(raw []byte) (digest []byte, err error) {
+	for len(raw) > 0 {
+		var attr attribute
+		if raw, err = asn1.Unmarshal(raw, &attr); err != nil {
+			return nil, fmt.Errorf("invalid PKCS7 signed attributes: %v", err)
+		}
+		if attr.Type.Equal(oidMessageDigest) {
+			if _, err = asn1.Unmarshal(attr.Values.Bytes, &digest); err != nil {
+				return nil, fmt.Errorf("invalid PKCS7 message digest: %v", err)
+			}
+		}
+	}
+	if digest == nil {
+		return nil, errors.New("the PKCS7 signed attributes don't include the message digest")
+	}
+	return digest, nil
+}
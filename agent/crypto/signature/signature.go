@@ -0,0 +1,115 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package signature verifies the detached signatures of the scripts run by the agent.
+// Signatures are either raw Ed25519 signatures or PKCS7 detached signatures from a trusted certificate.
+package signature
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"golang.org/x/crypto/ed25519"
+)
+
+// ErrMissingSignature is returned when a signature is required but none was provided
+var ErrMissingSignature = errors.New("the script signing policy of the agent requires a signature and none was provided")
+
+// Policy verifies detached signatures against the trusted keys of the agent configuration
+type Policy struct {
+	Required     bool
+	ed25519Keys  []ed25519.PublicKey
+	certificates *x509.CertPool
+}
+
+// NewPolicy loads the trusted keys and certificates of the script signing configuration
+func NewPolicy(config appconfig.ScriptSigningCfg) (policy Policy, err error) {
+	policy.Required = config.RequireSignature
+	for _, encoded := range config.TrustedEd25519Keys {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return policy, fmt.Errorf("invalid trusted Ed25519 key %v, expected %v base64 encoded bytes", encoded, ed25519.PublicKeySize)
+		}
+		policy.ed25519Keys = append(policy.ed25519Keys, ed25519.PublicKey(key))
+	}
+	for _, path := range config.TrustedCertificates {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return policy, fmt.Errorf("failed to read trusted certificates %v: %v", path, err)
+		}
+		if policy.certificates == nil {
+			policy.certificates = x509.NewCertPool()
+		}
+		if !policy.certificates.AppendCertsFromPEM(content) {
+			return policy, fmt.Errorf("no PEM certificates found in %v", path)
+		}
+	}
+	if policy.Required && len(policy.ed25519Keys) == 0 && policy.certificates == nil {
+		return policy, errors.New("signatures are required but no trusted key or certificate is configured")
+	}
+	return policy, nil
+}
+
+// Check verifies the signature of the content when the policy requires it
+func (policy Policy) Check(content []byte, encodedSignature string) error {
+	if !policy.Required {
+		return nil
+	}
+	if strings.TrimSpace(encodedSignature) == "" {
+		return ErrMissingSignature
+	}
+	signature, err := Decode(encodedSignature)
+	if err != nil {
+		return err
+	}
+	return policy.Verify(content, signature)
+}
+
+// Verify verifies the detached signature of the content, 64 bytes signatures are Ed25519 signatures
+// and anything else is expected to be a DER encoded PKCS7 signature
+func (policy Policy) Verify(content []byte, signature []byte) error {
+	if len(signature) == ed25519.SignatureSize {
+		for _, key := range policy.ed25519Keys {
+			if ed25519.Verify(key, content, signature) {
+				return nil
+			}
+		}
+		return errors.New("the Ed25519 signature doesn't match the content or any trusted key")
+	}
+	if policy.certificates == nil {
+		return errors.New("no trusted certificate is configured to verify the PKCS7 signature")
+	}
+	return verifyPKCS7(content, signature, policy.certificates)
+}
+
+// Decode returns the signature bytes of a PEM block, base64 string or raw DER signature
+func Decode(encoded string) ([]byte, error) {
+	trimmed := strings.TrimSpace(encoded)
+	if block, _ := pem.Decode([]byte(trimmed)); block != nil {
+		return block.Bytes, nil
+	}
+	if signature, err := base64.StdEncoding.DecodeString(trimmed); err == nil {
+		return signature, nil
+	}
+	// raw DER signature files are not valid base64
+	if strings.HasPrefix(encoded, "\x30") {
+		return []byte(encoded), nil
+	}
+	return nil, errors.New("the signature is neither PEM, base64 nor DER encoded")
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package signature
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ed25519"
+)
+
+func readTestData(t *testing.T, name string) []byte {
+	content, err := ioutil.ReadFile("testdata/" + name)
+	assert.NoError(t, err)
+	return content
+}
+
+func TestNewPolicy(t *testing.T) {
+	_, err := NewPolicy(appconfig.ScriptSigningCfg{RequireSignature: true})
+	assert.Error(t, err, "a required policy needs trusted keys")
+
+	_, err = NewPolicy(appconfig.ScriptSigningCfg{TrustedEd25519Keys: []string{"bm90IGEga2V5"}})
+	assert.Error(t, err)
+
+	_, err = NewPolicy(appconfig.ScriptSigningCfg{TrustedCertificates: []string{"testdata/script.sh"}})
+	assert.Error(t, err)
+
+	policy, err := NewPolicy(appconfig.ScriptSigningCfg{})
+	assert.NoError(t, err)
+	assert.NoError(t, policy.Check([]byte("echo unsigned"), ""), "signatures are opt-in")
+}
+
+func TestEd25519Signature(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	otherPublic, _, _ := ed25519.GenerateKey(rand.Reader)
+	content := []byte("echo hello\n")
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(private, content))
+
+	policy, err := NewPolicy(appconfig.ScriptSigningCfg{
+		RequireSignature:   true,
+		TrustedEd25519Keys: []string{base64.StdEncoding.EncodeToString(otherPublic), base64.StdEncoding.EncodeToString(public)},
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, policy.Check(content, signature))
+	assert.Error(t, policy.Check([]byte("echo tampered\n"), signature))
+	assert.Equal(t, ErrMissingSignature, policy.Check(content, ""))
+	assert.Error(t, policy.Check(content, "not a signature"))
+
+	untrusted, _ := NewPolicy(appconfig.ScriptSigningCfg{
+		RequireSignature:   true,
+		TrustedEd25519Keys: []string{base64.StdEncoding.EncodeToString(otherPublic)},
+	})
+	assert.Error(t, untrusted.Check(content, signature))
+}
+
+func TestPKCS7Signature(t *testing.T) {
+	policy, err := NewPolicy(appconfig.ScriptSigningCfg{
+		RequireSignature:    true,
+		TrustedCertificates: []string{"testdata/signer.pem"},
+	})
+	assert.NoError(t, err)
+	content := readTestData(t, "script.sh")
+
+	// DER signature with signed attributes
+	assert.NoError(t, policy.Check(content, string(readTestData(t, "script.sh.p7s"))))
+	assert.NoError(t, policy.Check(content, base64.StdEncoding.EncodeToString(readTestData(t, "script.sh.p7s"))))
+	// PEM signature without signed attributes
+	assert.NoError(t, policy.Check(content, string(readTestData(t, "script.sh.noattr.pem"))))
+
+	assert.Error(t, policy.Check([]byte("#!/bin/sh\necho tampered\n"), string(readTestData(t, "script.sh.p7s"))))
+	assert.Error(t, policy.Check([]byte("#!/bin/sh\necho tampered\n"), string(readTestData(t, "script.sh.noattr.pem"))))
+	assert.Error(t, policy.Check(content, string(readTestData(t, "untrusted.sh.p7s"))), "signer doesn't chain to a trusted certificate")
+}
+
+func TestPKCS7SignatureRequiresCodeSigningUsage(t *testing.T) {
+	policy, err := NewPolicy(appconfig.ScriptSigningCfg{
+		RequireSignature:    true,
+		TrustedCertificates: []string{"testdata/codesigner.pem", "testdata/tlsserver.pem"},
+	})
+	assert.NoError(t, err)
+	content := readTestData(t, "script.sh")
+
+	assert.NoError(t, policy.Check(content, string(readTestData(t, "script.sh.codesigning.p7s"))))
+	assert.Error(t, policy.Check(content, string(readTestData(t, "script.sh.tlsserver.p7s"))), "a TLS server certificate can't sign scripts")
+}
+
+func TestPKCS7SignatureWithoutTrustedCertificates(t *testing.T) {
+	public, _, _ := ed25519.GenerateKey(rand.Reader)
+	policy, _ := NewPolicy(appconfig.ScriptSigningCfg{
+		RequireSignature:   true,
+		TrustedEd25519Keys: []string{base64.StdEncoding.EncodeToString(public)},
+	})
+	assert.Error(t, policy.Check(readTestData(t, "script.sh"), string(readTestData(t, "script.sh.p7s"))))
+}
@@ -43,6 +43,25 @@ type DocumentParserInfo struct {
 	DocumentId        string
 	DefaultWorkingDir string
 	CloudWatchConfig  contracts.CloudWatchConfiguration
+	LocalArchive      contracts.LocalArchiveConfiguration
+	HTTPOutput        contracts.HTTPOutputConfiguration
+}
+
+// NewLocalOutputConfig returns the output destinations configured on the agent for the given document,
+// they are used on top of the S3 and CloudWatch destinations of the document
+func NewLocalOutputConfig(config appconfig.SsmagentConfig, documentID string) (contracts.LocalArchiveConfiguration, contracts.HTTPOutputConfiguration) {
+	localArchive := contracts.LocalArchiveConfiguration{
+		ArchiveDirectory:     config.Output.LocalArchiveDirectory,
+		DocumentID:           documentID,
+		MaxArchivedDocuments: config.Output.MaxLocalArchiveDocuments,
+	}
+	httpOutput := contracts.HTTPOutputConfiguration{
+		Url:        config.Output.HttpEndpoint,
+		Headers:    config.Output.HttpHeaders,
+		MaxRetries: config.Output.HttpMaxRetries,
+		DocumentID: documentID,
+	}
+	return localArchive, httpOutput
 }
 
 // InitializeDocState is a method to obtain the state of the document.
@@ -58,6 +77,7 @@ func InitializeDocState(log log.T,
 	docState.DocumentType = documentType
 	docState.DocumentInformation = docInfo
 	docState.IOConfig = docContent.GetIOConfiguration(parserInfo)
+	docState.Parameters = params
 
 	pluginInfo, err := docContent.ParseDocument(log, docInfo, parserInfo, params)
 	if err != nil {
@@ -89,6 +109,8 @@ func (docContent *DocContent) GetIOConfiguration(parserInfo DocumentParserInfo)
 		OutputS3BucketName:     parserInfo.S3Bucket,
 		OutputS3KeyPrefix:      parserInfo.S3Prefix,
 		CloudWatchConfig:       parserInfo.CloudWatchConfig,
+		LocalArchiveConfig:     parserInfo.LocalArchive,
+		HTTPOutputConfig:       parserInfo.HTTPOutput,
 	}
 }
 
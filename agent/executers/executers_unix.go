@@ -43,24 +43,31 @@ func killProcess(process *os.Process, signal *timeoutSignal) error {
 	return syscall.Kill(-process.Pid, syscall.SIGKILL) // note the minus sign
 }
 
-// Running powershell on linux erquired the HOME env variable to be set and to remove the TERM env variable
+// Running powershell on linux requires the HOME env variable to be set and the TERM env variable to be removed,
+// the locale defaults to UTF-8 so that the output of the scripts is not mangled
 func validateEnvironmentVariables(command *exec.Cmd) {
 
-	if command.Path == appconfig.PowerShellPluginCommandName {
-		env := command.Env
-		env = append(env, fmtEnvVariable("HOME", "/"))
-		i := 0
-		for _, a := range env {
-			if strings.Contains(a, "TERM") {
-				if i == len(env)-1 {
-					env = env[:i]
-				} else {
-					env = append(env[:i], env[i+1:]...)
-				}
-				break
-			}
-			i++
+	if !appconfig.IsPowerShell(command.Path) {
+		return
+	}
+	env := []string{}
+	hasHome, hasLocale := false, false
+	for _, variable := range command.Env {
+		switch {
+		case strings.HasPrefix(variable, "TERM="):
+			continue
+		case strings.HasPrefix(variable, "HOME="):
+			hasHome = true
+		case strings.HasPrefix(variable, "LANG="), strings.HasPrefix(variable, "LC_ALL="):
+			hasLocale = true
 		}
-		command.Env = env
+		env = append(env, variable)
+	}
+	if !hasHome {
+		env = append(env, fmtEnvVariable("HOME", "/"))
+	}
+	if !hasLocale {
+		env = append(env, fmtEnvVariable("LANG", "C.UTF-8"))
 	}
+	command.Env = env
 }
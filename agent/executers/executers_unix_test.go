@@ -0,0 +1,46 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package executers
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEnvironmentVariables_PowerShell(t *testing.T) {
+	command := &exec.Cmd{
+		Path: "/opt/microsoft/powershell/7/pwsh",
+		Env:  []string{"TERM=xterm", "XTERM_VERSION=1", "PATH=/usr/bin"},
+	}
+	validateEnvironmentVariables(command)
+	assert.Equal(t, []string{"XTERM_VERSION=1", "PATH=/usr/bin", "HOME=/", "LANG=C.UTF-8"}, command.Env)
+
+	// variables set for the agent are kept
+	command.Env = []string{"HOME=/root", "LC_ALL=en_US.UTF-8"}
+	validateEnvironmentVariables(command)
+	assert.Equal(t, []string{"HOME=/root", "LC_ALL=en_US.UTF-8"}, command.Env)
+}
+
+func TestValidateEnvironmentVariables_OtherCommands(t *testing.T) {
+	command := &exec.Cmd{
+		Path: "/bin/sh",
+		Env:  []string{"TERM=xterm"},
+	}
+	validateEnvironmentVariables(command)
+	assert.Equal(t, []string{"TERM=xterm"}, command.Env)
+}
@@ -21,7 +21,6 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
-	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -56,63 +55,8 @@ type DownloadInput struct {
 // httpDownload attempts to download a file via http/s call
 func httpDownload(log log.T, fileURL string, destFile string) (output DownloadOutput, err error) {
 	log.Debugf("attempting to download as http/https download %v", destFile)
-	eTagFile := destFile + ".etag"
-	var check http.Client
-	var request *http.Request
-	request, err = http.NewRequest("GET", fileURL, nil)
-	if err != nil {
-		return
-	}
-	if fileutil.Exists(destFile) == true && fileutil.Exists(eTagFile) == true {
-		var existingETag string
-		existingETag, err = fileutil.ReadAllText(eTagFile)
-		request.Header.Add("If-None-Match", existingETag)
-	}
-
-	check = http.Client{
-		CheckRedirect: func(r *http.Request, via []*http.Request) error {
-			r.URL.Opaque = r.URL.Path
-			return nil
-		},
-	}
-
-	var resp *http.Response
-	resp, err = check.Do(request)
-	if err != nil {
-		log.Debug("failed to download from http/https, ", err)
-		fileutil.DeleteFile(destFile)
-		fileutil.DeleteFile(eTagFile)
-		return
-	}
-
-	if resp.StatusCode == http.StatusNotModified {
-		log.Debugf("Unchanged file.")
-		output.IsUpdated = false
-		output.LocalFilePath = destFile
-		return output, nil
-	} else if resp.StatusCode != http.StatusOK {
+	if output, err = rangedDownload(log, newHTTPSource(fileURL), destFile); err != nil {
 		log.Debug("failed to download from http/https, ", err)
-		fileutil.DeleteFile(destFile)
-		fileutil.DeleteFile(eTagFile)
-		err = fmt.Errorf("http request failed. status:%v statuscode:%v", resp.Status, resp.StatusCode)
-		return
-	}
-	defer resp.Body.Close()
-	eTagValue := resp.Header.Get("Etag")
-	if eTagValue != "" {
-		log.Debug("file eTagValue is ", eTagValue)
-		err = fileutil.WriteAllText(eTagFile, eTagValue)
-		if err != nil {
-			log.Errorf("failed to write eTagfile %v, %v ", eTagFile, err)
-			return
-		}
-	}
-	_, err = FileCopy(log, destFile, resp.Body)
-	if err == nil {
-		output.LocalFilePath = destFile
-		output.IsUpdated = true
-	} else {
-		log.Errorf("failed to write destFile %v, %v ", destFile, err)
 	}
 	return
 }
@@ -246,61 +190,8 @@ func ListS3Directory(log log.T, amazonS3URL s3util.AmazonS3URL) (folderNames []s
 // s3Download attempts to download a file via the aws sdk.
 func s3Download(log log.T, amazonS3URL s3util.AmazonS3URL, destFile string) (output DownloadOutput, err error) {
 	log.Debugf("attempting to download as s3 download %v", destFile)
-	eTagFile := destFile + ".etag"
-
-	config, _ := awsConfig(log, amazonS3URL)
-	params := &s3.GetObjectInput{
-		Bucket: aws.String(amazonS3URL.Bucket),
-		Key:    aws.String(amazonS3URL.Key),
-	}
-
-	if fileutil.Exists(destFile) == true && fileutil.Exists(eTagFile) == true {
-		var existingETag string
-		existingETag, err = fileutil.ReadAllText(eTagFile)
-		if err != nil {
-			log.Debugf("failed to read etag file %v, %v", eTagFile, err)
-			return
-		}
-		params.IfNoneMatch = aws.String(existingETag)
-	}
-	appConfig, _ := appconfig.Config(false)
-	sess := session.New(config)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
-
-	s3client := s3.New(sess)
-
-	req, resp := s3client.GetObjectRequest(params)
-	err = req.Send()
-	if err != nil {
-		if req.HTTPResponse == nil || req.HTTPResponse.StatusCode != http.StatusNotModified {
-			log.Debug("failed to download from s3, ", err)
-			fileutil.DeleteFile(destFile)
-			fileutil.DeleteFile(eTagFile)
-			return
-		}
-
-		log.Debugf("Unchanged file.")
-		output.IsUpdated = false
-		output.LocalFilePath = destFile
-		return output, nil
-	}
-
-	if *resp.ETag != "" {
-		log.Debug("files etag is ", *resp.ETag)
-		err = fileutil.WriteAllText(eTagFile, *resp.ETag)
-		if err != nil {
-			log.Errorf("failed to write eTagfile %v, %v ", eTagFile, err)
-			return
-		}
-	}
-
-	defer resp.Body.Close()
-	_, err = FileCopy(log, destFile, resp.Body)
-	if err == nil {
-		output.LocalFilePath = destFile
-		output.IsUpdated = true
-	} else {
-		log.Errorf("failed to write destFile %v, %v ", destFile, err)
+	if output, err = rangedDownload(log, newS3Source(log, amazonS3URL), destFile); err != nil {
+		log.Debug("failed to download from s3, ", err)
 	}
 	return
 }
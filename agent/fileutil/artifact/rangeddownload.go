@@ -0,0 +1,493 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+const (
+	partFileExtension     = ".part"     //Extension of the file receiving the content until the download completes
+	progressFileExtension = ".progress" //Extension of the file recording the downloaded segments of the part file
+	eTagFileExtension     = ".etag"     //Extension of the file recording the version of the downloaded file
+
+	copyBufferSize = 32 * 1024
+)
+
+// rangedDownloadSettings controls how the files are downloaded in segments
+type rangedDownloadSettings struct {
+	// ParallelThreshold is the size from which a file is downloaded in parallel segments
+	ParallelThreshold int64
+	// SegmentSize is the size of the segments of a file downloaded in parallel
+	SegmentSize int64
+	// MaxParallelSegments is the number of segments downloaded at the same time
+	MaxParallelSegments int
+	// ProgressInterval is the number of bytes downloaded by a segment between two saves of the progress
+	ProgressInterval int64
+	// MaxAttempts is the number of attempts for each request before the download fails
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, it doubles at every retry up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var settings = rangedDownloadSettings{
+	ParallelThreshold:   64 * 1024 * 1024,
+	SegmentSize:         16 * 1024 * 1024,
+	MaxParallelSegments: 4,
+	ProgressInterval:    4 * 1024 * 1024,
+	MaxAttempts:         5,
+	InitialBackoff:      time.Second,
+	MaxBackoff:          30 * time.Second,
+}
+
+var sleep = time.Sleep
+
+// errObjectChanged is returned when the remote object changed during the download
+var errObjectChanged = errors.New("the remote file changed during the download")
+
+// objectInfo describes the remote object to download
+type objectInfo struct {
+	// Size is -1 when the size is unknown
+	Size int64
+	// Version identifies the content of the object, usually its ETag
+	Version string
+	// AcceptRanges is true when the object can be downloaded in byte ranges
+	AcceptRanges bool
+}
+
+// rangeSource is a remote object which can be downloaded in byte ranges
+type rangeSource interface {
+	// stat returns the size and version of the object
+	stat(log log.T) (objectInfo, error)
+	// fetch returns the content of the object from start to end inclusive, or the whole object when end is negative.
+	// It fails with errObjectChanged when the object doesn't have the given version anymore.
+	fetch(log log.T, start int64, end int64, version string) (io.ReadCloser, error)
+}
+
+// statusError is the failure of a request with an unexpected status code
+type statusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("http request failed. status:%v statuscode:%v", e.Status, e.StatusCode)
+}
+
+// incompleteError is a response which ended before the whole requested range was received
+type incompleteError struct {
+	Received int64
+	Segment  *segment
+}
+
+func (e incompleteError) Error() string {
+	return fmt.Sprintf("received %v of the %v bytes of range %v-%v, %v", e.Received, e.Segment.length(), e.Segment.Start, e.Segment.End, io.ErrUnexpectedEOF)
+}
+
+// digestError is a response whose content doesn't match the digest sent by the source
+type digestError struct {
+	Expected string
+	Actual   string
+}
+
+func (e digestError) Error() string {
+	return fmt.Sprintf("received content with digest %v instead of %v", e.Actual, e.Expected)
+}
+
+// segment is a byte range of the file, End is -1 for a file of unknown size
+type segment struct {
+	Start   int64
+	End     int64
+	Written int64
+	// Sha256 is the hash of the segment in the part file, it finds the segments which changed on disk between two
+	// attempts
+	Sha256 string `json:",omitempty"`
+}
+
+// length returns the size of the segment, -1 when unknown
+func (s *segment) length() int64 {
+	if s.End < 0 {
+		return -1
+	}
+	return s.End - s.Start + 1
+}
+
+// done returns true when the whole segment is downloaded
+func (s *segment) done() bool {
+	return s.Sha256 != ""
+}
+
+// progress is the state of the download of a file, saved next to the part file so the next attempt continues it
+type progress struct {
+	Version      string
+	Size         int64
+	AcceptRanges bool
+	Segments     []*segment
+}
+
+// newProgress splits the object in the segments to download
+func newProgress(info objectInfo) *progress {
+	p := &progress{Version: info.Version, Size: info.Size, AcceptRanges: info.AcceptRanges}
+	if info.Size < 0 || !info.AcceptRanges {
+		// the file can only be downloaded in one request
+		p.Segments = []*segment{{Start: 0, End: info.Size - 1}}
+		if info.Size < 0 {
+			p.Segments[0].End = -1
+		}
+		return p
+	}
+
+	segmentSize := info.Size
+	if info.Size >= settings.ParallelThreshold && settings.SegmentSize > 0 {
+		segmentSize = settings.SegmentSize
+	}
+	for start := int64(0); start < info.Size || len(p.Segments) == 0; start += segmentSize {
+		end := start + segmentSize - 1
+		if end >= info.Size {
+			end = info.Size - 1
+		}
+		p.Segments = append(p.Segments, &segment{Start: start, End: end})
+	}
+	return p
+}
+
+// loadProgress reads the progress of a previous attempt, nil when there is none
+func loadProgress(log log.T, progressFile string) *progress {
+	content, err := ioutil.ReadFile(progressFile)
+	if err != nil {
+		return nil
+	}
+	var p progress
+	if err = json.Unmarshal(content, &p); err != nil {
+		log.Debugf("ignoring unreadable download progress %v, %v", progressFile, err)
+		return nil
+	}
+	return &p
+}
+
+// save writes the progress to the file
+func (p *progress) save(progressFile string) error {
+	content, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	tmpFile := progressFile + ".tmp"
+	if err = ioutil.WriteFile(tmpFile, content, appconfig.ReadWriteAccess); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, progressFile)
+}
+
+// rangedDownload downloads the object to destFile. The content is written to a part file along with the progress
+// of the download, so an interrupted download continues where it stopped. Large files are downloaded in parallel
+// segments and every request is retried with an exponential backoff. Each segment is verified when the source
+// sends the digest of the content, such as the Content-MD5 of an http response, s3 doesn't send one so its objects
+// are only verified by the checksum of the whole file.
+func rangedDownload(log log.T, source rangeSource, destFile string) (output DownloadOutput, err error) {
+	eTagFile := destFile + eTagFileExtension
+	partFile := destFile + partFileExtension
+	progressFile := destFile + progressFileExtension
+
+	var info objectInfo
+	err = withRetry(log, func() (err error) {
+		info, err = source.stat(log)
+		return err
+	})
+	if err != nil {
+		fileutil.DeleteFile(destFile)
+		fileutil.DeleteFile(eTagFile)
+		return output, err
+	}
+
+	if info.Version != "" && fileutil.Exists(destFile) && fileutil.Exists(eTagFile) {
+		if existingETag, _ := fileutil.ReadAllText(eTagFile); existingETag == info.Version {
+			log.Debugf("Unchanged file.")
+			output.IsUpdated = false
+			output.LocalFilePath = destFile
+			return output, nil
+		}
+	}
+
+	state := loadProgress(log, progressFile)
+	if state == nil || !info.AcceptRanges || info.Version == "" || state.Version != info.Version || state.Size != info.Size || !fileutil.Exists(partFile) {
+		// the previous attempt can't be continued
+		state = newProgress(info)
+		fileutil.DeleteFile(partFile)
+	} else {
+		verifySegments(log, partFile, state)
+	}
+
+	if err = downloadSegments(log, source, partFile, progressFile, state); err != nil {
+		if err == errObjectChanged {
+			fileutil.DeleteFile(partFile)
+			fileutil.DeleteFile(progressFile)
+		}
+		return output, err
+	}
+
+	fileutil.DeleteFile(eTagFile)
+	if err = os.Rename(partFile, destFile); err != nil {
+		return output, fmt.Errorf("failed to move the downloaded file to %v, %v", destFile, err)
+	}
+	fileutil.DeleteFile(progressFile)
+	if info.Version != "" {
+		log.Debug("file eTagValue is ", info.Version)
+		if err = fileutil.WriteAllText(eTagFile, info.Version); err != nil {
+			log.Errorf("failed to write eTagfile %v, %v ", eTagFile, err)
+			return output, err
+		}
+	}
+
+	output.LocalFilePath = destFile
+	output.IsUpdated = true
+	return output, nil
+}
+
+// verifySegments downloads again the segments of a previous attempt whose content changed on disk
+func verifySegments(log log.T, partFile string, state *progress) {
+	for _, s := range state.Segments {
+		if !s.done() {
+			continue
+		}
+		if hash, err := segmentHash(partFile, s); err != nil || hash != s.Sha256 {
+			log.Infof("segment %v-%v of %v is corrupted, downloading it again", s.Start, s.End, partFile)
+			s.Written = 0
+			s.Sha256 = ""
+		}
+	}
+}
+
+// downloadSegments downloads the pending segments of the part file
+func downloadSegments(log log.T, source rangeSource, partFile string, progressFile string, state *progress) error {
+	file, err := os.OpenFile(partFile, os.O_WRONLY|os.O_CREATE, appconfig.ReadWriteAccess)
+	if err != nil {
+		return fmt.Errorf("failed to create file %v, %v", partFile, err)
+	}
+	defer file.Close()
+
+	var pending []*segment
+	for _, s := range state.Segments {
+		if !s.done() {
+			pending = append(pending, s)
+		}
+	}
+	log.Infof("downloading %v of %v segments of %v", len(pending), len(state.Segments), partFile)
+
+	var lock sync.Mutex
+	saveProgress := func() {
+		lock.Lock()
+		defer lock.Unlock()
+		if err := state.save(progressFile); err != nil {
+			log.Debugf("failed to save the download progress %v, %v", progressFile, err)
+		}
+	}
+
+	workers := settings.MaxParallelSegments
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+
+	queue := make(chan *segment, len(pending))
+	for _, s := range pending {
+		queue <- s
+	}
+	close(queue)
+
+	errs := make(chan error, len(pending))
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range queue {
+				err := withRetry(log, func() error {
+					return downloadSegment(log, source, file, partFile, state, s, &lock, saveProgress)
+				})
+				saveProgress()
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err == errObjectChanged {
+			return err
+		}
+		log.Debugf("failed to download segment of %v, %v", partFile, err)
+		return err
+	}
+	return nil
+}
+
+// downloadSegment downloads the rest of the segment to the part file and verifies it
+func downloadSegment(log log.T, source rangeSource, file *os.File, partFile string, state *progress, s *segment, lock *sync.Mutex, saveProgress func()) error {
+	end := s.End
+	if !state.AcceptRanges {
+		// the file can't be resumed, download it from the start
+		lock.Lock()
+		s.Written = 0
+		lock.Unlock()
+		end = -1
+	}
+	resumedAt := s.Written
+	offset := s.Start + resumedAt
+
+	body, err := source.fetch(log, offset, end, state.Version)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	// the content of a verified body is only kept once its digest is checked at the end of the response
+	_, verified := body.(*digestReader)
+
+	buffer := make([]byte, copyBufferSize)
+	var sinceSave int64
+	for {
+		n, readErr := body.Read(buffer)
+		if n > 0 {
+			if s.length() >= 0 && s.Written+int64(n) > s.length() {
+				return fmt.Errorf("received more data than the requested range %v-%v", s.Start, s.End)
+			}
+			if _, err = file.WriteAt(buffer[:n], offset); err != nil {
+				return fmt.Errorf("failed to write file %v, %v", partFile, err)
+			}
+			offset += int64(n)
+			lock.Lock()
+			s.Written += int64(n)
+			lock.Unlock()
+
+			if sinceSave += int64(n); sinceSave >= settings.ProgressInterval && !verified {
+				sinceSave = 0
+				saveProgress()
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			if verified {
+				lock.Lock()
+				s.Written = resumedAt
+				lock.Unlock()
+			}
+			return readErr
+		}
+	}
+
+	if s.length() >= 0 && s.Written != s.length() {
+		return incompleteError{Received: s.Written, Segment: s}
+	}
+
+	if s.End < 0 {
+		lock.Lock()
+		s.End = s.Start + s.Written - 1
+		lock.Unlock()
+	}
+	hash, err := segmentHash(partFile, s)
+	if err != nil {
+		return err
+	}
+	lock.Lock()
+	s.Sha256 = hash
+	lock.Unlock()
+	return nil
+}
+
+// segmentHash returns the sha256 hash of the segment in the part file
+func segmentHash(partFile string, s *segment) (string, error) {
+	file, err := os.Open(partFile)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	length := s.length()
+	if length < 0 {
+		// empty file
+		length = 0
+	}
+	hasher := sha256.New()
+	n, err := io.Copy(hasher, io.NewSectionReader(file, s.Start, length))
+	if err != nil {
+		return "", err
+	}
+	if n != length {
+		return "", io.ErrUnexpectedEOF
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// withRetry calls the function until it succeeds, it fails with an error which can't be retried or it was called
+// settings.MaxAttempts times. The wait between the calls doubles every time.
+func withRetry(log log.T, call func() error) (err error) {
+	backoff := settings.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		if err = call(); err == nil || !isRetryable(err) || attempt >= settings.MaxAttempts {
+			return err
+		}
+		log.Debugf("download attempt %v failed, retrying in %v, %v", attempt, backoff, err)
+		sleep(backoff)
+		if backoff *= 2; backoff > settings.MaxBackoff {
+			backoff = settings.MaxBackoff
+		}
+	}
+}
+
+// isRetryable returns true for the network errors, timeouts and the statuses of a busy or failing server. The other
+// errors, such as a missing file, denied access or missing credentials, would happen again.
+func isRetryable(err error) bool {
+	switch e := err.(type) {
+	case statusError:
+		return e.StatusCode >= http.StatusInternalServerError ||
+			e.StatusCode == http.StatusTooManyRequests ||
+			e.StatusCode == http.StatusRequestTimeout
+	case incompleteError, digestError:
+		return true
+	case *url.Error:
+		return isRetryable(e.Err)
+	case net.Error:
+		return true
+	case awserr.Error:
+		return request.IsErrorRetryable(e) || request.IsErrorThrottle(e)
+	}
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
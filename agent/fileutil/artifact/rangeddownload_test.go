@@ -0,0 +1,545 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package artifact
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/stretchr/testify/assert"
+)
+
+// testServer serves a file supporting HEAD, Range and If-Range, and can fail requests
+type testServer struct {
+	*httptest.Server
+	lock    sync.Mutex
+	content []byte
+	eTag    string
+	// headFailures and getFailures are the statuses returned to the next requests of each method
+	headFailures []int
+	getFailures  []int
+	// truncate cuts the responses of the ranges starting after truncateAfter to half of their size
+	truncate      bool
+	truncateAfter int64
+	// digests sends the Content-MD5 of the responses, the bodies of the ranges in corrupt are altered once
+	digests bool
+	corrupt map[string]bool
+	ranges  []string
+}
+
+func newTestServer(content []byte) *testServer {
+	server := &testServer{content: content, eTag: `"v1"`, truncateAfter: -1}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serve))
+	return server
+}
+
+func (s *testServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	content, eTag := s.content, s.eTag
+	if r.Method == http.MethodGet {
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+	}
+	failures := &s.getFailures
+	if r.Method == http.MethodHead {
+		failures = &s.headFailures
+	}
+	if len(*failures) > 0 {
+		status := (*failures)[0]
+		*failures = (*failures)[1:]
+		s.lock.Unlock()
+		w.WriteHeader(status)
+		return
+	}
+	truncate := s.truncate && r.Method == http.MethodGet && rangeStart(r) > s.truncateAfter
+	digests, corrupt := s.digests && r.Method == http.MethodGet, s.corrupt[r.Header.Get("Range")]
+	delete(s.corrupt, r.Header.Get("Range"))
+	s.lock.Unlock()
+
+	w.Header().Set("Etag", eTag)
+	if digests {
+		digest := md5.Sum(rangeContent(r, content))
+		w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(digest[:]))
+	}
+	if truncate {
+		w = &truncatingWriter{ResponseWriter: w}
+	}
+	if corrupt {
+		w = &corruptingWriter{ResponseWriter: w}
+	}
+	http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+}
+
+func (s *testServer) update(content []byte, eTag string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.content, s.eTag = content, eTag
+}
+
+func (s *testServer) setTruncate(truncate bool, after int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.truncate, s.truncateAfter = truncate, after
+}
+
+func (s *testServer) requestedRanges() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ranges := s.ranges
+	s.ranges = nil
+	return ranges
+}
+
+// rangeStart returns the start of the requested range, 0 without range
+func rangeStart(r *http.Request) int64 {
+	value := strings.TrimPrefix(r.Header.Get("Range"), "bytes=")
+	start, _ := strconv.ParseInt(strings.Split(value, "-")[0], 10, 64)
+	return start
+}
+
+// rangeContent returns the part of the content requested, the whole content without range
+func rangeContent(r *http.Request, content []byte) []byte {
+	value := strings.TrimPrefix(r.Header.Get("Range"), "bytes=")
+	if value == "" {
+		return content
+	}
+	bounds := strings.Split(value, "-")
+	start, _ := strconv.ParseInt(bounds[0], 10, 64)
+	end, _ := strconv.ParseInt(bounds[1], 10, 64)
+	return content[start : end+1]
+}
+
+// corruptingWriter alters the first byte of the body
+type corruptingWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *corruptingWriter) Write(data []byte) (int, error) {
+	if !w.written && len(data) > 0 {
+		w.written = true
+		altered := append([]byte{data[0] + 1}, data[1:]...)
+		return w.ResponseWriter.Write(altered)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// truncatingWriter sends the first half of the body, the connection is closed as the body is shorter than announced
+type truncatingWriter struct {
+	http.ResponseWriter
+	remaining int64
+	started   bool
+}
+
+func (w *truncatingWriter) WriteHeader(status int) {
+	w.started = true
+	length, _ := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64)
+	w.remaining = length / 2
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *truncatingWriter) Write(data []byte) (int, error) {
+	if !w.started {
+		w.WriteHeader(http.StatusOK)
+	}
+	if int64(len(data)) > w.remaining {
+		w.ResponseWriter.Write(data[:w.remaining])
+		w.remaining = 0
+		return len(data), nil
+	}
+	w.remaining -= int64(len(data))
+	return w.ResponseWriter.Write(data)
+}
+
+// useTestSettings makes the segments small and the retries immediate, it returns the waits between retries
+func useTestSettings(maxAttempts int) (*[]time.Duration, func()) {
+	originalSettings, originalSleep := settings, sleep
+	settings = rangedDownloadSettings{
+		ParallelThreshold:   1024,
+		SegmentSize:         256,
+		MaxParallelSegments: 4,
+		ProgressInterval:    64,
+		MaxAttempts:         maxAttempts,
+		InitialBackoff:      time.Second,
+		MaxBackoff:          3 * time.Second,
+	}
+	waits := &[]time.Duration{}
+	var lock sync.Mutex
+	sleep = func(d time.Duration) {
+		lock.Lock()
+		defer lock.Unlock()
+		*waits = append(*waits, d)
+	}
+	return waits, func() { settings, sleep = originalSettings, originalSleep }
+}
+
+func testContent(size int) []byte {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte('a' + i%26)
+	}
+	return content
+}
+
+func tempDestination(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "artifact")
+	assert.NoError(t, err)
+	return filepath.Join(dir, "file"), func() { os.RemoveAll(dir) }
+}
+
+func assertDownloaded(t *testing.T, destFile string, content []byte) {
+	downloaded, err := ioutil.ReadFile(destFile)
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(content, downloaded), "downloaded content differs")
+	assert.False(t, exists(destFile+partFileExtension), "the part file is removed")
+	assert.False(t, exists(destFile+progressFileExtension), "the progress file is removed")
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestHttpDownload_SingleSegment(t *testing.T) {
+	_, restore := useTestSettings(1)
+	defer restore()
+	content := testContent(1000)
+	server := newTestServer(content)
+	defer server.Close()
+	destFile, cleanup := tempDestination(t)
+	defer cleanup()
+
+	output, err := httpDownload(log.NewMockLog(), server.URL, destFile)
+
+	assert.NoError(t, err)
+	assert.True(t, output.IsUpdated)
+	assert.Equal(t, destFile, output.LocalFilePath)
+	assertDownloaded(t, destFile, content)
+	assert.Equal(t, []string{"bytes=0-999"}, server.requestedRanges())
+
+	// the file is not downloaded again while it didn't change
+	output, err = httpDownload(log.NewMockLog(), server.URL, destFile)
+	assert.NoError(t, err)
+	assert.False(t, output.IsUpdated)
+	assert.Empty(t, server.requestedRanges())
+
+	server.update(testContent(10), `"v2"`)
+	output, err = httpDownload(log.NewMockLog(), server.URL, destFile)
+	assert.NoError(t, err)
+	assert.True(t, output.IsUpdated)
+	assertDownloaded(t, destFile, testContent(10))
+}
+
+func TestHttpDownload_ParallelSegments(t *testing.T) {
+	_, restore := useTestSettings(1)
+	defer restore()
+	content := testContent(4000)
+	server := newTestServer(content)
+	defer server.Close()
+	destFile, cleanup := tempDestination(t)
+	defer cleanup()
+
+	_, err := httpDownload(log.NewMockLog(), server.URL, destFile)
+
+	assert.NoError(t, err)
+	assertDownloaded(t, destFile, content)
+	ranges := server.requestedRanges()
+	assert.Len(t, ranges, 16)
+	assert.Contains(t, ranges, "bytes=3840-3999")
+}
+
+func TestHttpDownload_ResumesInterruptedDownload(t *testing.T) {
+	_, restore := useTestSettings(1)
+	defer restore()
+	content := testContent(4000)
+	server := newTestServer(content)
+	defer server.Close()
+	destFile, cleanup := tempDestination(t)
+	defer cleanup()
+
+	// the connection breaks in the middle of the segments after the first 2048 bytes
+	server.setTruncate(true, 2047)
+	_, err := httpDownload(log.NewMockLog(), server.URL, destFile)
+	assert.Error(t, err)
+	assert.True(t, exists(destFile+partFileExtension), "the part file is kept for the next attempt")
+	assert.True(t, exists(destFile+progressFileExtension))
+	assert.False(t, exists(destFile))
+	server.requestedRanges()
+
+	server.setTruncate(false, -1)
+	_, err = httpDownload(log.NewMockLog(), server.URL, destFile)
+	assert.NoError(t, err)
+	assertDownloaded(t, destFile, content)
+
+	// only the missing halves of the interrupted segments are downloaded
+	ranges := server.requestedRanges()
+	assert.NotContains(t, ranges, "bytes=0-255")
+	assert.Contains(t, ranges, "bytes=2176-2303")
+	assert.NotContains(t, ranges, "bytes=2048-2303")
+}
+
+func TestHttpDownload_RedownloadsCorruptedSegments(t *testing.T) {
+	_, restore := useTestSettings(1)
+	defer restore()
+	content := testContent(4000)
+	server := newTestServer(content)
+	defer server.Close()
+	destFile, cleanup := tempDestination(t)
+	defer cleanup()
+
+	server.setTruncate(true, 2047)
+	_, err := httpDownload(log.NewMockLog(), server.URL, destFile)
+	assert.Error(t, err)
+	server.requestedRanges()
+
+	// corrupt the first segment, which was completely downloaded
+	file, err := os.OpenFile(destFile+partFileExtension, os.O_WRONLY, 0600)
+	assert.NoError(t, err)
+	file.WriteAt([]byte("corrupted"), 10)
+	file.Close()
+
+	server.setTruncate(false, -1)
+	_, err = httpDownload(log.NewMockLog(), server.URL, destFile)
+	assert.NoError(t, err)
+	assertDownloaded(t, destFile, content)
+	assert.Contains(t, server.requestedRanges(), "bytes=0-255")
+}
+
+func TestHttpDownload_RestartsWhenFileChanged(t *testing.T) {
+	_, restore := useTestSettings(1)
+	defer restore()
+	server := newTestServer(testContent(4000))
+	defer server.Close()
+	destFile, cleanup := tempDestination(t)
+	defer cleanup()
+
+	server.setTruncate(true, 2047)
+	_, err := httpDownload(log.NewMockLog(), server.URL, destFile)
+	assert.Error(t, err)
+
+	server.setTruncate(false, -1)
+	changed := bytes.ToUpper(testContent(4000))
+	server.update(changed, `"v2"`)
+	_, err = httpDownload(log.NewMockLog(), server.URL, destFile)
+	assert.NoError(t, err)
+	assertDownloaded(t, destFile, changed)
+}
+
+func TestHttpDownload_RetriesWithBackoff(t *testing.T) {
+	waits, restore := useTestSettings(4)
+	defer restore()
+	content := testContent(100)
+	server := newTestServer(content)
+	defer server.Close()
+	destFile, cleanup := tempDestination(t)
+	defer cleanup()
+
+	// HEAD fails twice, then the GET once
+	server.headFailures = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+	server.getFailures = []int{http.StatusInternalServerError}
+	_, err := httpDownload(log.NewMockLog(), server.URL, destFile)
+
+	assert.NoError(t, err)
+	assertDownloaded(t, destFile, content)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, time.Second}, *waits)
+}
+
+func TestHttpDownload_GivesUpAfterMaxAttempts(t *testing.T) {
+	waits, restore := useTestSettings(3)
+	defer restore()
+	server := newTestServer(testContent(100))
+	defer server.Close()
+	destFile, cleanup := tempDestination(t)
+	defer cleanup()
+
+	server.getFailures = []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}
+	_, err := httpDownload(log.NewMockLog(), server.URL, destFile)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "statuscode:502")
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *waits)
+	assert.Len(t, server.getFailures, 2, "the download stops after 3 attempts")
+}
+
+func TestHttpDownload_DoesNotRetryClientErrors(t *testing.T) {
+	waits, restore := useTestSettings(3)
+	defer restore()
+	server := newTestServer(testContent(100))
+	defer server.Close()
+	destFile, cleanup := tempDestination(t)
+	defer cleanup()
+
+	server.headFailures = []int{http.StatusNotFound}
+	server.getFailures = []int{http.StatusNotFound}
+	_, err := httpDownload(log.NewMockLog(), server.URL, destFile)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "statuscode:404")
+	assert.Empty(t, *waits)
+}
+
+func TestHttpDownload_SingleRequestWhenHeadIsDenied(t *testing.T) {
+	waits, restore := useTestSettings(3)
+	defer restore()
+	content := testContent(4000)
+	server := newTestServer(content)
+	defer server.Close()
+	destFile, cleanup := tempDestination(t)
+	defer cleanup()
+
+	// presigned urls are only signed for GET
+	server.headFailures = []int{http.StatusForbidden}
+	output, err := httpDownload(log.NewMockLog(), server.URL, destFile)
+
+	assert.NoError(t, err)
+	assert.True(t, output.IsUpdated)
+	assertDownloaded(t, destFile, content)
+	assert.Equal(t, []string{""}, server.requestedRanges())
+	assert.Empty(t, *waits)
+}
+
+func TestHttpDownload_RedownloadsSegmentsWithWrongDigest(t *testing.T) {
+	waits, restore := useTestSettings(2)
+	defer restore()
+	content := testContent(4000)
+	server := newTestServer(content)
+	defer server.Close()
+	destFile, cleanup := tempDestination(t)
+	defer cleanup()
+
+	server.digests = true
+	server.corrupt = map[string]bool{"bytes=256-511": true}
+	_, err := httpDownload(log.NewMockLog(), server.URL, destFile)
+
+	assert.NoError(t, err)
+	assertDownloaded(t, destFile, content)
+	ranges := server.requestedRanges()
+	assert.Len(t, ranges, 17)
+	assert.Contains(t, ranges, "bytes=256-511")
+	assert.Len(t, *waits, 1)
+}
+
+func TestHttpDownload_FailsWhenDigestNeverMatches(t *testing.T) {
+	_, restore := useTestSettings(1)
+	defer restore()
+	content := testContent(1000)
+	server := newTestServer(content)
+	defer server.Close()
+	destFile, cleanup := tempDestination(t)
+	defer cleanup()
+
+	server.digests = true
+	server.corrupt = map[string]bool{"bytes=0-999": true}
+	_, err := httpDownload(log.NewMockLog(), server.URL, destFile)
+
+	assert.Error(t, err)
+	assert.IsType(t, digestError{}, err)
+	assert.False(t, exists(destFile))
+
+	// the corrupted content is not kept, the next attempt downloads the whole segment again
+	server.requestedRanges()
+	_, err = httpDownload(log.NewMockLog(), server.URL, destFile)
+	assert.NoError(t, err)
+	assertDownloaded(t, destFile, content)
+	assert.Equal(t, []string{"bytes=0-999"}, server.requestedRanges())
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, isRetryable(statusError{StatusCode: http.StatusServiceUnavailable}))
+	assert.True(t, isRetryable(statusError{StatusCode: http.StatusTooManyRequests}))
+	assert.True(t, isRetryable(statusError{StatusCode: http.StatusRequestTimeout}))
+	assert.True(t, isRetryable(io.ErrUnexpectedEOF))
+	assert.True(t, isRetryable(incompleteError{Received: 1, Segment: &segment{Start: 0, End: 9}}))
+	assert.True(t, isRetryable(digestError{Expected: "a", Actual: "b"}))
+	assert.True(t, isRetryable(&url.Error{Op: "Get", URL: "http://host", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}))
+	assert.True(t, isRetryable(awserr.New(request.ErrCodeResponseTimeout, "timeout", nil)))
+
+	assert.False(t, isRetryable(statusError{StatusCode: http.StatusForbidden}))
+	assert.False(t, isRetryable(errObjectChanged))
+	assert.False(t, isRetryable(awserr.New("NoCredentialProviders", "no valid providers in chain", nil)))
+	assert.False(t, isRetryable(&url.Error{Op: "Get", URL: "http://host", Err: errors.New("stopped after 10 redirects")}))
+	assert.False(t, isRetryable(errors.New("failed to write file")))
+}
+
+func TestCheckContentRange(t *testing.T) {
+	assert.NoError(t, checkContentRange("bytes 256-511/4000", 256, 511))
+	assert.NoError(t, checkContentRange("bytes 0-99/*", 0, 99))
+
+	assert.Error(t, checkContentRange("bytes 0-255/4000", 256, 511))
+	assert.Error(t, checkContentRange("bytes 256-400/4000", 256, 511))
+	assert.Error(t, checkContentRange("", 256, 511))
+}
+
+func TestDownload_VerifiesHash(t *testing.T) {
+	_, restore := useTestSettings(1)
+	defer restore()
+	content := testContent(4000)
+	server := newTestServer(content)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "artifact")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	hash := sha256.Sum256(content)
+
+	output, err := Download(log.NewMockLog(), DownloadInput{
+		SourceURL:            server.URL + "/package.zip",
+		DestinationDirectory: dir,
+		SourceChecksums:      map[string]string{"sha256": hex.EncodeToString(hash[:])},
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, output.IsUpdated)
+	assert.True(t, output.IsHashMatched)
+	assertDownloaded(t, output.LocalFilePath, content)
+}
+
+func TestNewProgress(t *testing.T) {
+	_, restore := useTestSettings(1)
+	defer restore()
+
+	unknown := newProgress(objectInfo{Size: -1})
+	assert.Equal(t, []*segment{{Start: 0, End: -1}}, unknown.Segments)
+
+	noRanges := newProgress(objectInfo{Size: 4000})
+	assert.Equal(t, []*segment{{Start: 0, End: 3999}}, noRanges.Segments)
+
+	empty := newProgress(objectInfo{Size: 0, AcceptRanges: true})
+	assert.Equal(t, []*segment{{Start: 0, End: -1}}, empty.Segments)
+
+	small := newProgress(objectInfo{Size: 1000, AcceptRanges: true})
+	assert.Equal(t, []*segment{{Start: 0, End: 999}}, small.Segments)
+
+	large := newProgress(objectInfo{Size: 1100, AcceptRanges: true})
+	assert.Len(t, large.Segments, 5)
+	assert.Equal(t, &segment{Start: 1024, End: 1099}, large.Segments[4])
+}
@@ -0,0 +1,239 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package artifact
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/s3util"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// httpSource downloads a file from an http or https url
+type httpSource struct {
+	fileURL string
+	client  *http.Client
+}
+
+// newHTTPSource returns the source of the file at the url
+func newHTTPSource(fileURL string) *httpSource {
+	return &httpSource{
+		fileURL: fileURL,
+		client: &http.Client{
+			CheckRedirect: func(r *http.Request, via []*http.Request) error {
+				r.URL.Opaque = r.URL.Path
+				return nil
+			},
+		},
+	}
+}
+
+// stat returns the size and version of the file from a HEAD request. The size is unknown when the server doesn't
+// answer the HEAD request, such as for presigned s3 urls which are only signed for GET, so the file is downloaded
+// in a single request.
+func (h *httpSource) stat(log log.T) (info objectInfo, err error) {
+	info.Size = -1
+	var response *http.Response
+	if response, err = h.client.Head(h.fileURL); err != nil {
+		return info, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		statusErr := statusError{StatusCode: response.StatusCode, Status: response.Status}
+		if isRetryable(statusErr) {
+			return info, statusErr
+		}
+		log.Debugf("HEAD request failed with status %v, downloading %v in a single request", response.Status, h.fileURL)
+		return info, nil
+	}
+
+	info.Size = response.ContentLength
+	info.Version = response.Header.Get("Etag")
+	info.AcceptRanges = response.Header.Get("Accept-Ranges") == "bytes" && info.Size >= 0
+	return info, nil
+}
+
+// fetch returns the content of the range of the file, the If-Range header makes sure the file didn't change
+func (h *httpSource) fetch(log log.T, start int64, end int64, version string) (io.ReadCloser, error) {
+	request, err := http.NewRequest(http.MethodGet, h.fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	ranged := end >= 0
+	if ranged {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", start, end))
+		if version != "" {
+			request.Header.Set("If-Range", version)
+		}
+	}
+
+	response, err := h.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case ranged && response.StatusCode == http.StatusPartialContent:
+		if err = checkContentRange(response.Header.Get("Content-Range"), start, end); err != nil {
+			response.Body.Close()
+			return nil, err
+		}
+		return verifiedBody(response), nil
+	case response.StatusCode == http.StatusOK && (!ranged || start == 0 && end == response.ContentLength-1):
+		// the whole file was requested or returned
+		if version != "" && response.Header.Get("Etag") != "" && response.Header.Get("Etag") != version {
+			response.Body.Close()
+			return nil, errObjectChanged
+		}
+		return verifiedBody(response), nil
+	case ranged && response.StatusCode == http.StatusOK:
+		// the server ignores the range when the file changed
+		response.Body.Close()
+		return nil, errObjectChanged
+	default:
+		// drain the body so the connection can be reused
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+		return nil, statusError{StatusCode: response.StatusCode, Status: response.Status}
+	}
+}
+
+// verifiedBody returns the body of the response, verified against its Content-MD5 when the server sends one.
+// The Content-MD5 of a partial response is the digest of the range, so each segment is verified as it is received.
+func verifiedBody(response *http.Response) io.ReadCloser {
+	expected := response.Header.Get("Content-MD5")
+	if expected == "" {
+		return response.Body
+	}
+	return &digestReader{ReadCloser: response.Body, hash: md5.New(), expected: expected}
+}
+
+// digestReader fails with a digestError at the end of the content when it doesn't match the expected digest
+type digestReader struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+func (r *digestReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		if actual := base64.StdEncoding.EncodeToString(r.hash.Sum(nil)); actual != r.expected {
+			return n, digestError{Expected: r.expected, Actual: actual}
+		}
+	}
+	return n, err
+}
+
+// s3Source downloads an object from s3
+type s3Source struct {
+	amazonS3URL s3util.AmazonS3URL
+	client      *s3.S3
+}
+
+// newS3Source returns the source of the object at the s3 url
+func newS3Source(log log.T, amazonS3URL s3util.AmazonS3URL) *s3Source {
+	config, _ := awsConfig(log, amazonS3URL)
+	appConfig, _ := appconfig.Config(false)
+	sess := session.New(config)
+	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+
+	return &s3Source{
+		amazonS3URL: amazonS3URL,
+		client:      s3.New(sess),
+	}
+}
+
+// stat returns the size and ETag of the object
+func (s *s3Source) stat(log log.T) (info objectInfo, err error) {
+	var output *s3.HeadObjectOutput
+	output, err = s.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.amazonS3URL.Bucket),
+		Key:    aws.String(s.amazonS3URL.Key),
+	})
+	if err != nil {
+		return info, s3Error(err)
+	}
+
+	info.Size = aws.Int64Value(output.ContentLength)
+	info.Version = aws.StringValue(output.ETag)
+	info.AcceptRanges = true
+	return info, nil
+}
+
+// fetch returns the content of the range of the object, the If-Match condition makes sure the object didn't change
+func (s *s3Source) fetch(log log.T, start int64, end int64, version string) (io.ReadCloser, error) {
+	params := &s3.GetObjectInput{
+		Bucket: aws.String(s.amazonS3URL.Bucket),
+		Key:    aws.String(s.amazonS3URL.Key),
+	}
+	if end >= 0 {
+		params.Range = aws.String("bytes=" + strconv.FormatInt(start, 10) + "-" + strconv.FormatInt(end, 10))
+	}
+	if version != "" {
+		params.IfMatch = aws.String(version)
+	}
+
+	output, err := s.client.GetObject(params)
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	if params.Range != nil {
+		if err = checkContentRange(aws.StringValue(output.ContentRange), start, end); err != nil {
+			output.Body.Close()
+			return nil, err
+		}
+	}
+	return output.Body, nil
+}
+
+// checkContentRange verifies the Content-Range of a partial response is the requested range, so the content is
+// written at the right offset
+func checkContentRange(contentRange string, start int64, end int64) error {
+	var rangeStart, rangeEnd int64
+	var total string
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%s", &rangeStart, &rangeEnd, &total); err != nil {
+		return fmt.Errorf("invalid Content-Range %q for the requested range %v-%v", contentRange, start, end)
+	}
+	if rangeStart != start || rangeEnd != end {
+		return fmt.Errorf("received range %v-%v instead of the requested range %v-%v", rangeStart, rangeEnd, start, end)
+	}
+	return nil
+}
+
+// s3Error converts the failed requests to statusError, so the retries are decided like for http
+func s3Error(err error) error {
+	if requestFailure, ok := err.(awserr.RequestFailure); ok {
+		if requestFailure.StatusCode() == http.StatusPreconditionFailed {
+			return errObjectChanged
+		}
+		return statusError{StatusCode: requestFailure.StatusCode(), Status: requestFailure.Error()}
+	}
+	return err
+}
@@ -0,0 +1,284 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package history keeps a local journal of the documents executed by the agent.
+// The journal outlives the orchestration directories so that the executions on the
+// host can be reviewed after they are cleaned up.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	// RedactedValue replaces the parameter values that must not be written to the journal
+	RedactedValue = "****"
+
+	// backupSuffix is appended to the journal file when it is rotated
+	backupSuffix = ".1"
+
+	secureParameterReference = "ssm-secure:"
+)
+
+// sensitiveParameterName matches the parameter names whose values are redacted
+var sensitiveParameterName = regexp.MustCompile(`(?i)(password|passwd|secret|token|credential|privatekey|apikey|accesskey)`)
+
+// lock serializes the writers of the journal within the agent process
+var lock sync.Mutex
+
+// Record is a journal entry describing a document execution
+type Record struct {
+	DocumentID      string
+	CommandID       string `json:",omitempty"`
+	AssociationID   string `json:",omitempty"`
+	DocumentType    contracts.DocumentType
+	DocumentName    string
+	DocumentVersion string                 `json:",omitempty"`
+	Parameters      map[string]interface{} `json:",omitempty"`
+	Status          contracts.ResultStatus
+	StartDateTime   time.Time
+	EndDateTime     time.Time
+	Steps           []Step `json:",omitempty"`
+}
+
+// Step is the outcome of a single plugin of a document execution
+type Step struct {
+	Name          string
+	PluginName    string
+	Status        contracts.ResultStatus
+	ExitCode      int
+	StartDateTime time.Time
+	EndDateTime   time.Time
+	Error         string `json:",omitempty"`
+}
+
+// Filter selects the records returned by Query, zero values match everything
+type Filter struct {
+	Since        time.Time
+	CommandID    string
+	DocumentName string
+	Status       contracts.ResultStatus
+	// MaxResults caps the number of records returned, the most recent ones are kept
+	MaxResults int
+}
+
+// Matches returns true if the record satisfies the filter
+func (f Filter) Matches(record Record) bool {
+	if !f.Since.IsZero() && record.EndDateTime.Before(f.Since) {
+		return false
+	}
+	if f.CommandID != "" && record.CommandID != f.CommandID && record.DocumentID != f.CommandID {
+		return false
+	}
+	if f.DocumentName != "" && !strings.EqualFold(record.DocumentName, f.DocumentName) {
+		return false
+	}
+	if f.Status != "" && !strings.EqualFold(string(record.Status), string(f.Status)) {
+		return false
+	}
+	return true
+}
+
+// JournalPath returns the path of the journal for the given instance
+func JournalPath(instanceID string) string {
+	return filepath.Join(appconfig.DefaultDataStorePath, instanceID, appconfig.HistoryRootDirName, appconfig.HistoryFileName)
+}
+
+// NewRecord builds the journal entry of a completed document
+func NewRecord(docState *contracts.DocumentState, result contracts.DocumentResult) Record {
+	info := docState.DocumentInformation
+	record := Record{
+		DocumentID:      info.DocumentID,
+		CommandID:       info.CommandID,
+		AssociationID:   info.AssociationID,
+		DocumentType:    docState.DocumentType,
+		DocumentName:    info.DocumentName,
+		DocumentVersion: info.DocumentVersion,
+		Parameters:      RedactParameters(docState.Parameters),
+		Status:          result.Status,
+		Steps:           []Step{},
+	}
+	if result.DocumentName != "" {
+		record.DocumentName = result.DocumentName
+	}
+	if result.DocumentVersion != "" {
+		record.DocumentVersion = result.DocumentVersion
+	}
+	for id, pluginResult := range result.PluginResults {
+		if pluginResult == nil {
+			continue
+		}
+		record.Steps = append(record.Steps, Step{
+			Name:          id,
+			PluginName:    pluginResult.PluginName,
+			Status:        pluginResult.Status,
+			ExitCode:      pluginResult.Code,
+			StartDateTime: pluginResult.StartDateTime,
+			EndDateTime:   pluginResult.EndDateTime,
+			Error:         pluginResult.Error,
+		})
+		if !pluginResult.StartDateTime.IsZero() && (record.StartDateTime.IsZero() || pluginResult.StartDateTime.Before(record.StartDateTime)) {
+			record.StartDateTime = pluginResult.StartDateTime
+		}
+		if pluginResult.EndDateTime.After(record.EndDateTime) {
+			record.EndDateTime = pluginResult.EndDateTime
+		}
+	}
+	// plugin results come in a map, report the steps in execution order
+	sort.SliceStable(record.Steps, func(i, j int) bool {
+		return record.Steps[i].StartDateTime.Before(record.Steps[j].StartDateTime)
+	})
+	if record.EndDateTime.IsZero() {
+		record.EndDateTime = time.Now().UTC()
+	}
+	if record.StartDateTime.IsZero() {
+		record.StartDateTime = record.EndDateTime
+	}
+	return record
+}
+
+// RedactParameters returns a copy of the parameters where secure string references and
+// values of parameters with sensitive names are replaced by RedactedValue
+func RedactParameters(parameters map[string]interface{}) map[string]interface{} {
+	if len(parameters) == 0 {
+		return nil
+	}
+	redacted := make(map[string]interface{}, len(parameters))
+	for name, value := range parameters {
+		if sensitiveParameterName.MatchString(name) || containsSecureReference(value) {
+			redacted[name] = RedactedValue
+		} else {
+			redacted[name] = value
+		}
+	}
+	return redacted
+}
+
+// containsSecureReference returns true if the value references a secure string parameter
+func containsSecureReference(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return strings.Contains(v, secureParameterReference)
+	case []interface{}:
+		for _, item := range v {
+			if containsSecureReference(item) {
+				return true
+			}
+		}
+	case []string:
+		for _, item := range v {
+			if containsSecureReference(item) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			if containsSecureReference(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Append adds the record at the end of the journal, the journal is rotated once it grows over
+// appconfig.HistoryMaxFileBytes and only the previous file is kept.
+func Append(log log.T, journalPath string, record Record) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	content, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal execution record of %v: %v", record.DocumentID, err)
+	}
+	if err = fileutil.MakeDirs(filepath.Dir(journalPath)); err != nil {
+		return fmt.Errorf("failed to create history directory: %v", err)
+	}
+	if info, err := os.Stat(journalPath); err == nil && info.Size()+int64(len(content)) > appconfig.HistoryMaxFileBytes {
+		log.Debugf("rotating execution history %v", journalPath)
+		if err = os.Rename(journalPath, journalPath+backupSuffix); err != nil {
+			return fmt.Errorf("failed to rotate execution history: %v", err)
+		}
+	}
+	file, err := os.OpenFile(journalPath, appconfig.FileFlagsCreateOrAppend, appconfig.ReadWriteAccess)
+	if err != nil {
+		return fmt.Errorf("failed to open execution history: %v", err)
+	}
+	defer file.Close()
+	if _, err = file.Write(append(content, '\n')); err != nil {
+		return fmt.Errorf("failed to write execution history: %v", err)
+	}
+	return nil
+}
+
+// Query returns the records of the journal matching the filter, oldest first.
+// Lines that can't be parsed, such as a line cut short by a crash, are skipped.
+func Query(journalPath string, filter Filter) ([]Record, error) {
+	records := []Record{}
+	for _, path := range []string{journalPath + backupSuffix, journalPath} {
+		if !fileutil.Exists(path) {
+			continue
+		}
+		fileRecords, err := readJournal(path, filter)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, fileRecords...)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].EndDateTime.Before(records[j].EndDateTime)
+	})
+	if filter.MaxResults > 0 && len(records) > filter.MaxResults {
+		records = records[len(records)-filter.MaxResults:]
+	}
+	return records, nil
+}
+
+func readJournal(path string, filter Filter) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open execution history: %v", err)
+	}
+	defer file.Close()
+
+	records := []Record{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), appconfig.HistoryMaxFileBytes)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if filter.Matches(record) {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read execution history: %v", err)
+	}
+	return records, nil
+}
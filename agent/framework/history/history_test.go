@@ -0,0 +1,132 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger = log.NewMockLog()
+
+func TestRedactParameters(t *testing.T) {
+	parameters := map[string]interface{}{
+		"commands":      []interface{}{"echo hello"},
+		"dbPassword":    "hunter2",
+		"githubToken":   "{{ssm-secure:token}}",
+		"tokenSource":   "plain",
+		"sourceInfo":    map[string]interface{}{"owner": "me", "tokenInfo": "{{ ssm-secure:gh }}"},
+		"executionTime": "3600",
+	}
+	redacted := RedactParameters(parameters)
+
+	assert.Equal(t, []interface{}{"echo hello"}, redacted["commands"])
+	assert.Equal(t, "3600", redacted["executionTime"])
+	assert.Equal(t, RedactedValue, redacted["dbPassword"])
+	assert.Equal(t, RedactedValue, redacted["githubToken"])
+	assert.Equal(t, RedactedValue, redacted["tokenSource"])
+	assert.Equal(t, RedactedValue, redacted["sourceInfo"])
+	// the input is left untouched
+	assert.Equal(t, "hunter2", parameters["dbPassword"])
+	assert.Nil(t, RedactParameters(nil))
+}
+
+func TestNewRecord(t *testing.T) {
+	start := time.Date(2018, 5, 14, 10, 0, 0, 0, time.UTC)
+	docState := contracts.DocumentState{
+		DocumentType: contracts.SendCommand,
+		Parameters:   map[string]interface{}{"password": "secret"},
+	}
+	docState.DocumentInformation.DocumentID = "documentID"
+	docState.DocumentInformation.CommandID = "commandID"
+	docState.DocumentInformation.DocumentName = "AWS-RunShellScript"
+	result := contracts.DocumentResult{
+		Status: contracts.ResultStatusFailed,
+		PluginResults: map[string]*contracts.PluginResult{
+			"second": {PluginName: "aws:runShellScript", Status: contracts.ResultStatusFailed, Code: 2, Error: "boom",
+				StartDateTime: start.Add(time.Minute), EndDateTime: start.Add(2 * time.Minute)},
+			"first": {PluginName: "aws:runShellScript", Status: contracts.ResultStatusSuccess,
+				StartDateTime: start, EndDateTime: start.Add(time.Minute)},
+		},
+	}
+
+	record := NewRecord(&docState, result)
+
+	assert.Equal(t, "commandID", record.CommandID)
+	assert.Equal(t, "AWS-RunShellScript", record.DocumentName)
+	assert.Equal(t, contracts.ResultStatusFailed, record.Status)
+	assert.Equal(t, RedactedValue, record.Parameters["password"])
+	assert.Equal(t, start, record.StartDateTime)
+	assert.Equal(t, start.Add(2*time.Minute), record.EndDateTime)
+	assert.Len(t, record.Steps, 2)
+	assert.Equal(t, "first", record.Steps[0].Name)
+	assert.Equal(t, "second", record.Steps[1].Name)
+	assert.Equal(t, 2, record.Steps[1].ExitCode)
+	assert.Equal(t, "boom", record.Steps[1].Error)
+}
+
+func TestAppendAndQuery(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "history")
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "history", "executions.jsonl")
+	now := time.Now().UTC()
+
+	records := []Record{
+		{DocumentID: "old", DocumentName: "AWS-RunShellScript", Status: contracts.ResultStatusSuccess, EndDateTime: now.Add(-10 * 24 * time.Hour)},
+		{DocumentID: "failed", DocumentName: "AWS-RunShellScript", Status: contracts.ResultStatusFailed, EndDateTime: now.Add(-time.Hour)},
+		{DocumentID: "patch", DocumentName: "AWS-RunPatchBaseline", Status: contracts.ResultStatusSuccess, EndDateTime: now.Add(-time.Minute)},
+	}
+	for _, record := range records {
+		assert.NoError(t, Append(logger, journal, record))
+	}
+	// a line cut short by a crash doesn't hide the rest of the journal
+	file, _ := os.OpenFile(journal, os.O_APPEND|os.O_WRONLY, 0600)
+	file.WriteString(`{"DocumentID":"trunc`)
+	file.Close()
+
+	all, err := Query(journal, Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	lastWeek, _ := Query(journal, Filter{Since: now.Add(-7 * 24 * time.Hour)})
+	assert.Len(t, lastWeek, 2)
+	assert.Equal(t, "failed", lastWeek[0].DocumentID)
+
+	failed, _ := Query(journal, Filter{Status: "failed"})
+	assert.Len(t, failed, 1)
+	assert.Equal(t, "failed", failed[0].DocumentID)
+
+	byName, _ := Query(journal, Filter{DocumentName: "aws-runpatchbaseline"})
+	assert.Len(t, byName, 1)
+
+	byID, _ := Query(journal, Filter{CommandID: "old"})
+	assert.Len(t, byID, 1)
+
+	latest, _ := Query(journal, Filter{MaxResults: 1})
+	assert.Len(t, latest, 1)
+	assert.Equal(t, "patch", latest[0].DocumentID)
+}
+
+func TestQueryMissingJournal(t *testing.T) {
+	records, err := Query(filepath.Join(os.TempDir(), "missing", "executions.jsonl"), Filter{})
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
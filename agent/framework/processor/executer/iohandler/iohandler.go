@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"path"
 
 	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
@@ -166,7 +167,7 @@ func (out *DefaultIOHandler) Init(log log.T, filePath ...string) {
 	log.Debug("Initializing the Stdout Multi-writer with file and console listeners")
 	// Get a multi-writer for standard output
 	out.StdoutWriter = multiwriter.NewDocumentIOMultiWriter()
-	out.RegisterOutputSource(log, out.StdoutWriter, stdoutFile, stdoutConsole)
+	out.RegisterOutputSource(log, out.StdoutWriter, out.localOutputModules(pluginConfig.StdoutFileName, filePath, stdoutFile, stdoutConsole)...)
 
 	// Initialize file error module
 	stderrFile := iomodule.File{
@@ -188,7 +189,32 @@ func (out *DefaultIOHandler) Init(log log.T, filePath ...string) {
 	log.Debug("Initializing the Stderr Multi-writer with file and console listeners")
 	// Get a multi-writer for standard error
 	out.StderrWriter = multiwriter.NewDocumentIOMultiWriter()
-	out.RegisterOutputSource(log, out.StderrWriter, stderrFile, stderrConsole)
+	out.RegisterOutputSource(log, out.StderrWriter, out.localOutputModules(pluginConfig.StderrFileName, filePath, stderrFile, stderrConsole)...)
+}
+
+// localOutputModules appends the output modules of the destinations configured on the agent to the given modules
+func (out *DefaultIOHandler) localOutputModules(fileName string, filePath []string, modules ...iomodule.IOModule) []iomodule.IOModule {
+	outputPath := path.Join(filePath...)
+	if archiveConfig := out.ioConfig.LocalArchiveConfig; archiveConfig.ArchiveDirectory != "" {
+		modules = append(modules, iomodule.LocalArchive{
+			FileName:             fileName,
+			ArchiveDirectory:     archiveConfig.ArchiveDirectory,
+			DocumentID:           archiveConfig.DocumentID,
+			OutputPath:           outputPath,
+			MaxArchivedDocuments: archiveConfig.MaxArchivedDocuments,
+		})
+	}
+	if httpConfig := out.ioConfig.HTTPOutputConfig; httpConfig.Url != "" {
+		modules = append(modules, iomodule.HTTPOutput{
+			FileName:   fileName,
+			Url:        httpConfig.Url,
+			Headers:    httpConfig.Headers,
+			MaxRetries: httpConfig.MaxRetries,
+			DocumentID: httpConfig.DocumentID,
+			OutputPath: outputPath,
+		})
+	}
+	return modules
 }
 
 // RegisterOutputSource returns a new output source by creating a multiwriter for the output modules.
@@ -9,6 +9,9 @@ import (
 
 	"strconv"
 
+	"io/ioutil"
+	"os"
+
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/stretchr/testify/assert"
 )
@@ -34,22 +37,26 @@ func TestCommandOuput(t *testing.T) {
 			"id gravida facilisis, felis leo malesuada eros, non dignissim quam turpis a massa. ",
 	}
 
+	orchestrationDir, err := ioutil.TempDir("", "commandoutput")
+	assert.NoError(t, err)
+	defer os.RemoveAll(orchestrationDir)
+
 	i := 0
 	for _, testCase := range TestInputCases {
-		stdout := testFileCommandOutput(testCase, i)
+		stdout := testFileCommandOutput(testCase, i, orchestrationDir)
 		assert.Equal(t, testCase, stdout)
 		i++
 	}
 }
 
-func testFileCommandOutput(pipeTestCase string, i int) string {
+func testFileCommandOutput(pipeTestCase string, i int, orchestrationDir string) string {
 	r, w := io.Pipe()
 	wg := new(sync.WaitGroup)
 	var stdout string
 	stdoutConsole := CommandOutput{
 		OutputString:           &stdout,
 		FileName:               "file" + strconv.Itoa(i),
-		OrchestrationDirectory: "testdata",
+		OrchestrationDirectory: orchestrationDir,
 	}
 
 	wg.Add(1)
@@ -0,0 +1,127 @@
+// Copyright 2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iomodule
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	httpOutputTimeout = 30 * time.Second
+
+	// HTTPOutputDocumentIDHeader identifies the document the posted output belongs to
+	HTTPOutputDocumentIDHeader = "X-Amz-Ssm-Document-Id"
+	// HTTPOutputPathHeader identifies the step and stream of the posted output
+	HTTPOutputPathHeader = "X-Amz-Ssm-Output-Path"
+	// HTTPOutputTruncatedHeader is set when the output was larger than what is posted
+	HTTPOutputTruncatedHeader = "X-Amz-Ssm-Output-Truncated"
+)
+
+var (
+	// httpOutputBackoff is the delay before the first retry, it doubles on every attempt
+	httpOutputBackoff = time.Second
+	// httpOutputRetryTimeout bounds the time spent retrying, the plugin only completes once its output is posted
+	httpOutputRetryTimeout = 2 * time.Minute
+	// httpOutputMaxSize caps the size of the posted output of a stream
+	httpOutputMaxSize int64 = 2 * 1024 * 1024
+)
+
+var httpOutputClient = &http.Client{Timeout: httpOutputTimeout}
+
+// HTTPOutput handles posting the output to an HTTP(S) endpoint once the stream is complete
+type HTTPOutput struct {
+	FileName   string
+	Url        string
+	Headers    map[string]string
+	MaxRetries int
+	DocumentID string
+	OutputPath string
+}
+
+// Read reads the stream and posts the output to the endpoint, failed requests are retried
+// with an exponential backoff when the error is transient, as long as the retry timeout allows it.
+// The output beyond the maximum size is dropped.
+func (output HTTPOutput) Read(log log.T, reader *io.PipeReader) {
+	defer func() { reader.Close() }()
+
+	content, err := ioutil.ReadAll(io.LimitReader(reader, httpOutputMaxSize+1))
+	if err != nil {
+		log.Errorf("Failed to read the output for %v: %v", output.Url, err)
+		return
+	}
+	truncated := int64(len(content)) > httpOutputMaxSize
+	if truncated {
+		content = content[:httpOutputMaxSize]
+		// keep reading the stream so the plugin writing its output isn't blocked
+		dropped, _ := io.Copy(ioutil.Discard, reader)
+		log.Warnf("Output for %v is larger than %v bytes, the last %v bytes are not posted", output.Url, httpOutputMaxSize, dropped+1)
+	}
+	if len(content) == 0 {
+		return
+	}
+
+	deadline := time.Now().Add(httpOutputRetryTimeout)
+	backoff := httpOutputBackoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := output.post(content, truncated)
+		if err == nil {
+			return
+		}
+		if !retryable || attempt >= output.MaxRetries || time.Now().Add(backoff).After(deadline) {
+			log.Errorf("Failed to post the output to %v: %v", output.Url, err)
+			return
+		}
+		log.Debugf("Failed to post the output to %v, retrying in %v: %v", output.Url, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post sends the content to the endpoint and reports whether a failure is worth retrying
+func (output HTTPOutput) post(content []byte, truncated bool) (retryable bool, err error) {
+	request, err := http.NewRequest(http.MethodPost, output.Url, bytes.NewReader(content))
+	if err != nil {
+		return false, err
+	}
+	for name, value := range output.Headers {
+		request.Header.Set(name, value)
+	}
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	request.Header.Set(HTTPOutputDocumentIDHeader, output.DocumentID)
+	request.Header.Set(HTTPOutputPathHeader, path.Join(output.OutputPath, output.FileName))
+	if truncated {
+		request.Header.Set(HTTPOutputTruncatedHeader, "true")
+	}
+
+	response, err := httpOutputClient.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("unexpected status %v", response.Status)
+	return response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests, err
+}
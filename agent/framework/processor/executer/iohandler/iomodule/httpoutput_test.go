@@ -0,0 +1,139 @@
+// Copyright 2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iomodule
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type httpOutputServer struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []string
+}
+
+func (s *httpOutputServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, string(body))
+	status := http.StatusOK
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func init() {
+	httpOutputBackoff = time.Millisecond
+}
+
+// TestHTTPOutput tests the output is posted with the configured and identifying headers
+func TestHTTPOutput(t *testing.T) {
+	handler := &httpOutputServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	output := HTTPOutput{
+		FileName:   "stdout",
+		Url:        server.URL,
+		Headers:    map[string]string{"Authorization": "Bearer abc"},
+		MaxRetries: 3,
+		DocumentID: "command1",
+		OutputPath: "awsrunShellScript/0.awsrunShellScript",
+	}
+	readIntoModule(output, "hello world")
+
+	assert.Len(t, handler.requests, 1)
+	request := handler.requests[0]
+	assert.Equal(t, http.MethodPost, request.Method)
+	assert.Equal(t, "hello world", handler.bodies[0])
+	assert.Equal(t, "Bearer abc", request.Header.Get("Authorization"))
+	assert.Equal(t, "command1", request.Header.Get(HTTPOutputDocumentIDHeader))
+	assert.Equal(t, "awsrunShellScript/0.awsrunShellScript/stdout", request.Header.Get(HTTPOutputPathHeader))
+}
+
+// TestHTTPOutputRetries tests transient failures are retried up to MaxRetries
+func TestHTTPOutputRetries(t *testing.T) {
+	handler := &httpOutputServer{statuses: []int{http.StatusInternalServerError, http.StatusTooManyRequests}}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	readIntoModule(HTTPOutput{FileName: "stdout", Url: server.URL, MaxRetries: 3}, "output")
+	assert.Len(t, handler.requests, 3)
+
+	handler.requests = nil
+	handler.statuses = []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}
+	readIntoModule(HTTPOutput{FileName: "stdout", Url: server.URL, MaxRetries: 1}, "output")
+	assert.Len(t, handler.requests, 2)
+}
+
+// TestHTTPOutputDoesNotRetryClientErrors tests client errors are not retried
+func TestHTTPOutputDoesNotRetryClientErrors(t *testing.T) {
+	handler := &httpOutputServer{statuses: []int{http.StatusForbidden}}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	readIntoModule(HTTPOutput{FileName: "stdout", Url: server.URL, MaxRetries: 3}, "output")
+	assert.Len(t, handler.requests, 1)
+
+	// empty output is not posted
+	readIntoModule(HTTPOutput{FileName: "stdout", Url: server.URL, MaxRetries: 3}, "")
+	assert.Len(t, handler.requests, 1)
+}
+
+// TestHTTPOutputTruncatesLargeOutput tests the output beyond the maximum size is dropped and flagged
+func TestHTTPOutputTruncatesLargeOutput(t *testing.T) {
+	handler := &httpOutputServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	defer func(size int64) { httpOutputMaxSize = size }(httpOutputMaxSize)
+	httpOutputMaxSize = 5
+
+	readIntoModule(HTTPOutput{FileName: "stdout", Url: server.URL}, "hello world")
+	assert.Len(t, handler.requests, 1)
+	assert.Equal(t, "hello", handler.bodies[0])
+	assert.Equal(t, "true", handler.requests[0].Header.Get(HTTPOutputTruncatedHeader))
+
+	readIntoModule(HTTPOutput{FileName: "stdout", Url: server.URL}, "hello")
+	assert.Equal(t, "hello", handler.bodies[1])
+	assert.Equal(t, "", handler.requests[1].Header.Get(HTTPOutputTruncatedHeader))
+}
+
+// TestHTTPOutputRetryTimeout tests retries stop when the next one would exceed the retry timeout
+func TestHTTPOutputRetryTimeout(t *testing.T) {
+	handler := &httpOutputServer{statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	defer func(backoff, timeout time.Duration) {
+		httpOutputBackoff, httpOutputRetryTimeout = backoff, timeout
+	}(httpOutputBackoff, httpOutputRetryTimeout)
+	httpOutputBackoff = 50 * time.Millisecond
+	httpOutputRetryTimeout = 120 * time.Millisecond
+
+	start := time.Now()
+	readIntoModule(HTTPOutput{FileName: "stdout", Url: server.URL, MaxRetries: 10}, "output")
+	// retries after 50ms, then stops as the next retry 100ms later would exceed the timeout
+	assert.Len(t, handler.requests, 2)
+	assert.True(t, time.Since(start) < time.Second)
+}
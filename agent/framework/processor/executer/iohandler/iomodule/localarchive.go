@@ -0,0 +1,96 @@
+// Copyright 2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iomodule
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// LocalArchive handles writing the output to a local archive directory, the archive keeps one
+// directory per document and removes the oldest documents once MaxArchivedDocuments is reached.
+type LocalArchive struct {
+	FileName             string
+	ArchiveDirectory     string
+	DocumentID           string
+	OutputPath           string
+	MaxArchivedDocuments int
+}
+
+// Read reads from the stream and writes to the archived output file.
+func (archive LocalArchive) Read(log log.T, reader *io.PipeReader) {
+	defer func() { reader.Close() }()
+
+	outputDir := filepath.Join(archive.ArchiveDirectory, archive.DocumentID, archive.OutputPath)
+	if err := fileutil.MakeDirs(outputDir); err != nil {
+		log.Errorf("failed to create archive directory at %v: %v", outputDir, err)
+		// drain the stream so that the other output modules are not blocked
+		io.Copy(ioutil.Discard, reader)
+		return
+	}
+
+	filePath := filepath.Join(outputDir, archive.FileName)
+	fileWriter, err := os.OpenFile(filePath, appconfig.FileFlagsCreateOrAppend, appconfig.ReadWriteAccess)
+	if err != nil {
+		log.Errorf("Failed to open the archive file at %v: %v", filePath, err)
+		io.Copy(ioutil.Discard, reader)
+		return
+	}
+	defer fileWriter.Close()
+
+	if _, err = io.Copy(fileWriter, reader); err != nil {
+		log.Errorf("Failed to write the output to the archive: %v", err)
+	}
+
+	archive.rotate(log)
+}
+
+// rotate removes the oldest documents of the archive beyond MaxArchivedDocuments
+func (archive LocalArchive) rotate(log log.T) {
+	if archive.MaxArchivedDocuments <= 0 {
+		return
+	}
+	entries, err := ioutil.ReadDir(archive.ArchiveDirectory)
+	if err != nil {
+		log.Warnf("failed to list archive directory %v: %v", archive.ArchiveDirectory, err)
+		return
+	}
+	documents := []os.FileInfo{}
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != archive.DocumentID {
+			documents = append(documents, entry)
+		}
+	}
+	// the current document is always kept
+	excess := len(documents) + 1 - archive.MaxArchivedDocuments
+	if excess <= 0 {
+		return
+	}
+	sort.Slice(documents, func(i, j int) bool {
+		return documents[i].ModTime().Before(documents[j].ModTime())
+	})
+	for _, document := range documents[:excess] {
+		log.Debugf("removing archived output of document %v", document.Name())
+		if err := os.RemoveAll(filepath.Join(archive.ArchiveDirectory, document.Name())); err != nil {
+			log.Warnf("failed to remove archived output %v: %v", document.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iomodule
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readIntoModule(module IOModule, content string) {
+	r, w := io.Pipe()
+	done := make(chan bool)
+	go func() {
+		module.Read(logger, r)
+		close(done)
+	}()
+	w.Write([]byte(content))
+	w.Close()
+	<-done
+}
+
+// TestLocalArchive tests the output is written under the document directory of the archive
+func TestLocalArchive(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "archive")
+	defer os.RemoveAll(dir)
+
+	archive := LocalArchive{
+		FileName:             "stdout",
+		ArchiveDirectory:     dir,
+		DocumentID:           "command1",
+		OutputPath:           "awsrunShellScript/0.awsrunShellScript",
+		MaxArchivedDocuments: 10,
+	}
+	readIntoModule(archive, "hello world")
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "command1", "awsrunShellScript", "0.awsrunShellScript", "stdout"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(content))
+}
+
+// TestLocalArchiveRotation tests the oldest documents are removed once the archive is full
+func TestLocalArchiveRotation(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "archive")
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	for i, id := range []string{"old", "older", "recent"} {
+		os.MkdirAll(filepath.Join(dir, id), 0700)
+		modTime := now.Add(-time.Duration(i+1) * time.Hour)
+		if id == "recent" {
+			modTime = now.Add(-time.Minute)
+		}
+		os.Chtimes(filepath.Join(dir, id), modTime, modTime)
+	}
+
+	archive := LocalArchive{
+		FileName:             "stderr",
+		ArchiveDirectory:     dir,
+		DocumentID:           "current",
+		MaxArchivedDocuments: 2,
+	}
+	readIntoModule(archive, "error")
+
+	names, _ := ioutil.ReadDir(dir)
+	kept := []string{}
+	for _, name := range names {
+		kept = append(kept, name.Name())
+	}
+	assert.Equal(t, []string{"current", "recent"}, kept)
+}
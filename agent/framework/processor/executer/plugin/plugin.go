@@ -24,6 +24,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurecontainers"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/desiredstate"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/dockercontainer"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory"
@@ -42,6 +43,7 @@ import (
 var allPlugins = map[string]struct{}{
 	appconfig.PluginNameAwsAgentUpdate:         {},
 	appconfig.PluginNameAwsApplications:        {},
+	appconfig.PluginNameAwsCheckDesiredState:   {},
 	appconfig.PluginNameAwsConfigureDaemon:     {},
 	appconfig.PluginNameAwsConfigurePackage:    {},
 	appconfig.PluginNameAwsPowerShellModule:    {},
@@ -81,7 +83,7 @@ type RunPowerShellFactory struct {
 }
 
 func (f RunPowerShellFactory) Create(context context.T) (runpluginutil.T, error) {
-	return runscript.NewRunPowerShellPlugin()
+	return runscript.NewRunPowerShellPlugin(context)
 }
 
 type UpdateAgentFactory struct {
@@ -133,6 +135,13 @@ func (r RunDocumentFactory) Create(context context.T) (runpluginutil.T, error) {
 	return rundocument.NewPlugin()
 }
 
+type CheckDesiredStateFactory struct {
+}
+
+func (f CheckDesiredStateFactory) Create(context context.T) (runpluginutil.T, error) {
+	return desiredstate.NewPlugin()
+}
+
 type SessionPluginFactory struct {
 	newPluginFunc sessionplugin.NewPluginFunc
 }
@@ -236,5 +245,9 @@ func loadPlatformIndependentPlugins(context context.T) runpluginutil.PluginRegis
 	runDocumentPluginName := rundocument.Name()
 	workerPlugins[runDocumentPluginName] = RunDocumentFactory{}
 
+	//registering aws:checkDesiredState
+	checkDesiredStatePluginName := desiredstate.Name()
+	workerPlugins[checkDesiredStatePluginName] = CheckDesiredStateFactory{}
+
 	return workerPlugins
 }
@@ -22,15 +22,20 @@ import (
 	"time"
 
 	"path/filepath"
+	"sort"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
+	"github.com/aws/amazon-ssm-agent/agent/framework/history"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/queue"
+	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/longrunning/manager"
+	"github.com/aws/amazon-ssm-agent/agent/maintenancewindow"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/rebooter"
 	"github.com/aws/amazon-ssm-agent/agent/task"
@@ -39,6 +44,11 @@ import (
 
 type ExecuterCreator func(ctx context.T) executer.Executer
 
+// recordHistory appends a completed document to the local execution history, tests replace it to stay off the disk
+var recordHistory = func(log log.T, instanceID string, record history.Record) error {
+	return history.Append(log, history.JournalPath(instanceID), record)
+}
+
 const (
 
 	// hardstopTimeout is the time before the processor will be shutdown during a hardstop
@@ -69,6 +79,17 @@ type EngineProcessor struct {
 	supportedDocTypes []contracts.DocumentType
 	resChan           chan contracts.DocumentResult
 	documentMgr       docmanager.DocumentMgr
+	// commandQueue orders the documents waiting for a worker, it survives agent restarts
+	commandQueue queue.Queue
+	// queuedDocs holds the states of the documents in commandQueue, keyed by document id
+	queuedDocs map[string]contracts.DocumentState
+	queueMut   sync.Mutex
+	stopped    bool
+	// dispatchMut is held while documents are submitted to the worker pools, Stop takes it so that
+	// the pools are never shut down during a submit
+	dispatchMut sync.RWMutex
+	// maintenance decides when associations are allowed to start
+	maintenance *maintenancewindow.Policy
 }
 
 //TODO worker pool should be triggered in the Start() function
@@ -86,6 +107,13 @@ func NewEngineProcessor(ctx context.T, commandWorkerLimit int, cancelWorkerLimit
 		return outofproc.NewOutOfProcExecuter(ctx)
 	}
 	documentMgr := docmanager.NewDocumentFileMgr(appconfig.DefaultDataStorePath, appconfig.DefaultDocumentRootDirName, appconfig.DefaultLocationOfState)
+	// cancel requests have their own pool, the other documents share the command workers
+	limits := queue.Limits{
+		Classes:  map[queue.Class]int{queue.ClassCancel: cancelWorkerLimit},
+		Commands: commandWorkerLimit,
+	}
+	instanceID, _ := platform.InstanceID()
+	commandQueue := queue.NewPersistentQueue(log, docmanager.DocumentStateDir(instanceID, appconfig.DefaultLocationOfQueue), limits)
 	return &EngineProcessor{
 		context:           ctx.With("[EngineProcessor]"),
 		executerCreator:   executerCreator,
@@ -94,6 +122,9 @@ func NewEngineProcessor(ctx context.T, commandWorkerLimit int, cancelWorkerLimit
 		supportedDocTypes: supportedDocs,
 		resChan:           resChan,
 		documentMgr:       documentMgr,
+		commandQueue:      commandQueue,
+		queuedDocs:        make(map[string]contracts.DocumentState),
+		maintenance:       maintenancewindow.NewPolicy(log, ctx.AppConfig().Maintenance),
 	}
 }
 
@@ -128,6 +159,8 @@ func (p *EngineProcessor) InitialProcessing() (err error) {
 	p.processInProgressDocuments(instanceID)
 	//deal with the pending jobs that haven't picked up by worker yet
 	p.processPendingDocuments(instanceID)
+	//drop the queue entries left by documents whose state is gone
+	p.commandQueue.Prune(log, p.supportedDocTypes)
 	return
 }
 
@@ -136,7 +169,7 @@ func (p *EngineProcessor) Submit(docState contracts.DocumentState) {
 	log := p.context.Log()
 	//queue up the pending document
 	p.documentMgr.PersistDocumentState(log, docState.DocumentInformation.DocumentID, docState.DocumentInformation.InstanceID, appconfig.DefaultLocationOfPending, docState)
-	err := p.submit(&docState)
+	err := p.enqueue(docState, queue.ClassOf(docState.DocumentType))
 	if err != nil {
 		log.Error("Document Submission failed", err)
 		//move the fail-to-submit document to corrupt folder
@@ -147,16 +180,94 @@ func (p *EngineProcessor) Submit(docState contracts.DocumentState) {
 	return
 }
 
-func (p *EngineProcessor) submit(docState *contracts.DocumentState) error {
+func (p *EngineProcessor) Cancel(docState contracts.DocumentState) {
 	log := p.context.Log()
-	//TODO this is a hack, in future jobID should be managed by Processing engine itself, instead of inferring from job's internal field
-	var jobID string
-	if docState.IsAssociation() {
-		jobID = docState.DocumentInformation.AssociationID
+	//queue up the pending document
+	p.documentMgr.PersistDocumentState(log, docState.DocumentInformation.DocumentID, docState.DocumentInformation.InstanceID, appconfig.DefaultLocationOfPending, docState)
+	if err := p.enqueue(docState, queue.ClassCancel); err != nil {
+		log.Error("CancelCommand failed", err)
+		return
+	}
+}
+
+// enqueue adds the document to the command queue and dispatches whatever the queue allows to run
+func (p *EngineProcessor) enqueue(docState contracts.DocumentState, class queue.Class) error {
+	item := queue.Item{
+		DocumentID:   docState.DocumentInformation.DocumentID,
+		JobID:        jobIDOf(&docState),
+		DocumentType: docState.DocumentType,
+		Class:        class,
+	}
+	p.queueMut.Lock()
+	if _, err := p.commandQueue.Push(p.context.Log(), item); err != nil {
+		p.queueMut.Unlock()
+		return err
+	}
+	p.queuedDocs[item.DocumentID] = docState
+	p.queueMut.Unlock()
+	p.dispatch()
+	return nil
+}
+
+// dispatch submits the queued documents to the worker pools in the order decided by the command queue. The lock is
+// only held to take the next document, submitting to a busy pool blocks without stalling enqueue.
+func (p *EngineProcessor) dispatch() {
+	for p.dispatchNext() {
+	}
+}
+
+// dispatchNext submits the next queued document, it returns false when no document can be dispatched
+func (p *EngineProcessor) dispatchNext() bool {
+	log := p.context.Log()
+	p.dispatchMut.RLock()
+	defer p.dispatchMut.RUnlock()
+
+	p.queueMut.Lock()
+	if p.stopped {
+		p.queueMut.Unlock()
+		return false
+	}
+	item, ok := p.commandQueue.Pop(log)
+	if !ok {
+		p.queueMut.Unlock()
+		return false
+	}
+	docState := p.queuedDocs[item.DocumentID]
+	delete(p.queuedDocs, item.DocumentID)
+	p.queueMut.Unlock()
+
+	var err error
+	if item.Class == queue.ClassCancel {
+		err = p.cancel(docState)
 	} else {
-		jobID = docState.DocumentInformation.MessageID
+		err = p.submit(&docState, item.Canceled)
 	}
-	return p.sendCommandPool.Submit(log, jobID, func(cancelFlag task.CancelFlag) {
+	if err != nil {
+		log.Errorf("failed to dispatch document %v: %v", item.DocumentID, err)
+		p.commandQueue.Done(log, item.DocumentID)
+		p.documentMgr.MoveDocumentState(log, item.DocumentID, docState.DocumentInformation.InstanceID, appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCorrupt)
+	}
+	return true
+}
+
+// complete releases the queue slot of a finished document and dispatches the next ones
+func (p *EngineProcessor) complete(documentID string) {
+	p.commandQueue.Done(p.context.Log(), documentID)
+	// the calling worker is still busy, dispatch asynchronously so that a full pool doesn't block it
+	go p.dispatch()
+}
+
+func (p *EngineProcessor) submit(docState *contracts.DocumentState, canceled bool) error {
+	log := p.context.Log()
+	return p.sendCommandPool.Submit(log, jobIDOf(docState), func(cancelFlag task.CancelFlag) {
+		defer p.complete(docState.DocumentInformation.DocumentID)
+		if canceled {
+			//the document was canceled while queued, run it canceled so that every plugin reports the cancellation
+			cancelFlag.Set(task.Canceled)
+		} else if decision := p.maintenanceDecision(docState); !decision.Allowed {
+			skipCommand(p.context, p.resChan, docState, p.documentMgr, decision.Reason)
+			return
+		}
 		processCommand(
 			p.context,
 			p.executerCreator,
@@ -168,24 +279,31 @@ func (p *EngineProcessor) submit(docState *contracts.DocumentState) error {
 
 }
 
-func (p *EngineProcessor) Cancel(docState contracts.DocumentState) {
-	log := p.context.Log()
-	//TODO this is a hack, in future jobID should be managed by Processing engine itself, instead of inferring from job's internal field
-	var jobID string
-	if docState.IsAssociation() {
-		jobID = docState.DocumentInformation.AssociationID
-	} else {
-		jobID = docState.DocumentInformation.MessageID
+// maintenanceDecision checks the associations which did not start yet against the maintenance windows and blackouts,
+// the association resumed after a restart or a reboot completes
+func (p *EngineProcessor) maintenanceDecision(docState *contracts.DocumentState) maintenancewindow.Decision {
+	if !docState.IsAssociation() || docState.DocumentInformation.RunCount > 0 {
+		return maintenancewindow.Decision{Allowed: true}
 	}
-	//queue up the pending document
-	p.documentMgr.PersistDocumentState(log, docState.DocumentInformation.DocumentID, docState.DocumentInformation.InstanceID, appconfig.DefaultLocationOfPending, docState)
-	err := p.cancelCommandPool.Submit(log, jobID, func(cancelFlag task.CancelFlag) {
-		processCancelCommand(p.context, p.sendCommandPool, &docState, p.documentMgr)
+	return p.maintenance.Check(docState.DocumentInformation.DocumentName, time.Now())
+}
+
+func (p *EngineProcessor) cancel(docState contracts.DocumentState) error {
+	log := p.context.Log()
+	return p.cancelCommandPool.Submit(log, jobIDOf(&docState), func(cancelFlag task.CancelFlag) {
+		defer p.complete(docState.DocumentInformation.DocumentID)
+		processCancelCommand(p.context, p.sendCommandPool, p.commandQueue, &docState, p.documentMgr)
 	})
-	if err != nil {
-		log.Error("CancelCommand failed", err)
-		return
+}
+
+// TODO this is a hack, in future jobID should be managed by Processing engine itself, instead of inferring from job's internal field
+// Associations are keyed by document id: the queue slot of a run is released before the pool forgets its job, so the
+// next run of the same association must not reuse the id.
+func jobIDOf(docState *contracts.DocumentState) string {
+	if docState.IsAssociation() {
+		return docState.DocumentInformation.DocumentID
 	}
+	return docState.DocumentInformation.MessageID
 }
 
 //Stop set the cancel flags of all the running jobs, which are to be captured by the command worker and shutdown gracefully
@@ -198,6 +316,15 @@ func (p *EngineProcessor) Stop(stopType contracts.StopType) {
 		waitTimeout = hardStopTimeout
 	}
 
+	// stop dispatching, the documents still queued are persisted and resumed on the next start.
+	// A submit in progress only waits for a worker finishing its job since the queue never has more
+	// documents in flight than workers, it completes before the pools are shut down.
+	p.dispatchMut.Lock()
+	p.queueMut.Lock()
+	p.stopped = true
+	p.queueMut.Unlock()
+	p.dispatchMut.Unlock()
+
 	var wg sync.WaitGroup
 
 	// shutdown the send command pool in a separate go routine
@@ -240,7 +367,8 @@ func (p *EngineProcessor) processPendingDocuments(instanceID string) {
 		return
 	}
 
-	//iterate through all pending messages
+	//iterate through all pending messages in the order they were queued before the restart
+	p.sortByQueueOrder(files)
 	for _, f := range files {
 		log.Infof("Found pending document - %v", f.Name())
 		//inspect document state
@@ -275,6 +403,7 @@ func (p *EngineProcessor) processInProgressDocuments(instanceID string) {
 	}
 
 	//iterate through all InProgress docs
+	p.sortByQueueOrder(files)
 	for _, f := range files {
 		log.Infof("Found in-progress document - %v", f.Name())
 
@@ -294,8 +423,8 @@ func (p *EngineProcessor) processInProgressDocuments(instanceID string) {
 
 		if p.isSupportedDocumentType(docState.DocumentType) {
 			log.Infof("Processing in-progress document %v", docState.DocumentInformation.DocumentID)
-			//Submit the work to Job Pool so that we don't block for processing of new messages
-			if err := p.submit(&docState); err != nil {
+			//Queue the work for the Job Pool so that we don't block for processing of new messages
+			if err := p.enqueue(docState, queue.ClassOf(docState.DocumentType)); err != nil {
 				log.Errorf("failed to submit in progress document %v : %v", docState.DocumentInformation.DocumentID, err)
 				p.documentMgr.MoveDocumentState(log, f.Name(), instanceID, appconfig.DefaultLocationOfCurrent, appconfig.DefaultLocationOfCorrupt)
 			}
@@ -303,6 +432,23 @@ func (p *EngineProcessor) processInProgressDocuments(instanceID string) {
 	}
 }
 
+// sortByQueueOrder sorts the document state files by their position in the command queue of the previous run,
+// documents unknown to the queue are moved last
+func (p *EngineProcessor) sortByQueueOrder(files []os.FileInfo) {
+	positions := make(map[string]int)
+	for i, item := range p.commandQueue.Recovered() {
+		positions[item.DocumentID] = i
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		pi, foundi := positions[files[i].Name()]
+		pj, foundj := positions[files[j].Name()]
+		if foundi && foundj {
+			return pi < pj
+		}
+		return foundi && !foundj
+	})
+}
+
 func (p *EngineProcessor) isSupportedDocumentType(documentType contracts.DocumentType) bool {
 	for _, d := range p.supportedDocTypes {
 		if documentType == d {
@@ -312,6 +458,44 @@ func (p *EngineProcessor) isSupportedDocumentType(documentType contracts.Documen
 	return false
 }
 
+// skipCommand reports the document as skipped for the given reason without running it
+func skipCommand(context context.T, resChan chan contracts.DocumentResult, docState *contracts.DocumentState, docMgr docmanager.DocumentMgr, reason string) {
+	log := context.Log()
+	documentID := docState.DocumentInformation.DocumentID
+	instanceID := docState.DocumentInformation.InstanceID
+	log.Infof("skipping document %v, %v", documentID, reason)
+
+	now := time.Now()
+	pluginResults := make(map[string]*contracts.PluginResult)
+	for _, plugin := range docState.InstancePluginsInformation {
+		pluginResults[plugin.Id] = &contracts.PluginResult{
+			PluginID:       plugin.Id,
+			PluginName:     plugin.Name,
+			Status:         contracts.ResultStatusSkipped,
+			Output:         reason,
+			StandardOutput: reason,
+			StartDateTime:  now,
+			EndDateTime:    now,
+		}
+	}
+	res := contracts.DocumentResult{
+		DocumentName:    docState.DocumentInformation.DocumentName,
+		DocumentVersion: docState.DocumentInformation.DocumentVersion,
+		MessageID:       docState.DocumentInformation.MessageID,
+		AssociationID:   docState.DocumentInformation.AssociationID,
+		PluginResults:   pluginResults,
+		Status:          contracts.ResultStatusSkipped,
+		NPlugins:        len(docState.InstancePluginsInformation),
+		SkippedReason:   reason,
+	}
+	resChan <- res
+
+	if err := recordHistory(log, instanceID, history.NewRecord(docState, res)); err != nil {
+		log.Warnf("failed to record execution of %v in the local history: %v", documentID, err)
+	}
+	docMgr.RemoveDocumentState(log, documentID, instanceID, appconfig.DefaultLocationOfPending)
+}
+
 func processCommand(context context.T, executerCreator ExecuterCreator, cancelFlag task.CancelFlag, resChan chan contracts.DocumentResult, docState *contracts.DocumentState, docMgr docmanager.DocumentMgr) {
 	log := context.Log()
 	//persist the current running document
@@ -355,6 +539,11 @@ func processCommand(context context.T, executerCreator ExecuterCreator, cancelFl
 		return
 	}
 
+	//keep track of the execution once its state and orchestration directory are gone
+	if err := recordHistory(log, instanceID, history.NewRecord(docState, *final)); err != nil {
+		log.Warnf("failed to record execution of %v in the local history: %v", documentID, err)
+	}
+
 	//persist : commands execution in completed folder (terminal state folder)
 	log.Infof("execution of %v is over. Removing interimState from current folder", messageID)
 
@@ -366,7 +555,7 @@ func processCommand(context context.T, executerCreator ExecuterCreator, cancelFl
 }
 
 //TODO CancelCommand is currently treated as a special type of Command by the Processor, but in general Cancel operation should be seen as a probe to existing commands
-func processCancelCommand(context context.T, sendCommandPool task.Pool, docState *contracts.DocumentState, docMgr docmanager.DocumentMgr) {
+func processCancelCommand(context context.T, sendCommandPool task.Pool, commandQueue queue.Queue, docState *contracts.DocumentState, docMgr docmanager.DocumentMgr) {
 
 	log := context.Log()
 	//persist the final status of cancel-message in current folder
@@ -376,7 +565,10 @@ func processCancelCommand(context context.T, sendCommandPool task.Pool, docState
 		appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
 	log.Debugf("Canceling job with id %v...", docState.CancelInformation.CancelMessageID)
 
-	if found := sendCommandPool.Cancel(docState.CancelInformation.CancelMessageID); !found {
+	//the command might still be waiting in the queue, in which case it will run canceled
+	found := commandQueue.Cancel(log, docState.CancelInformation.CancelMessageID) ||
+		sendCommandPool.Cancel(docState.CancelInformation.CancelMessageID)
+	if !found {
 		log.Debugf("Job with id %v not found (possibly completed)", docState.CancelInformation.CancelMessageID)
 		docState.CancelInformation.DebugInfo = fmt.Sprintf("Command %v couldn't be cancelled", docState.CancelInformation.CancelCommandID)
 		docState.DocumentInformation.DocumentStatus = contracts.ResultStatusFailed
@@ -15,21 +15,35 @@
 package processor
 
 import (
-	"testing"
-
 	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer"
+	"github.com/aws/amazon-ssm-agent/agent/framework/history"
 	executermocks "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/mock"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/queue"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/maintenancewindow"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+var recordedHistory []history.Record
+
+func init() {
+	recordHistory = func(log log.T, instanceID string, record history.Record) error {
+		recordedHistory = append(recordedHistory, record)
+		return nil
+	}
+}
+
 //TODO implement processor_integ_test once we encapsulate docmanager
 func TestEngineProcessor_Submit(t *testing.T) {
 	sendCommandPoolMock := new(task.MockedPool)
@@ -40,19 +54,108 @@ func TestEngineProcessor_Submit(t *testing.T) {
 	}
 	sendCommandPoolMock.On("Submit", ctx.Log(), "messageID", mock.Anything).Return(nil)
 	docMock := new(DocumentMgrMock)
+	commandQueue, cleanup := newTestQueue(t, ctx)
+	defer cleanup()
 	processor := EngineProcessor{
 		executerCreator: creator,
 		sendCommandPool: sendCommandPoolMock,
 		context:         ctx,
 		documentMgr:     docMock,
+		commandQueue:    commandQueue,
+		queuedDocs:      make(map[string]contracts.DocumentState),
 	}
 	docState := contracts.DocumentState{}
+	docState.DocumentInformation.DocumentID = "documentID"
 	docState.DocumentInformation.MessageID = "messageID"
 	docMock.On("PersistDocumentState", mock.Anything, mock.Anything, mock.Anything, appconfig.DefaultLocationOfPending, docState)
 	processor.Submit(docState)
 	sendCommandPoolMock.AssertExpectations(t)
 }
 
+func TestEngineProcessor_SubmitQueuesWhenWorkersAreBusy(t *testing.T) {
+	sendCommandPoolMock := new(task.MockedPool)
+	ctx := context.NewMockDefault()
+	sendCommandPoolMock.On("Submit", ctx.Log(), "messageID1", mock.Anything).Return(nil)
+	docMock := new(DocumentMgrMock)
+	docMock.On("PersistDocumentState", mock.Anything, mock.Anything, mock.Anything, appconfig.DefaultLocationOfPending, mock.Anything)
+	dir, cleanup := testQueueDir(t)
+	defer cleanup()
+	commandQueue := queue.NewPersistentQueue(ctx.Log(), dir, queue.Limits{Commands: 1})
+	processor := EngineProcessor{
+		sendCommandPool: sendCommandPoolMock,
+		context:         ctx,
+		documentMgr:     docMock,
+		commandQueue:    commandQueue,
+		queuedDocs:      make(map[string]contracts.DocumentState),
+	}
+	for _, id := range []string{"1", "2"} {
+		docState := contracts.DocumentState{DocumentType: contracts.SendCommand}
+		docState.DocumentInformation.DocumentID = "documentID" + id
+		docState.DocumentInformation.MessageID = "messageID" + id
+		processor.Submit(docState)
+	}
+	sendCommandPoolMock.AssertExpectations(t)
+	sendCommandPoolMock.AssertNotCalled(t, "Submit", ctx.Log(), "messageID2", mock.Anything)
+	assert.Equal(t, 1, commandQueue.Len())
+}
+
+func TestEngineProcessor_SubmitDoesNotWaitForBusyPool(t *testing.T) {
+	sendCommandPoolMock := new(task.MockedPool)
+	ctx := context.NewMockDefault()
+	waiting, release := make(chan struct{}), make(chan struct{})
+	// the first document waits for a free worker
+	sendCommandPoolMock.On("Submit", ctx.Log(), "messageID1", mock.Anything).Return(nil).Run(func(mock.Arguments) {
+		close(waiting)
+		<-release
+	})
+	docMock := new(DocumentMgrMock)
+	docMock.On("PersistDocumentState", mock.Anything, mock.Anything, mock.Anything, appconfig.DefaultLocationOfPending, mock.Anything)
+	dir, cleanup := testQueueDir(t)
+	defer cleanup()
+	commandQueue := queue.NewPersistentQueue(ctx.Log(), dir, queue.Limits{Commands: 1})
+	processor := EngineProcessor{
+		sendCommandPool: sendCommandPoolMock,
+		context:         ctx,
+		documentMgr:     docMock,
+		commandQueue:    commandQueue,
+		queuedDocs:      make(map[string]contracts.DocumentState),
+	}
+	newDocState := func(id string) contracts.DocumentState {
+		docState := contracts.DocumentState{DocumentType: contracts.SendCommand}
+		docState.DocumentInformation.DocumentID = "documentID" + id
+		docState.DocumentInformation.MessageID = "messageID" + id
+		return docState
+	}
+	go processor.Submit(newDocState("1"))
+	<-waiting
+
+	submitted := make(chan struct{})
+	go func() {
+		processor.Submit(newDocState("2"))
+		close(submitted)
+	}()
+	select {
+	case <-submitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Submit waited for the busy pool")
+	}
+	close(release)
+	assert.Equal(t, 1, commandQueue.Len())
+}
+
+func TestJobIDOf(t *testing.T) {
+	command := contracts.DocumentState{DocumentType: contracts.SendCommand}
+	command.DocumentInformation.MessageID = "messageID"
+	command.DocumentInformation.DocumentID = "documentID"
+	assert.Equal(t, "messageID", jobIDOf(&command))
+
+	// runs of the same association get different ids
+	association := contracts.DocumentState{DocumentType: contracts.Association}
+	association.DocumentInformation.AssociationID = "associationID"
+	association.DocumentInformation.DocumentID = "associationID.run1"
+	assert.Equal(t, "associationID.run1", jobIDOf(&association))
+}
+
 func TestEngineProcessor_Cancel(t *testing.T) {
 	cancelCommandPoolMock := new(task.MockedPool)
 	ctx := context.NewMockDefault()
@@ -62,14 +165,19 @@ func TestEngineProcessor_Cancel(t *testing.T) {
 	}
 	cancelCommandPoolMock.On("Submit", ctx.Log(), "cancelMessageID", mock.Anything).Return(nil)
 	docMock := new(DocumentMgrMock)
+	commandQueue, cleanup := newTestQueue(t, ctx)
+	defer cleanup()
 
 	processor := EngineProcessor{
 		executerCreator:   creator,
 		cancelCommandPool: cancelCommandPoolMock,
 		context:           ctx,
 		documentMgr:       docMock,
+		commandQueue:      commandQueue,
+		queuedDocs:        make(map[string]contracts.DocumentState),
 	}
 	docState := contracts.DocumentState{}
+	docState.DocumentInformation.DocumentID = "cancelDocumentID"
 	docState.DocumentInformation.MessageID = "cancelMessageID"
 	docMock.On("PersistDocumentState", mock.Anything, mock.Anything, mock.Anything, appconfig.DefaultLocationOfPending, docState)
 	processor.Cancel(docState)
@@ -94,6 +202,68 @@ func TestEngineProcessor_Stop(t *testing.T) {
 	cancelCommandPoolMock.AssertExpectations(t)
 }
 
+func TestEngineProcessor_StopWaitsForOngoingSubmit(t *testing.T) {
+	sendCommandPoolMock := new(task.MockedPool)
+	cancelCommandPoolMock := new(task.MockedPool)
+	ctx := context.NewMockDefault()
+	waiting, release := make(chan struct{}), make(chan struct{})
+	submitting := true
+	// the document waits for a worker finishing its job
+	sendCommandPoolMock.On("Submit", ctx.Log(), "messageID1", mock.Anything).Return(nil).Run(func(mock.Arguments) {
+		close(waiting)
+		<-release
+		submitting = false
+	})
+	sendCommandPoolMock.On("ShutdownAndWait", mock.AnythingOfType("time.Duration")).Return(true).Run(func(mock.Arguments) {
+		assert.False(t, submitting, "the pool is shut down during a submit")
+	})
+	cancelCommandPoolMock.On("ShutdownAndWait", mock.AnythingOfType("time.Duration")).Return(true)
+	docMock := new(DocumentMgrMock)
+	docMock.On("PersistDocumentState", mock.Anything, mock.Anything, mock.Anything, appconfig.DefaultLocationOfPending, mock.Anything)
+	commandQueue, cleanup := newTestQueue(t, ctx)
+	defer cleanup()
+	processor := EngineProcessor{
+		sendCommandPool:   sendCommandPoolMock,
+		cancelCommandPool: cancelCommandPoolMock,
+		context:           ctx,
+		resChan:           make(chan contracts.DocumentResult),
+		documentMgr:       docMock,
+		commandQueue:      commandQueue,
+		queuedDocs:        make(map[string]contracts.DocumentState),
+	}
+	newDocState := func(id string) contracts.DocumentState {
+		docState := contracts.DocumentState{DocumentType: contracts.SendCommand}
+		docState.DocumentInformation.DocumentID = "documentID" + id
+		docState.DocumentInformation.MessageID = "messageID" + id
+		return docState
+	}
+	submitted := make(chan struct{})
+	go func() {
+		processor.Submit(newDocState("1"))
+		close(submitted)
+	}()
+	<-waiting
+
+	stopped := make(chan struct{})
+	go func() {
+		processor.Stop(contracts.StopTypeSoftStop)
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("Stop did not wait for the ongoing submit")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	<-stopped
+	<-submitted
+	sendCommandPoolMock.AssertExpectations(t)
+
+	// nothing is dispatched once the processor is stopped
+	processor.Submit(newDocState("2"))
+	sendCommandPoolMock.AssertNotCalled(t, "Submit", ctx.Log(), "messageID2", mock.Anything)
+}
+
 //TODO add shutdown and reboot test once we encapsulate docmanager
 func TestProcessCommand(t *testing.T) {
 	ctx := context.NewMockDefault()
@@ -132,15 +302,71 @@ func TestProcessCommand(t *testing.T) {
 	docMock := new(DocumentMgrMock)
 	docMock.On("MoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
 	docMock.On("RemoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfCurrent)
+	recordedHistory = nil
 	processCommand(ctx, creator, cancelFlag, resChan, &docState, docMock)
 	executerMock.AssertExpectations(t)
 	docMock.AssertExpectations(t)
+	//the completed document is recorded in the execution history
+	assert.Len(t, recordedHistory, 1)
+	assert.Equal(t, "documentID", recordedHistory[0].DocumentID)
+	assert.Equal(t, contracts.ResultStatusSuccess, recordedHistory[0].Status)
 	close(resChan)
 	//assert channel is not closed, each instance of Processor keeps a distinct copy of channel
 	assert.NotNil(t, resChan)
 
 }
 
+func TestSkipCommand(t *testing.T) {
+	ctx := context.NewMockDefault()
+	docState := contracts.DocumentState{
+		DocumentType: contracts.Association,
+		InstancePluginsInformation: []contracts.PluginState{
+			{Id: "plugin1", Name: "aws:runShellScript"},
+		},
+	}
+	docState.DocumentInformation.AssociationID = "associationID"
+	docState.DocumentInformation.InstanceID = "instanceID"
+	docState.DocumentInformation.DocumentID = "documentID"
+	resChan := make(chan contracts.DocumentResult, 1)
+	docMock := new(DocumentMgrMock)
+	docMock.On("RemoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfPending)
+	recordedHistory = nil
+
+	skipCommand(ctx, resChan, &docState, docMock, "blackout 1 is in effect")
+
+	res := <-resChan
+	assert.Equal(t, contracts.ResultStatusSkipped, res.Status)
+	assert.Equal(t, "", res.LastPlugin)
+	assert.Equal(t, "associationID", res.AssociationID)
+	assert.Equal(t, "blackout 1 is in effect", res.SkippedReason)
+	assert.Equal(t, contracts.ResultStatusSkipped, res.PluginResults["plugin1"].Status)
+	docMock.AssertExpectations(t)
+	assert.Len(t, recordedHistory, 1)
+}
+
+func TestEngineProcessor_MaintenanceDecision(t *testing.T) {
+	ctx := context.NewMockDefault()
+	processor := EngineProcessor{
+		context: ctx,
+		maintenance: maintenancewindow.NewPolicy(ctx.Log(), appconfig.MaintenanceCfg{
+			Blackouts: []appconfig.TimeWindowCfg{{
+				Start: time.Now().Add(-time.Hour).Format(time.RFC3339),
+				End:   time.Now().Add(time.Hour).Format(time.RFC3339),
+			}},
+		}),
+	}
+
+	association := contracts.DocumentState{DocumentType: contracts.Association}
+	assert.False(t, processor.maintenanceDecision(&association).Allowed)
+
+	// a resumed association completes
+	association.DocumentInformation.RunCount = 1
+	assert.True(t, processor.maintenanceDecision(&association).Allowed)
+
+	command := contracts.DocumentState{DocumentType: contracts.SendCommand}
+	assert.True(t, processor.maintenanceDecision(&command).Allowed)
+}
+
 //TODO add shutdown and reboot test once we encapsulate docmanager
 func TestProcessCommand_Shutdown(t *testing.T) {
 	ctx := context.NewMockDefault()
@@ -165,9 +391,12 @@ func TestProcessCommand_Shutdown(t *testing.T) {
 	}()
 	docMock := new(DocumentMgrMock)
 	docMock.On("MoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
+	recordedHistory = nil
 	processCommand(ctx, creator, cancelFlag, resChan, &docState, docMock)
 	executerMock.AssertExpectations(t)
 	docMock.AssertExpectations(t)
+	//a document interrupted by a shutdown is not over yet
+	assert.Empty(t, recordedHistory)
 	close(resChan)
 	//assert channel is not closed, each instance of Processor keeps a distinct copy of channel
 	assert.NotNil(t, resChan)
@@ -184,13 +413,48 @@ func TestProcessCancelCommand_Success(t *testing.T) {
 	docMock := new(DocumentMgrMock)
 	docMock.On("MoveDocumentState", mock.Anything, "", "", appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
 	docMock.On("RemoveDocumentState", mock.Anything, "", "", appconfig.DefaultLocationOfCurrent, mock.Anything)
-	processCancelCommand(ctx, sendCommandPoolMock, &docState, docMock)
+	commandQueue, cleanup := newTestQueue(t, ctx)
+	defer cleanup()
+	processCancelCommand(ctx, sendCommandPoolMock, commandQueue, &docState, docMock)
 	sendCommandPoolMock.AssertExpectations(t)
 	docMock.AssertExpectations(t)
 	assert.Equal(t, docState.DocumentInformation.DocumentStatus, contracts.ResultStatusSuccess)
 
 }
 
+func TestProcessCancelCommand_Queued(t *testing.T) {
+	ctx := context.NewMockDefault()
+	sendCommandPoolMock := new(task.MockedPool)
+	commandQueue, cleanup := newTestQueue(t, ctx)
+	defer cleanup()
+	commandQueue.Push(ctx.Log(), queue.Item{DocumentID: "documentID", JobID: "messageID", Class: queue.ClassInteractive})
+	docState := contracts.DocumentState{}
+	docState.CancelInformation.CancelMessageID = "messageID"
+	docMock := new(DocumentMgrMock)
+	docMock.On("MoveDocumentState", mock.Anything, "", "", appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
+	docMock.On("RemoveDocumentState", mock.Anything, "", "", appconfig.DefaultLocationOfCurrent, mock.Anything)
+	processCancelCommand(ctx, sendCommandPoolMock, commandQueue, &docState, docMock)
+	// the command never reached the pool
+	sendCommandPoolMock.AssertNotCalled(t, "Cancel", mock.Anything)
+	docMock.AssertExpectations(t)
+	assert.Equal(t, docState.DocumentInformation.DocumentStatus, contracts.ResultStatusSuccess)
+	item, ok := commandQueue.Pop(ctx.Log())
+	assert.True(t, ok)
+	assert.True(t, item.Canceled)
+}
+
+// testQueueDir returns a new queue directory and the function removing it
+func testQueueDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "queue")
+	assert.NoError(t, err)
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func newTestQueue(t *testing.T, ctx context.T) (queue.Queue, func()) {
+	dir, cleanup := testQueueDir(t)
+	return queue.NewPersistentQueue(ctx.Log(), dir, queue.Limits{}), cleanup
+}
+
 type DocumentMgrMock struct {
 	mock.Mock
 }
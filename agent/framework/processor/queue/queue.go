@@ -0,0 +1,410 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package queue implements a durable, priority aware queue of documents waiting to be executed by the processor
+package queue
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// Class is the priority class of a queued document, lower values are dispatched first
+type Class int
+
+const (
+	// ClassCancel is the priority class of cancel requests
+	ClassCancel Class = iota
+	// ClassInteractive is the priority class of interactive runs such as send command and sessions
+	ClassInteractive
+	// ClassAssociation is the priority class of association runs
+	ClassAssociation
+)
+
+// classes lists all the priority classes in dispatch order
+var classes = []Class{ClassCancel, ClassInteractive, ClassAssociation}
+
+// classWeights defines how many documents of a class can be dispatched in a row while
+// a lower priority class is waiting, so that lower classes are never starved.
+// Cancel requests are not weighted, they always go first.
+var classWeights = map[Class]int{
+	ClassInteractive: 3,
+	ClassAssociation: 1,
+}
+
+// tempFileSuffix is the suffix of the files written before being renamed into place
+const tempFileSuffix = ".tmp"
+
+// String returns the name of the priority class
+func (c Class) String() string {
+	switch c {
+	case ClassCancel:
+		return "cancel"
+	case ClassInteractive:
+		return "interactive"
+	case ClassAssociation:
+		return "association"
+	}
+	return fmt.Sprintf("class(%d)", int(c))
+}
+
+// ClassOf returns the priority class for the given document type
+func ClassOf(documentType contracts.DocumentType) Class {
+	switch documentType {
+	case contracts.CancelCommand, contracts.CancelCommandOffline, contracts.TerminateSession:
+		return ClassCancel
+	case contracts.Association:
+		return ClassAssociation
+	}
+	return ClassInteractive
+}
+
+// Item is a document waiting in the queue, it is persisted as is in the queue directory
+type Item struct {
+	// DocumentID is the name of the document state file, it identifies the item in the queue
+	DocumentID string
+	// JobID is the id the document is submitted with to the worker pool
+	JobID        string
+	DocumentType contracts.DocumentType
+	Class        Class
+	Sequence     uint64
+	EnqueuedAt   time.Time
+	// Canceled is set when the document is canceled before being dispatched
+	Canceled bool
+}
+
+// Limits holds the maximum number of documents that can be in flight at the same time
+type Limits struct {
+	// Classes caps the documents in flight per class, classes without an entry are not capped
+	Classes map[Class]int
+	// Commands caps the interactive and association documents in flight together since they share
+	// the command workers, zero means no cap
+	Commands int
+}
+
+// Queue is a durable queue of documents, items are dispatched by class priority with
+// per class concurrency limits.
+type Queue interface {
+	// Push adds a document to the queue and persists it. Pushing a document that was recovered
+	// from disk keeps its original position in the queue.
+	Push(log log.T, item Item) (Item, error)
+	// Pop returns the next document to run and marks it in flight, false is returned when
+	// no document can be dispatched with the current limits.
+	Pop(log log.T) (Item, bool)
+	// Done removes an in flight document from the queue and releases its slot.
+	Done(log log.T, documentID string)
+	// Cancel marks a queued document with the given job id as canceled and moves it ahead of its class.
+	// Returns false if no queued document matches the job id.
+	Cancel(log log.T, jobID string) bool
+	// Recovered returns the documents persisted by a previous run that were not pushed again, ordered by sequence.
+	Recovered() []Item
+	// Prune deletes the recovered documents of the given types that were not pushed again.
+	Prune(log log.T, documentTypes []contracts.DocumentType)
+	// Len returns the number of documents waiting to be dispatched.
+	Len() int
+}
+
+// PersistentQueue implements Queue, every item is persisted in its own file under the queue directory
+type PersistentQueue struct {
+	dir       string
+	limits    Limits
+	mut       sync.Mutex
+	nextSeq   uint64
+	waiting   map[Class][]Item
+	inFlight  map[Class]int
+	running   map[string]Class
+	credits   map[Class]int
+	recovered map[string]Item
+}
+
+// NewPersistentQueue creates a queue persisted under dir, items left in dir by a previous run are loaded
+// and can be retrieved through Recovered.
+func NewPersistentQueue(log log.T, dir string, limits Limits) *PersistentQueue {
+	q := &PersistentQueue{
+		dir:       dir,
+		limits:    limits,
+		nextSeq:   1,
+		waiting:   make(map[Class][]Item),
+		inFlight:  make(map[Class]int),
+		running:   make(map[string]Class),
+		credits:   make(map[Class]int),
+		recovered: make(map[string]Item),
+	}
+	q.resetCredits()
+	q.load(log)
+	return q
+}
+
+// load reads the items persisted in the queue directory
+func (q *PersistentQueue) load(log log.T) {
+	files, err := fileutil.GetFileNames(q.dir)
+	if err != nil {
+		log.Debugf("failed to read queue directory %v: %v", q.dir, err)
+		return
+	}
+	for _, name := range files {
+		path := filepath.Join(q.dir, name)
+		if filepath.Ext(name) == tempFileSuffix {
+			// interrupted write, the previous version of the item (if any) is still in place
+			fileutil.DeleteFile(path)
+			continue
+		}
+		var item Item
+		if err := jsonutil.UnmarshalFile(path, &item); err != nil || item.DocumentID == "" {
+			log.Warnf("discarding unreadable queue item %v: %v", path, err)
+			fileutil.DeleteFile(path)
+			continue
+		}
+		q.recovered[item.DocumentID] = item
+		if item.Sequence >= q.nextSeq {
+			q.nextSeq = item.Sequence + 1
+		}
+	}
+	if len(q.recovered) > 0 {
+		log.Infof("recovered %v queued documents from %v", len(q.recovered), q.dir)
+	}
+}
+
+// Push adds a document to the queue and persists it.
+func (q *PersistentQueue) Push(log log.T, item Item) (Item, error) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	if q.contains(item.DocumentID) {
+		return item, fmt.Errorf("document %v is already queued", item.DocumentID)
+	}
+	if previous, found := q.recovered[item.DocumentID]; found {
+		item.Sequence = previous.Sequence
+		item.EnqueuedAt = previous.EnqueuedAt
+		item.Canceled = item.Canceled || previous.Canceled
+		delete(q.recovered, item.DocumentID)
+	} else {
+		item.Sequence = q.nextSeq
+		q.nextSeq++
+	}
+	if item.EnqueuedAt.IsZero() {
+		item.EnqueuedAt = time.Now().UTC()
+	}
+	if err := q.persist(item); err != nil {
+		return item, err
+	}
+	q.insert(item)
+	log.Debugf("queued document %v in %v class at position %v", item.DocumentID, item.Class, item.Sequence)
+	return item, nil
+}
+
+// Pop returns the next document to run and marks it in flight.
+func (q *PersistentQueue) Pop(log log.T) (Item, bool) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	class, found := q.nextClass()
+	if !found {
+		return Item{}, false
+	}
+	item := q.waiting[class][0]
+	q.waiting[class] = q.waiting[class][1:]
+	q.inFlight[class]++
+	q.running[item.DocumentID] = class
+	if q.credits[class] > 0 {
+		q.credits[class]--
+	}
+	log.Debugf("dispatching document %v from %v class, %v in flight", item.DocumentID, class, q.inFlight[class])
+	return item, true
+}
+
+// Done removes an in flight document from the queue and releases its slot.
+func (q *PersistentQueue) Done(log log.T, documentID string) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	class, found := q.running[documentID]
+	if !found {
+		log.Debugf("document %v is not in flight", documentID)
+		return
+	}
+	delete(q.running, documentID)
+	q.inFlight[class]--
+	if err := fileutil.DeleteFile(q.itemPath(documentID)); err != nil {
+		log.Errorf("failed to delete queue item of document %v: %v", documentID, err)
+	}
+}
+
+// Cancel marks a queued document with the given job id as canceled.
+func (q *PersistentQueue) Cancel(log log.T, jobID string) bool {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	for _, class := range classes {
+		for i, item := range q.waiting[class] {
+			if item.JobID != jobID {
+				continue
+			}
+			item.Canceled = true
+			if err := q.persist(item); err != nil {
+				log.Errorf("failed to persist cancellation of document %v: %v", item.DocumentID, err)
+			}
+			// canceled documents don't run anything, move them ahead so the cancellation is reported right away
+			q.waiting[class] = append(q.waiting[class][:i], q.waiting[class][i+1:]...)
+			q.waiting[class] = append([]Item{item}, q.waiting[class]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Recovered returns the documents persisted by a previous run that were not pushed again.
+func (q *PersistentQueue) Recovered() []Item {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	items := make([]Item, 0, len(q.recovered))
+	for _, item := range q.recovered {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Sequence < items[j].Sequence
+	})
+	return items
+}
+
+// Prune deletes the recovered documents of the given types that were not pushed again.
+func (q *PersistentQueue) Prune(log log.T, documentTypes []contracts.DocumentType) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	for id, item := range q.recovered {
+		for _, documentType := range documentTypes {
+			if item.DocumentType == documentType {
+				log.Debugf("removing orphaned queue item of document %v", id)
+				fileutil.DeleteFile(q.itemPath(id))
+				delete(q.recovered, id)
+				break
+			}
+		}
+	}
+}
+
+// Len returns the number of documents waiting to be dispatched.
+func (q *PersistentQueue) Len() int {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	count := 0
+	for _, items := range q.waiting {
+		count += len(items)
+	}
+	return count
+}
+
+// nextClass picks the class of the next document to dispatch. Cancel requests always go first,
+// the other classes are served in priority order until they run out of credits, credits are
+// refilled once no waiting class has any left.
+func (q *PersistentQueue) nextClass() (Class, bool) {
+	if q.canDispatch(ClassCancel) {
+		return ClassCancel, true
+	}
+	for refilled := false; ; refilled = true {
+		eligible := false
+		for class := ClassInteractive; class <= ClassAssociation; class++ {
+			if !q.canDispatch(class) {
+				continue
+			}
+			eligible = true
+			if q.credits[class] > 0 {
+				return class, true
+			}
+		}
+		if !eligible || refilled {
+			return 0, false
+		}
+		q.resetCredits()
+	}
+}
+
+// canDispatch returns true if the class has waiting documents and a free slot
+func (q *PersistentQueue) canDispatch(class Class) bool {
+	if len(q.waiting[class]) == 0 {
+		return false
+	}
+	if class != ClassCancel && q.limits.Commands > 0 &&
+		q.inFlight[ClassInteractive]+q.inFlight[ClassAssociation] >= q.limits.Commands {
+		return false
+	}
+	limit, found := q.limits.Classes[class]
+	return !found || q.inFlight[class] < limit
+}
+
+func (q *PersistentQueue) resetCredits() {
+	for class, weight := range classWeights {
+		q.credits[class] = weight
+	}
+}
+
+// insert adds the item to its class keeping the class ordered by sequence
+func (q *PersistentQueue) insert(item Item) {
+	items := q.waiting[item.Class]
+	i := sort.Search(len(items), func(i int) bool {
+		return items[i].Sequence > item.Sequence
+	})
+	items = append(items, Item{})
+	copy(items[i+1:], items[i:])
+	items[i] = item
+	q.waiting[item.Class] = items
+}
+
+func (q *PersistentQueue) contains(documentID string) bool {
+	if _, found := q.running[documentID]; found {
+		return true
+	}
+	for _, items := range q.waiting {
+		for _, item := range items {
+			if item.DocumentID == documentID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// persist writes the item to a temporary file and renames it into place so that
+// a crash never leaves a partially written item behind
+func (q *PersistentQueue) persist(item Item) error {
+	if err := fileutil.MakeDirs(q.dir); err != nil {
+		return fmt.Errorf("failed to create queue directory %v: %v", q.dir, err)
+	}
+	content, err := jsonutil.Marshal(item)
+	if err != nil {
+		return err
+	}
+	path := q.itemPath(item.DocumentID)
+	tempPath := path + tempFileSuffix
+	if _, err = fileutil.WriteIntoFileWithPermissions(tempPath, content, os.FileMode(int(appconfig.ReadWriteAccess))); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+func (q *PersistentQueue) itemPath(documentID string) string {
+	return filepath.Join(q.dir, documentID)
+}
@@ -0,0 +1,223 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package queue
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger = log.NewMockLog()
+
+func newItem(id string, documentType contracts.DocumentType) Item {
+	return Item{
+		DocumentID:   id,
+		JobID:        "job-" + id,
+		DocumentType: documentType,
+		Class:        ClassOf(documentType),
+	}
+}
+
+func popAll(q Queue) (ids []string) {
+	for {
+		item, ok := q.Pop(logger)
+		if !ok {
+			return
+		}
+		ids = append(ids, item.DocumentID)
+	}
+}
+
+func TestClassOf(t *testing.T) {
+	assert.Equal(t, ClassCancel, ClassOf(contracts.CancelCommand))
+	assert.Equal(t, ClassCancel, ClassOf(contracts.CancelCommandOffline))
+	assert.Equal(t, ClassInteractive, ClassOf(contracts.SendCommand))
+	assert.Equal(t, ClassInteractive, ClassOf(contracts.SendCommandOffline))
+	assert.Equal(t, ClassAssociation, ClassOf(contracts.Association))
+}
+
+func TestPopOrdersByPriority(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "queue")
+	defer os.RemoveAll(dir)
+	q := NewPersistentQueue(logger, dir, Limits{})
+
+	q.Push(logger, newItem("assoc", contracts.Association))
+	q.Push(logger, newItem("command", contracts.SendCommand))
+	q.Push(logger, newItem("cancel", contracts.CancelCommand))
+
+	assert.Equal(t, []string{"cancel", "command", "assoc"}, popAll(q))
+}
+
+func TestPopDoesNotStarveAssociations(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "queue")
+	defer os.RemoveAll(dir)
+	q := NewPersistentQueue(logger, dir, Limits{})
+
+	q.Push(logger, newItem("assoc1", contracts.Association))
+	q.Push(logger, newItem("assoc2", contracts.Association))
+	for _, id := range []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7"} {
+		q.Push(logger, newItem(id, contracts.SendCommand))
+	}
+
+	// interactive commands get three slots for every association slot
+	assert.Equal(t, []string{"c1", "c2", "c3", "assoc1", "c4", "c5", "c6", "assoc2", "c7"}, popAll(q))
+}
+
+func TestPopRespectsLimits(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "queue")
+	defer os.RemoveAll(dir)
+	q := NewPersistentQueue(logger, dir, Limits{
+		Classes:  map[Class]int{ClassCancel: 1, ClassAssociation: 1},
+		Commands: 2,
+	})
+
+	for _, id := range []string{"a1", "a2"} {
+		q.Push(logger, newItem(id, contracts.Association))
+	}
+	for _, id := range []string{"c1", "c2"} {
+		q.Push(logger, newItem(id, contracts.SendCommand))
+	}
+	for _, id := range []string{"x1", "x2"} {
+		q.Push(logger, newItem(id, contracts.CancelCommand))
+	}
+
+	// one cancel, then the two command slots
+	assert.Equal(t, []string{"x1", "c1", "c2"}, popAll(q))
+
+	// freeing a command slot lets the association through, the association limit holds the second one back
+	q.Done(logger, "c1")
+	assert.Equal(t, []string{"a1"}, popAll(q))
+	q.Done(logger, "c2")
+	assert.Empty(t, popAll(q))
+
+	q.Done(logger, "x1")
+	q.Done(logger, "a1")
+	assert.Equal(t, []string{"x2", "a2"}, popAll(q))
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestCancelMovesItemAhead(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "queue")
+	defer os.RemoveAll(dir)
+	q := NewPersistentQueue(logger, dir, Limits{})
+
+	q.Push(logger, newItem("c1", contracts.SendCommand))
+	q.Push(logger, newItem("c2", contracts.SendCommand))
+
+	assert.True(t, q.Cancel(logger, "job-c2"))
+	assert.False(t, q.Cancel(logger, "job-unknown"))
+
+	item, ok := q.Pop(logger)
+	assert.True(t, ok)
+	assert.Equal(t, "c2", item.DocumentID)
+	assert.True(t, item.Canceled)
+}
+
+func TestPushRejectsDuplicates(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "queue")
+	defer os.RemoveAll(dir)
+	q := NewPersistentQueue(logger, dir, Limits{})
+
+	_, err := q.Push(logger, newItem("c1", contracts.SendCommand))
+	assert.NoError(t, err)
+	_, err = q.Push(logger, newItem("c1", contracts.SendCommand))
+	assert.Error(t, err)
+
+	// still a duplicate while running
+	q.Pop(logger)
+	_, err = q.Push(logger, newItem("c1", contracts.SendCommand))
+	assert.Error(t, err)
+}
+
+func TestRecoveryAfterCrash(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "queue")
+	defer os.RemoveAll(dir)
+	q := NewPersistentQueue(logger, dir, Limits{Commands: 1})
+
+	q.Push(logger, newItem("c1", contracts.SendCommand))
+	q.Push(logger, newItem("c2", contracts.SendCommand))
+	q.Push(logger, newItem("c3", contracts.SendCommand))
+	q.Push(logger, newItem("gone", contracts.SendCommand))
+	q.Cancel(logger, "job-c3")
+
+	// c3 was canceled so it runs first and completes, c1 is still in flight when the agent crashes
+	assert.Equal(t, []string{"c3"}, popAll(q))
+	q.Done(logger, "c3")
+	assert.Equal(t, []string{"c1"}, popAll(q))
+
+	// simulate a crash: the queue is dropped without completing c1
+	q = NewPersistentQueue(logger, dir, Limits{})
+	recovered := q.Recovered()
+	ids := []string{}
+	for _, item := range recovered {
+		ids = append(ids, item.DocumentID)
+	}
+	assert.Equal(t, []string{"c1", "c2", "gone"}, ids)
+
+	// the processor pushes the documents it still has state for, in any order,
+	// the state of the last one was lost so its entry gets pruned
+	q.Push(logger, newItem("c2", contracts.SendCommand))
+	q.Push(logger, newItem("c1", contracts.SendCommand))
+	q.Prune(logger, []contracts.DocumentType{contracts.SendCommand})
+
+	assert.Empty(t, q.Recovered())
+	assert.False(t, fileExists(filepath.Join(dir, "gone")))
+	// original order is kept and new items queue up behind the recovered ones
+	q.Push(logger, newItem("c4", contracts.SendCommand))
+	assert.Equal(t, []string{"c1", "c2", "c4"}, popAll(q))
+}
+
+func TestRecoveryKeepsCancellation(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "queue")
+	defer os.RemoveAll(dir)
+	q := NewPersistentQueue(logger, dir, Limits{})
+
+	q.Push(logger, newItem("c1", contracts.SendCommand))
+	q.Cancel(logger, "job-c1")
+
+	q = NewPersistentQueue(logger, dir, Limits{})
+	q.Push(logger, newItem("c1", contracts.SendCommand))
+	item, ok := q.Pop(logger)
+	assert.True(t, ok)
+	assert.True(t, item.Canceled)
+}
+
+func TestRecoveryDiscardsPartialWrites(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "queue")
+	defer os.RemoveAll(dir)
+	q := NewPersistentQueue(logger, dir, Limits{})
+	q.Push(logger, newItem("c1", contracts.SendCommand))
+
+	// simulate crashes in the middle of writes
+	ioutil.WriteFile(filepath.Join(dir, "c2"+tempFileSuffix), []byte(`{"DocumentID":`), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "c3"), []byte(`{"DocumentID":`), 0600)
+
+	q = NewPersistentQueue(logger, dir, Limits{})
+	recovered := q.Recovered()
+	assert.Len(t, recovered, 1)
+	assert.Equal(t, "c1", recovered[0].DocumentID)
+	assert.False(t, fileExists(filepath.Join(dir, "c2"+tempFileSuffix)))
+	assert.False(t, fileExists(filepath.Join(dir, "c3")))
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
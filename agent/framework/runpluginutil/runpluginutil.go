@@ -57,6 +57,7 @@ var SSMPluginRegistry PluginRegistry
 var allPlugins = map[string]struct{}{
 	appconfig.PluginNameAwsAgentUpdate:         {},
 	appconfig.PluginNameAwsApplications:        {},
+	appconfig.PluginNameAwsCheckDesiredState:   {},
 	appconfig.PluginNameAwsConfigureDaemon:     {},
 	appconfig.PluginNameAwsConfigurePackage:    {},
 	appconfig.PluginNameAwsPowerShellModule:    {},
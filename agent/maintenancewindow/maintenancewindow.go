@@ -0,0 +1,222 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package maintenancewindow decides when associations are allowed to run from the maintenance windows
+// and the blackout periods of the agent configuration.
+package maintenancewindow
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/gorhill/cronexpr"
+)
+
+const (
+	// searchHorizon is how far the next allowed time is searched for
+	searchHorizon = 366 * 24 * time.Hour
+	// maxSearchSteps bounds the search when windows and blackouts alternate often
+	maxSearchSteps = 10000
+
+	cronPrefix = "cron("
+	cronSuffix = ")"
+)
+
+// Decision tells whether a document is allowed to run at a given time
+type Decision struct {
+	Allowed bool
+	// NextAllowed is the first time the document is allowed to run, zero when there is none within a year
+	NextAllowed time.Time
+	// Reason explains why the document is not allowed to run
+	Reason string
+}
+
+// Policy holds the maintenance windows and the blackouts
+type Policy struct {
+	windows   []*window
+	blackouts []*window
+	exempt    map[string]bool
+}
+
+// window is a recurring or one-off period of time
+type window struct {
+	name       string
+	expression *cronexpr.Expression
+	duration   time.Duration
+	location   *time.Location
+	start      time.Time
+	end        time.Time
+}
+
+// NewPolicy creates the policy of the configuration, invalid windows are logged and ignored
+func NewPolicy(log log.T, config appconfig.MaintenanceCfg) *Policy {
+	policy := &Policy{exempt: make(map[string]bool)}
+	for i, cfg := range config.Windows {
+		if w, err := newWindow(cfg, fmt.Sprintf("maintenance window %v", i+1)); err != nil {
+			log.Errorf("Ignoring invalid maintenance window %v, %v", i+1, err)
+		} else {
+			policy.windows = append(policy.windows, w)
+		}
+	}
+	for i, cfg := range config.Blackouts {
+		if w, err := newWindow(cfg, fmt.Sprintf("blackout %v", i+1)); err != nil {
+			log.Errorf("Ignoring invalid blackout %v, %v", i+1, err)
+		} else {
+			policy.blackouts = append(policy.blackouts, w)
+		}
+	}
+	for _, name := range config.ExemptDocuments {
+		policy.exempt[name] = true
+	}
+	return policy
+}
+
+// newWindow parses a window configuration
+func newWindow(cfg appconfig.TimeWindowCfg, defaultName string) (w *window, err error) {
+	w = &window{name: cfg.Name, location: time.UTC}
+	if w.name == "" {
+		w.name = defaultName
+	}
+
+	if cfg.TimeZone != "" {
+		if w.location, err = time.LoadLocation(cfg.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone %v, %v", cfg.TimeZone, err)
+		}
+	}
+
+	switch {
+	case cfg.Cron != "" && (cfg.Start != "" || cfg.End != ""):
+		return nil, fmt.Errorf("either Cron or Start and End must be set")
+	case cfg.Cron != "":
+		expression := strings.TrimSpace(cfg.Cron)
+		if !strings.HasPrefix(strings.ToLower(expression), cronPrefix) || !strings.HasSuffix(expression, cronSuffix) {
+			return nil, fmt.Errorf("cron expression %v must have the format cron(...)", cfg.Cron)
+		}
+		if w.expression, err = cronexpr.Parse(expression[len(cronPrefix) : len(expression)-len(cronSuffix)]); err != nil {
+			return nil, fmt.Errorf("invalid cron expression %v, %v", cfg.Cron, err)
+		}
+		if cfg.DurationMinutes <= 0 {
+			return nil, fmt.Errorf("DurationMinutes must be positive")
+		}
+		w.duration = time.Duration(cfg.DurationMinutes) * time.Minute
+	case cfg.Start != "" && cfg.End != "":
+		if w.start, err = time.Parse(time.RFC3339, cfg.Start); err != nil {
+			return nil, fmt.Errorf("invalid start %v, %v", cfg.Start, err)
+		}
+		if w.end, err = time.Parse(time.RFC3339, cfg.End); err != nil {
+			return nil, fmt.Errorf("invalid end %v, %v", cfg.End, err)
+		}
+		if !w.end.After(w.start) {
+			return nil, fmt.Errorf("end %v must be after start %v", cfg.End, cfg.Start)
+		}
+	default:
+		return nil, fmt.Errorf("either Cron or Start and End must be set")
+	}
+	return w, nil
+}
+
+// IsEnabled returns true if the policy restricts when documents run
+func (p *Policy) IsEnabled() bool {
+	return p != nil && (len(p.windows) > 0 || len(p.blackouts) > 0)
+}
+
+// Check decides whether the document is allowed to run at the given time, and when it is allowed next otherwise
+func (p *Policy) Check(documentName string, t time.Time) Decision {
+	if !p.IsEnabled() || p.exempt[documentName] {
+		return Decision{Allowed: true, NextAllowed: t}
+	}
+
+	reason := ""
+	candidate := t
+	for step := 0; step < maxSearchSteps && candidate.Before(t.Add(searchHorizon)); step++ {
+		if end, name, blocked := p.blackoutAt(candidate); blocked {
+			if reason == "" {
+				reason = fmt.Sprintf("%v is in effect until %v", name, end.UTC().Format(time.RFC3339))
+			}
+			candidate = end
+			continue
+		}
+
+		if len(p.windows) > 0 && !p.inWindow(candidate) {
+			if reason == "" {
+				reason = "outside of the maintenance windows"
+			}
+			if candidate = p.nextWindowStart(candidate); candidate.IsZero() {
+				break
+			}
+			continue
+		}
+
+		return Decision{Allowed: candidate.Equal(t), NextAllowed: candidate, Reason: reason}
+	}
+
+	return Decision{Allowed: false, Reason: reason}
+}
+
+// blackoutAt returns the end of the blackout in effect at the given time
+func (p *Policy) blackoutAt(t time.Time) (end time.Time, name string, blocked bool) {
+	for _, w := range p.blackouts {
+		if occurrenceEnd, ok := w.contains(t); ok && occurrenceEnd.After(end) {
+			end, name, blocked = occurrenceEnd, w.name, true
+		}
+	}
+	return
+}
+
+// inWindow returns true if the given time is in a maintenance window
+func (p *Policy) inWindow(t time.Time) bool {
+	for _, w := range p.windows {
+		if _, ok := w.contains(t); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// nextWindowStart returns the earliest start of a maintenance window after the given time
+func (p *Policy) nextWindowStart(t time.Time) (next time.Time) {
+	for _, w := range p.windows {
+		if start := w.nextStart(t); !start.IsZero() && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return next
+}
+
+// contains returns the end of the occurrence of the window the given time is in
+func (w *window) contains(t time.Time) (end time.Time, ok bool) {
+	if w.expression == nil {
+		return w.end, !t.Before(w.start) && t.Before(w.end)
+	}
+
+	// the occurrence containing t is the first one starting after t - duration
+	start := w.expression.Next(t.Add(-w.duration).In(w.location))
+	if start.IsZero() || start.After(t) {
+		return time.Time{}, false
+	}
+	return start.Add(w.duration), true
+}
+
+// nextStart returns the start of the first occurrence of the window after the given time, zero when there is none
+func (w *window) nextStart(t time.Time) time.Time {
+	if w.expression == nil {
+		if w.start.After(t) {
+			return w.start
+		}
+		return time.Time{}
+	}
+	return w.expression.Next(t.In(w.location))
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package maintenancewindow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func date(value string) time.Time {
+	t, _ := time.Parse(time.RFC3339, value)
+	return t
+}
+
+func TestCheckWithoutWindows(t *testing.T) {
+	policy := NewPolicy(log.NewMockLog(), appconfig.MaintenanceCfg{})
+	now := date("2026-10-19T12:00:00Z")
+
+	decision := policy.Check("AWS-RunPatchBaseline", now)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, now, decision.NextAllowed)
+	assert.False(t, policy.IsEnabled())
+	assert.True(t, (*Policy)(nil).Check("AWS-RunPatchBaseline", now).Allowed)
+}
+
+func TestCheckMaintenanceWindowInTimeZone(t *testing.T) {
+	policy := NewPolicy(log.NewMockLog(), appconfig.MaintenanceCfg{
+		Windows: []appconfig.TimeWindowCfg{{
+			Name:            "nightly",
+			Cron:            "cron(0 22 ? * MON-FRI *)",
+			DurationMinutes: 240,
+			TimeZone:        "America/New_York",
+		}},
+		ExemptDocuments: []string{"AWS-GatherSoftwareInventory"},
+	})
+
+	// Monday 12:00 in New York is outside of the window which opens at 22:00 New York time
+	decision := policy.Check("AWS-RunPatchBaseline", date("2026-10-19T16:00:00Z"))
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, date("2026-10-20T02:00:00Z"), decision.NextAllowed.UTC())
+	assert.Equal(t, "outside of the maintenance windows", decision.Reason)
+
+	// Tuesday 01:00 in New York is in the window opened on Monday
+	decision = policy.Check("AWS-RunPatchBaseline", date("2026-10-20T05:00:00Z"))
+	assert.True(t, decision.Allowed)
+
+	// Saturday 01:00 in New York is in the window opened on Friday, the next one opens on Monday
+	decision = policy.Check("AWS-RunPatchBaseline", date("2026-10-24T06:00:00Z"))
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, date("2026-10-27T02:00:00Z"), decision.NextAllowed.UTC())
+
+	// exempt documents run at any time
+	assert.True(t, policy.Check("AWS-GatherSoftwareInventory", date("2026-10-19T16:00:00Z")).Allowed)
+}
+
+func TestCheckBlackoutTakesPrecedence(t *testing.T) {
+	policy := NewPolicy(log.NewMockLog(), appconfig.MaintenanceCfg{
+		Windows: []appconfig.TimeWindowCfg{{
+			Cron:            "cron(0 22 * * ? *)",
+			DurationMinutes: 120,
+		}},
+		Blackouts: []appconfig.TimeWindowCfg{{
+			Name:  "year end freeze",
+			Start: "2026-12-20T00:00:00Z",
+			End:   "2027-01-04T00:00:00Z",
+		}},
+	})
+
+	decision := policy.Check("AWS-RunPatchBaseline", date("2026-12-24T22:30:00Z"))
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, "year end freeze is in effect until 2027-01-04T00:00:00Z", decision.Reason)
+	assert.Equal(t, date("2027-01-04T22:00:00Z"), decision.NextAllowed.UTC())
+
+	assert.True(t, policy.Check("AWS-RunPatchBaseline", date("2026-12-19T23:00:00Z")).Allowed)
+}
+
+func TestCheckRecurringBlackout(t *testing.T) {
+	policy := NewPolicy(log.NewMockLog(), appconfig.MaintenanceCfg{
+		Blackouts: []appconfig.TimeWindowCfg{{
+			Name:            "business hours",
+			Cron:            "cron(0 9 ? * MON-FRI *)",
+			DurationMinutes: 8 * 60,
+			TimeZone:        "Europe/Paris",
+		}},
+	})
+
+	decision := policy.Check("AWS-RunPatchBaseline", date("2026-10-19T10:00:00Z"))
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, date("2026-10-19T15:00:00Z"), decision.NextAllowed.UTC())
+
+	assert.True(t, policy.Check("AWS-RunPatchBaseline", date("2026-10-18T10:00:00Z")).Allowed, "weekends are not blacked out")
+}
+
+func TestCheckWithoutAllowedTime(t *testing.T) {
+	policy := NewPolicy(log.NewMockLog(), appconfig.MaintenanceCfg{
+		Windows: []appconfig.TimeWindowCfg{{
+			Start: "2026-01-01T00:00:00Z",
+			End:   "2026-01-02T00:00:00Z",
+		}},
+	})
+
+	decision := policy.Check("AWS-RunPatchBaseline", date("2026-10-19T10:00:00Z"))
+	assert.False(t, decision.Allowed)
+	assert.True(t, decision.NextAllowed.IsZero())
+}
+
+func TestNewPolicyIgnoresInvalidWindows(t *testing.T) {
+	policy := NewPolicy(log.NewMockLog(), appconfig.MaintenanceCfg{
+		Windows: []appconfig.TimeWindowCfg{
+			{Cron: "0 22 * * ? *", DurationMinutes: 60},
+			{Cron: "cron(0 22 * * ? *)"},
+			{Cron: "cron(0 22 * * ? *)", DurationMinutes: 60, TimeZone: "Mars/Olympus_Mons"},
+			{Start: "2026-01-02T00:00:00Z", End: "2026-01-01T00:00:00Z"},
+			{Start: "2026-01-01T00:00:00Z"},
+		},
+	})
+
+	assert.False(t, policy.IsEnabled())
+}
@@ -0,0 +1,72 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package desiredstate implements the aws:checkDesiredState plugin, which evaluates the desired state checks
+// declared by a document. Associations running the plugin keep evaluating the checks periodically
+// and report the drifted checks as compliance items.
+package desiredstate
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/compliance/drift"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// Plugin is the type for the aws:checkDesiredState plugin.
+type Plugin struct {
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin() (*Plugin, error) {
+	var plugin Plugin
+	return &plugin, nil
+}
+
+// Name returns the name of the plugin
+func Name() string {
+	return appconfig.PluginNameAwsCheckDesiredState
+}
+
+// Execute evaluates the checks once and reports the drifted ones in the output.
+// Drift doesn't fail the plugin, it is reported through the configuration drift compliance.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", Name(), config)
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+		return
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+		return
+	}
+
+	input, err := drift.ParseInput(config.Properties)
+	if err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+
+	drifted := 0
+	for _, result := range drift.Evaluate(log, input.Checks) {
+		if result.Drifted {
+			drifted++
+			output.AppendErrorf("Check %v drifted, %v", result.Check.ID, result.Message)
+		}
+	}
+	output.AppendInfof("%v of %v checks drifted from the desired state", drifted, len(input.Checks))
+	output.SetStatus(contracts.ResultStatusSuccess)
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package desiredstate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCancelFlag() *task.MockCancelFlag {
+	cancelFlag := task.NewMockDefault()
+	cancelFlag.On("ShutDown").Return(false)
+	cancelFlag.On("Canceled").Return(false)
+	return cancelFlag
+}
+
+func TestExecuteReportsDriftedChecks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "desiredstate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	plugin, _ := NewPlugin()
+	output := iohandler.NewDefaultIOHandler(log.NewMockLog(), contracts.IOConfiguration{})
+	config := contracts.Configuration{
+		Properties: map[string]interface{}{
+			"checks": []interface{}{
+				map[string]interface{}{"id": "motd", "type": "fileHash", "target": filepath.Join(dir, "motd"), "expected": "abc"},
+			},
+		},
+	}
+
+	plugin.Execute(context.NewMockDefault(), config, newCancelFlag(), output)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Contains(t, output.GetStdout(), "1 of 1 checks drifted from the desired state")
+	assert.Contains(t, output.GetStderr(), "Check motd drifted")
+}
+
+func TestExecuteFailsForInvalidChecks(t *testing.T) {
+	plugin, _ := NewPlugin()
+	output := iohandler.NewDefaultIOHandler(log.NewMockLog(), contracts.IOConfiguration{})
+	config := contracts.Configuration{
+		Properties: map[string]interface{}{
+			"checks": []interface{}{map[string]interface{}{"id": "motd", "type": "unknown", "target": "/etc/motd"}},
+		},
+	}
+
+	plugin.Execute(context.NewMockDefault(), config, newCancelFlag(), output)
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+}
@@ -19,12 +19,15 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/crypto/signature"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/gitrepositoryresource"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/gitresource"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/gitresource/privategithub"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/httpresource"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/remoteresource"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/s3resource"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/ssmdocresource"
@@ -43,9 +46,13 @@ const (
 	GitHub      = "GitHub"      //Github represents the source type "GitHub" from where the resource can be downloaded
 	S3          = "S3"          //S3 represents the source type "S3" from where the resource is being downloaded
 	SSMDocument = "SSMDocument" //SSMDocument represents the source type as SSM Document
+	HTTP        = "HTTP"        //HTTP represents the source type "HTTP" from where a file is downloaded with http or https
+	Git         = "Git"         //Git represents the source type "Git" from where a repository is cloned with https or ssh
 
 	downloadsDir = "downloads" //Directory under the orchestration directory where the downloaded resource resides
 
+	signatureFileExtension = ".sig" //Extension of the detached signature files downloaded along with the files they sign
+
 	FailExitCode = 1
 	PassExitCode = 0
 )
@@ -63,6 +70,7 @@ func NewPlugin() (*Plugin, error) {
 type Plugin struct {
 	remoteResourceCreator func(log log.T, sourceType string, SourceInfo string) (remoteresource.RemoteResource, error)
 	filesys               filemanager.FileSystem
+	signaturePolicy       signature.Policy
 }
 
 // ExecutePluginInput is a struct that holds the parameters sent through send command
@@ -71,6 +79,8 @@ type DownloadContentPlugin struct {
 	SourceType      string `json:"sourceType"`
 	SourceInfo      string `json:"sourceInfo"`
 	DestinationPath string `json:"destinationPath"`
+	// Signature is the detached signature of the downloaded file, it's only used when a single file is downloaded
+	Signature string `json:"signature"`
 	// TODO: 08/25/2017 meloniam@ Change the type of SourceInfo and documentParameters to map[string]interface{}
 	// TODO: https://amazon.awsapps.com/workdocs/index.html#/document/7d56a42ea5b040a7c33548d77dc98040f0fb380bbbfb2fd580c861225e2ee1c7
 }
@@ -87,6 +97,10 @@ func newRemoteResource(log log.T, SourceType string, SourceInfo string) (resourc
 		return s3resource.NewS3Resource(log, SourceInfo)
 	case SSMDocument:
 		return ssmdocresource.NewSSMDocResource(SourceInfo)
+	case HTTP:
+		return httpresource.NewHTTPResource(SourceInfo)
+	case Git:
+		return gitrepositoryresource.NewGitRepositoryResource(SourceInfo)
 	default:
 		return nil, fmt.Errorf("Invalid SourceType - %v", SourceType)
 	}
@@ -109,7 +123,10 @@ func (p *Plugin) execute(context context.T, config contracts.Configuration, canc
 		output.MarkAsCancelled()
 	} else if input, err := parseAndValidateInput(config.Properties); err != nil {
 		output.MarkAsFailed(err)
+	} else if policy, err := signature.NewPolicy(context.AppConfig().ScriptSigning); err != nil {
+		output.MarkAsFailed(fmt.Errorf("failed to load the script signing policy: %v", err))
 	} else {
+		p.signaturePolicy = policy
 		p.runCopyContent(log, input, config, output)
 	}
 }
@@ -154,6 +171,12 @@ func (p *Plugin) runCopyContent(log log.T, input *DownloadContentPlugin, config
 		return
 	}
 
+	if err := p.verifySignatures(log, input.Signature, result); err != nil {
+		p.removeDownloadedFiles(log, result)
+		output.MarkAsFailed(fmt.Errorf("Signature verification of the downloaded content failed - %v", err))
+		return
+	}
+
 	if err := setPermissions(log, result); err != nil {
 		output.MarkAsFailed(fmt.Errorf("Failed to set right permissions to the content. Error - %v", err))
 		return
@@ -164,6 +187,55 @@ func (p *Plugin) runCopyContent(log log.T, input *DownloadContentPlugin, config
 	return
 }
 
+// verifySignatures checks the downloaded files when the script signing policy requires it. A single file can be verified
+// with the signature of the input, otherwise every file needs a detached signature file downloaded along with it.
+func (p *Plugin) verifySignatures(log log.T, encodedSignature string, result *remoteresource.DownloadResult) error {
+	if !p.signaturePolicy.Required {
+		return nil
+	}
+	downloaded := make(map[string]bool, len(result.Files))
+	for _, path := range result.Files {
+		downloaded[path] = true
+	}
+	// a signature file is only skipped when it is the signature of another downloaded file, otherwise it is content
+	files := []string{}
+	for _, path := range result.Files {
+		if !strings.HasSuffix(path, signatureFileExtension) || !downloaded[strings.TrimSuffix(path, signatureFileExtension)] {
+			files = append(files, path)
+		}
+	}
+	for _, path := range files {
+		log.Debugf("Verifying the signature of %v", path)
+		fileSignature := encodedSignature
+		if len(files) > 1 || fileSignature == "" {
+			if !p.filesys.Exists(path + signatureFileExtension) {
+				return fmt.Errorf("%v - %v", path, signature.ErrMissingSignature)
+			}
+			var err error
+			if fileSignature, err = p.filesys.ReadFile(path + signatureFileExtension); err != nil {
+				return fmt.Errorf("failed to read the signature of %v - %v", path, err)
+			}
+		}
+		content, err := p.filesys.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %v - %v", path, err)
+		}
+		if err = p.signaturePolicy.Check([]byte(content), fileSignature); err != nil {
+			return fmt.Errorf("%v - %v", path, err)
+		}
+	}
+	return nil
+}
+
+// removeDownloadedFiles removes the files that failed verification so that later steps can't run them
+func (p *Plugin) removeDownloadedFiles(log log.T, result *remoteresource.DownloadResult) {
+	for _, path := range result.Files {
+		if err := p.filesys.DeleteFile(path); err != nil {
+			log.Warnf("Failed to remove unverified file %v - %v", path, err)
+		}
+	}
+}
+
 func setPermissions(log log.T, result *remoteresource.DownloadResult) error {
 	for _, path := range result.Files {
 		log.Infof("Setting permission for file %v", path)
@@ -205,7 +277,7 @@ func validateInput(input *DownloadContentPlugin) (valid bool, err error) {
 		return false, errors.New("SourceType must be specified")
 	}
 	//ensure all entries are valid
-	if input.SourceType != GitHub && input.SourceType != S3 && input.SourceType != SSMDocument {
+	if input.SourceType != GitHub && input.SourceType != S3 && input.SourceType != SSMDocument && input.SourceType != HTTP && input.SourceType != Git {
 		return false, errors.New("Unsupported source type")
 	}
 	// ensure non-empty source info
@@ -16,14 +16,18 @@
 package downloadcontent
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"testing"
 
 	"time"
 
 	"errors"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/crypto/signature"
 	filemock "github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager/mock"
 	iohandlermocks "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/mock"
 	"github.com/aws/amazon-ssm-agent/agent/log"
@@ -32,6 +36,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/task"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/ed25519"
 )
 
 var logger = log.NewMockLog()
@@ -88,6 +93,33 @@ func TestNewRemoteResource_SSMDocument(t *testing.T) {
 
 }
 
+func TestNewRemoteResource_HTTP(t *testing.T) {
+
+	locationInfo := `{
+		"url" : "https://artifactory.example.com/repo/tool.tar.gz",
+		"authMethod" : "Bearer",
+		"token" : "{{ssm-secure:artifactory-token}}"
+		}`
+	remoteresource, err := newRemoteResource(logger, "HTTP", locationInfo)
+
+	assert.NotNil(t, remoteresource)
+	assert.NoError(t, err)
+
+}
+
+func TestNewRemoteResource_Git(t *testing.T) {
+
+	locationInfo := `{
+		"repository" : "git@gitlab.example.com:group/project.git",
+		"ref" : "v1.0"
+		}`
+	remoteresource, err := newRemoteResource(logger, "Git", locationInfo)
+
+	assert.NotNil(t, remoteresource)
+	assert.NoError(t, err)
+
+}
+
 func TestNewPlugin_RunCopyContent(t *testing.T) {
 
 	fileMock := filemock.FileSystemMock{}
@@ -475,3 +507,44 @@ func createMockCancelFlag() task.CancelFlag {
 func stubChmod(log log.T, workingDir string) error {
 	return nil
 }
+
+func TestVerifySignatures(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	policy, _ := signature.NewPolicy(appconfig.ScriptSigningCfg{
+		RequireSignature:   true,
+		TrustedEd25519Keys: []string{base64.StdEncoding.EncodeToString(public)},
+	})
+	sign := func(content string) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(private, []byte(content)))
+	}
+
+	fileMock := filemock.FileSystemMock{}
+	fileMock.On("ReadFile", "dir/a.sh").Return("echo a", nil)
+	fileMock.On("ReadFile", "dir/b.sh").Return("echo b", nil)
+	fileMock.On("Exists", "dir/a.sh.sig").Return(true)
+	fileMock.On("ReadFile", "dir/a.sh.sig").Return(sign("echo a"), nil)
+	fileMock.On("Exists", "dir/b.sh.sig").Return(false)
+	fileMock.On("ReadFile", "dir/x.sig").Return("echo x", nil)
+	fileMock.On("Exists", "dir/x.sig.sig").Return(false)
+	p := Plugin{filesys: fileMock, signaturePolicy: policy}
+
+	// a single file is verified with the signature of the input
+	assert.NoError(t, p.verifySignatures(logger, sign("echo b"), &remoteresource.DownloadResult{Files: []string{"dir/b.sh"}}))
+	assert.Error(t, p.verifySignatures(logger, sign("echo a"), &remoteresource.DownloadResult{Files: []string{"dir/b.sh"}}))
+
+	// otherwise every file needs its signature file
+	assert.NoError(t, p.verifySignatures(logger, "", &remoteresource.DownloadResult{Files: []string{"dir/a.sh", "dir/a.sh.sig"}}))
+	err := p.verifySignatures(logger, "", &remoteresource.DownloadResult{Files: []string{"dir/a.sh", "dir/a.sh.sig", "dir/b.sh"}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "dir/b.sh")
+
+	// a signature file without the file it signs is content which is verified too
+	assert.NoError(t, p.verifySignatures(logger, sign("echo x"), &remoteresource.DownloadResult{Files: []string{"dir/x.sig"}}))
+	err = p.verifySignatures(logger, "", &remoteresource.DownloadResult{Files: []string{"dir/a.sh", "dir/a.sh.sig", "dir/x.sig"}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "dir/x.sig")
+
+	// nothing is checked unless the policy requires it
+	p.signaturePolicy = signature.Policy{}
+	assert.NoError(t, p.verifySignatures(logger, "", &remoteresource.DownloadResult{Files: []string{"dir/b.sh"}}))
+}
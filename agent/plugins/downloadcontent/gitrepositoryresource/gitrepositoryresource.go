@@ -0,0 +1,338 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package gitrepositoryresource implements the methods to access resources from any git repository
+package gitrepositoryresource
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/remoteresource"
+
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	gitBinary = "git"
+	gitDir    = ".git"
+
+	cloneDirPrefix     = ".clone-"     //Prefix of the temporary directory the repository is cloned to
+	sshKeyFileName     = "id_key"      //Name of the private key file given to ssh
+	knownHostsFileName = "known_hosts" //Name of the known hosts file given to ssh
+	askPassFileName    = "askpass.sh"  //Name of the script answering the credential prompts of git
+
+	usernameEnv = "SSM_GIT_USERNAME" //Environment variable passing the username to the askpass script
+	passwordEnv = "SSM_GIT_PASSWORD" //Environment variable passing the password to the askpass script
+)
+
+// askPassScript answers the username and password prompts of git with the environment variables, so the credentials
+// are neither arguments nor stored in a file. GIT_ASKPASS is supported by every git version, unlike GIT_CONFIG_COUNT.
+const askPassScript = `#!/bin/sh
+case "$1" in
+Username*) printf '%s\n' "$` + usernameEnv + `" ;;
+*) printf '%s\n' "$` + passwordEnv + `" ;;
+esac
+`
+
+// scpLikeRepository matches the scp-like syntax of ssh repositories, e.g. git@gitlab.example.com:group/project.git
+var scpLikeRepository = regexp.MustCompile(`^[\w.-]+@[\w.-]+:[^/\\]`)
+
+// GitRepositoryResource is a struct for the remote resource of type Git
+type GitRepositoryResource struct {
+	Info GitRepositoryInfo
+}
+
+// GitRepositoryInfo represents the sourceInfo type sent by runcommand.
+// Username and Password are used for https repositories and PrivateSSHKey for ssh repositories,
+// they can reference Parameter Store parameters, e.g. {{ssm-secure:parameter-name}}.
+// KnownHosts holds the known_hosts lines of the ssh host keys, without it the host must be known to the agent user.
+type GitRepositoryInfo struct {
+	Repository    string `json:"repository"`
+	Ref           string `json:"ref"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	PrivateSSHKey string `json:"privateSSHKey"`
+	KnownHosts    string `json:"knownHosts"`
+}
+
+// NewGitRepositoryResource is a constructor of type GitRepositoryResource
+func NewGitRepositoryResource(info string) (*GitRepositoryResource, error) {
+	gitInfo, err := parseSourceInfo(info)
+	if err != nil {
+		return nil, fmt.Errorf("Git SourceInfo parsing failed. %v", err)
+	}
+
+	return &GitRepositoryResource{
+		Info: gitInfo,
+	}, nil
+}
+
+// parseSourceInfo unmarshals the information in sourceInfo of type GitRepositoryInfo and returns it
+func parseSourceInfo(sourceInfo string) (gitInfo GitRepositoryInfo, err error) {
+	if err = jsonutil.Unmarshal(sourceInfo, &gitInfo); err != nil {
+		return gitInfo, errors.New("SourceInfo could not be unmarshalled for SourceType Git. Please check JSON format of SourceInfo")
+	}
+
+	gitInfo.Repository = strings.TrimSpace(gitInfo.Repository)
+	gitInfo.Ref = strings.TrimSpace(gitInfo.Ref)
+	return gitInfo, nil
+}
+
+// DownloadRemoteResource clones the repository at the ref and places its files in the destination directory
+func (g *GitRepositoryResource) DownloadRemoteResource(log log.T, filesys filemanager.FileSystem, destinationDir string) (err error, result *remoteresource.DownloadResult) {
+	if destinationDir == "" {
+		destinationDir = appconfig.DownloadRoot
+	}
+	if err = filesys.MakeDirs(destinationDir); err != nil {
+		return fmt.Errorf("failed to create directory %v - %v", destinationDir, err), nil
+	}
+
+	// clone next to the destination so the files can be moved in place once checked out
+	var cloneDir string
+	if cloneDir, err = ioutil.TempDir(destinationDir, cloneDirPrefix); err != nil {
+		return fmt.Errorf("failed to create the clone directory - %v", err), nil
+	}
+	defer filesys.DeleteDirectory(cloneDir)
+
+	var env []string
+	var cleanup func()
+	if env, cleanup, err = g.credentialsEnv(log); err != nil {
+		return err, nil
+	}
+	defer cleanup()
+
+	log.Infof("Cloning git repository %v at ref %v", g.Info.Repository, g.Info.Ref)
+	if err = g.clone(log, env, cloneDir); err != nil {
+		return err, nil
+	}
+
+	// the repository metadata is not part of the downloaded content
+	if err = filesys.DeleteDirectory(filepath.Join(cloneDir, gitDir)); err != nil {
+		return fmt.Errorf("failed to remove the repository metadata - %v", err), nil
+	}
+
+	result = &remoteresource.DownloadResult{}
+	if err = moveContent(filesys, cloneDir, destinationDir, result); err != nil {
+		return err, nil
+	}
+	return nil, result
+}
+
+// clone clones the repository to the directory and checks out the ref, the default branch when no ref is given
+func (g *GitRepositoryResource) clone(log log.T, env []string, cloneDir string) error {
+	args := []string{"clone", "--quiet"}
+	if g.Info.Ref != "" {
+		args = append(args, "--no-checkout")
+	}
+	if _, err := runGit(log, env, "", append(args, "--", g.Info.Repository, cloneDir)...); err != nil {
+		return fmt.Errorf("failed to clone git repository %v - %v", g.Info.Repository, err)
+	}
+	if g.Info.Ref == "" {
+		return nil
+	}
+
+	// branches are only known as remote branches after the clone, tags and commits are known as is
+	var commit string
+	var err error
+	for _, ref := range []string{"origin/" + g.Info.Ref, g.Info.Ref} {
+		if commit, err = runGit(log, env, cloneDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("ref %v not found in git repository %v", g.Info.Ref, g.Info.Repository)
+	}
+
+	if _, err = runGit(log, env, cloneDir, "checkout", "--quiet", "--detach", commit); err != nil {
+		return fmt.Errorf("failed to checkout ref %v of git repository %v - %v", g.Info.Ref, g.Info.Repository, err)
+	}
+	return nil
+}
+
+// credentialsEnv returns the environment passing the credentials to git, and a function removing the files it needs.
+// The credentials are not passed as arguments, so they don't show in the process list.
+func (g *GitRepositoryResource) credentialsEnv(log log.T) (env []string, cleanup func(), err error) {
+	// never prompt for credentials, the agent has no terminal
+	env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cleanup = func() {}
+	if g.Info.Username == "" && g.Info.Password == "" && g.Info.PrivateSSHKey == "" && g.Info.KnownHosts == "" {
+		return env, cleanup, nil
+	}
+
+	var credentialsDir string
+	if credentialsDir, err = ioutil.TempDir("", "git"); err != nil {
+		return nil, cleanup, fmt.Errorf("failed to create the credentials directory - %v", err)
+	}
+	cleanup = func() { os.RemoveAll(credentialsDir) }
+	if env, err = g.appendCredentials(log, env, credentialsDir); err != nil {
+		cleanup()
+		return nil, func() {}, err
+	}
+	return env, cleanup, nil
+}
+
+// appendCredentials adds the credentials to the environment, the files given to git and ssh are written to the directory
+func (g *GitRepositoryResource) appendCredentials(log log.T, env []string, credentialsDir string) ([]string, error) {
+	// NOTE: Do not log the resolved credentials
+	if g.Info.Username != "" || g.Info.Password != "" {
+		username, err := resolveParameters(log, g.Info.Username)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the username - %v", err)
+		}
+		password, err := resolveParameters(log, g.Info.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the password - %v", err)
+		}
+		askPassFile := filepath.Join(credentialsDir, askPassFileName)
+		if err = ioutil.WriteFile(askPassFile, []byte(askPassScript), appconfig.ReadWriteExecuteAccess); err != nil {
+			return nil, fmt.Errorf("failed to write the askpass script - %v", err)
+		}
+		env = append(env,
+			"GIT_ASKPASS="+filepath.ToSlash(askPassFile),
+			usernameEnv+"="+username,
+			passwordEnv+"="+password)
+	}
+
+	if g.Info.PrivateSSHKey == "" && g.Info.KnownHosts == "" {
+		return env, nil
+	}
+	sshCommand := "ssh -o BatchMode=yes"
+	if g.Info.PrivateSSHKey != "" {
+		key, err := resolveParameters(log, g.Info.PrivateSSHKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the private ssh key - %v", err)
+		}
+		keyFile := filepath.Join(credentialsDir, sshKeyFileName)
+		// ssh refuses keys readable by other users, and expects the key to end with a new line
+		if err = ioutil.WriteFile(keyFile, []byte(strings.TrimSpace(key)+"\n"), appconfig.ReadWriteAccess); err != nil {
+			return nil, fmt.Errorf("failed to write the private ssh key - %v", err)
+		}
+		sshCommand += fmt.Sprintf(" -i '%v' -o IdentitiesOnly=yes", filepath.ToSlash(keyFile))
+	}
+	if g.Info.KnownHosts != "" {
+		knownHosts, err := resolveParameters(log, g.Info.KnownHosts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the known hosts - %v", err)
+		}
+		knownHostsFile := filepath.Join(credentialsDir, knownHostsFileName)
+		if err = ioutil.WriteFile(knownHostsFile, []byte(strings.TrimSpace(knownHosts)+"\n"), appconfig.ReadWriteAccess); err != nil {
+			return nil, fmt.Errorf("failed to write the known hosts - %v", err)
+		}
+		sshCommand += fmt.Sprintf(" -o UserKnownHostsFile='%v' -o StrictHostKeyChecking=yes", filepath.ToSlash(knownHostsFile))
+	}
+	return append(env, "GIT_SSH_COMMAND="+sshCommand), nil
+}
+
+// runGit runs git with the arguments in the directory and returns its trimmed output
+func runGit(log log.T, env []string, dir string, args ...string) (string, error) {
+	command := exec.Command(gitBinary, args...)
+	command.Dir = dir
+	command.Env = env
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		log.Debugf("git %v failed - %v", args[0], stderr.String())
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", errors.New(message)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// moveContent moves the files of the source directory to the destination directory, replacing the existing ones,
+// and adds the moved files to the result
+func moveContent(filesys filemanager.FileSystem, sourceDir string, destinationDir string, result *remoteresource.DownloadResult) error {
+	entries, err := ioutil.ReadDir(sourceDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		source := filepath.Join(sourceDir, entry.Name())
+		destination := filepath.Join(destinationDir, entry.Name())
+		if filesys.Exists(destination) {
+			if err = os.RemoveAll(destination); err != nil {
+				return fmt.Errorf("failed to replace %v - %v", destination, err)
+			}
+		}
+		if err = os.Rename(source, destination); err != nil {
+			return fmt.Errorf("failed to move %v to %v - %v", entry.Name(), destinationDir, err)
+		}
+
+		err = filepath.Walk(destination, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() {
+				result.Files = append(result.Files, path)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateLocationInfo ensures that the required parameters of SourceInfo are specified
+func (g *GitRepositoryResource) ValidateLocationInfo() (valid bool, err error) {
+	if g.Info.Repository == "" {
+		return false, errors.New("Repository in SourceInfo must be specified")
+	}
+	if strings.HasPrefix(g.Info.Ref, "-") {
+		return false, fmt.Errorf("Ref %v in SourceInfo is invalid", g.Info.Ref)
+	}
+
+	isSSH := scpLikeRepository.MatchString(g.Info.Repository)
+	if !isSSH {
+		repositoryURL, err := url.Parse(g.Info.Repository)
+		if err != nil {
+			return false, fmt.Errorf("Repository in SourceInfo is invalid - %v", err)
+		}
+		switch repositoryURL.Scheme {
+		case "https":
+		case "ssh":
+			isSSH = true
+		default:
+			return false, errors.New("Repository in SourceInfo must use https or ssh")
+		}
+		if _, hasPassword := repositoryURL.User.Password(); hasPassword {
+			return false, errors.New("Repository in SourceInfo must not contain credentials, use the authentication parameters instead")
+		}
+	}
+
+	if isSSH && (g.Info.Username != "" || g.Info.Password != "") {
+		return false, errors.New("Username and Password in SourceInfo are only supported for https repositories, use PrivateSSHKey for ssh repositories")
+	}
+	if !isSSH && g.Info.PrivateSSHKey != "" {
+		return false, errors.New("PrivateSSHKey in SourceInfo is only supported for ssh repositories")
+	}
+	if !isSSH && g.Info.KnownHosts != "" {
+		return false, errors.New("KnownHosts in SourceInfo is only supported for ssh repositories")
+	}
+	if !isSSH && (g.Info.Username == "") != (g.Info.Password == "") {
+		return false, errors.New("Username and Password in SourceInfo must be specified together")
+	}
+	return true, nil
+}
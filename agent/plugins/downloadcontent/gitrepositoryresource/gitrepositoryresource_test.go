@@ -0,0 +1,296 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package gitrepositoryresource
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var logMock = log.NewMockLog()
+
+// git runs git in the directory for the test setup
+func git(t *testing.T, dir string, args ...string) string {
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	command := exec.Command(gitBinary, args...)
+	command.Dir = dir
+	output, err := command.CombinedOutput()
+	assert.NoError(t, err, string(output))
+	return strings.TrimSpace(string(output))
+}
+
+// writeFile writes the file of the working copy
+func writeFile(t *testing.T, dir string, name string, content string) {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+}
+
+// createBareRepository creates a bare repository with a v1 tag, a newer main branch and a feature branch.
+// It returns the directory of the test and the url of the repository.
+func createBareRepository(t *testing.T) (string, string) {
+	if _, err := exec.LookPath(gitBinary); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "gitrepositoryresource")
+	assert.NoError(t, err)
+	bare := filepath.Join(dir, "repo.git")
+	work := filepath.Join(dir, "work")
+
+	git(t, dir, "init", "--quiet", "--bare", "--initial-branch=main", bare)
+	git(t, dir, "init", "--quiet", "--initial-branch=main", work)
+	writeFile(t, work, "version.txt", "v1")
+	writeFile(t, work, "scripts/install.sh", "echo install")
+	git(t, work, "add", ".")
+	git(t, work, "commit", "--quiet", "-m", "v1")
+	git(t, work, "tag", "v1")
+	writeFile(t, work, "version.txt", "v2")
+	git(t, work, "commit", "--quiet", "-am", "v2")
+	git(t, work, "checkout", "--quiet", "-b", "feature", "v1")
+	writeFile(t, work, "version.txt", "feature")
+	git(t, work, "commit", "--quiet", "-am", "feature")
+	git(t, work, "push", "--quiet", bare, "main", "feature", "--tags")
+
+	return dir, "file://" + filepath.ToSlash(bare)
+}
+
+// download downloads the repository at the ref and returns the destination directory
+func download(t *testing.T, dir string, repository string, ref string) (string, []string, error) {
+	destination := filepath.Join(dir, "download-"+ref)
+	resource := GitRepositoryResource{Info: GitRepositoryInfo{Repository: repository, Ref: ref}}
+	err, result := resource.DownloadRemoteResource(logMock, filemanager.FileSystemImpl{}, destination)
+	if result == nil {
+		return destination, nil, err
+	}
+	return destination, result.Files, err
+}
+
+func readFile(t *testing.T, path string) string {
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestDownloadRemoteResource_DefaultBranch(t *testing.T) {
+	dir, repository := createBareRepository(t)
+	defer os.RemoveAll(dir)
+
+	destination, files, err := download(t, dir, repository, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", readFile(t, filepath.Join(destination, "version.txt")))
+	sort.Strings(files)
+	assert.Equal(t, []string{
+		filepath.Join(destination, "scripts", "install.sh"),
+		filepath.Join(destination, "version.txt"),
+	}, files)
+	entries, _ := ioutil.ReadDir(destination)
+	assert.Len(t, entries, 2, "neither the repository metadata nor the clone directory are left")
+}
+
+func TestDownloadRemoteResource_Refs(t *testing.T) {
+	dir, repository := createBareRepository(t)
+	defer os.RemoveAll(dir)
+	v1Commit := git(t, filepath.Join(dir, "work"), "rev-parse", "v1")
+
+	for ref, version := range map[string]string{"v1": "v1", "main": "v2", "feature": "feature", v1Commit: "v1"} {
+		destination, _, err := download(t, dir, repository, ref)
+		assert.NoError(t, err, ref)
+		assert.Equal(t, version, readFile(t, filepath.Join(destination, "version.txt")), ref)
+	}
+}
+
+func TestDownloadRemoteResource_ReplacesExistingFiles(t *testing.T) {
+	dir, repository := createBareRepository(t)
+	defer os.RemoveAll(dir)
+	destination := filepath.Join(dir, "download-v1")
+	writeFile(t, destination, "version.txt", "old")
+	writeFile(t, destination, "other.txt", "kept")
+
+	_, _, err := download(t, dir, repository, "v1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", readFile(t, filepath.Join(destination, "version.txt")))
+	assert.Equal(t, "kept", readFile(t, filepath.Join(destination, "other.txt")))
+}
+
+func TestDownloadRemoteResource_Errors(t *testing.T) {
+	dir, repository := createBareRepository(t)
+	defer os.RemoveAll(dir)
+
+	_, files, err := download(t, dir, repository, "unknown")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "ref unknown not found")
+	assert.Nil(t, files)
+
+	destination, _, err := download(t, dir, repository+"-missing", "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to clone")
+	entries, _ := ioutil.ReadDir(destination)
+	assert.Empty(t, entries, "the clone directory is removed")
+}
+
+func TestCredentialsEnv(t *testing.T) {
+	defer func(original func(log.T, string) (string, error)) { resolveParameters = original }(resolveParameters)
+	resolveParameters = func(log log.T, text string) (string, error) {
+		return strings.Replace(text, "{{ssm-secure:secret}}", "s3cret", -1), nil
+	}
+
+	resource := GitRepositoryResource{Info: GitRepositoryInfo{Username: "user", Password: "{{ssm-secure:secret}}"}}
+	env, cleanup, err := resource.credentialsEnv(logMock)
+	assert.NoError(t, err)
+	assert.Contains(t, env, "GIT_TERMINAL_PROMPT=0")
+	assert.Contains(t, env, usernameEnv+"=user")
+	assert.Contains(t, env, passwordEnv+"=s3cret")
+	askPass := envValue(env, "GIT_ASKPASS")
+	assert.Equal(t, "user\n", runAskPass(t, env, askPass, "Username for 'https://gitlab.example.com': "))
+	assert.Equal(t, "s3cret\n", runAskPass(t, env, askPass, "Password for 'https://user@gitlab.example.com': "))
+	assert.NotContains(t, readFile(t, askPass), "s3cret", "the script doesn't hold the credentials")
+	cleanup()
+	_, err = os.Stat(askPass)
+	assert.True(t, os.IsNotExist(err), "the script is removed after the clone")
+
+	resource = GitRepositoryResource{Info: GitRepositoryInfo{PrivateSSHKey: "{{ssm-secure:secret}}", KnownHosts: "gitlab.example.com ssh-ed25519 AAAA"}}
+	env, cleanup, err = resource.credentialsEnv(logMock)
+	assert.NoError(t, err)
+	sshCommand := envValue(env, "GIT_SSH_COMMAND")
+	assert.True(t, strings.HasPrefix(sshCommand, "ssh -o BatchMode=yes -i '"), sshCommand)
+	keyFile := strings.Split(sshCommand, "'")[1]
+	assert.Equal(t, "s3cret\n", readFile(t, keyFile))
+	info, err := os.Stat(keyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.Contains(t, sshCommand, "-o StrictHostKeyChecking=yes")
+	knownHostsFile := strings.Split(sshCommand, "'")[3]
+	assert.Equal(t, "gitlab.example.com ssh-ed25519 AAAA\n", readFile(t, knownHostsFile))
+	cleanup()
+	_, err = os.Stat(keyFile)
+	assert.True(t, os.IsNotExist(err), "the key is removed after the clone")
+
+	resource = GitRepositoryResource{Info: GitRepositoryInfo{}}
+	env, cleanup, err = resource.credentialsEnv(logMock)
+	assert.NoError(t, err)
+	cleanup()
+	assert.Empty(t, envValue(env, "GIT_ASKPASS"))
+	assert.Empty(t, envValue(env, "GIT_SSH_COMMAND"))
+}
+
+func TestDownloadRemoteResource_HttpCredentials(t *testing.T) {
+	dir, _ := createBareRepository(t)
+	defer os.RemoveAll(dir)
+	backend, err := exec.Command(gitBinary, "--exec-path").Output()
+	if err != nil || !fileExists(filepath.Join(strings.TrimSpace(string(backend)), "git-http-backend")) {
+		t.Skip("git-http-backend is not installed")
+	}
+	handler := &cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(string(backend)), "git-http-backend"),
+		Env:  []string{"GIT_PROJECT_ROOT=" + dir, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "s3cret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	destination := filepath.Join(dir, "download-http")
+	resource := GitRepositoryResource{Info: GitRepositoryInfo{Repository: server.URL + "/repo.git", Ref: "v1", Username: "user", Password: "s3cret"}}
+	err, _ = resource.DownloadRemoteResource(logMock, filemanager.FileSystemImpl{}, destination)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", readFile(t, filepath.Join(destination, "version.txt")))
+
+	resource.Info.Password = "wrong"
+	err, _ = resource.DownloadRemoteResource(logMock, filemanager.FileSystemImpl{}, filepath.Join(dir, "download-denied"))
+	assert.Error(t, err)
+}
+
+// envValue returns the value of the variable in the environment, empty when it is not set
+func envValue(env []string, name string) string {
+	value := ""
+	for _, variable := range env {
+		if strings.HasPrefix(variable, name+"=") {
+			value = strings.TrimPrefix(variable, name+"=")
+		}
+	}
+	return value
+}
+
+// runAskPass runs the askpass script with the prompt of git and returns its output
+func runAskPass(t *testing.T, env []string, askPass string, prompt string) string {
+	command := exec.Command(askPass, prompt)
+	command.Env = env
+	output, err := command.Output()
+	assert.NoError(t, err)
+	return string(output)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestValidateLocationInfo(t *testing.T) {
+	validInfos := []string{
+		`{"repository": "https://gitlab.example.com/group/project.git"}`,
+		`{"repository": "https://gitlab.example.com/group/project.git", "ref": "v1", "username": "u", "password": "{{ssm-secure:p}}"}`,
+		`{"repository": "ssh://git@gitlab.example.com/group/project.git", "privateSSHKey": "{{ssm-secure:key}}"}`,
+		`{"repository": "git@gitlab.example.com:group/project.git", "knownHosts": "gitlab.example.com ssh-ed25519 AAAA"}`,
+		`{"repository": "git@gitlab.example.com:group/project.git", "ref": "main"}`,
+	}
+	for _, info := range validInfos {
+		resource, err := NewGitRepositoryResource(info)
+		assert.NoError(t, err)
+		valid, err := resource.ValidateLocationInfo()
+		assert.True(t, valid, info)
+		assert.NoError(t, err, info)
+	}
+
+	invalidInfos := map[string]string{
+		`{}`: "must be specified",
+		`{"repository": "http://gitlab.example.com/group/project.git"}`:                                      "must use https or ssh",
+		`{"repository": "file:///tmp/repo.git"}`:                                                             "must use https or ssh",
+		`{"repository": "https://u:p@gitlab.example.com/group/project.git"}`:                                 "must not contain credentials",
+		`{"repository": "https://gitlab.example.com/group/project.git", "ref": "--upload-pack=x"}`:           "Ref",
+		`{"repository": "https://gitlab.example.com/group/project.git", "username": "u"}`:                    "must be specified together",
+		`{"repository": "https://gitlab.example.com/group/project.git", "privateSSHKey": "k"}`:               "only supported for ssh",
+		`{"repository": "https://gitlab.example.com/group/project.git", "knownHosts": "k"}`:                  "only supported for ssh",
+		`{"repository": "git@gitlab.example.com:group/project.git", "username": "u", "password": "p"}`:       "only supported for https",
+		`{"repository": "ssh://git@gitlab.example.com/group/project.git", "username": "u", "password": "p"}`: "only supported for https",
+	}
+	for info, message := range invalidInfos {
+		resource, err := NewGitRepositoryResource(info)
+		assert.NoError(t, err)
+		valid, err := resource.ValidateLocationInfo()
+		assert.False(t, valid, info)
+		assert.Contains(t, err.Error(), message, info)
+	}
+
+	_, err := NewGitRepositoryResource(`{"repository": `)
+	assert.Error(t, err)
+}
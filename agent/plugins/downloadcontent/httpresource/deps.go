@@ -0,0 +1,68 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package httpresource
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/system"
+)
+
+// resolveParameters resolves the Parameter Store references of the credentials and headers
+var resolveParameters = system.ResolveParameterReferences
+
+const (
+	connectTimeout  = 30 * time.Second //Timeout of the connection and TLS handshake with the server
+	responseTimeout = time.Minute      //Timeout of the response headers once the request is sent
+	maxRedirects    = 10
+)
+
+// stallTimeout is the time without receiving any data after which a download is abandoned
+var stallTimeout = 2 * time.Minute
+
+// httpClient downloads the resources
+var httpClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: responseTimeout,
+	},
+	CheckRedirect: checkRedirect,
+}
+
+// checkRedirect refuses the redirects which would send the headers and credentials of the request to another host,
+// or over http when the request was sent over https
+func checkRedirect(request *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.New("stopped after 10 redirects")
+	}
+	original := via[0]
+	if len(original.Header) == 0 {
+		return nil
+	}
+	if request.URL.Host != original.URL.Host {
+		return errors.New("refusing to send the headers and credentials of the request to another host")
+	}
+	if original.URL.Scheme == "https" && request.URL.Scheme != "https" {
+		return errors.New("refusing to send the headers and credentials of the request over http")
+	}
+	return nil
+}
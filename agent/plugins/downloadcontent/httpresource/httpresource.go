@@ -0,0 +1,265 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package httpresource implements the methods to access resources from any http or https server
+package httpresource
+
+import (
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/remoteresource"
+
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	AuthMethodNone   = "None"   //AuthMethodNone sends no credentials
+	AuthMethodBasic  = "Basic"  //AuthMethodBasic sends the username and password with basic authentication
+	AuthMethodBearer = "Bearer" //AuthMethodBearer sends the token as a bearer token
+
+	defaultFileName = "download" //Name of the downloaded file when the URL has no file name
+)
+
+// HTTPResource is a struct for the remote resource of type HTTP
+type HTTPResource struct {
+	Info HTTPInfo
+}
+
+// HTTPInfo represents the sourceInfo type sent by runcommand.
+// Headers, Username, Password and Token can reference Parameter Store parameters, e.g. {{ssm-secure:parameter-name}}
+type HTTPInfo struct {
+	URL        string            `json:"url"`
+	Headers    map[string]string `json:"headers"`
+	AuthMethod string            `json:"authMethod"`
+	Username   string            `json:"username"`
+	Password   string            `json:"password"`
+	Token      string            `json:"token"`
+	// Checksums maps the hash algorithm, sha256 or md5, to the expected hash of the file
+	Checksums map[string]string `json:"checksums"`
+}
+
+// NewHTTPResource is a constructor of type HTTPResource
+func NewHTTPResource(info string) (*HTTPResource, error) {
+	httpInfo, err := parseSourceInfo(info)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP SourceInfo parsing failed. %v", err)
+	}
+
+	return &HTTPResource{
+		Info: httpInfo,
+	}, nil
+}
+
+// parseSourceInfo unmarshals the information in sourceInfo of type HTTPInfo and returns it
+func parseSourceInfo(sourceInfo string) (httpInfo HTTPInfo, err error) {
+	if err = jsonutil.Unmarshal(sourceInfo, &httpInfo); err != nil {
+		return httpInfo, errors.New("SourceInfo could not be unmarshalled for SourceType HTTP. Please check JSON format of SourceInfo")
+	}
+
+	httpInfo.URL = strings.TrimSpace(httpInfo.URL)
+	if httpInfo.AuthMethod == "" {
+		httpInfo.AuthMethod = AuthMethodNone
+	}
+	return httpInfo, nil
+}
+
+// DownloadRemoteResource downloads the file from the url, verifies its checksums and saves it to the destination
+func (h *HTTPResource) DownloadRemoteResource(log log.T, filesys filemanager.FileSystem, destinationPath string) (err error, result *remoteresource.DownloadResult) {
+	if destinationPath == "" {
+		destinationPath = appconfig.DownloadRoot
+	}
+
+	var request *http.Request
+	if request, err = h.newRequest(log); err != nil {
+		return err, nil
+	}
+
+	destinationFilePath := destinationPath
+	if filesys.Exists(destinationPath) && filesys.IsDirectory(destinationPath) || os.IsPathSeparator(destinationPath[len(destinationPath)-1]) {
+		destinationFilePath = filepath.Join(destinationPath, fileName(request.URL))
+	}
+	if err = filesys.MakeDirs(filepath.Dir(destinationFilePath)); err != nil {
+		return fmt.Errorf("failed to create directory for %v - %v", destinationFilePath, err), nil
+	}
+
+	log.Info("Downloading HTTP resource from ", request.URL.String())
+	// download next to the destination and only replace it once the checksums are verified
+	tmpFilePath := destinationFilePath + ".download"
+	defer filesys.DeleteFile(tmpFilePath)
+	if err = download(log, request, tmpFilePath); err != nil {
+		return err, nil
+	}
+
+	input := artifact.DownloadInput{SourceURL: request.URL.String(), SourceChecksums: h.Info.Checksums}
+	if matched, err := artifact.VerifyHash(log, input, artifact.DownloadOutput{LocalFilePath: tmpFilePath}); !matched {
+		return fmt.Errorf("checksum verification failed for %v - %v", request.URL.String(), err), nil
+	}
+
+	if err = os.Rename(tmpFilePath, destinationFilePath); err != nil {
+		return fmt.Errorf("failed to save the downloaded file to %v - %v", destinationFilePath, err), nil
+	}
+
+	return nil, &remoteresource.DownloadResult{Files: []string{destinationFilePath}}
+}
+
+// newRequest builds the download request with the headers and credentials resolved from Parameter Store
+func (h *HTTPResource) newRequest(log log.T) (request *http.Request, err error) {
+	if request, err = http.NewRequest(http.MethodGet, h.Info.URL, nil); err != nil {
+		return nil, err
+	}
+
+	for name, value := range h.Info.Headers {
+		// NOTE: Do not log the header values, they may be credentials
+		if value, err = resolveParameters(log, value); err != nil {
+			return nil, fmt.Errorf("failed to resolve the value of header %v - %v", name, err)
+		}
+		request.Header.Set(name, value)
+	}
+
+	switch h.Info.AuthMethod {
+	case AuthMethodBasic:
+		var username, password string
+		if username, err = resolveParameters(log, h.Info.Username); err != nil {
+			return nil, fmt.Errorf("failed to resolve the username - %v", err)
+		}
+		if password, err = resolveParameters(log, h.Info.Password); err != nil {
+			return nil, fmt.Errorf("failed to resolve the password - %v", err)
+		}
+		request.SetBasicAuth(username, password)
+	case AuthMethodBearer:
+		var token string
+		if token, err = resolveParameters(log, h.Info.Token); err != nil {
+			return nil, fmt.Errorf("failed to resolve the token - %v", err)
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	return request, nil
+}
+
+// download saves the response body of the request to the file
+func download(log log.T, request *http.Request, filePath string) error {
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to download %v - %v", request.URL.String(), err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		// drain the body so the connection can be reused
+		io.Copy(ioutil.Discard, response.Body)
+		return fmt.Errorf("http request failed. status:%v statuscode:%v", response.Status, response.StatusCode)
+	}
+
+	// a server which stops sending data would otherwise block the plugin forever
+	body := newStallTimeoutReader(response.Body, stallTimeout)
+	defer body.stop()
+	if _, err = artifact.FileCopy(log, filePath, body); err != nil {
+		if body.stalled() {
+			return fmt.Errorf("failed to download %v - no data received for %v", request.URL.String(), stallTimeout)
+		}
+		return fmt.Errorf("failed to write the downloaded file %v - %v", filePath, err)
+	}
+	return nil
+}
+
+// stallTimeoutReader closes the response body when no data was received for the timeout, which fails the pending read
+type stallTimeoutReader struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	fired   int32
+}
+
+// newStallTimeoutReader starts the timer of the body
+func newStallTimeoutReader(body io.ReadCloser, timeout time.Duration) *stallTimeoutReader {
+	r := &stallTimeoutReader{body: body, timeout: timeout}
+	r.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&r.fired, 1)
+		body.Close()
+	})
+	return r
+}
+
+func (r *stallTimeoutReader) Read(p []byte) (n int, err error) {
+	n, err = r.body.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+// stop stops the timer once the body is read
+func (r *stallTimeoutReader) stop() {
+	r.timer.Stop()
+}
+
+// stalled returns true when the body was closed because no data was received
+func (r *stallTimeoutReader) stalled() bool {
+	return atomic.LoadInt32(&r.fired) == 1
+}
+
+// fileName returns the name of the file from the url path
+func fileName(fileURL *url.URL) string {
+	name := path.Base(fileURL.Path)
+	if name == "." || name == "/" {
+		return defaultFileName
+	}
+	return name
+}
+
+// ValidateLocationInfo ensures that the required parameters of SourceInfo are specified
+func (h *HTTPResource) ValidateLocationInfo() (valid bool, err error) {
+	if h.Info.URL == "" {
+		return false, errors.New("URL in SourceInfo must be specified")
+	}
+
+	fileURL, err := url.Parse(h.Info.URL)
+	if err != nil {
+		return false, fmt.Errorf("URL in SourceInfo is invalid - %v", err)
+	}
+	if fileURL.Scheme != "http" && fileURL.Scheme != "https" {
+		return false, errors.New("URL in SourceInfo must use http or https")
+	}
+	if fileURL.User != nil {
+		return false, errors.New("URL in SourceInfo must not contain credentials, use the authentication parameters instead")
+	}
+
+	switch h.Info.AuthMethod {
+	case AuthMethodNone:
+	case AuthMethodBasic:
+		if h.Info.Username == "" || h.Info.Password == "" {
+			return false, errors.New("Username and Password in SourceInfo must be specified for Basic authentication")
+		}
+	case AuthMethodBearer:
+		if h.Info.Token == "" {
+			return false, errors.New("Token in SourceInfo must be specified for Bearer authentication")
+		}
+	default:
+		return false, fmt.Errorf("Unsupported AuthMethod %v, must be one of %v, %v or %v", h.Info.AuthMethod, AuthMethodNone, AuthMethodBasic, AuthMethodBearer)
+	}
+	return true, nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package system
+
+import (
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/ssmparameterresolver"
+)
+
+// ResolveParameterReferences replaces the Parameter Store references in the text, such as
+// {{ssm:parameter-name}} and {{ssm-secure:parameter-name}}, by the values of the parameters.
+// The resolved values must not be logged.
+func ResolveParameterReferences(log log.T, text string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	service := ssmparameterresolver.NewService()
+	return ssmparameterresolver.ResolveParametersInText(&service, log, text, ssmparameterresolver.ResolveOptions{IgnoreSecureParameters: false})
+}
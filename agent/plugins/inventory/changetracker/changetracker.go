@@ -0,0 +1,346 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package changetracker keeps the last snapshot of each inventory type and computes the entries that were
+// added, removed or modified between two collections.
+package changetracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+const (
+	// ChangesTypeName is the inventory type under which detected changes are uploaded
+	ChangesTypeName = "Custom:InventoryChanges"
+	// SchemaVersionOfInventoryChanges represents schema version of the inventory changes type
+	SchemaVersionOfInventoryChanges = "1.0"
+
+	// ChangeTypeAdded marks an entry that wasn't present in the previous collection
+	ChangeTypeAdded = "Added"
+	// ChangeTypeRemoved marks an entry that is no longer present
+	ChangeTypeRemoved = "Removed"
+	// ChangeTypeModified marks an entry whose attributes changed
+	ChangeTypeModified = "Modified"
+	// ChangeTypeTruncated marks the end of a list of changes too long to be uploaded, the others are in the change log
+	ChangeTypeTruncated = "Truncated"
+
+	// maxUploadedChanges bounds the changes uploaded at once so that the item stays within the inventory size limits
+	maxUploadedChanges = 1000
+)
+
+// identityAttributes lists the attributes identifying an entry of an inventory type. Types which aren't listed
+// here, like custom inventory, are identified by their whole content and thus only report additions and removals.
+var identityAttributes = map[string][]string{
+	"AWS:Application":        {"Name", "Architecture"},
+	"AWS:AWSComponent":       {"Name", "Architecture"},
+	"AWS:File":               {"InstalledDir", "Name"},
+	"AWS:Network":            {"Name"},
+	"AWS:Service":            {"Name"},
+	"AWS:WindowsRole":        {"Name"},
+	"AWS:WindowsUpdate":      {"HotFixId"},
+	"AWS:WindowsRegistry":    {"KeyPath", "ValueName"},
+	"Custom:KernelModule":    {"Name"},
+	"Custom:KernelParameter": {"Name"},
+	"Custom:ListeningPort":   {"Protocol", "Address", "Port"},
+	"Custom:LocalUser":       {"Name"},
+	"Custom:LanguagePackage": {"PackageManager", "Location", "Name"},
+	"Custom:DockerContainer": {"ID"},
+	"Custom:DockerImage":     {"ID"},
+}
+
+// singleEntryTypes hold exactly one entry, which is reported as modified whenever it changes
+var singleEntryTypes = map[string]bool{
+	"AWS:InstanceDetailedInformation": true,
+	"Custom:KernelInfo":               true,
+}
+
+// volatileAttributes change without the entry itself changing and are ignored when comparing entries
+var volatileAttributes = map[string][]string{
+	"AWS:File":               {"LastAccessTime"},
+	"Custom:DockerContainer": {"Status"},
+}
+
+// T tracks the changes of inventory data between collections
+type T interface {
+	Track(context context.T, items []model.Item) (changes []model.InventoryChangeData, err error)
+}
+
+// Tracker persists the last snapshot of each inventory type and appends detected changes to a local change log
+type Tracker struct {
+	snapshotDir       string
+	changeLog         string
+	maxChangeLogBytes int64
+	lock              sync.Mutex
+}
+
+// decoupling platform.InstanceID for easy testability
+var machineIDProvider = platform.InstanceID
+
+// NewTracker creates a Tracker which keeps its data in the inventory folder of the agent's data store
+func NewTracker(context context.T) (*Tracker, error) {
+	machineID, err := machineIDProvider()
+	if err != nil {
+		return nil, fmt.Errorf("unable to detect machineID because of %v", err.Error())
+	}
+	root := filepath.Join(appconfig.DefaultDataStorePath, machineID, appconfig.InventoryRootDirName)
+	return NewTrackerWithLocation(root), nil
+}
+
+// NewTrackerWithLocation creates a Tracker which keeps its snapshots and change log in the given folder
+func NewTrackerWithLocation(root string) *Tracker {
+	return &Tracker{
+		snapshotDir:       filepath.Join(root, appconfig.InventorySnapshotDirName),
+		changeLog:         filepath.Join(root, appconfig.InventoryChangeLogFileName),
+		maxChangeLogBytes: appconfig.InventoryChangeLogMaxBytes,
+	}
+}
+
+// Track compares the given items with the snapshots of the previous collection, records the changes in the change
+// log and replaces the snapshots. The first collection of a type only creates its snapshot.
+func (t *Tracker) Track(context context.T, items []model.Item) (changes []model.InventoryChangeData, err error) {
+	log := context.Log()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, item := range items {
+		if item.Name == ChangesTypeName {
+			continue
+		}
+
+		var current []map[string]interface{}
+		if current, err = model.ContentEntries(item.Content); err != nil {
+			return nil, fmt.Errorf("unable to read inventory data of %v: %v", item.Name, err)
+		}
+
+		snapshot := t.snapshotPath(item.Name)
+		if fileutil.Exists(snapshot) {
+			var previous []map[string]interface{}
+			if content, rerr := fileutil.ReadAllText(snapshot); rerr != nil {
+				log.Debugf("Unable to read inventory snapshot of %v: %v", item.Name, rerr)
+			} else if rerr = json.Unmarshal([]byte(content), &previous); rerr != nil {
+				log.Debugf("Ignoring invalid inventory snapshot of %v: %v", item.Name, rerr)
+			} else {
+				changes = append(changes, Diff(item.Name, item.CaptureTime, previous, current)...)
+			}
+		}
+
+		dataB, _ := json.Marshal(current)
+		if err = fileutil.MakeDirs(t.snapshotDir); err != nil {
+			return nil, fmt.Errorf("unable to create inventory snapshot folder: %v", err)
+		}
+		if _, err = fileutil.WriteIntoFileWithPermissions(snapshot, string(dataB), appconfig.ReadWriteAccess); err != nil {
+			return nil, fmt.Errorf("unable to save inventory snapshot of %v: %v", item.Name, err)
+		}
+	}
+
+	if len(changes) > 0 {
+		log.Infof("Detected %v inventory changes since the previous collection", len(changes))
+		if lerr := t.appendToChangeLog(changes); lerr != nil {
+			log.Errorf("Unable to write inventory change log: %v", lerr)
+		}
+	}
+	return
+}
+
+// ChangesItem returns the inventory item reporting the given changes. When there are too many changes to upload,
+// the last entry of the item records how many were left out, they are still in the local change log.
+func ChangesItem(log log.T, changes []model.InventoryChangeData, captureTime string) model.Item {
+	content := []model.InventoryChangeData{}
+	content = append(content, changes...)
+	if len(content) > maxUploadedChanges {
+		omitted := len(content) - maxUploadedChanges + 1
+		log.Warnf("Uploading %v of the %v inventory changes, the others are only in the local change log", maxUploadedChanges-1, len(content))
+		content = append(content[:maxUploadedChanges-1], model.InventoryChangeData{
+			TypeName:      ChangesTypeName,
+			ChangeType:    ChangeTypeTruncated,
+			CurrentValues: fmt.Sprintf("OmittedChanges=%v", omitted),
+			CaptureTime:   captureTime,
+		})
+	}
+	return model.Item{
+		Name:          ChangesTypeName,
+		SchemaVersion: SchemaVersionOfInventoryChanges,
+		Content:       content,
+		CaptureTime:   captureTime,
+	}
+}
+
+// Diff computes the changes between two snapshots of an inventory type
+func Diff(typeName, captureTime string, previous, current []map[string]interface{}) (changes []model.InventoryChangeData) {
+	identify := identityFunc(typeName, previous, current)
+
+	previousByID := make(map[string]map[string]interface{})
+	for _, entry := range previous {
+		previousByID[identify(entry)] = entry
+	}
+	currentIDs := make(map[string]bool)
+
+	for _, entry := range current {
+		id := identify(entry)
+		currentIDs[id] = true
+		old, found := previousByID[id]
+		if !found {
+			changes = append(changes, model.InventoryChangeData{
+				TypeName:      typeName,
+				ChangeType:    ChangeTypeAdded,
+				Identity:      id,
+				CurrentValues: formatValues(entry, nil),
+				CaptureTime:   captureTime,
+			})
+			continue
+		}
+		if attributes := changedAttributes(typeName, old, entry); len(attributes) > 0 {
+			changes = append(changes, model.InventoryChangeData{
+				TypeName:          typeName,
+				ChangeType:        ChangeTypeModified,
+				Identity:          id,
+				ChangedAttributes: strings.Join(attributes, ","),
+				PreviousValues:    formatValues(old, attributes),
+				CurrentValues:     formatValues(entry, attributes),
+				CaptureTime:       captureTime,
+			})
+		}
+	}
+
+	for _, entry := range previous {
+		if id := identify(entry); !currentIDs[id] {
+			changes = append(changes, model.InventoryChangeData{
+				TypeName:       typeName,
+				ChangeType:     ChangeTypeRemoved,
+				Identity:       id,
+				PreviousValues: formatValues(entry, nil),
+				CaptureTime:    captureTime,
+			})
+		}
+	}
+	return
+}
+
+// identityFunc returns how entries of a type are identified. When the identity attributes of a type don't
+// identify its entries uniquely, the whole content of the entries is used instead.
+func identityFunc(typeName string, snapshots ...[]map[string]interface{}) func(map[string]interface{}) string {
+	if singleEntryTypes[typeName] {
+		return func(map[string]interface{}) string { return typeName }
+	}
+
+	byContent := func(entry map[string]interface{}) string {
+		dataB, _ := json.Marshal(entry)
+		return string(dataB)
+	}
+	attributes, found := identityAttributes[typeName]
+	if !found {
+		return byContent
+	}
+	byAttributes := func(entry map[string]interface{}) string {
+		var values []string
+		for _, attribute := range attributes {
+			values = append(values, fmt.Sprint(entry[attribute]))
+		}
+		return strings.Join(values, "|")
+	}
+
+	for _, snapshot := range snapshots {
+		seen := make(map[string]bool)
+		for _, entry := range snapshot {
+			id := byAttributes(entry)
+			if seen[id] {
+				return byContent
+			}
+			seen[id] = true
+		}
+	}
+	return byAttributes
+}
+
+// changedAttributes returns the sorted names of the attributes whose value differs between two entries
+func changedAttributes(typeName string, old, new map[string]interface{}) (attributes []string) {
+	ignored := make(map[string]bool)
+	for _, attribute := range volatileAttributes[typeName] {
+		ignored[attribute] = true
+	}
+	names := make(map[string]bool)
+	for name := range old {
+		names[name] = true
+	}
+	for name := range new {
+		names[name] = true
+	}
+	for name := range names {
+		if !ignored[name] && fmt.Sprint(old[name]) != fmt.Sprint(new[name]) {
+			attributes = append(attributes, name)
+		}
+	}
+	sort.Strings(attributes)
+	return
+}
+
+// formatValues formats the given attributes of an entry, or all of them, as "name=value" pairs
+func formatValues(entry map[string]interface{}, attributes []string) string {
+	if attributes == nil {
+		for name := range entry {
+			attributes = append(attributes, name)
+		}
+		sort.Strings(attributes)
+	}
+	var pairs []string
+	for _, name := range attributes {
+		if value, found := entry[name]; found {
+			pairs = append(pairs, fmt.Sprintf("%v=%v", name, value))
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+// snapshotPath returns the file holding the snapshot of an inventory type
+func (t *Tracker) snapshotPath(typeName string) string {
+	return filepath.Join(t.snapshotDir, model.TypeFileName(typeName)+".json")
+}
+
+// appendToChangeLog appends changes as json lines, moving the log aside once it grows beyond its size limit
+func (t *Tracker) appendToChangeLog(changes []model.InventoryChangeData) (err error) {
+	if info, serr := os.Stat(t.changeLog); serr == nil && info.Size() >= t.maxChangeLogBytes {
+		if err = os.Rename(t.changeLog, t.changeLog+".1"); err != nil {
+			return
+		}
+	}
+
+	if err = fileutil.MakeDirs(filepath.Dir(t.changeLog)); err != nil {
+		return
+	}
+	file, err := os.OpenFile(t.changeLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, appconfig.ReadWriteAccess)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, change := range changes {
+		if err = encoder.Encode(change); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,174 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package changetracker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func applicationItem(apps ...model.ApplicationData) model.Item {
+	return model.Item{Name: "AWS:Application", Content: apps, CaptureTime: "2018-08-22T10:00:00Z"}
+}
+
+func TestTrackDetectsChanges(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "changetracker")
+	defer os.RemoveAll(dir)
+	tracker := NewTrackerWithLocation(dir)
+	contextMock := context.NewMockDefault()
+
+	changes, err := tracker.Track(contextMock, []model.Item{applicationItem(
+		model.ApplicationData{Name: "openssl", Version: "1.0.2k", Architecture: "x86_64"},
+		model.ApplicationData{Name: "telnet", Version: "0.17", Architecture: "x86_64"},
+	)})
+	assert.Nil(t, err)
+	assert.Empty(t, changes, "first collection only creates the snapshot")
+
+	changes, err = tracker.Track(contextMock, []model.Item{applicationItem(
+		model.ApplicationData{Name: "openssl", Version: "1.0.2o", Architecture: "x86_64"},
+		model.ApplicationData{Name: "nginx", Version: "1.12.1", Architecture: "x86_64"},
+	)})
+	assert.Nil(t, err)
+	assert.Equal(t, []model.InventoryChangeData{
+		{
+			TypeName:          "AWS:Application",
+			ChangeType:        ChangeTypeModified,
+			Identity:          "openssl|x86_64",
+			ChangedAttributes: "Version",
+			PreviousValues:    "Version=1.0.2k",
+			CurrentValues:     "Version=1.0.2o",
+			CaptureTime:       "2018-08-22T10:00:00Z",
+		},
+		{
+			TypeName:      "AWS:Application",
+			ChangeType:    ChangeTypeAdded,
+			Identity:      "nginx|x86_64",
+			CurrentValues: "Architecture=x86_64,Name=nginx,Publisher=,Version=1.12.1",
+			CaptureTime:   "2018-08-22T10:00:00Z",
+		},
+		{
+			TypeName:       "AWS:Application",
+			ChangeType:     ChangeTypeRemoved,
+			Identity:       "telnet|x86_64",
+			PreviousValues: "Architecture=x86_64,Name=telnet,Publisher=,Version=0.17",
+			CaptureTime:    "2018-08-22T10:00:00Z",
+		},
+	}, changes)
+
+	changeLog, err := ioutil.ReadFile(filepath.Join(dir, "changes.jsonl"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, strings.Count(string(changeLog), "\n"))
+
+	changes, err = tracker.Track(contextMock, []model.Item{applicationItem(
+		model.ApplicationData{Name: "openssl", Version: "1.0.2o", Architecture: "x86_64"},
+		model.ApplicationData{Name: "nginx", Version: "1.12.1", Architecture: "x86_64"},
+	)})
+	assert.Nil(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestTrackRotatesChangeLog(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "changetracker")
+	defer os.RemoveAll(dir)
+	tracker := NewTrackerWithLocation(dir)
+	tracker.maxChangeLogBytes = 1
+	contextMock := context.NewMockDefault()
+
+	for _, version := range []string{"1", "2", "3"} {
+		_, err := tracker.Track(contextMock, []model.Item{applicationItem(model.ApplicationData{Name: "app", Version: version})})
+		assert.Nil(t, err)
+	}
+
+	assert.True(t, fileExists(filepath.Join(dir, "changes.jsonl")))
+	assert.True(t, fileExists(filepath.Join(dir, "changes.jsonl.1")))
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestDiffWithoutIdentityAttributes(t *testing.T) {
+	previous := []map[string]interface{}{{"Key": "a"}, {"Key": "b"}}
+	current := []map[string]interface{}{{"Key": "b"}, {"Key": "c"}}
+
+	changes := Diff("Custom:Rack", "", previous, current)
+
+	assert.Equal(t, 2, len(changes))
+	assert.Equal(t, ChangeTypeAdded, changes[0].ChangeType)
+	assert.Equal(t, `{"Key":"c"}`, changes[0].Identity)
+	assert.Equal(t, ChangeTypeRemoved, changes[1].ChangeType)
+	assert.Equal(t, `{"Key":"a"}`, changes[1].Identity)
+}
+
+func TestDiffSingleEntryType(t *testing.T) {
+	previous := []map[string]interface{}{{"Name": "Linux", "Release": "4.14.62"}}
+	current := []map[string]interface{}{{"Name": "Linux", "Release": "4.14.67"}}
+
+	changes := Diff("Custom:KernelInfo", "", previous, current)
+
+	assert.Equal(t, 1, len(changes))
+	assert.Equal(t, ChangeTypeModified, changes[0].ChangeType)
+	assert.Equal(t, "Release", changes[0].ChangedAttributes)
+}
+
+func TestDiffIgnoresVolatileAttributes(t *testing.T) {
+	previous := []map[string]interface{}{{"ID": "8dfafdbc3a40", "State": "running", "Status": "Up 2 hours"}}
+	current := []map[string]interface{}{{"ID": "8dfafdbc3a40", "State": "running", "Status": "Up 3 hours"}}
+
+	assert.Empty(t, Diff("Custom:DockerContainer", "", previous, current))
+}
+
+func TestDiffDuplicateIdentities(t *testing.T) {
+	// two versions of the same gem share the identity attributes
+	previous := []map[string]interface{}{
+		{"PackageManager": "gem", "Name": "rake", "Version": "12.3.1"},
+		{"PackageManager": "gem", "Name": "rake", "Version": "10.5.0"},
+	}
+	current := []map[string]interface{}{
+		{"PackageManager": "gem", "Name": "rake", "Version": "12.3.1"},
+	}
+
+	changes := Diff("Custom:LanguagePackage", "", previous, current)
+
+	assert.Equal(t, 1, len(changes))
+	assert.Equal(t, ChangeTypeRemoved, changes[0].ChangeType)
+	assert.Contains(t, changes[0].PreviousValues, "Version=10.5.0")
+}
+
+func TestChangesItem(t *testing.T) {
+	changes := make([]model.InventoryChangeData, maxUploadedChanges+10)
+
+	item := ChangesItem(log.NewMockLog(), changes, "2018-08-22T10:00:00Z")
+
+	assert.Equal(t, ChangesTypeName, item.Name)
+	content := item.Content.([]model.InventoryChangeData)
+	assert.Equal(t, maxUploadedChanges, len(content))
+	//the last entry records the changes left out
+	assert.Equal(t, ChangeTypeTruncated, content[maxUploadedChanges-1].ChangeType)
+	assert.Equal(t, "OmittedChanges=11", content[maxUploadedChanges-1].CurrentValues)
+
+	complete := ChangesItem(log.NewMockLog(), changes[:maxUploadedChanges], "")
+	assert.Equal(t, maxUploadedChanges, len(complete.Content.([]model.InventoryChangeData)))
+	assert.NotEqual(t, ChangeTypeTruncated, complete.Content.([]model.InventoryChangeData)[maxUploadedChanges-1].ChangeType)
+	assert.Equal(t, []model.InventoryChangeData{}, ChangesItem(log.NewMockLog(), nil, "").Content)
+}
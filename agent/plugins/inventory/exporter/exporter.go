@@ -0,0 +1,160 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package exporter writes collected inventory data to the local file system, for hosts that can't or
+// shouldn't upload their inventory to Systems Manager.
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+// snapshotDirFormat names the folder of a collection after its time so that folders sort chronologically
+const snapshotDirFormat = "20060102T150405Z"
+
+// Settings describes where and how inventory data is exported
+type Settings struct {
+	Directory    string
+	Format       string
+	MaxSnapshots int
+}
+
+// Validate returns an error if the settings can't be used for an export
+func (s Settings) Validate() error {
+	if s.Directory == "" {
+		return fmt.Errorf("no inventory export directory is configured")
+	}
+	if !strings.EqualFold(s.Format, appconfig.InventoryExportFormatJSON) && !strings.EqualFold(s.Format, appconfig.InventoryExportFormatCSV) {
+		return fmt.Errorf("unsupported inventory export format %v, expected %v or %v",
+			s.Format, appconfig.InventoryExportFormatJSON, appconfig.InventoryExportFormatCSV)
+	}
+	return nil
+}
+
+// Export writes each item to its own file in a new folder of the export directory, named after the collection
+// time, and removes the oldest folders beyond the configured number of snapshots. It returns the new folder.
+func Export(context context.T, settings Settings, items []model.Item, collectionTime time.Time) (snapshotDir string, err error) {
+	log := context.Log()
+
+	if err = settings.Validate(); err != nil {
+		return
+	}
+
+	snapshotDir = filepath.Join(settings.Directory, collectionTime.UTC().Format(snapshotDirFormat))
+	if err = fileutil.MakeDirs(snapshotDir); err != nil {
+		return "", fmt.Errorf("unable to create inventory export folder %v: %v", snapshotDir, err)
+	}
+
+	for _, item := range items {
+		var content []byte
+		extension := ".json"
+		if strings.EqualFold(settings.Format, appconfig.InventoryExportFormatCSV) {
+			extension = ".csv"
+			content, err = toCSV(item)
+		} else {
+			content, err = json.MarshalIndent(item, "", "  ")
+		}
+		if err != nil {
+			return "", fmt.Errorf("unable to format inventory data of %v: %v", item.Name, err)
+		}
+
+		path := filepath.Join(snapshotDir, model.TypeFileName(item.Name)+extension)
+		if _, err = fileutil.WriteIntoFileWithPermissions(path, string(content), appconfig.ReadWriteAccess); err != nil {
+			return "", fmt.Errorf("unable to write inventory data of %v: %v", item.Name, err)
+		}
+	}
+	log.Infof("Exported %v inventory types to %v", len(items), snapshotDir)
+
+	rotate(context, settings)
+	return
+}
+
+// toCSV formats the entries of an item as csv, with one column per attribute found in any entry
+func toCSV(item model.Item) ([]byte, error) {
+	entries, err := model.ContentEntries(item.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	columnSet := make(map[string]bool)
+	for _, entry := range entries {
+		for name := range entry {
+			columnSet[name] = true
+		}
+	}
+	var columns []string
+	for name := range columnSet {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	if err = writer.Write(columns); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		row := make([]string, len(columns))
+		for i, name := range columns {
+			if value, found := entry[name]; found && value != nil {
+				row[i] = fmt.Sprint(value)
+			}
+		}
+		if err = writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+// rotate removes the oldest collection folders of the export directory beyond the configured number of snapshots
+func rotate(context context.T, settings Settings) {
+	log := context.Log()
+
+	files, err := ioutil.ReadDir(settings.Directory)
+	if err != nil {
+		log.Debugf("Unable to list inventory export folder: %v", err)
+		return
+	}
+
+	var snapshots []string
+	for _, file := range files {
+		if _, perr := time.Parse(snapshotDirFormat, file.Name()); file.IsDir() && perr == nil {
+			snapshots = append(snapshots, file.Name())
+		}
+	}
+	sort.Strings(snapshots)
+
+	for len(snapshots) > settings.MaxSnapshots && settings.MaxSnapshots > 0 {
+		oldest := filepath.Join(settings.Directory, snapshots[0])
+		if err = os.RemoveAll(oldest); err != nil {
+			log.Warnf("Unable to remove exported inventory %v: %v", oldest, err)
+		}
+		snapshots = snapshots[1:]
+	}
+}
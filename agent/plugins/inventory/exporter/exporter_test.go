@@ -0,0 +1,107 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var testItems = []model.Item{
+	{
+		Name:          "AWS:Application",
+		SchemaVersion: "1.1",
+		CaptureTime:   "2018-08-22T10:00:00Z",
+		Content: []model.ApplicationData{
+			{Name: "openssl", Version: "1.0.2k", Architecture: "x86_64", Publisher: "Amazon Linux"},
+			{Name: "nginx", Version: "1.12.1", Architecture: "x86_64", Summary: "A high performance web server, and reverse proxy"},
+		},
+	},
+	{
+		Name:          "Custom:RackInfo",
+		SchemaVersion: "1.0",
+		CaptureTime:   "2018-08-22T10:00:00Z",
+		Content:       map[string]string{"RackLocation": "Bay, 42"},
+	},
+}
+
+func TestExportJSON(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "exporter")
+	defer os.RemoveAll(dir)
+	collectionTime := time.Date(2018, 8, 22, 10, 0, 0, 0, time.UTC)
+
+	snapshotDir, err := Export(context.NewMockDefault(), Settings{Directory: dir, Format: "JSON", MaxSnapshots: 2}, testItems, collectionTime)
+
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "20180822T100000Z"), snapshotDir)
+	content, err := ioutil.ReadFile(filepath.Join(snapshotDir, "AWS_Application.json"))
+	assert.Nil(t, err)
+	var item model.Item
+	assert.Nil(t, json.Unmarshal(content, &item))
+	assert.Equal(t, "AWS:Application", item.Name)
+	assert.Equal(t, 2, len(item.Content.([]interface{})))
+	_, err = os.Stat(filepath.Join(snapshotDir, "Custom_RackInfo.json"))
+	assert.Nil(t, err)
+}
+
+func TestExportCSV(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "exporter")
+	defer os.RemoveAll(dir)
+
+	snapshotDir, err := Export(context.NewMockDefault(), Settings{Directory: dir, Format: "csv", MaxSnapshots: 2}, testItems, time.Now())
+
+	assert.Nil(t, err)
+	content, err := ioutil.ReadFile(filepath.Join(snapshotDir, "AWS_Application.csv"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Architecture,Name,Publisher,Summary,Version\n"+
+		"x86_64,openssl,Amazon Linux,,1.0.2k\n"+
+		"x86_64,nginx,,\"A high performance web server, and reverse proxy\",1.12.1\n", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(snapshotDir, "Custom_RackInfo.csv"))
+	assert.Nil(t, err)
+	assert.Equal(t, "RackLocation\n\"Bay, 42\"\n", string(content))
+}
+
+func TestExportRotatesSnapshots(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "exporter")
+	defer os.RemoveAll(dir)
+	settings := Settings{Directory: dir, Format: "JSON", MaxSnapshots: 2}
+	start := time.Date(2018, 8, 22, 10, 0, 0, 0, time.UTC)
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "keep-me"), 0700))
+
+	for i := 0; i < 4; i++ {
+		_, err := Export(context.NewMockDefault(), settings, testItems, start.Add(time.Duration(i)*time.Hour))
+		assert.Nil(t, err)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	assert.Equal(t, []string{"20180822T120000Z", "20180822T130000Z", "keep-me"}, names)
+}
+
+func TestSettingsValidate(t *testing.T) {
+	assert.Nil(t, Settings{Directory: "/var/lib/inventory", Format: "CSV"}.Validate())
+	assert.NotNil(t, Settings{Format: "JSON"}.Validate())
+	assert.NotNil(t, Settings{Directory: "/var/lib/inventory", Format: "XML"}.Validate())
+}
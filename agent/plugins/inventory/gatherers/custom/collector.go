@@ -0,0 +1,156 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package custom contains a gatherer for collecting custom inventory items
+package custom
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+// CollectorOutputLimit represents the maximum size of the output of a collector script
+const CollectorOutputLimit = 4 * 1024 * 1024
+
+// collectorSettings represents where collector scripts are found and how they run
+type collectorSettings struct {
+	directory string
+	timeout   time.Duration
+	user      string
+}
+
+// decoupling for easy testability
+var runCollectorFunc = runCollector
+
+// newCollectorSettings returns the collector script settings of the agent configuration
+func newCollectorSettings(cfg appconfig.InventoryCfg) collectorSettings {
+	return collectorSettings{
+		directory: cfg.CustomCollectorDirectory,
+		timeout:   time.Duration(cfg.CustomCollectorTimeoutSeconds) * time.Second,
+		user:      cfg.CustomCollectorUser,
+	}
+}
+
+// getCollectorPaths returns the executable collector scripts in the given folder sorted by name
+func getCollectorPaths(log log.T, folder string) (collectorPaths []string, err error) {
+	files, readDirError := readDirFunc(folder)
+	if readDirError != nil {
+		LogError(log, fmt.Errorf("Read collector directory %v failed, error: %v", folder, readDirError))
+		// In case of directory not found error, ignore
+		return []string{}, nil
+	}
+
+	for _, f := range files {
+		if f.IsDir() || !isCollector(f) {
+			continue
+		}
+		if f.Mode().Perm()&0002 != 0 {
+			LogError(log, fmt.Errorf("Collector script %v is writable by everyone, skipping it", f.Name()))
+			continue
+		}
+		collectorPaths = append(collectorPaths, filepath.Clean(filepath.Join(folder, f.Name())))
+	}
+	sort.Strings(collectorPaths)
+
+	if len(collectorPaths) > CustomInventoryCountLimit {
+		err = fmt.Errorf("Total custom inventory collector count (%v) exceed limit (%v)",
+			len(collectorPaths), CustomInventoryCountLimit)
+		LogError(log, err)
+		return nil, err
+	}
+	return
+}
+
+// getItemFromCollector runs one collector script and converts its output to an inventory item
+func getItemFromCollector(log log.T, settings collectorSettings, collectorPath string) (result model.Item, err error) {
+	var output []byte
+	if output, err = runCollectorFunc(log, settings, collectorPath); err != nil {
+		LogError(log, fmt.Errorf("Failed to run collector: %v, error: %v", collectorPath, err))
+		return
+	}
+
+	result, err = convertToItem(log, output)
+	if err != nil {
+		LogError(log, fmt.Errorf("Failed to convert output of collector (%v) to inventory item, error: %v",
+			collectorPath, err))
+	}
+	return
+}
+
+// runCollector runs the collector script as the configured user and returns its standard output.
+// The script is killed if it does not finish within the configured timeout.
+func runCollector(log log.T, settings collectorSettings, collectorPath string) (output []byte, err error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(collectorPath)
+	cmd.Dir = settings.directory
+	cmd.Stdout = &limitedBuffer{buffer: &stdout, limit: CollectorOutputLimit}
+	cmd.Stderr = &limitedBuffer{buffer: &stderr, limit: CollectorOutputLimit}
+	if err = prepareCollectorCommand(cmd, settings.user); err != nil {
+		return
+	}
+
+	log.Debugf("Running custom inventory collector %v", collectorPath)
+	if err = cmd.Start(); err != nil {
+		return
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err = <-done:
+	case <-time.After(settings.timeout):
+		killCollector(log, cmd)
+		<-done
+		return nil, fmt.Errorf("collector did not finish within %v", settings.timeout)
+	}
+
+	if stderr.Len() > 0 {
+		log.Debugf("Collector %v wrote to stderr: %v", collectorPath, stderr.String())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v, stderr: %v", err, stderr.String())
+	}
+	if stdout.Len() >= CollectorOutputLimit {
+		return nil, fmt.Errorf("collector output exceeded the limit of %v bytes", CollectorOutputLimit)
+	}
+	return stdout.Bytes(), nil
+}
+
+// limitedBuffer is a writer which keeps at most limit bytes and discards the rest
+type limitedBuffer struct {
+	buffer *bytes.Buffer
+	limit  int
+}
+
+// Write writes p to the buffer as long as the limit is not reached
+func (b *limitedBuffer) Write(p []byte) (n int, err error) {
+	if remaining := b.limit - b.buffer.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buffer.Write(p[:remaining])
+		} else {
+			b.buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
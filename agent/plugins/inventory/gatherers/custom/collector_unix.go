@@ -0,0 +1,70 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+// Package custom contains a gatherer for collecting custom inventory items
+package custom
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// isCollector returns true if the file is executable
+func isCollector(fi os.FileInfo) bool {
+	return fi.Mode().IsRegular() && fi.Mode().Perm()&0111 != 0
+}
+
+// prepareCollectorCommand makes the command run as the given unprivileged user in its own process group
+func prepareCollectorCommand(cmd *exec.Cmd, userName string) (err error) {
+	var u *user.User
+	var uid, gid uint64
+	if u, err = user.Lookup(userName); err != nil {
+		return fmt.Errorf("unable to find collector user %v, %v", userName, err)
+	}
+	if uid, err = strconv.ParseUint(u.Uid, 10, 32); err != nil {
+		return
+	}
+	if gid, err = strconv.ParseUint(u.Gid, 10, 32); err != nil {
+		return
+	}
+	if uid == 0 {
+		return fmt.Errorf("collector user %v is privileged, collectors must run as an unprivileged user", userName)
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if uint64(os.Getuid()) != uid {
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	}
+	cmd.Env = []string{
+		"PATH=/usr/local/bin:/usr/bin:/bin:/usr/sbin:/sbin",
+		"HOME=" + u.HomeDir,
+		"USER=" + u.Username,
+	}
+	return
+}
+
+// killCollector kills the collector and all processes it started
+func killCollector(log log.T, cmd *exec.Cmd) {
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		log.Debugf("Failed to kill collector process group %v, %v", cmd.Process.Pid, err)
+		cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,131 @@
+// +build darwin freebsd linux netbsd openbsd
+
+// Package custom contains a gatherer for collecting custom inventory items
+package custom
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func MockReadDirCollectors(dirname string) (files []os.FileInfo, err error) {
+	files = append(files,
+		MockFileInfo{name: "webserver.sh", mode: 0755},
+		MockFileInfo{name: "invalid.sh", mode: 0755},
+		MockFileInfo{name: "README", mode: 0644},
+		MockFileInfo{name: "unsafe.sh", mode: 0777},
+		MockFileInfo{name: "scripts", mode: os.ModeDir | 0755, isDir: true})
+	return
+}
+
+func MockRunCollector(log log.T, settings collectorSettings, collectorPath string) ([]byte, error) {
+	switch collectorPath {
+	case "/collectors/webserver.sh":
+		return json.Marshal(MockCustomInventoryItem())
+	case "/collectors/invalid.sh":
+		return json.Marshal(MockItemTypeNameInvalidPrefix())
+	}
+	return nil, errors.New("collector should not run")
+}
+
+func TestGetCollectorItems(t *testing.T) {
+	readDirFunc = MockReadDirCollectors
+	runCollectorFunc = MockRunCollector
+	defer func() { runCollectorFunc = runCollector }()
+
+	settings := collectorSettings{directory: "/collectors", timeout: time.Second, user: "nobody"}
+	items := getCollectorItems(log.NewMockLog(), settings, map[string]bool{}, 0)
+
+	assert.Equal(t, 1, len(items), "only the valid output of executable collectors becomes an item")
+	assert.Equal(t, "Custom:MyFile", items[0].Name)
+}
+
+func TestGetCollectorItemsDuplicateTypeName(t *testing.T) {
+	readDirFunc = MockReadDirCollectors
+	runCollectorFunc = MockRunCollector
+	defer func() { runCollectorFunc = runCollector }()
+
+	settings := collectorSettings{directory: "/collectors", timeout: time.Second, user: "nobody"}
+	items := getCollectorItems(log.NewMockLog(), settings, map[string]bool{"Custom:MyFile": true}, 1)
+
+	assert.Equal(t, 0, len(items), "a type already collected from a file is not replaced by a collector")
+}
+
+func TestGetCollectorItemsCountExceed(t *testing.T) {
+	readDirFunc = MockReadDirCollectors
+	runCollectorFunc = MockRunCollector
+	defer func() { runCollectorFunc = runCollector }()
+
+	settings := collectorSettings{directory: "/collectors", timeout: time.Second, user: "nobody"}
+	items := getCollectorItems(log.NewMockLog(), settings, map[string]bool{}, CustomInventoryCountLimit)
+
+	assert.Equal(t, 0, len(items))
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	buffer := limitedBuffer{buffer: &bytes.Buffer{}, limit: 4}
+	n, err := buffer.Write([]byte("abcdef"))
+	assert.Nil(t, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, "abcd", buffer.buffer.String())
+}
+
+func TestRunCollector(t *testing.T) {
+	dir, settings := createCollectorDir(t)
+	defer os.RemoveAll(dir)
+	collector := filepath.Join(dir, "collector.sh")
+	script := "#!/bin/sh\necho '{\"TypeName\": \"Custom:User\", \"SchemaVersion\": \"1.0\", \"Content\": {\"Name\": \"'$(id -un)'\"}}'\n"
+	assert.Nil(t, ioutil.WriteFile(collector, []byte(script), 0755))
+
+	item, err := getItemFromCollector(log.NewMockLog(), settings, collector)
+	assert.Nil(t, err)
+	assert.Equal(t, "Custom:User", item.Name)
+	assert.Equal(t, []map[string]interface{}{{"Name": settings.user}}, item.Content)
+}
+
+func TestRunCollectorTimeout(t *testing.T) {
+	dir, settings := createCollectorDir(t)
+	defer os.RemoveAll(dir)
+	collector := filepath.Join(dir, "collector.sh")
+	assert.Nil(t, ioutil.WriteFile(collector, []byte("#!/bin/sh\nsleep 30\n"), 0755))
+
+	start := time.Now()
+	_, err := runCollector(log.NewMockLog(), settings, collector)
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < 10*time.Second, "collector is killed once the timeout expires")
+}
+
+func TestPrepareCollectorCommandPrivilegedUser(t *testing.T) {
+	err := prepareCollectorCommand(exec.Command("true"), "root")
+	assert.NotNil(t, err)
+}
+
+// createCollectorDir creates a collector directory the collector user is able to use
+func createCollectorDir(t *testing.T) (dir string, settings collectorSettings) {
+	dir, err := ioutil.TempDir("", "collectors")
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chmod(dir, 0755))
+
+	userName := "nobody"
+	if os.Getuid() != 0 {
+		current, err := user.Current()
+		assert.Nil(t, err)
+		userName = current.Username
+	}
+	if _, err := user.Lookup(userName); err != nil {
+		t.Skipf("collector user %v is not available", userName)
+	}
+	settings = collectorSettings{directory: dir, timeout: time.Second, user: userName}
+	return
+}
@@ -0,0 +1,42 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+// Package custom contains a gatherer for collecting custom inventory items
+package custom
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// isCollector returns true if the file is an executable
+func isCollector(fi os.FileInfo) bool {
+	return fi.Mode().IsRegular() && strings.EqualFold(filepath.Ext(fi.Name()), ".exe")
+}
+
+// prepareCollectorCommand fails since running a collector as a different user requires the user's credentials on windows
+func prepareCollectorCommand(cmd *exec.Cmd, userName string) error {
+	return errors.New("custom inventory collectors are not supported on windows")
+}
+
+// killCollector kills the collector
+func killCollector(log log.T, cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
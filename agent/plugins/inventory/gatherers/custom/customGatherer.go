@@ -147,6 +147,11 @@ func (t *T) Run(context context.T, configuration model.Config) (items []model.It
 		}
 	}
 
+	// Run collector scripts when configured
+	if settings := newCollectorSettings(context.AppConfig().Inventory); settings.directory != "" {
+		items = append(items, getCollectorItems(log, settings, setTypeName, len(items))...)
+	}
+
 	count := len(items)
 	log.Debugf("Count of custom inventory items : %v.", count)
 	if count == 0 {
@@ -162,6 +167,35 @@ func (t *T) RequestStop(stopType contracts.StopType) error {
 	return err
 }
 
+// getCollectorItems runs the collector scripts and returns the items of those with a valid and unique TypeName
+func getCollectorItems(log log.T, settings collectorSettings, setTypeName map[string]bool, fileItemCount int) (items []model.Item) {
+	collectorList, err := getCollectorPaths(log, settings.directory)
+	if err != nil {
+		return
+	}
+	if fileItemCount+len(collectorList) > CustomInventoryCountLimit {
+		LogError(log, fmt.Errorf("Total custom inventory file and collector count (%v) exceed limit (%v)",
+			fileItemCount+len(collectorList), CustomInventoryCountLimit))
+		return
+	}
+
+	for _, collectorPath := range collectorList {
+		customItem, err := getItemFromCollector(log, settings, collectorPath)
+		if err != nil {
+			continue
+		}
+		if _, ok := setTypeName[customItem.Name]; ok {
+			LogError(log, fmt.Errorf("Custom inventory typeName (%v) from collector (%v) already exists,"+
+				" i.e., a custom inventory file or other collector contains the same typeName.",
+				customItem.Name, collectorPath))
+			continue
+		}
+		setTypeName[customItem.Name] = true
+		items = append(items, customItem)
+	}
+	return
+}
+
 // getItemFromFile Reads one custom inventory file
 func getItemFromFile(log log.T, file string) (result model.Item, err error) {
 
@@ -0,0 +1,191 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+const (
+	// engineHost is a placeholder, requests are always sent over the unix socket
+	engineHost     = "http://docker"
+	requestTimeout = 30 * time.Second
+)
+
+var dockerSocket = "/var/run/docker.sock"
+
+// container is the subset of the Engine API container summary used for inventory
+type container struct {
+	Id      string
+	Names   []string
+	Image   string
+	ImageID string
+	State   string
+	Status  string
+	Created int64
+	Labels  map[string]string
+	Ports   []struct {
+		IP          string
+		PrivatePort int
+		PublicPort  int
+		Type        string
+	}
+}
+
+// containerDetails is the subset of the Engine API container inspect response used for inventory
+type containerDetails struct {
+	RestartCount int
+}
+
+// image is the subset of the Engine API image summary used for inventory
+type image struct {
+	Id          string
+	RepoTags    []string
+	RepoDigests []string
+	Size        int64
+	Created     int64
+}
+
+// newEngineClient returns an http client which sends every request to the Docker engine unix socket
+func newEngineClient(socket string) *http.Client {
+	return &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			Dial: func(network, addr string) (net.Conn, error) {
+				return net.DialTimeout("unix", socket, requestTimeout)
+			},
+		},
+	}
+}
+
+// getJSON queries the Docker Engine API and decodes the json response
+func getJSON(client *http.Client, path string, result interface{}) error {
+	resp, err := client.Get(engineHost + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("docker engine returned %v for %v", resp.Status, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// collectDockerData lists all containers, running or not, and all local images of the Docker engine.
+// Hosts without a Docker engine socket report no containers and images.
+func collectDockerData(context context.T) (containers []model.DockerContainerData, images []model.DockerImageData, err error) {
+	log := context.Log()
+	containers = []model.DockerContainerData{}
+	images = []model.DockerImageData{}
+
+	if _, err = os.Stat(dockerSocket); err != nil {
+		log.Infof("Docker engine socket %v not found, no container inventory collected: %v", dockerSocket, err)
+		return containers, images, nil
+	}
+
+	client := newEngineClient(dockerSocket)
+
+	var engineImages []image
+	if err = getJSON(client, "/images/json", &engineImages); err != nil {
+		return nil, nil, fmt.Errorf("unable to list docker images: %v", err)
+	}
+	digests := make(map[string]string)
+	for _, i := range engineImages {
+		data := model.DockerImageData{
+			ID:          i.Id,
+			RepoTags:    strings.Join(i.RepoTags, ","),
+			Size:        strconv.FormatInt(i.Size, 10),
+			CreatedTime: formatTime(i.Created),
+		}
+		if len(i.RepoDigests) > 0 {
+			data.RepoDigest = i.RepoDigests[0]
+			digests[i.Id] = i.RepoDigests[0]
+		}
+		images = append(images, data)
+	}
+
+	var engineContainers []container
+	if err = getJSON(client, "/containers/json?all=1", &engineContainers); err != nil {
+		return nil, nil, fmt.Errorf("unable to list docker containers: %v", err)
+	}
+	for _, c := range engineContainers {
+		data := model.DockerContainerData{
+			ID:          c.Id,
+			Image:       c.Image,
+			ImageID:     c.ImageID,
+			ImageDigest: digests[c.ImageID],
+			State:       c.State,
+			Status:      c.Status,
+			Ports:       formatPorts(c),
+			Labels:      formatLabels(c.Labels),
+			CreatedTime: formatTime(c.Created),
+		}
+		if len(c.Names) > 0 {
+			data.Name = strings.TrimPrefix(c.Names[0], "/")
+		}
+
+		// the restart count is only part of the detailed container information
+		var details containerDetails
+		if derr := getJSON(client, "/containers/"+c.Id+"/json", &details); derr != nil {
+			log.Debugf("Unable to inspect container %v: %v", c.Id, derr)
+		} else {
+			data.RestartCount = strconv.Itoa(details.RestartCount)
+		}
+		containers = append(containers, data)
+	}
+
+	log.Infof("Number of docker containers detected - %v, images - %v", len(containers), len(images))
+	return
+}
+
+// formatPorts formats the port bindings of a container the way "docker ps" does, e.g. "0.0.0.0:8080->80/tcp"
+func formatPorts(c container) string {
+	var ports []string
+	for _, p := range c.Ports {
+		if p.PublicPort != 0 {
+			ports = append(ports, fmt.Sprintf("%v:%d->%d/%v", p.IP, p.PublicPort, p.PrivatePort, p.Type))
+		} else {
+			ports = append(ports, fmt.Sprintf("%d/%v", p.PrivatePort, p.Type))
+		}
+	}
+	sort.Strings(ports)
+	return strings.Join(ports, ",")
+}
+
+// formatLabels formats labels as a sorted list of key=value pairs
+func formatLabels(labels map[string]string) string {
+	var pairs []string
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func formatTime(unix int64) string {
+	//time must comply with format: 2016-07-30T18:15:37Z
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,141 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docker
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	sampleImages = `[
+  {"Id": "sha256:2ca708c1c9cc", "RepoTags": ["nginx:1.15", "nginx:latest"],
+   "RepoDigests": ["nginx@sha256:d98b66402922eccdbee49ef093edb2d2c5001637bd291ae0a8cd21bb4c36bebe"],
+   "Size": 109094228, "Created": 1534906413},
+  {"Id": "sha256:7a0ab1ecbc2d", "RepoTags": ["<none>:<none>"], "RepoDigests": [], "Size": 5234, "Created": 1534000000}
+]`
+	sampleContainers = `[
+  {"Id": "8dfafdbc3a40", "Names": ["/web"], "Image": "nginx:1.15", "ImageID": "sha256:2ca708c1c9cc",
+   "State": "running", "Status": "Up 2 hours", "Created": 1534910000,
+   "Labels": {"tier": "frontend", "com.example.owner": "ops"},
+   "Ports": [{"IP": "0.0.0.0", "PrivatePort": 80, "PublicPort": 8080, "Type": "tcp"}, {"PrivatePort": 443, "Type": "tcp"}]},
+  {"Id": "9cd87474be90", "Names": ["/job"], "Image": "sha256:7a0ab1ecbc2d", "ImageID": "sha256:7a0ab1ecbc2d",
+   "State": "exited", "Status": "Exited (1) 5 minutes ago", "Created": 1534920000, "Labels": {}, "Ports": []}
+]`
+)
+
+// startFakeEngine serves the given responses over a unix socket in a temp directory
+func startFakeEngine(t *testing.T, responses map[string]string) (socket string, stop func()) {
+	dir, err := ioutil.TempDir("", "docker")
+	assert.Nil(t, err)
+	socket = filepath.Join(dir, "docker.sock")
+
+	listener, err := net.Listen("unix", socket)
+	assert.Nil(t, err)
+
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if response, found := responses[r.URL.RequestURI()]; found {
+			w.Write([]byte(response))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+
+	return socket, func() {
+		listener.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCollectDockerData(t *testing.T) {
+	socket, stop := startFakeEngine(t, map[string]string{
+		"/images/json":                  sampleImages,
+		"/containers/json?all=1":        sampleContainers,
+		"/containers/8dfafdbc3a40/json": `{"Id": "8dfafdbc3a40", "RestartCount": 3}`,
+	})
+	defer stop()
+	oldSocket := dockerSocket
+	defer func() { dockerSocket = oldSocket }()
+	dockerSocket = socket
+
+	containers, images, err := collectDockerData(context.NewMockDefault())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []model.DockerImageData{
+		{
+			ID:          "sha256:2ca708c1c9cc",
+			RepoTags:    "nginx:1.15,nginx:latest",
+			RepoDigest:  "nginx@sha256:d98b66402922eccdbee49ef093edb2d2c5001637bd291ae0a8cd21bb4c36bebe",
+			Size:        "109094228",
+			CreatedTime: "2018-08-22T02:53:33Z",
+		},
+		{ID: "sha256:7a0ab1ecbc2d", RepoTags: "<none>:<none>", Size: "5234", CreatedTime: "2018-08-11T15:06:40Z"},
+	}, images)
+	assert.Equal(t, []model.DockerContainerData{
+		{
+			ID:           "8dfafdbc3a40",
+			Name:         "web",
+			Image:        "nginx:1.15",
+			ImageID:      "sha256:2ca708c1c9cc",
+			ImageDigest:  "nginx@sha256:d98b66402922eccdbee49ef093edb2d2c5001637bd291ae0a8cd21bb4c36bebe",
+			State:        "running",
+			Status:       "Up 2 hours",
+			Ports:        "0.0.0.0:8080->80/tcp,443/tcp",
+			Labels:       "com.example.owner=ops,tier=frontend",
+			RestartCount: "3",
+			CreatedTime:  "2018-08-22T03:53:20Z",
+		},
+		{
+			ID:          "9cd87474be90",
+			Name:        "job",
+			Image:       "sha256:7a0ab1ecbc2d",
+			ImageID:     "sha256:7a0ab1ecbc2d",
+			State:       "exited",
+			Status:      "Exited (1) 5 minutes ago",
+			CreatedTime: "2018-08-22T06:40:00Z",
+		},
+	}, containers)
+}
+
+func TestCollectDockerDataEngineError(t *testing.T) {
+	socket, stop := startFakeEngine(t, map[string]string{})
+	defer stop()
+	oldSocket := dockerSocket
+	defer func() { dockerSocket = oldSocket }()
+	dockerSocket = socket
+
+	_, _, err := collectDockerData(context.NewMockDefault())
+
+	assert.NotNil(t, err)
+}
+
+func TestCollectDockerDataWithoutEngine(t *testing.T) {
+	oldSocket := dockerSocket
+	defer func() { dockerSocket = oldSocket }()
+	dockerSocket = filepath.Join("testdata", "missing.sock")
+
+	containers, images, err := collectDockerData(context.NewMockDefault())
+
+	assert.Nil(t, err)
+	assert.Empty(t, containers)
+	assert.Empty(t, images)
+}
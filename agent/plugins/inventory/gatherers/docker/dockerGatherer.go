@@ -0,0 +1,80 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package docker contains a gatherer for the containers and images of the local Docker engine.
+package docker
+
+import (
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+const (
+	// GathererName captures name of docker gatherer
+	GathererName = "Custom:DockerContainer"
+	// ImageTypeName captures the inventory type name of docker images
+	ImageTypeName = "Custom:DockerImage"
+	// SchemaVersionOfDockerGatherer represents schema version of docker gatherer
+	SchemaVersionOfDockerGatherer = "1.0"
+)
+
+type T struct{}
+
+// Gatherer returns new docker gatherer
+func Gatherer(context context.T) *T {
+	return new(T)
+}
+
+var collectData = collectDockerData
+
+// Name returns name of docker gatherer
+func (t *T) Name() string {
+	return GathererName
+}
+
+// Run executes docker gatherer and returns the container and image inventory items
+func (t *T) Run(context context.T, configuration model.Config) (items []model.Item, err error) {
+	//CaptureTime must comply with format: 2016-07-30T18:15:37Z to comply with regex at SSM.
+	currentTime := time.Now().UTC()
+	captureTime := currentTime.Format(time.RFC3339)
+
+	var containers []model.DockerContainerData
+	var images []model.DockerImageData
+	if containers, images, err = collectData(context); err != nil {
+		return
+	}
+
+	items = append(items,
+		model.Item{
+			Name:          GathererName,
+			SchemaVersion: SchemaVersionOfDockerGatherer,
+			Content:       containers,
+			CaptureTime:   captureTime,
+		},
+		model.Item{
+			Name:          ImageTypeName,
+			SchemaVersion: SchemaVersionOfDockerGatherer,
+			Content:       images,
+			CaptureTime:   captureTime,
+		})
+	return
+}
+
+// RequestStop stops the execution of docker gatherer.
+func (t *T) RequestStop(stopType contracts.StopType) error {
+	var err error
+	return err
+}
@@ -18,10 +18,13 @@ import (
 )
 
 type filterObj struct {
-	Path         string
-	Pattern      []string
-	Recursive    bool
-	DirScanLimit *int
+	Path               string
+	Pattern            []string
+	Recursive          bool
+	DirScanLimit       *int
+	Hash               bool
+	HashSizeLimit      *int64
+	ExtendedAttributes bool
 }
 
 type fileInfoObject struct {
@@ -173,6 +176,7 @@ func getAllMeta(log log.T, config model.Config) (data []model.FileData, err erro
 		return
 	}
 	var fileList []string
+	options := make(map[string]collectOptions)
 	for _, filter := range filterList {
 
 		var fullPath string
@@ -197,12 +201,18 @@ func getAllMeta(log log.T, config model.Config) (data []model.FileData, err erro
 				return nil, getFilesErr
 			}
 		}
+		filterOptions := newCollectOptions(filter)
+		for _, fp := range foundFiles {
+			fp = filepath.Clean(fp)
+			options[fp] = options[fp].merge(filterOptions)
+		}
 		fileList = append(fileList, foundFiles...)
 		fileList = removeDuplicatesString(fileList)
 	}
 
 	if len(fileList) > 0 {
 		data, err = getMetaDataFunc(log, fileList)
+		data = collectExtendedData(log, data, options)
 	}
 	log.Infof("Collected Files %d", len(data))
 	return
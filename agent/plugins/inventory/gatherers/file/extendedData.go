@@ -0,0 +1,204 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package file contains file gatherer.
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+// Limits to keep content hashing from competing with the workloads on the instance.
+// The per file size limit can be configured through the HashSizeLimit filter parameter
+const DefaultHashSizeLimit = 100 * 1024 * 1024
+const HashRateLimit = 20 * 1024 * 1024
+const HashTimeLimit = 5 * time.Minute
+const HashSizeLimitExceeded = "Hash Size Limit Exceeded"
+const HashTimeLimitExceeded = "Hash Time Limit Exceeded"
+const HashNotRegularFile = "Hash Not Supported"
+
+const hashChunkSize = 64 * 1024
+
+//decoupling for easy testability
+var hashRateLimit = int64(HashRateLimit)
+var hashTimeLimit = HashTimeLimit
+var lstatFunc = os.Lstat
+var statFunc = os.Stat
+var readlinkFunc = os.Readlink
+
+// collectOptions holds the optional file attributes requested for a file
+type collectOptions struct {
+	hash               bool
+	hashSizeLimit      int64
+	extendedAttributes bool
+}
+
+// newCollectOptions returns the collection options requested by the given filter
+func newCollectOptions(filter filterObj) (options collectOptions) {
+	options.hash = filter.Hash
+	options.hashSizeLimit = DefaultHashSizeLimit
+	if filter.HashSizeLimit != nil {
+		options.hashSizeLimit = *filter.HashSizeLimit
+	}
+	options.extendedAttributes = filter.ExtendedAttributes
+	return
+}
+
+// merge combines the options of all filters matching the same file
+func (options collectOptions) merge(other collectOptions) collectOptions {
+	if other.hash {
+		if !options.hash || other.hashSizeLimit > options.hashSizeLimit {
+			options.hashSizeLimit = other.hashSizeLimit
+		}
+		options.hash = true
+	}
+	options.extendedAttributes = options.extendedAttributes || other.extendedAttributes
+	return options
+}
+
+// requested returns true if any optional attribute is requested
+func (options collectOptions) requested() bool {
+	return options.hash || options.extendedAttributes
+}
+
+// collectExtendedData adds the requested content hashes and extended attributes to the collected file data.
+// Hashing of all files shares a single time budget, files not hashed within it are reported without a hash.
+func collectExtendedData(log log.T, data []model.FileData, options map[string]collectOptions) []model.FileData {
+	deadline := time.Now().Add(hashTimeLimit)
+	for i := range data {
+		path := filepath.Clean(filepath.Join(data[i].InstalledDir, data[i].Name))
+		opt, found := options[path]
+		if !found || !opt.requested() {
+			continue
+		}
+		if opt.extendedAttributes {
+			if err := addExtendedAttributes(&data[i], path); err != nil {
+				LogError(log, err)
+			}
+		}
+		if opt.hash {
+			if time.Now().After(deadline) {
+				log.Errorf("%v, skipping hash of %v", HashTimeLimitExceeded, path)
+				continue
+			}
+			sum, err := hashFile(path, opt.hashSizeLimit, deadline)
+			if err != nil {
+				LogError(log, err)
+				continue
+			}
+			data[i].Sha256 = sum
+		}
+	}
+	return data
+}
+
+// addExtendedAttributes adds mode, ownership, SELinux context and symlink target of the file at path
+func addExtendedAttributes(data *model.FileData, path string) (err error) {
+	var fi os.FileInfo
+	if fi, err = lstatFunc(path); err != nil {
+		return
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		if data.SymlinkTarget, err = readlinkFunc(path); err != nil {
+			return
+		}
+		if fi, err = statFunc(path); err != nil {
+			return
+		}
+	}
+	data.Mode = unixMode(fi.Mode())
+	data.Owner, data.Group = fileOwnership(fi)
+	data.SELinuxContext = selinuxContext(path)
+	return
+}
+
+// unixMode formats the permission bits of mode in octal notation, e.g. 0755 or 4755
+func unixMode(mode os.FileMode) string {
+	bits := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 01000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
+
+// hashFile returns the hex encoded SHA-256 of the file content, reading at most hashRateLimit bytes per second.
+// Only regular files are hashed: opening a FIFO blocks and a device such as /dev/zero never ends.
+func hashFile(path string, sizeLimit int64, deadline time.Time) (sum string, err error) {
+	var file *os.File
+	var fi os.FileInfo
+	if fi, err = statFunc(path); err != nil {
+		return
+	}
+	if !fi.Mode().IsRegular() {
+		err = fmt.Errorf("%v, %v is not a regular file", HashNotRegularFile, path)
+		return
+	}
+	if file, err = os.Open(path); err != nil {
+		return
+	}
+	defer file.Close()
+
+	// the file may have been replaced since it was checked
+	if fi, err = file.Stat(); err != nil {
+		return
+	}
+	if !fi.Mode().IsRegular() {
+		err = fmt.Errorf("%v, %v is not a regular file", HashNotRegularFile, path)
+		return
+	}
+	if fi.Size() > sizeLimit {
+		err = fmt.Errorf("%v, file %v is %d bytes, limit is %d bytes", HashSizeLimitExceeded, path, fi.Size(), sizeLimit)
+		return
+	}
+
+	hash := sha256.New()
+	buf := make([]byte, hashChunkSize)
+	start := time.Now()
+	var read int64
+	for {
+		n, readErr := file.Read(buf)
+		hash.Write(buf[:n])
+		read += int64(n)
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return "", readErr
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("%v while hashing %v", HashTimeLimitExceeded, path)
+		}
+		if hashRateLimit > 0 {
+			expected := time.Duration(read * int64(time.Second) / hashRateLimit)
+			if elapsed := time.Since(start); elapsed < expected {
+				time.Sleep(expected - elapsed)
+			}
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,99 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// sha256 of "hello world"
+const helloWorldSha256 = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+
+func createHashTestFile(t *testing.T) (dir string, data model.FileData) {
+	dir, err := ioutil.TempDir("", "filegatherer")
+	assert.Nil(t, err)
+	err = ioutil.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello world"), 0644)
+	assert.Nil(t, err)
+	data = model.FileData{Name: "hello.txt", InstalledDir: dir}
+	return
+}
+
+func TestCollectExtendedDataHash(t *testing.T) {
+	dir, data := createHashTestFile(t)
+	defer os.RemoveAll(dir)
+
+	options := map[string]collectOptions{
+		filepath.Join(dir, "hello.txt"): newCollectOptions(filterObj{Hash: true}),
+	}
+	result := collectExtendedData(log.NewMockLog(), []model.FileData{data}, options)
+	assert.Equal(t, helloWorldSha256, result[0].Sha256)
+	assert.Equal(t, "", result[0].Mode)
+}
+
+func TestCollectExtendedDataNotRequested(t *testing.T) {
+	dir, data := createHashTestFile(t)
+	defer os.RemoveAll(dir)
+
+	result := collectExtendedData(log.NewMockLog(), []model.FileData{data}, map[string]collectOptions{})
+	assert.Equal(t, "", result[0].Sha256)
+}
+
+func TestCollectExtendedDataHashSizeLimit(t *testing.T) {
+	dir, data := createHashTestFile(t)
+	defer os.RemoveAll(dir)
+
+	limit := int64(5)
+	options := map[string]collectOptions{
+		filepath.Join(dir, "hello.txt"): newCollectOptions(filterObj{Hash: true, HashSizeLimit: &limit}),
+	}
+	result := collectExtendedData(log.NewMockLog(), []model.FileData{data}, options)
+	assert.Equal(t, "", result[0].Sha256)
+}
+
+func TestCollectExtendedDataHashTimeLimit(t *testing.T) {
+	dir, data := createHashTestFile(t)
+	defer os.RemoveAll(dir)
+	defer func() { hashTimeLimit = HashTimeLimit }()
+	hashTimeLimit = -time.Second
+
+	options := map[string]collectOptions{
+		filepath.Join(dir, "hello.txt"): newCollectOptions(filterObj{Hash: true}),
+	}
+	result := collectExtendedData(log.NewMockLog(), []model.FileData{data}, options)
+	assert.Equal(t, "", result[0].Sha256)
+}
+
+func TestHashFileRateLimit(t *testing.T) {
+	dir, data := createHashTestFile(t)
+	defer os.RemoveAll(dir)
+	defer func() { hashRateLimit = HashRateLimit }()
+	hashRateLimit = 22
+
+	start := time.Now()
+	sum, err := hashFile(filepath.Join(data.InstalledDir, data.Name), DefaultHashSizeLimit, time.Now().Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, helloWorldSha256, sum)
+	assert.True(t, time.Since(start) >= 400*time.Millisecond, "reading 11 bytes at 22 bytes per second takes half a second")
+}
+
+func TestMergeCollectOptions(t *testing.T) {
+	small, large := int64(10), int64(1000)
+	options := collectOptions{}.merge(newCollectOptions(filterObj{Hash: true, HashSizeLimit: &small}))
+	options = options.merge(newCollectOptions(filterObj{Hash: true, HashSizeLimit: &large}))
+	options = options.merge(newCollectOptions(filterObj{ExtendedAttributes: true}))
+	assert.True(t, options.hash)
+	assert.True(t, options.extendedAttributes)
+	assert.Equal(t, large, options.hashSizeLimit)
+}
+
+func TestUnixMode(t *testing.T) {
+	assert.Equal(t, "0644", unixMode(0644))
+	assert.Equal(t, "4755", unixMode(os.ModeSetuid|0755))
+	assert.Equal(t, "1777", unixMode(os.ModeSticky|os.ModeDir|0777))
+}
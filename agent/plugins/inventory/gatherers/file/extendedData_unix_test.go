@@ -0,0 +1,68 @@
+// +build darwin freebsd linux netbsd openbsd
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectExtendedDataAttributes(t *testing.T) {
+	dir, data := createHashTestFile(t)
+	defer os.RemoveAll(dir)
+	link := filepath.Join(dir, "hello.lnk")
+	assert.Nil(t, os.Symlink(filepath.Join(dir, "hello.txt"), link))
+	assert.Nil(t, os.Chmod(filepath.Join(dir, "hello.txt"), 0640))
+
+	linkData := model.FileData{Name: "hello.lnk", InstalledDir: dir}
+	options := map[string]collectOptions{
+		filepath.Join(dir, "hello.txt"): newCollectOptions(filterObj{ExtendedAttributes: true}),
+		link: newCollectOptions(filterObj{ExtendedAttributes: true, Hash: true}),
+	}
+	result := collectExtendedData(log.NewMockLog(), []model.FileData{data, linkData}, options)
+
+	assert.Equal(t, "0640", result[0].Mode)
+	assert.NotEqual(t, "", result[0].Owner)
+	assert.NotEqual(t, "", result[0].Group)
+	assert.Equal(t, "", result[0].SymlinkTarget)
+	assert.Equal(t, "", result[0].Sha256)
+
+	assert.Equal(t, "0640", result[1].Mode)
+	assert.Equal(t, filepath.Join(dir, "hello.txt"), result[1].SymlinkTarget)
+	assert.Equal(t, helloWorldSha256, result[1].Sha256)
+}
+
+func TestCollectExtendedDataSkipsHashOfSpecialFiles(t *testing.T) {
+	dir, data := createHashTestFile(t)
+	defer os.RemoveAll(dir)
+	fifo := filepath.Join(dir, "hello.fifo")
+	assert.Nil(t, syscall.Mkfifo(fifo, 0644))
+
+	fifoData := model.FileData{Name: "hello.fifo", InstalledDir: dir}
+	deviceData := model.FileData{Name: "zero", InstalledDir: "/dev"}
+	options := map[string]collectOptions{
+		fifo:                            newCollectOptions(filterObj{Hash: true}),
+		"/dev/zero":                     newCollectOptions(filterObj{Hash: true}),
+		filepath.Join(dir, "hello.txt"): newCollectOptions(filterObj{Hash: true}),
+	}
+
+	done := make(chan []model.FileData)
+	go func() {
+		done <- collectExtendedData(log.NewMockLog(), []model.FileData{fifoData, deviceData, data}, options)
+	}()
+	select {
+	case result := <-done:
+		assert.Equal(t, "", result[0].Sha256)
+		assert.Equal(t, "", result[1].Sha256)
+		assert.Equal(t, helloWorldSha256, result[2].Sha256)
+	case <-time.After(10 * time.Second):
+		t.Fatal("hashing a special file blocked")
+	}
+}
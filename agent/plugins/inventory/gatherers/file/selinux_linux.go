@@ -0,0 +1,40 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+// Package file contains file gatherer.
+package file
+
+import (
+	"strings"
+	"syscall"
+)
+
+const selinuxXattr = "security.selinux"
+
+// selinuxContext returns the SELinux security context of the file, or empty string if SELinux labels are not in use
+func selinuxContext(path string) string {
+	buf := make([]byte, 256)
+	n, err := syscall.Getxattr(path, selinuxXattr, buf)
+	if err == syscall.ERANGE {
+		if n, err = syscall.Getxattr(path, selinuxXattr, nil); err == nil {
+			buf = make([]byte, n)
+			n, err = syscall.Getxattr(path, selinuxXattr, buf)
+		}
+	}
+	if err != nil || n <= 0 {
+		return ""
+	}
+	return strings.TrimRight(string(buf[:n]), "\x00")
+}
@@ -0,0 +1,186 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kernel
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+var (
+	procRoot      = "/proc"
+	sysModuleRoot = "/sys/module"
+)
+
+// defaultParameterNames lists the kernel parameters collected when no allowlist is configured
+var defaultParameterNames = []string{
+	"kernel.randomize_va_space",
+	"kernel.kptr_restrict",
+	"kernel.dmesg_restrict",
+	"kernel.yama.ptrace_scope",
+	"kernel.unprivileged_bpf_disabled",
+	"kernel.modules_disabled",
+	"fs.protected_hardlinks",
+	"fs.protected_symlinks",
+	"fs.suid_dumpable",
+	"net.ipv4.ip_forward",
+	"net.ipv4.tcp_syncookies",
+	"net.ipv4.conf.all.accept_redirects",
+	"net.ipv4.conf.all.send_redirects",
+	"net.ipv4.conf.all.rp_filter",
+	"net.ipv6.conf.all.accept_redirects",
+	"net.ipv6.conf.all.forwarding",
+}
+
+var parameterNameRegex = regexp.MustCompile(`^[A-Za-z0-9_\-]+([./][A-Za-z0-9_\-]+)*$`)
+
+// parseParameterFilters returns the kernel parameters to collect. Filters is a json array of parameter
+// names written either in sysctl form (net.ipv4.ip_forward) or as a path below /proc/sys (net/ipv4/ip_forward).
+func parseParameterFilters(filters string) (names []string, err error) {
+	if strings.TrimSpace(filters) == "" {
+		return defaultParameterNames, nil
+	}
+	if err = json.Unmarshal([]byte(filters), &names); err != nil {
+		return nil, fmt.Errorf("kernel parameter filters must be a json array of parameter names: %v", err)
+	}
+	for _, name := range names {
+		if !parameterNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid kernel parameter name %q", name)
+		}
+	}
+	return
+}
+
+// parameterPath returns the location of a kernel parameter below /proc/sys
+func parameterPath(name string) string {
+	if !strings.Contains(name, "/") {
+		name = strings.Replace(name, ".", "/", -1)
+	}
+	return filepath.Join(procRoot, "sys", filepath.FromSlash(name))
+}
+
+// readProcValue reads a single value file and collapses its whitespace
+func readProcValue(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(string(content)), " "), nil
+}
+
+// collectKernelInfoData reads the running kernel's name, release and version
+func collectKernelInfoData(context context.T) (data model.KernelInfoData, err error) {
+	log := context.Log()
+
+	if data.FullVersion, err = readProcValue(filepath.Join(procRoot, "version")); err != nil {
+		return data, fmt.Errorf("unable to read kernel version: %v", err)
+	}
+
+	values := map[string]*string{
+		"ostype":    &data.Name,
+		"osrelease": &data.Release,
+		"version":   &data.Version,
+		"tainted":   &data.Tainted,
+	}
+	for name, value := range values {
+		if *value, err = readProcValue(filepath.Join(procRoot, "sys", "kernel", name)); err != nil {
+			log.Debugf("Unable to read kernel %v: %v", name, err)
+			err = nil
+		}
+	}
+
+	// /proc/version reads "Linux version <release> (<builder>) <version>"
+	if fields := strings.Fields(data.FullVersion); len(fields) >= 3 {
+		if data.Name == "" {
+			data.Name = fields[0]
+		}
+		if data.Release == "" {
+			data.Release = fields[2]
+		}
+	}
+	return
+}
+
+// collectKernelModuleData parses /proc/modules, whose lines look like
+// "nf_nat 36864 2 nf_nat_ipv4,xt_nat, Live 0xffffffffc0573000"
+func collectKernelModuleData(context context.T) (data []model.KernelModuleData, err error) {
+	log := context.Log()
+	data = []model.KernelModuleData{}
+
+	file, err := os.Open(filepath.Join(procRoot, "modules"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			// kernels without loadable module support have no /proc/modules
+			log.Debugf("No loaded kernel modules: %v", err)
+			return data, nil
+		}
+		return nil, fmt.Errorf("unable to read kernel modules: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		usedBy := strings.TrimSuffix(fields[3], ",")
+		if usedBy == "-" {
+			usedBy = ""
+		}
+		module := model.KernelModuleData{
+			Name:      fields[0],
+			Size:      fields[1],
+			Instances: fields[2],
+			UsedBy:    usedBy,
+			State:     fields[4],
+		}
+		if version, verr := readProcValue(filepath.Join(sysModuleRoot, module.Name, "version")); verr == nil {
+			module.Version = version
+		}
+		data = append(data, module)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read kernel modules: %v", err)
+	}
+	return
+}
+
+// collectKernelParameterData reads the given kernel parameters, skipping the ones that do not exist or can't be read
+func collectKernelParameterData(context context.T, names []string) (data []model.KernelParameterData) {
+	log := context.Log()
+	data = []model.KernelParameterData{}
+
+	for _, name := range names {
+		value, err := readProcValue(parameterPath(name))
+		if err != nil {
+			log.Debugf("Skipping kernel parameter %v: %v", name, err)
+			continue
+		}
+		data = append(data, model.KernelParameterData{
+			Name:  strings.Replace(name, "/", ".", -1),
+			Value: value,
+		})
+	}
+	return
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kernel
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func useTestData(t *testing.T) func() {
+	oldProcRoot, oldSysModuleRoot := procRoot, sysModuleRoot
+	procRoot = filepath.Join("testdata", "proc")
+	sysModuleRoot = filepath.Join("testdata", "sys", "module")
+	return func() {
+		procRoot, sysModuleRoot = oldProcRoot, oldSysModuleRoot
+	}
+}
+
+func TestCollectKernelInfoData(t *testing.T) {
+	defer useTestData(t)()
+
+	data, err := collectKernelInfoData(context.NewMockDefault())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Linux", data.Name)
+	assert.Equal(t, "4.14.62-70.117.amzn2.x86_64", data.Release)
+	assert.Equal(t, "#1 SMP Fri Aug 10 20:14:53 UTC 2018", data.Version)
+	assert.Equal(t, "0", data.Tainted)
+	assert.Contains(t, data.FullVersion, "Linux version 4.14.62-70.117.amzn2.x86_64")
+}
+
+func TestCollectKernelInfoDataMissingProc(t *testing.T) {
+	defer useTestData(t)()
+	procRoot = filepath.Join("testdata", "missing")
+
+	_, err := collectKernelInfoData(context.NewMockDefault())
+
+	assert.NotNil(t, err)
+}
+
+func TestCollectKernelModuleData(t *testing.T) {
+	defer useTestData(t)()
+
+	data, err := collectKernelModuleData(context.NewMockDefault())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []model.KernelModuleData{
+		{Name: "nf_nat_ipv4", Size: "16384", Instances: "1", UsedBy: "iptable_nat", State: "Live"},
+		{Name: "nf_conntrack", Size: "135168", Instances: "4", UsedBy: "nf_nat_ipv4,nf_nat,xt_conntrack,nf_conntrack_ipv4", State: "Live", Version: "e2f1b3a"},
+		{Name: "crc32_pclmul", Size: "16384", Instances: "0", UsedBy: "", State: "Live"},
+	}, data)
+}
+
+func TestCollectKernelParameterData(t *testing.T) {
+	defer useTestData(t)()
+
+	data := collectKernelParameterData(context.NewMockDefault(),
+		[]string{"kernel.randomize_va_space", "kernel.printk", "net/ipv4/conf/all/rp_filter", "kernel.does_not_exist"})
+
+	assert.Equal(t, []model.KernelParameterData{
+		{Name: "kernel.randomize_va_space", Value: "2"},
+		{Name: "kernel.printk", Value: "4 4 1 7"},
+		{Name: "net.ipv4.conf.all.rp_filter", Value: "1"},
+	}, data)
+}
+
+func TestParseParameterFilters(t *testing.T) {
+	names, err := parseParameterFilters("")
+	assert.Nil(t, err)
+	assert.Equal(t, defaultParameterNames, names)
+
+	names, err = parseParameterFilters(`["net.ipv4.ip_forward", "net/ipv4/conf/eth0.1/rp_filter"]`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"net.ipv4.ip_forward", "net/ipv4/conf/eth0.1/rp_filter"}, names)
+
+	for _, filters := range []string{`net.ipv4.ip_forward`, `["../../etc/shadow"]`, `["/etc/shadow"]`, `[""]`} {
+		_, err = parseParameterFilters(filters)
+		assert.NotNil(t, err, filters)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package kernel contains a gatherer for the running kernel, its loaded modules and selected kernel parameters.
+package kernel
+
+import (
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+const (
+	// GathererName captures name of kernel gatherer
+	GathererName = "Custom:KernelInfo"
+	// ModuleTypeName captures the inventory type name of loaded kernel modules
+	ModuleTypeName = "Custom:KernelModule"
+	// ParameterTypeName captures the inventory type name of kernel parameters
+	ParameterTypeName = "Custom:KernelParameter"
+	// SchemaVersionOfKernelGatherer represents schema version of kernel gatherer
+	SchemaVersionOfKernelGatherer = "1.0"
+)
+
+type T struct{}
+
+// Gatherer returns new kernel gatherer
+func Gatherer(context context.T) *T {
+	return new(T)
+}
+
+var (
+	collectKernelInfo       = collectKernelInfoData
+	collectKernelModules    = collectKernelModuleData
+	collectKernelParameters = collectKernelParameterData
+)
+
+// Name returns name of kernel gatherer
+func (t *T) Name() string {
+	return GathererName
+}
+
+// Run executes kernel gatherer and returns the kernel, kernel module and kernel parameter inventory items.
+// The kernel parameters collected are the ones listed in the Filters of the configuration, or a default
+// set of security relevant parameters when no filters are given.
+func (t *T) Run(context context.T, configuration model.Config) (items []model.Item, err error) {
+	//CaptureTime must comply with format: 2016-07-30T18:15:37Z to comply with regex at SSM.
+	currentTime := time.Now().UTC()
+	captureTime := currentTime.Format(time.RFC3339)
+
+	var parameterNames []string
+	if parameterNames, err = parseParameterFilters(configuration.Filters); err != nil {
+		return
+	}
+
+	var info model.KernelInfoData
+	if info, err = collectKernelInfo(context); err != nil {
+		return
+	}
+
+	var modules []model.KernelModuleData
+	if modules, err = collectKernelModules(context); err != nil {
+		return
+	}
+
+	parameters := collectKernelParameters(context, parameterNames)
+
+	items = append(items,
+		model.Item{
+			Name:          GathererName,
+			SchemaVersion: SchemaVersionOfKernelGatherer,
+			Content:       []model.KernelInfoData{info},
+			CaptureTime:   captureTime,
+		},
+		model.Item{
+			Name:          ModuleTypeName,
+			SchemaVersion: SchemaVersionOfKernelGatherer,
+			Content:       modules,
+			CaptureTime:   captureTime,
+		},
+		model.Item{
+			Name:          ParameterTypeName,
+			SchemaVersion: SchemaVersionOfKernelGatherer,
+			Content:       parameters,
+			CaptureTime:   captureTime,
+		})
+	return
+}
+
+// RequestStop stops the execution of kernel gatherer.
+func (t *T) RequestStop(stopType contracts.StopType) error {
+	var err error
+	return err
+}
@@ -0,0 +1,64 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kernel
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var testKernelInfo = model.KernelInfoData{
+	Name:        "Linux",
+	Release:     "4.14.62-70.117.amzn2.x86_64",
+	Version:     "#1 SMP Fri Aug 10 20:14:53 UTC 2018",
+	FullVersion: "Linux version 4.14.62-70.117.amzn2.x86_64",
+}
+
+var testKernelModules = []model.KernelModuleData{
+	{Name: "xfs", Size: "1200128", Instances: "1", State: "Live"},
+}
+
+func TestGatherer(t *testing.T) {
+	contextMock := context.NewMockDefault()
+	gatherer := Gatherer(contextMock)
+	var requested []string
+	collectKernelInfo = func(context context.T) (model.KernelInfoData, error) {
+		return testKernelInfo, nil
+	}
+	collectKernelModules = func(context context.T) ([]model.KernelModuleData, error) {
+		return testKernelModules, nil
+	}
+	collectKernelParameters = func(context context.T, names []string) []model.KernelParameterData {
+		requested = names
+		return []model.KernelParameterData{{Name: "net.ipv4.ip_forward", Value: "0"}}
+	}
+
+	items, err := gatherer.Run(contextMock, model.Config{Collection: model.Enabled, Filters: `["net.ipv4.ip_forward"]`})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(items))
+	assert.Equal(t, GathererName, items[0].Name)
+	assert.Equal(t, []model.KernelInfoData{testKernelInfo}, items[0].Content)
+	assert.Equal(t, ModuleTypeName, items[1].Name)
+	assert.Equal(t, testKernelModules, items[1].Content)
+	assert.Equal(t, ParameterTypeName, items[2].Name)
+	assert.Equal(t, SchemaVersionOfKernelGatherer, items[2].SchemaVersion)
+	assert.Equal(t, []string{"net.ipv4.ip_forward"}, requested)
+
+	_, err = gatherer.Run(contextMock, model.Config{Collection: model.Enabled, Filters: `net.ipv4.ip_forward`})
+	assert.NotNil(t, err)
+}
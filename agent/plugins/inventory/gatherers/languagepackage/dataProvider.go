@@ -0,0 +1,269 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package languagepackage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+const (
+	// PackageManagerPip identifies python packages installed with pip
+	PackageManagerPip = "pip"
+	// PackageManagerNpm identifies globally installed node packages
+	PackageManagerNpm = "npm"
+	// PackageManagerGem identifies ruby gems
+	PackageManagerGem = "gem"
+	// PackageManagerSnap identifies snaps
+	PackageManagerSnap = "snap"
+	// PackageManagerGoModule identifies Go modules compiled into binaries
+	PackageManagerGoModule = "gomodule"
+)
+
+// packageProvider lists the packages of one package manager. commands are the alternative executables
+// of the package manager, the first one found on the path is used. Providers without commands read the
+// packages from the file system.
+type packageProvider struct {
+	commands []string
+	collect  func(context context.T, command string) ([]model.LanguagePackageData, error)
+}
+
+// packageProviders holds the supported package managers, new package sources are added here
+var packageProviders = map[string]packageProvider{
+	PackageManagerPip:      {commands: []string{"pip3", "pip"}, collect: collectPipPackages},
+	PackageManagerNpm:      {commands: []string{"npm"}, collect: collectNpmPackages},
+	PackageManagerGem:      {commands: []string{"gem"}, collect: collectGemPackages},
+	PackageManagerSnap:     {commands: []string{"snap"}, collect: collectSnapPackages},
+	PackageManagerGoModule: {collect: collectGoModules},
+}
+
+// goBinaryDirectories are searched for Go binaries whose embedded module information is reported
+var goBinaryDirectories = []string{"/usr/local/bin", "/usr/local/sbin", "/usr/bin", "/usr/sbin"}
+
+// decoupling exec for easy testability
+var (
+	cmdExecutor       = executeCommand
+	lookPath          = exec.LookPath
+	goBuildInfoReader = readGoBuildInfo
+)
+
+func executeCommand(command string, args ...string) ([]byte, error) {
+	return exec.Command(command, args...).Output()
+}
+
+// collectLanguagePackageData runs the package managers enabled by the filters of the configuration.
+// Package managers that aren't installed are skipped.
+func collectLanguagePackageData(context context.T, config model.Config) (data []model.LanguagePackageData, err error) {
+	log := context.Log()
+
+	var managers []string
+	if managers, err = parseFilters(config.Filters); err != nil {
+		return
+	}
+
+	data = []model.LanguagePackageData{}
+	for _, manager := range managers {
+		provider := packageProviders[manager]
+		command, found := findCommand(provider.commands)
+		if !found {
+			log.Debugf("Package manager %v is not installed, skipping", manager)
+			continue
+		}
+
+		packages, perr := provider.collect(context, command)
+		if perr != nil {
+			log.Errorf("Unable to list %v packages: %v", manager, perr)
+			continue
+		}
+		log.Infof("Number of %v packages detected - %v", manager, len(packages))
+		for i := range packages {
+			packages[i].PackageManager = manager
+		}
+		data = append(data, packages...)
+	}
+	return
+}
+
+// parseFilters returns the package managers listed in the filters, a json array such as ["pip", "npm"]
+func parseFilters(filters string) (managers []string, err error) {
+	if err = json.Unmarshal([]byte(filters), &managers); err != nil {
+		return nil, fmt.Errorf("language package filters must be a json array of package managers: %v", err)
+	}
+	for i, manager := range managers {
+		managers[i] = strings.ToLower(strings.TrimSpace(manager))
+		if _, found := packageProviders[managers[i]]; !found {
+			return nil, fmt.Errorf("unsupported package manager %q", manager)
+		}
+	}
+	return
+}
+
+func findCommand(commands []string) (string, bool) {
+	if len(commands) == 0 {
+		return "", true
+	}
+	for _, command := range commands {
+		if path, err := lookPath(command); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// collectPipPackages parses the output of "pip list --format=json", e.g. [{"name": "requests", "version": "2.19.1"}]
+func collectPipPackages(context context.T, command string) (data []model.LanguagePackageData, err error) {
+	var output []byte
+	if output, err = cmdExecutor(command, "list", "--format=json", "--disable-pip-version-check"); err != nil {
+		return
+	}
+
+	var packages []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err = json.Unmarshal(output, &packages); err != nil {
+		return
+	}
+	for _, p := range packages {
+		data = append(data, model.LanguagePackageData{Name: p.Name, Version: p.Version})
+	}
+	return
+}
+
+// collectNpmPackages parses the output of "npm ls --global --json --depth=0"
+func collectNpmPackages(context context.T, command string) (data []model.LanguagePackageData, err error) {
+	// npm ls exits with an error for missing peer dependencies but still prints the tree
+	output, cmdErr := cmdExecutor(command, "ls", "--global", "--json", "--depth=0")
+
+	var tree struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err = json.Unmarshal(output, &tree); err != nil {
+		if cmdErr != nil {
+			err = cmdErr
+		}
+		return
+	}
+
+	var root string
+	if rootOutput, rootErr := cmdExecutor(command, "root", "--global"); rootErr == nil {
+		root = strings.TrimSpace(string(rootOutput))
+	}
+	for name, dependency := range tree.Dependencies {
+		data = append(data, model.LanguagePackageData{Name: name, Version: dependency.Version, Location: root})
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Name < data[j].Name })
+	return
+}
+
+// gemListRegex matches lines of "gem list --local" such as "json (default: 2.1.0)" or "rake (12.3.1, 10.5.0)"
+var gemListRegex = regexp.MustCompile(`^(\S+) \((.*)\)$`)
+
+// collectGemPackages parses the output of "gem list --local", reporting each installed version separately
+func collectGemPackages(context context.T, command string) (data []model.LanguagePackageData, err error) {
+	var output []byte
+	if output, err = cmdExecutor(command, "list", "--local"); err != nil {
+		return
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		match := gemListRegex.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if match == nil {
+			continue
+		}
+		for _, version := range strings.Split(match[2], ",") {
+			version = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(version), "default:"))
+			// platform specific gems are listed as "1.8.2 x86_64-linux"
+			if fields := strings.Fields(version); len(fields) > 0 {
+				data = append(data, model.LanguagePackageData{Name: match[1], Version: fields[0]})
+			}
+		}
+	}
+	return data, scanner.Err()
+}
+
+// collectSnapPackages parses the table printed by "snap list"
+func collectSnapPackages(context context.T, command string) (data []model.LanguagePackageData, err error) {
+	var output []byte
+	if output, err = cmdExecutor(command, "list"); err != nil {
+		return
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	// skip header: Name  Version  Rev  Tracking  Publisher  Notes
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		data = append(data, model.LanguagePackageData{Name: fields[0], Version: fields[1]})
+	}
+	return data, scanner.Err()
+}
+
+// collectGoModules reads the modules compiled into the Go binaries of goBinaryDirectories from their build
+// information, without the Go toolchain. The module information has the format printed by "go version -m":
+//
+//	path	example.com/tool
+//	mod	example.com/tool	v1.2.0	h1:...
+//	dep	github.com/pkg/errors	v0.8.0	h1:...
+func collectGoModules(context context.T, command string) (data []model.LanguagePackageData, err error) {
+	log := context.Log()
+
+	for _, dir := range goBinaryDirectories {
+		files, dirErr := ioutil.ReadDir(dir)
+		if dirErr != nil {
+			log.Debugf("Unable to list Go binaries in %v: %v", dir, dirErr)
+			continue
+		}
+
+		for _, file := range files {
+			if !file.Mode().IsRegular() || file.Mode().Perm()&0111 == 0 {
+				continue
+			}
+			binary := filepath.Join(dir, file.Name())
+			_, modInfo, readErr := goBuildInfoReader(binary)
+			if readErr != nil {
+				if readErr != errNotGoBinary {
+					log.Debugf("Unable to read Go modules of %v: %v", binary, readErr)
+				}
+				continue
+			}
+
+			scanner := bufio.NewScanner(strings.NewReader(modInfo))
+			for scanner.Scan() {
+				fields := strings.Fields(scanner.Text())
+				if len(fields) < 3 || (fields[0] != "mod" && fields[0] != "dep") || fields[2] == "(devel)" {
+					continue
+				}
+				data = append(data, model.LanguagePackageData{Name: fields[1], Version: fields[2], Location: binary})
+			}
+		}
+	}
+	return
+}
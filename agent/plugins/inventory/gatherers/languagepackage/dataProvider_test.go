@@ -0,0 +1,196 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package languagepackage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	samplePipOutput = `[{"name": "pip", "version": "9.0.3"}, {"name": "requests", "version": "2.19.1"}]`
+	sampleNpmOutput = `{
+  "dependencies": {
+    "yarn": {"version": "1.9.4", "from": "yarn", "resolved": "https://registry.npmjs.org/yarn/-/yarn-1.9.4.tgz"},
+    "npm": {"version": "6.2.0"}
+  }
+}`
+	sampleGemOutput = `
+*** LOCAL GEMS ***
+
+bigdecimal (default: 1.3.4)
+json (default: 2.1.0, 1.8.6)
+nokogiri (1.8.4 x86_64-linux)
+rake (12.3.1, 10.5.0)
+`
+	sampleSnapOutput = `Name            Version    Rev   Tracking  Publisher   Notes
+amazon-ssm-agent 2.3.68.0  734   stable/…  aws✓        classic
+core            16-2.35    5328  stable    canonical✓  core
+`
+	sampleGoModInfo = `path	example.com/tool
+mod	example.com/tool	v1.2.0	h1:aaaa=
+dep	github.com/pkg/errors	v0.8.0	h1:bbbb=
+`
+	sampleGoDevelModInfo = `path	example.com/dev
+mod	example.com/dev	(devel)
+`
+)
+
+func mockCommands(outputs map[string]string, installed ...string) func() {
+	oldExecutor, oldLookPath := cmdExecutor, lookPath
+	cmdExecutor = func(command string, args ...string) ([]byte, error) {
+		key := strings.Join(append([]string{command}, args...), " ")
+		if output, found := outputs[key]; found {
+			return []byte(output), nil
+		}
+		return nil, fmt.Errorf("unexpected command %v", key)
+	}
+	lookPath = func(command string) (string, error) {
+		for _, c := range installed {
+			if c == command {
+				return command, nil
+			}
+		}
+		return "", fmt.Errorf("%v not found", command)
+	}
+	return func() {
+		cmdExecutor, lookPath = oldExecutor, oldLookPath
+	}
+}
+
+func TestCollectLanguagePackageData(t *testing.T) {
+	defer mockCommands(map[string]string{
+		"pip list --format=json --disable-pip-version-check": samplePipOutput,
+		"gem list --local": sampleGemOutput,
+	}, "pip", "gem")()
+
+	data, err := collectLanguagePackageData(context.NewMockDefault(), model.Config{Filters: `["pip", "npm", "Gem"]`})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []model.LanguagePackageData{
+		{Name: "pip", Version: "9.0.3", PackageManager: "pip"},
+		{Name: "requests", Version: "2.19.1", PackageManager: "pip"},
+		{Name: "bigdecimal", Version: "1.3.4", PackageManager: "gem"},
+		{Name: "json", Version: "2.1.0", PackageManager: "gem"},
+		{Name: "json", Version: "1.8.6", PackageManager: "gem"},
+		{Name: "nokogiri", Version: "1.8.4", PackageManager: "gem"},
+		{Name: "rake", Version: "12.3.1", PackageManager: "gem"},
+		{Name: "rake", Version: "10.5.0", PackageManager: "gem"},
+	}, data)
+}
+
+func TestCollectLanguagePackageDataInvalidFilters(t *testing.T) {
+	for _, filters := range []string{`pip`, `["pip", "cargo"]`} {
+		_, err := collectLanguagePackageData(context.NewMockDefault(), model.Config{Filters: filters})
+		assert.NotNil(t, err, filters)
+	}
+}
+
+func TestCollectLanguagePackageDataFailingPackageManager(t *testing.T) {
+	defer mockCommands(map[string]string{}, "pip3")()
+
+	data, err := collectLanguagePackageData(context.NewMockDefault(), model.Config{Filters: `["pip"]`})
+
+	assert.Nil(t, err)
+	assert.Empty(t, data)
+}
+
+func TestCollectNpmPackages(t *testing.T) {
+	defer mockCommands(map[string]string{
+		"npm ls --global --json --depth=0": sampleNpmOutput,
+		"npm root --global":                "/usr/lib/node_modules\n",
+	}, "npm")()
+
+	data, err := collectNpmPackages(context.NewMockDefault(), "npm")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []model.LanguagePackageData{
+		{Name: "npm", Version: "6.2.0", Location: "/usr/lib/node_modules"},
+		{Name: "yarn", Version: "1.9.4", Location: "/usr/lib/node_modules"},
+	}, data)
+}
+
+func TestCollectSnapPackages(t *testing.T) {
+	defer mockCommands(map[string]string{"snap list": sampleSnapOutput}, "snap")()
+
+	data, err := collectSnapPackages(context.NewMockDefault(), "snap")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []model.LanguagePackageData{
+		{Name: "amazon-ssm-agent", Version: "2.3.68.0"},
+		{Name: "core", Version: "16-2.35"},
+	}, data)
+}
+
+func TestCollectGoModules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gobinaries")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	for name, mode := range map[string]os.FileMode{"tool": 0755, "dev": 0755, "script": 0755, "readme": 0644} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte{}, mode))
+	}
+
+	oldDirectories, oldReader := goBinaryDirectories, goBuildInfoReader
+	defer func() { goBinaryDirectories, goBuildInfoReader = oldDirectories, oldReader }()
+	goBinaryDirectories = []string{dir, filepath.Join(dir, "missing")}
+	goBuildInfoReader = func(path string) (string, string, error) {
+		switch filepath.Base(path) {
+		case "tool":
+			return "go1.11", sampleGoModInfo, nil
+		case "dev":
+			return "go1.11", sampleGoDevelModInfo, nil
+		case "readme":
+			assert.Fail(t, "files which are not executable are not read")
+		}
+		return "", "", errNotGoBinary
+	}
+
+	data, err := collectGoModules(context.NewMockDefault(), "")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []model.LanguagePackageData{
+		{Name: "example.com/tool", Version: "v1.2.0", Location: filepath.Join(dir, "tool")},
+		{Name: "github.com/pkg/errors", Version: "v0.8.0", Location: filepath.Join(dir, "tool")},
+	}, data)
+}
+
+func TestCollectLanguagePackageDataGoModulesWithoutToolchain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gobinaries")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "tool"), []byte{}, 0755))
+
+	// no package manager is installed
+	defer mockCommands(map[string]string{})()
+	oldDirectories, oldReader := goBinaryDirectories, goBuildInfoReader
+	defer func() { goBinaryDirectories, goBuildInfoReader = oldDirectories, oldReader }()
+	goBinaryDirectories = []string{dir}
+	goBuildInfoReader = func(path string) (string, string, error) {
+		return "go1.11", sampleGoModInfo, nil
+	}
+
+	data, err := collectLanguagePackageData(context.NewMockDefault(), model.Config{Filters: `["gomodule"]`})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(data))
+	assert.Equal(t, PackageManagerGoModule, data[0].PackageManager)
+}
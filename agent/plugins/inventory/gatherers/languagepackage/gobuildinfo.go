@@ -0,0 +1,252 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package languagepackage
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// The Go linker embeds the build information of a binary, including the modules it is built from, in a data
+// section starting with buildInfoMagic. readGoBuildInfo reads it like "go version -m" does, so Go modules are
+// reported on instances without a Go toolchain.
+const (
+	buildInfoAlign      = 16
+	buildInfoHeaderSize = 32
+	// maxBuildInfoSearch bounds how far into the data section the build information is searched
+	maxBuildInfoSearch = 64 * 1024
+	// buildInfoFlagsInline is set by Go 1.18 and later, which store the strings right after the header
+	buildInfoFlagsInline = 0x2
+	// modInfoSentinelLength is the length of the markers enclosing the module information
+	modInfoSentinelLength = 16
+)
+
+var (
+	buildInfoMagic = []byte("\xff Go buildinf:")
+	errNotGoBinary = errors.New("not a Go binary")
+)
+
+// executable gives access to the memory image of a binary
+type executable interface {
+	// readData reads up to size bytes at the virtual address addr
+	readData(addr, size uint64) ([]byte, error)
+	// dataStart returns the virtual address of the section holding the build information
+	dataStart() uint64
+	Close() error
+}
+
+// readGoBuildInfo returns the Go version and the module information embedded in the binary at path,
+// errNotGoBinary is returned for other files.
+func readGoBuildInfo(path string) (goVersion string, modInfo string, err error) {
+	var exe executable
+	if exe, err = openExecutable(path); err != nil {
+		return
+	}
+	defer exe.Close()
+	return parseGoBuildInfo(exe)
+}
+
+// openExecutable opens ELF and Mach-O binaries, the formats of the binaries in goBinaryDirectories
+func openExecutable(path string) (executable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 4)
+	if _, err = file.ReadAt(header, 0); err != nil {
+		file.Close()
+		return nil, errNotGoBinary
+	}
+
+	switch {
+	case bytes.Equal(header, []byte(elf.ELFMAG)):
+		var f *elf.File
+		if f, err = elf.NewFile(file); err == nil {
+			return &elfExecutable{file: file, f: f}, nil
+		}
+	case bytes.Equal(header[:3], []byte("\xfe\xed\xfa")) || bytes.Equal(header[1:], []byte("\xfa\xed\xfe")):
+		var f *macho.File
+		if f, err = macho.NewFile(file); err == nil {
+			return &machoExecutable{file: file, f: f}, nil
+		}
+	}
+	file.Close()
+	return nil, errNotGoBinary
+}
+
+// parseGoBuildInfo locates the build information header in the data section and reads the strings it refers to
+func parseGoBuildInfo(exe executable) (goVersion string, modInfo string, err error) {
+	var data []byte
+	if data, err = exe.readData(exe.dataStart(), maxBuildInfoSearch); err != nil {
+		return
+	}
+	for {
+		i := bytes.Index(data, buildInfoMagic)
+		if i < 0 || len(data)-i < buildInfoHeaderSize {
+			return "", "", errNotGoBinary
+		}
+		if i%buildInfoAlign == 0 {
+			data = data[i:]
+			break
+		}
+		data = data[(i+buildInfoAlign-1)&^(buildInfoAlign-1):]
+	}
+
+	ptrSize := int(data[14])
+	flags := data[15]
+	if flags&buildInfoFlagsInline != 0 {
+		var rest []byte
+		goVersion, rest = decodeBuildInfoString(data[buildInfoHeaderSize:])
+		modInfo, _ = decodeBuildInfoString(rest)
+	} else {
+		if ptrSize != 4 && ptrSize != 8 {
+			return "", "", fmt.Errorf("invalid pointer size %v in Go build information", ptrSize)
+		}
+		var byteOrder binary.ByteOrder = binary.LittleEndian
+		if flags != 0 {
+			byteOrder = binary.BigEndian
+		}
+		readPtr := func(b []byte) uint64 {
+			if ptrSize == 4 {
+				return uint64(byteOrder.Uint32(b))
+			}
+			return byteOrder.Uint64(b)
+		}
+		goVersion = readBuildInfoString(exe, ptrSize, readPtr, readPtr(data[16:]))
+		modInfo = readBuildInfoString(exe, ptrSize, readPtr, readPtr(data[16+ptrSize:]))
+	}
+	if goVersion == "" {
+		return "", "", errNotGoBinary
+	}
+
+	// the module information is enclosed in sentinels, binaries built without modules have none
+	if len(modInfo) >= 2*modInfoSentinelLength+1 && modInfo[len(modInfo)-modInfoSentinelLength-1] == '\n' {
+		modInfo = modInfo[modInfoSentinelLength : len(modInfo)-modInfoSentinelLength]
+	} else {
+		modInfo = ""
+	}
+	return
+}
+
+// decodeBuildInfoString decodes a string prefixed with its varint encoded length
+func decodeBuildInfoString(data []byte) (string, []byte) {
+	length, n := binary.Uvarint(data)
+	if n <= 0 || length > uint64(len(data)-n) {
+		return "", nil
+	}
+	return string(data[n : uint64(n)+length]), data[uint64(n)+length:]
+}
+
+// readBuildInfoString reads the Go string header at addr, a data pointer and a length, and the string it refers to
+func readBuildInfoString(exe executable, ptrSize int, readPtr func([]byte) uint64, addr uint64) string {
+	header, err := exe.readData(addr, uint64(2*ptrSize))
+	if err != nil || len(header) < 2*ptrSize {
+		return ""
+	}
+	dataAddr, dataLength := readPtr(header), readPtr(header[ptrSize:])
+	if dataLength > maxBuildInfoSearch {
+		return ""
+	}
+	data, err := exe.readData(dataAddr, dataLength)
+	if err != nil || uint64(len(data)) < dataLength {
+		return ""
+	}
+	return string(data)
+}
+
+// elfExecutable is an executable in the ELF format used on Linux
+type elfExecutable struct {
+	file *os.File
+	f    *elf.File
+}
+
+func (x *elfExecutable) readData(addr, size uint64) ([]byte, error) {
+	for _, prog := range x.f.Progs {
+		if prog.Type == elf.PT_LOAD && prog.Vaddr <= addr && addr < prog.Vaddr+prog.Filesz {
+			n := prog.Vaddr + prog.Filesz - addr
+			if n > size {
+				n = size
+			}
+			data := make([]byte, n)
+			if _, err := prog.ReadAt(data, int64(addr-prog.Vaddr)); err != nil {
+				return nil, err
+			}
+			return data, nil
+		}
+	}
+	return nil, errNotGoBinary
+}
+
+func (x *elfExecutable) dataStart() uint64 {
+	if section := x.f.Section(".go.buildinfo"); section != nil {
+		return section.Addr
+	}
+	// binaries built before Go 1.13 have no build information section, it is at the start of the writable data
+	for _, prog := range x.f.Progs {
+		if prog.Type == elf.PT_LOAD && prog.Flags&(elf.PF_X|elf.PF_W) == elf.PF_W {
+			return prog.Vaddr
+		}
+	}
+	return 0
+}
+
+func (x *elfExecutable) Close() error {
+	return x.file.Close()
+}
+
+// machoExecutable is an executable in the Mach-O format used on macOS
+type machoExecutable struct {
+	file *os.File
+	f    *macho.File
+}
+
+func (x *machoExecutable) readData(addr, size uint64) ([]byte, error) {
+	for _, load := range x.f.Loads {
+		segment, ok := load.(*macho.Segment)
+		if !ok || segment.Name == "__PAGEZERO" {
+			continue
+		}
+		if segment.Addr <= addr && addr < segment.Addr+segment.Filesz {
+			n := segment.Addr + segment.Filesz - addr
+			if n > size {
+				n = size
+			}
+			data := make([]byte, n)
+			if _, err := segment.ReadAt(data, int64(addr-segment.Addr)); err != nil {
+				return nil, err
+			}
+			return data, nil
+		}
+	}
+	return nil, errNotGoBinary
+}
+
+func (x *machoExecutable) dataStart() uint64 {
+	if section := x.f.Section("__go_buildinfo"); section != nil {
+		return section.Addr
+	}
+	if segment := x.f.Segment("__DATA"); segment != nil {
+		return segment.Addr
+	}
+	return 0
+}
+
+func (x *machoExecutable) Close() error {
+	return x.file.Close()
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package languagepackage
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sampleModInfo is module information enclosed in its sentinels
+var sampleModInfo = "0123456789abcdef" + sampleGoModInfo + "fedcba9876543210"
+
+// memoryExecutable is an executable whose memory image is a single segment
+type memoryExecutable struct {
+	addr uint64
+	data []byte
+}
+
+func (x *memoryExecutable) readData(addr, size uint64) ([]byte, error) {
+	if addr < x.addr || addr >= x.addr+uint64(len(x.data)) {
+		return nil, errNotGoBinary
+	}
+	data := x.data[addr-x.addr:]
+	if uint64(len(data)) > size {
+		data = data[:size]
+	}
+	return data, nil
+}
+
+func (x *memoryExecutable) dataStart() uint64 {
+	return x.addr
+}
+
+func (x *memoryExecutable) Close() error {
+	return nil
+}
+
+// buildInfoHeader returns the build information header, preceded by unrelated data
+func buildInfoHeader(ptrSize byte, flags byte) []byte {
+	header := make([]byte, 48, 256)
+	copy(header[16:], buildInfoMagic)
+	header[16+14] = ptrSize
+	header[16+15] = flags
+	return header
+}
+
+func appendVarintString(data []byte, s string) []byte {
+	length := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(length, uint64(len(s)))
+	return append(append(data, length[:n]...), s...)
+}
+
+func TestParseGoBuildInfoInlineStrings(t *testing.T) {
+	data := buildInfoHeader(8, buildInfoFlagsInline)
+	data = appendVarintString(data, "go1.18")
+	data = appendVarintString(data, sampleModInfo)
+
+	goVersion, modInfo, err := parseGoBuildInfo(&memoryExecutable{addr: 0x1000, data: data})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "go1.18", goVersion)
+	assert.Equal(t, sampleGoModInfo, modInfo)
+}
+
+func TestParseGoBuildInfoStringPointers(t *testing.T) {
+	const base = 0x1000
+	data := buildInfoHeader(8, 0)
+	// string headers of the version and the module information, followed by their data
+	stringHeaders := len(data)
+	data = append(data, make([]byte, 32)...)
+	version := len(data)
+	data = append(data, "go1.13"...)
+	mod := len(data)
+	data = append(data, sampleModInfo...)
+
+	binary.LittleEndian.PutUint64(data[16+16:], uint64(base+stringHeaders))
+	binary.LittleEndian.PutUint64(data[16+24:], uint64(base+stringHeaders+16))
+	binary.LittleEndian.PutUint64(data[stringHeaders:], uint64(base+version))
+	binary.LittleEndian.PutUint64(data[stringHeaders+8:], uint64(len("go1.13")))
+	binary.LittleEndian.PutUint64(data[stringHeaders+16:], uint64(base+mod))
+	binary.LittleEndian.PutUint64(data[stringHeaders+24:], uint64(len(sampleModInfo)))
+
+	goVersion, modInfo, err := parseGoBuildInfo(&memoryExecutable{addr: base, data: data})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "go1.13", goVersion)
+	assert.Equal(t, sampleGoModInfo, modInfo)
+}
+
+func TestParseGoBuildInfoWithoutModules(t *testing.T) {
+	data := buildInfoHeader(8, buildInfoFlagsInline)
+	data = appendVarintString(data, "go1.18")
+	data = appendVarintString(data, "")
+
+	goVersion, modInfo, err := parseGoBuildInfo(&memoryExecutable{addr: 0x1000, data: data})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "go1.18", goVersion)
+	assert.Equal(t, "", modInfo)
+}
+
+func TestParseGoBuildInfoNotGoBinary(t *testing.T) {
+	_, _, err := parseGoBuildInfo(&memoryExecutable{addr: 0x1000, data: make([]byte, 1024)})
+	assert.Equal(t, errNotGoBinary, err)
+}
+
+func TestReadGoBuildInfoNotExecutable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gobinaries")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "script")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("#!/bin/sh\necho hello\n"), 0755))
+
+	_, _, err = readGoBuildInfo(path)
+	assert.Equal(t, errNotGoBinary, err)
+}
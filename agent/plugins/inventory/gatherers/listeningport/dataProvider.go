@@ -0,0 +1,216 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package listeningport
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+const (
+	// tcpListenState is the state of a listening tcp socket in /proc/net/tcp
+	tcpListenState = "0A"
+	// udpUnconnectedState is the state of a bound, unconnected udp socket in /proc/net/udp
+	udpUnconnectedState = "07"
+	socketLinkPrefix    = "socket:["
+)
+
+var procRoot = "/proc"
+
+// socketTable describes one of the socket tables under /proc/net
+type socketTable struct {
+	file        string
+	protocol    string
+	listenState string
+}
+
+var socketTables = []socketTable{
+	{file: "tcp", protocol: "tcp", listenState: tcpListenState},
+	{file: "tcp6", protocol: "tcp6", listenState: tcpListenState},
+	{file: "udp", protocol: "udp", listenState: udpUnconnectedState},
+	{file: "udp6", protocol: "udp6", listenState: udpUnconnectedState},
+}
+
+// process captures the process owning a socket
+type process struct {
+	pid        string
+	name       string
+	executable string
+}
+
+// listeningSocket is a socket read from /proc/net along with its inode
+type listeningSocket struct {
+	data  model.ListeningPortData
+	inode string
+}
+
+// collectListeningPortData lists the listening sockets of the instance along with their owning processes
+func collectListeningPortData(context context.T) (data []model.ListeningPortData, err error) {
+	log := context.Log()
+	var sockets []listeningSocket
+
+	for _, table := range socketTables {
+		var tableSockets []listeningSocket
+		if tableSockets, err = readSocketTable(table); err != nil {
+			if os.IsNotExist(err) {
+				// e.g. /proc/net/tcp6 is missing when ipv6 is disabled
+				log.Debugf("Skipping socket table %v: %v", table.file, err)
+				err = nil
+				continue
+			}
+			return nil, fmt.Errorf("unable to read socket table %v: %v", table.file, err)
+		}
+		sockets = append(sockets, tableSockets...)
+	}
+
+	owners := socketOwners(context)
+
+	data = []model.ListeningPortData{}
+	seen := make(map[model.ListeningPortData]bool)
+	for _, socket := range sockets {
+		entry := socket.data
+		if owner, ok := owners[socket.inode]; ok {
+			entry.PID = owner.pid
+			entry.ProcessName = owner.name
+			entry.Executable = owner.executable
+		}
+		// processes with several workers report the same socket more than once
+		if seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		data = append(data, entry)
+	}
+
+	sort.SliceStable(data, func(i, j int) bool {
+		if data[i].Protocol != data[j].Protocol {
+			return data[i].Protocol < data[j].Protocol
+		}
+		pi, _ := strconv.Atoi(data[i].Port)
+		pj, _ := strconv.Atoi(data[j].Port)
+		return pi < pj
+	})
+	return
+}
+
+// readSocketTable parses a /proc/net socket table and returns the sockets in listening state. Lines look like
+// "0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000 0 0 20921 1 ..."
+func readSocketTable(table socketTable) (sockets []listeningSocket, err error) {
+	file, err := os.Open(filepath.Join(procRoot, "net", table.file))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// skip header
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != table.listenState {
+			continue
+		}
+		var address, port string
+		if address, port, err = parseSocketAddress(fields[1]); err != nil {
+			return nil, err
+		}
+		sockets = append(sockets, listeningSocket{
+			data: model.ListeningPortData{
+				Protocol: table.protocol,
+				Address:  address,
+				Port:     port,
+			},
+			inode: fields[9],
+		})
+	}
+	return sockets, scanner.Err()
+}
+
+// parseSocketAddress decodes an address of the form "0100007F:0CEA". The ip address is written as
+// 32 bit words in host byte order, the port in network byte order.
+func parseSocketAddress(value string) (address string, port string, err error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid socket address %v", value)
+	}
+	var ip []byte
+	if ip, err = hex.DecodeString(parts[0]); err != nil || (len(ip) != net.IPv4len && len(ip) != net.IPv6len) {
+		return "", "", fmt.Errorf("invalid socket address %v", value)
+	}
+	for i := 0; i < len(ip); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = ip[i+3], ip[i+2], ip[i+1], ip[i]
+	}
+	var portNumber uint64
+	if portNumber, err = strconv.ParseUint(parts[1], 16, 16); err != nil {
+		return "", "", fmt.Errorf("invalid socket port %v", value)
+	}
+	return net.IP(ip).String(), strconv.FormatUint(portNumber, 10), nil
+}
+
+// socketOwners maps socket inodes to the processes holding them open by walking /proc/<pid>/fd.
+// Processes that can't be inspected, e.g. when the agent isn't running as root, are skipped.
+func socketOwners(context context.T) map[string]process {
+	log := context.Log()
+	owners := make(map[string]process)
+
+	entries, err := ioutil.ReadDir(procRoot)
+	if err != nil {
+		log.Debugf("Unable to list processes: %v", err)
+		return owners
+	}
+
+	for _, entry := range entries {
+		pid := entry.Name()
+		if _, err := strconv.Atoi(pid); err != nil {
+			continue
+		}
+		processDir := filepath.Join(procRoot, pid)
+		fds, err := ioutil.ReadDir(filepath.Join(processDir, "fd"))
+		if err != nil {
+			continue
+		}
+
+		var owner *process
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(processDir, "fd", fd.Name()))
+			if err != nil || !strings.HasPrefix(link, socketLinkPrefix) {
+				continue
+			}
+			inode := strings.TrimSuffix(strings.TrimPrefix(link, socketLinkPrefix), "]")
+			if _, found := owners[inode]; found {
+				continue
+			}
+			if owner == nil {
+				owner = &process{pid: pid}
+				if comm, err := ioutil.ReadFile(filepath.Join(processDir, "comm")); err == nil {
+					owner.name = strings.TrimSpace(string(comm))
+				}
+				owner.executable, _ = os.Readlink(filepath.Join(processDir, "exe"))
+			}
+			owners[inode] = *owner
+		}
+	}
+	return owners
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package listeningport
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectListeningPortData(t *testing.T) {
+	oldProcRoot := procRoot
+	defer func() { procRoot = oldProcRoot }()
+	procRoot = filepath.Join("testdata", "proc")
+
+	data, err := collectListeningPortData(context.NewMockDefault())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []model.ListeningPortData{
+		{Protocol: "tcp", Address: "0.0.0.0", Port: "22", PID: "812", ProcessName: "sshd", Executable: "/usr/sbin/sshd"},
+		{Protocol: "tcp", Address: "127.0.0.1", Port: "25"},
+		{Protocol: "tcp6", Address: "::", Port: "22", PID: "812", ProcessName: "sshd", Executable: "/usr/sbin/sshd"},
+		{Protocol: "tcp6", Address: "::1", Port: "8080"},
+		{Protocol: "udp", Address: "0.0.0.0", Port: "68", PID: "933", ProcessName: "dhclient", Executable: "/usr/sbin/dhclient"},
+	}, data)
+}
+
+func TestCollectListeningPortDataWithoutProc(t *testing.T) {
+	oldProcRoot := procRoot
+	defer func() { procRoot = oldProcRoot }()
+	procRoot = filepath.Join("testdata", "missing")
+
+	data, err := collectListeningPortData(context.NewMockDefault())
+
+	assert.Nil(t, err)
+	assert.Empty(t, data)
+}
+
+func TestParseSocketAddress(t *testing.T) {
+	testCases := []struct {
+		input   string
+		address string
+		port    string
+	}{
+		{"0100007F:0CEA", "127.0.0.1", "3306"},
+		{"0F02000A:0016", "10.0.2.15", "22"},
+		{"000080FE00000000FF57A6A0F7A1B3FE:0050", "fe80::a0a6:57ff:feb3:a1f7", "80"},
+	}
+	for _, tc := range testCases {
+		address, port, err := parseSocketAddress(tc.input)
+		assert.Nil(t, err, tc.input)
+		assert.Equal(t, tc.address, address, tc.input)
+		assert.Equal(t, tc.port, port, tc.input)
+	}
+
+	for _, input := range []string{"0100007F", "0100007G:0016", "01007F:0016", "0100007F:10000"} {
+		_, _, err := parseSocketAddress(input)
+		assert.NotNil(t, err, input)
+	}
+}
@@ -0,0 +1,398 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package localuser
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+const (
+	// maxSudoersIncludeDepth bounds nested #include/#includedir directives, as sudo does
+	maxSudoersIncludeDepth = 8
+	// maxAliasDepth bounds nested User_Alias expansion
+	maxAliasDepth = 8
+	dateFormat    = "2006-01-02"
+)
+
+var (
+	passwdFile  = "/etc/passwd"
+	groupFile   = "/etc/group"
+	shadowFile  = "/etc/shadow"
+	sudoersFile = "/etc/sudoers"
+)
+
+// group is an entry of /etc/group
+type group struct {
+	name    string
+	gid     string
+	members []string
+}
+
+// shadowEntry holds the metadata of an /etc/shadow entry, the password hash itself is never kept
+type shadowEntry struct {
+	locked         bool
+	lastChange     string
+	maxAge         string
+	accountExpires string
+}
+
+// sudoRule is a user specification of the sudoers policy
+type sudoRule struct {
+	users []string
+	rule  string
+}
+
+// sudoersPolicy holds the parsed user specifications and user aliases of the sudoers policy
+type sudoersPolicy struct {
+	rules   []sudoRule
+	aliases map[string][]string
+}
+
+// collectLocalUserData lists the local accounts with their groups, password metadata and sudo rights
+func collectLocalUserData(context context.T) (data []model.LocalUserData, err error) {
+	log := context.Log()
+
+	var users []model.LocalUserData
+	if users, err = readPasswd(passwdFile); err != nil {
+		return nil, fmt.Errorf("unable to read local users: %v", err)
+	}
+
+	groups, err := readGroups(groupFile)
+	if err != nil {
+		log.Debugf("Unable to read local groups: %v", err)
+		err = nil
+	}
+
+	shadow, err := readShadow(shadowFile)
+	if err != nil {
+		// shadow is only readable by root
+		log.Debugf("Unable to read password metadata: %v", err)
+		err = nil
+	}
+
+	policy := &sudoersPolicy{aliases: make(map[string][]string)}
+	if err = policy.load(log, sudoersFile, 0); err != nil {
+		log.Debugf("Unable to read sudoers policy: %v", err)
+		err = nil
+	}
+
+	data = []model.LocalUserData{}
+	for _, user := range users {
+		userGroups := groupsOf(user, groups)
+		var names []string
+		for _, g := range userGroups {
+			names = append(names, g.name)
+		}
+		user.Groups = strings.Join(names, ",")
+		if entry, found := shadow[user.Name]; found {
+			user.PasswordLocked = strconv.FormatBool(entry.locked)
+			user.LastPasswordChange = entry.lastChange
+			user.PasswordMaxAgeDays = entry.maxAge
+			user.AccountExpires = entry.accountExpires
+		}
+		user.SudoRights = strings.Join(policy.rulesFor(user, userGroups), "; ")
+		data = append(data, user)
+	}
+	return
+}
+
+// readColonFile returns the colon separated fields of each non comment line of a file such as /etc/passwd
+func readColonFile(path string) (entries [][]string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, strings.Split(line, ":"))
+	}
+	return entries, scanner.Err()
+}
+
+// readPasswd parses /etc/passwd entries of the form name:password:uid:gid:gecos:home:shell
+func readPasswd(path string) (users []model.LocalUserData, err error) {
+	entries, err := readColonFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, fields := range entries {
+		if len(fields) < 7 {
+			continue
+		}
+		users = append(users, model.LocalUserData{
+			Name:          fields[0],
+			UID:           fields[2],
+			GID:           fields[3],
+			Description:   fields[4],
+			HomeDirectory: fields[5],
+			Shell:         fields[6],
+		})
+	}
+	return
+}
+
+// readGroups parses /etc/group entries of the form name:password:gid:member,member
+func readGroups(path string) (groups []group, err error) {
+	entries, err := readColonFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, fields := range entries {
+		if len(fields) < 4 {
+			continue
+		}
+		g := group{name: fields[0], gid: fields[2]}
+		for _, member := range strings.Split(fields[3], ",") {
+			if member = strings.TrimSpace(member); member != "" {
+				g.members = append(g.members, member)
+			}
+		}
+		groups = append(groups, g)
+	}
+	return
+}
+
+// readShadow parses /etc/shadow entries of the form name:password:lastchg:min:max:warn:inactive:expire:flag
+func readShadow(path string) (entries map[string]shadowEntry, err error) {
+	lines, err := readColonFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entries = make(map[string]shadowEntry)
+	for _, fields := range lines {
+		if len(fields) < 8 {
+			continue
+		}
+		password := fields[1]
+		entries[fields[0]] = shadowEntry{
+			locked:         strings.HasPrefix(password, "!") || strings.HasPrefix(password, "*"),
+			lastChange:     daysToDate(fields[2]),
+			maxAge:         fields[4],
+			accountExpires: daysToDate(fields[7]),
+		}
+	}
+	return
+}
+
+// daysToDate converts a number of days since the epoch, as used by /etc/shadow, to a date.
+// Empty values and 0, which forces a password change at next login, have no date.
+func daysToDate(value string) string {
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		return ""
+	}
+	return time.Unix(0, 0).UTC().AddDate(0, 0, days).Format(dateFormat)
+}
+
+// groupsOf returns the primary and supplementary groups of a user
+func groupsOf(user model.LocalUserData, groups []group) (memberOf []group) {
+	for _, g := range groups {
+		if g.gid == user.GID || containsString(g.members, user.Name) {
+			memberOf = append(memberOf, g)
+		}
+	}
+	return
+}
+
+// load parses a sudoers file, following #include and #includedir directives
+func (p *sudoersPolicy) load(log log.T, path string, depth int) error {
+	if depth > maxSudoersIncludeDepth {
+		return fmt.Errorf("too many nested includes at %v", path)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range joinContinuationLines(string(content)) {
+		line = strings.TrimSpace(line)
+		if directive, target, ok := includeDirective(line); ok {
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(path), target)
+			}
+			if directive == "includedir" {
+				p.loadDir(log, target, depth+1)
+			} else if err := p.load(log, target, depth+1); err != nil {
+				log.Debugf("Unable to read sudoers include %v: %v", target, err)
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "Defaults") {
+			continue
+		}
+		if strings.HasPrefix(line, "User_Alias") {
+			p.parseUserAlias(strings.TrimSpace(strings.TrimPrefix(line, "User_Alias")))
+			continue
+		}
+		if strings.HasPrefix(line, "Runas_Alias") || strings.HasPrefix(line, "Host_Alias") || strings.HasPrefix(line, "Cmnd_Alias") {
+			continue
+		}
+		p.parseUserSpecification(line)
+	}
+	return nil
+}
+
+// loadDir parses the files of a sudoers include directory. Like sudo, files whose name ends in '~' or
+// contains a '.' are skipped and the remaining files are read in lexical order.
+func (p *sudoersPolicy) loadDir(log log.T, dir string, depth int) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		log.Debugf("Unable to read sudoers directory %v: %v", dir, err)
+		return
+	}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || strings.HasSuffix(name, "~") || strings.Contains(name, ".") {
+			continue
+		}
+		if err := p.load(log, filepath.Join(dir, name), depth); err != nil {
+			log.Debugf("Unable to read sudoers file %v: %v", name, err)
+		}
+	}
+}
+
+// includeDirective recognizes both the legacy "#include" and the newer "@include" forms
+func includeDirective(line string) (directive string, target string, ok bool) {
+	if !strings.HasPrefix(line, "#include") && !strings.HasPrefix(line, "@include") {
+		return "", "", false
+	}
+	fields := strings.Fields(line[1:])
+	if len(fields) != 2 || (fields[0] != "include" && fields[0] != "includedir") {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
+
+// joinContinuationLines joins lines ending with a backslash with the line that follows
+func joinContinuationLines(content string) (lines []string) {
+	var current string
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasSuffix(line, "\\") {
+			current += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		lines = append(lines, current+line)
+		current = ""
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+	return
+}
+
+// parseUserAlias parses "NAME = user, %group : NAME2 = user"
+func (p *sudoersPolicy) parseUserAlias(definition string) {
+	for _, alias := range strings.Split(definition, ":") {
+		parts := strings.SplitN(alias, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		p.aliases[strings.TrimSpace(parts[0])] = splitList(parts[1])
+	}
+}
+
+// parseUserSpecification parses "alice, %wheel ALL=(ALL) NOPASSWD: ALL"
+func (p *sudoersPolicy) parseUserSpecification(line string) {
+	index := strings.Index(line, "=")
+	if index < 0 {
+		return
+	}
+	fields := strings.Fields(line[:index])
+	if len(fields) < 2 {
+		return
+	}
+	// the last field before '=' is the host list, everything in front of it the user list
+	hosts := fields[len(fields)-1]
+	users := splitList(strings.Join(fields[:len(fields)-1], " "))
+	rule := hosts + "=" + strings.TrimSpace(line[index+1:])
+	p.rules = append(p.rules, sudoRule{users: users, rule: strings.Join(strings.Fields(rule), " ")})
+}
+
+// splitList splits a comma separated sudoers list
+func splitList(list string) (items []string) {
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return
+}
+
+// rulesFor returns the sudo rules that apply to a user
+func (p *sudoersPolicy) rulesFor(user model.LocalUserData, groups []group) (rules []string) {
+	seen := make(map[string]bool)
+	for _, rule := range p.rules {
+		if p.matches(user, groups, rule.users, 0) && !seen[rule.rule] {
+			seen[rule.rule] = true
+			rules = append(rules, rule.rule)
+		}
+	}
+	return
+}
+
+// matches reports whether any entry of a sudoers user list designates the user. Negated entries are ignored.
+func (p *sudoersPolicy) matches(user model.LocalUserData, groups []group, entries []string, depth int) bool {
+	for _, entry := range entries {
+		switch {
+		case entry == "ALL":
+			return true
+		case strings.HasPrefix(entry, "!"):
+			continue
+		case strings.HasPrefix(entry, "%"):
+			for _, g := range groups {
+				if entry[1:] == g.name || entry[1:] == "#"+g.gid {
+					return true
+				}
+			}
+		case strings.HasPrefix(entry, "#"):
+			if entry[1:] == user.UID {
+				return true
+			}
+		case entry == user.Name:
+			return true
+		default:
+			if alias, found := p.aliases[entry]; found && depth < maxAliasDepth && p.matches(user, groups, alias, depth+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
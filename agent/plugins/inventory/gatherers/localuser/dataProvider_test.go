@@ -0,0 +1,113 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package localuser
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func useTestData() func() {
+	oldPasswd, oldGroup, oldShadow, oldSudoers := passwdFile, groupFile, shadowFile, sudoersFile
+	passwdFile = filepath.Join("testdata", "passwd")
+	groupFile = filepath.Join("testdata", "group")
+	shadowFile = filepath.Join("testdata", "shadow")
+	sudoersFile = filepath.Join("testdata", "sudoers")
+	return func() {
+		passwdFile, groupFile, shadowFile, sudoersFile = oldPasswd, oldGroup, oldShadow, oldSudoers
+	}
+}
+
+func TestCollectLocalUserData(t *testing.T) {
+	defer useTestData()()
+
+	data, err := collectLocalUserData(context.NewMockDefault())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []model.LocalUserData{
+		{
+			Name: "root", UID: "0", GID: "0", Description: "root", HomeDirectory: "/root", Shell: "/bin/bash",
+			Groups: "root", PasswordLocked: "true", LastPasswordChange: "2009-12-22",
+			SudoRights: "ALL=(ALL) ALL",
+		},
+		{
+			Name: "daemon", UID: "2", GID: "2", Description: "daemon", HomeDirectory: "/sbin", Shell: "/sbin/nologin",
+			Groups: "daemon", PasswordLocked: "true", LastPasswordChange: "2016-11-05", PasswordMaxAgeDays: "99999",
+		},
+		{
+			Name: "ec2-user", UID: "1000", GID: "1000", Description: "EC2 Default User", HomeDirectory: "/home/ec2-user", Shell: "/bin/bash",
+			Groups: "wheel,ec2-user", PasswordLocked: "true", LastPasswordChange: "2018-09-26", PasswordMaxAgeDays: "99999",
+			SudoRights: "ALL=(ALL) ALL; ALL=(ALL) NOPASSWD:ALL",
+		},
+		{
+			Name: "alice", UID: "1001", GID: "1001", HomeDirectory: "/home/alice", Shell: "/bin/zsh",
+			Groups: "wheel,alice,docker", PasswordLocked: "false", LastPasswordChange: "2018-11-15", PasswordMaxAgeDays: "90",
+			AccountExpires: "2019-04-14", SudoRights: "ALL=(ALL) ALL; ALL=(root) NOPASSWD: SERVICES",
+		},
+		{
+			Name: "bob", UID: "1002", GID: "1002", Description: "Bob", HomeDirectory: "/home/bob", Shell: "/bin/bash",
+			Groups: "bob,docker", PasswordLocked: "true", PasswordMaxAgeDays: "99999",
+			SudoRights: "ALL=(root) NOPASSWD: SERVICES",
+		},
+	}, data)
+}
+
+func TestCollectLocalUserDataWithoutPrivileges(t *testing.T) {
+	defer useTestData()()
+	shadowFile = filepath.Join("testdata", "missing")
+	sudoersFile = filepath.Join("testdata", "missing")
+
+	data, err := collectLocalUserData(context.NewMockDefault())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(data))
+	for _, user := range data {
+		assert.Empty(t, user.PasswordLocked)
+		assert.Empty(t, user.SudoRights)
+	}
+}
+
+func TestCollectLocalUserDataMissingPasswd(t *testing.T) {
+	defer useTestData()()
+	passwdFile = filepath.Join("testdata", "missing")
+
+	_, err := collectLocalUserData(context.NewMockDefault())
+
+	assert.NotNil(t, err)
+}
+
+func TestIncludeDirective(t *testing.T) {
+	testCases := []struct {
+		line      string
+		directive string
+		target    string
+		ok        bool
+	}{
+		{"#includedir /etc/sudoers.d", "includedir", "/etc/sudoers.d", true},
+		{"@includedir /etc/sudoers.d", "includedir", "/etc/sudoers.d", true},
+		{"#include /etc/sudoers.local", "include", "/etc/sudoers.local", true},
+		{"# include the local rules", "", "", false},
+		{"#1000 ALL=(ALL) ALL", "", "", false},
+	}
+	for _, tc := range testCases {
+		directive, target, ok := includeDirective(tc.line)
+		assert.Equal(t, tc.ok, ok, tc.line)
+		assert.Equal(t, tc.directive, directive, tc.line)
+		assert.Equal(t, tc.target, target, tc.line)
+	}
+}
@@ -20,8 +20,13 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/application"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/awscomponent"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/custom"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/docker"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/file"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/instancedetailedinformation"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/kernel"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/languagepackage"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/listeningport"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/localuser"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/network"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/registry"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/role"
@@ -64,6 +69,11 @@ func InitializeGatherers(context context.T) (SupportedGatherer, InstalledGathere
 		role.GathererName:                        role.Gatherer(context),
 		service.GathererName:                     service.Gatherer(context),
 		registry.GathererName:                    registry.Gatherer(context),
+		kernel.GathererName:                      kernel.Gatherer(context),
+		listeningport.GathererName:               listeningport.Gatherer(context),
+		localuser.GathererName:                   localuser.Gatherer(context),
+		languagepackage.GathererName:             languagepackage.Gatherer(context),
+		docker.GathererName:                      docker.Gatherer(context),
 	}
 
 	for key := range installedGatherer {
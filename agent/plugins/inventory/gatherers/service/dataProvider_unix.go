@@ -0,0 +1,357 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package service
+
+import (
+	"bufio"
+	gocontext "context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+const (
+	systemctlCmd = "systemctl"
+	initctlCmd   = "initctl"
+
+	serviceUnitSuffix = ".service"
+
+	statusRunning      = "Running"
+	statusStopped      = "Stopped"
+	statusFailed       = "Failed"
+	statusStartPending = "StartPending"
+	statusStopPending  = "StopPending"
+
+	startTypeAutomatic = "Automatic"
+	startTypeManual    = "Manual"
+	startTypeDisabled  = "Disabled"
+)
+
+var (
+	// sysVInitDir, sysVRcDirPattern and upstartJobDir are variables for easy testability
+	sysVInitDir      = "/etc/init.d"
+	sysVRcDirPattern = "/etc/rc[2-5].d"
+	upstartJobDir    = "/etc/init"
+
+	// sysVStatusTimeout is the time an init script gets to report its status
+	sysVStatusTimeout = 5 * time.Second
+
+	// initctlListLine matches the jobs of initctl list, such as "ssh start/running, process 1234"
+	initctlListLine = regexp.MustCompile(`^(\S+)(?: \(\S+\))? (start|stop)/(\S+?),?(?:\s|$)`)
+	// upstartStartOn matches the start on stanza of upstart job files
+	upstartStartOn = regexp.MustCompile(`(?m)^\s*start\s+on\s`)
+	// upstartManual matches the manual stanza of upstart job and override files
+	upstartManual = regexp.MustCompile(`(?m)^\s*manual\s*$`)
+)
+
+// decoupling exec.Command for easy testability
+var cmdExecutor = executeCommand
+
+// decoupling the status action of init scripts for easy testability
+var scriptStatus = executeScriptStatus
+
+// trustedUID is the owner required for the init scripts run to get their status, tests replace it
+var trustedUID uint32 = 0
+
+func executeCommand(command string, args ...string) ([]byte, error) {
+	return exec.Command(command, args...).CombinedOutput()
+}
+
+// executeScriptStatus runs the status action of the init script, the script is killed when it doesn't exit in time.
+// The output is not read, so a process left behind by the script can't keep the collection waiting.
+func executeScriptStatus(script string) error {
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), sysVStatusTimeout)
+	defer cancel()
+	return exec.CommandContext(ctx, script, "status").Run()
+}
+
+// isTrustedScript returns true if the init script, or the file it links to, is owned by root and can't be written
+// by group or others, since it is run as root to get its status
+func isTrustedScript(path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && stat.Uid == trustedUID && fi.Mode().Perm()&0022 == 0
+}
+
+// collectServiceData collects the services managed by systemd, or by upstart when systemd isn't available.
+// The SysV init scripts that aren't managed by either of them are added to the result.
+func collectServiceData(context context.T, config model.Config) (data []model.ServiceData, err error) {
+	log := context.Log()
+	log.Infof("collectServiceData called")
+
+	var systemdErr, upstartErr error
+	if data, systemdErr = collectSystemdServices(log); systemdErr != nil {
+		log.Debugf("Unable to list systemd services, trying upstart: %v", systemdErr)
+		if data, upstartErr = collectUpstartServices(log); upstartErr != nil {
+			log.Debugf("Unable to list upstart jobs: %v", upstartErr)
+		}
+	}
+
+	known := map[string]bool{}
+	for _, service := range data {
+		known[service.Name] = true
+	}
+	data = append(data, collectSysVServices(log, known)...)
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].Name < data[j].Name
+	})
+
+	if len(data) == 0 && systemdErr != nil && upstartErr != nil {
+		err = fmt.Errorf("Unable to detect the init system - %v; %v", systemdErr, upstartErr)
+		log.Error(err.Error())
+	}
+	return
+}
+
+// collectSystemdServices lists the service units with their state, start type and dependencies.
+// Unit files are listed first since systemctl can read them without a running systemd.
+func collectSystemdServices(log log.T) (data []model.ServiceData, err error) {
+	output, err := cmdExecutor(systemctlCmd, "list-unit-files", "--type=service", "--no-legend", "--no-pager")
+	if err != nil {
+		return nil, fmt.Errorf("systemctl list-unit-files failed: %v %v", err, strings.TrimSpace(string(output)))
+	}
+	services := map[string]*model.ServiceData{}
+	names := []string{}
+	add := func(unit string) *model.ServiceData {
+		name := strings.TrimSuffix(unit, serviceUnitSuffix)
+		if service, ok := services[name]; ok {
+			return service
+		}
+		names = append(names, unit)
+		services[name] = &model.ServiceData{Name: name, DisplayName: name, Status: statusStopped}
+		return services[name]
+	}
+
+	// unit files: UNIT STATE [VENDOR PRESET]
+	for _, fields := range splitLines(string(output)) {
+		// templates such as getty@.service are instantiated by other units
+		if len(fields) < 2 || strings.HasSuffix(fields[0], "@"+serviceUnitSuffix) {
+			continue
+		}
+		add(fields[0]).StartType = systemdStartType(fields[1])
+	}
+
+	// loaded units: UNIT LOAD ACTIVE SUB DESCRIPTION
+	if output, err := cmdExecutor(systemctlCmd, "list-units", "--type=service", "--all", "--no-legend", "--no-pager", "--plain"); err != nil {
+		log.Debugf("systemctl list-units failed, service status is not available: %v", err)
+	} else {
+		for _, fields := range splitLines(string(output)) {
+			if len(fields) < 4 || fields[1] == "not-found" {
+				continue
+			}
+			service := add(fields[0])
+			service.Status = systemdStatus(fields[2], fields[3])
+			if len(fields) > 4 {
+				service.DisplayName = strings.Join(fields[4:], " ")
+			}
+		}
+	}
+
+	if len(names) > 0 {
+		collectSystemdProperties(log, names, services)
+	}
+
+	for _, service := range services {
+		data = append(data, *service)
+	}
+	return data, nil
+}
+
+// collectSystemdProperties fills the service type and dependencies from the output of systemctl show,
+// which prints the properties of every unit as KEY=VALUE lines separated by empty lines
+func collectSystemdProperties(log log.T, units []string, services map[string]*model.ServiceData) {
+	args := append([]string{"show", "--no-pager", "--property=Id,Type,Requires,Wants,RequiredBy,WantedBy"}, units...)
+	output, err := cmdExecutor(systemctlCmd, args...)
+	if err != nil {
+		log.Debugf("systemctl show failed, service dependencies are not available: %v", err)
+		return
+	}
+	for _, block := range strings.Split(strings.Replace(string(output), "\r\n", "\n", -1), "\n\n") {
+		properties := map[string]string{}
+		for _, line := range strings.Split(block, "\n") {
+			if parts := strings.SplitN(line, "=", 2); len(parts) == 2 {
+				properties[parts[0]] = parts[1]
+			}
+		}
+		service, ok := services[strings.TrimSuffix(properties["Id"], serviceUnitSuffix)]
+		if !ok {
+			continue
+		}
+		service.ServiceType = properties["Type"]
+		service.ServicesDependedOn = serviceNames(properties["Requires"], properties["Wants"])
+		service.DependentServices = serviceNames(properties["RequiredBy"], properties["WantedBy"])
+	}
+}
+
+// serviceNames returns the services among the space separated units, without their suffix
+func serviceNames(unitLists ...string) string {
+	names := []string{}
+	for _, units := range unitLists {
+		for _, unit := range strings.Fields(units) {
+			if strings.HasSuffix(unit, serviceUnitSuffix) {
+				names = append(names, strings.TrimSuffix(unit, serviceUnitSuffix))
+			}
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// systemdStatus maps the active and sub states of a unit to the service statuses used on Windows
+func systemdStatus(active, sub string) string {
+	switch active {
+	case "active", "reloading":
+		if sub == "exited" {
+			return statusStopped
+		}
+		return statusRunning
+	case "inactive":
+		return statusStopped
+	case "failed":
+		return statusFailed
+	case "activating":
+		return statusStartPending
+	case "deactivating":
+		return statusStopPending
+	}
+	return active
+}
+
+// systemdStartType maps the unit file state to the start types used on Windows
+func systemdStartType(state string) string {
+	switch state {
+	case "enabled", "enabled-runtime", "alias":
+		return startTypeAutomatic
+	case "static", "indirect", "generated", "transient":
+		return startTypeManual
+	case "disabled", "masked", "masked-runtime":
+		return startTypeDisabled
+	}
+	return state
+}
+
+// collectUpstartServices lists the upstart jobs, a job is started automatically when it has a start on stanza
+// that isn't overridden by a manual stanza
+func collectUpstartServices(log log.T) (data []model.ServiceData, err error) {
+	output, err := cmdExecutor(initctlCmd, "list")
+	if err != nil {
+		return nil, fmt.Errorf("initctl list failed: %v %v", err, strings.TrimSpace(string(output)))
+	}
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		match := initctlListLine.FindStringSubmatch(scanner.Text())
+		// jobs with several instances are listed once per instance
+		if match == nil || seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+		status := statusStopped
+		if match[2] == "start" {
+			status = statusRunning
+			if match[3] != "running" {
+				status = statusStartPending
+			}
+		} else if match[3] != "waiting" {
+			status = statusStopPending
+		}
+		data = append(data, model.ServiceData{
+			Name:        match[1],
+			DisplayName: match[1],
+			Status:      status,
+			StartType:   upstartStartType(match[1]),
+		})
+	}
+	return data, nil
+}
+
+func upstartStartType(job string) string {
+	content, err := ioutil.ReadFile(filepath.Join(upstartJobDir, job+".conf"))
+	if err != nil || !upstartStartOn.Match(content) || upstartManual.Match(content) {
+		return startTypeManual
+	}
+	if override, err := ioutil.ReadFile(filepath.Join(upstartJobDir, job+".override")); err == nil && upstartManual.Match(override) {
+		return startTypeManual
+	}
+	return startTypeAutomatic
+}
+
+// collectSysVServices lists the init scripts that aren't known yet, a script is started automatically when it has a start link
+// in one of the multi-user runlevels. The status is the LSB exit code of the status action.
+func collectSysVServices(log log.T, known map[string]bool) (data []model.ServiceData) {
+	scripts, err := ioutil.ReadDir(sysVInitDir)
+	if err != nil {
+		log.Debugf("Unable to list SysV init scripts: %v", err)
+		return
+	}
+	startLinks, _ := filepath.Glob(filepath.Join(sysVRcDirPattern, "S*"))
+	automatic := map[string]bool{}
+	for _, link := range startLinks {
+		// links are named S<priority><script>
+		name := strings.TrimLeft(strings.TrimPrefix(filepath.Base(link), "S"), "0123456789")
+		automatic[name] = true
+	}
+
+	for _, script := range scripts {
+		name := script.Name()
+		if known[name] || script.IsDir() || script.Mode()&0111 == 0 || strings.HasPrefix(name, ".") || name == "README" || name == "skeleton" || name == "functions" {
+			continue
+		}
+		if !isTrustedScript(filepath.Join(sysVInitDir, name)) {
+			log.Debugf("Skipping SysV init script %v, it is not owned by root or is writable by others", name)
+			continue
+		}
+		status := statusStopped
+		if err := scriptStatus(filepath.Join(sysVInitDir, name)); err == nil {
+			status = statusRunning
+		}
+		startType := startTypeManual
+		if automatic[name] {
+			startType = startTypeAutomatic
+		}
+		data = append(data, model.ServiceData{
+			Name:        name,
+			DisplayName: name,
+			Status:      status,
+			StartType:   startType,
+		})
+	}
+	return
+}
+
+// splitLines returns the whitespace separated fields of the non empty lines
+func splitLines(output string) (lines [][]string) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			lines = append(lines, fields)
+		}
+	}
+	return
+}
@@ -0,0 +1,222 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+//go:build darwin || freebsd || linux || netbsd || openbsd
+// +build darwin freebsd linux netbsd openbsd
+
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	// the test scripts are owned by the user running the tests
+	trustedUID = uint32(os.Getuid())
+}
+
+// commandFixtures returns a command executor answering with the captured outputs in testdata,
+// the status of the init scripts is running for the given scripts
+func commandFixtures(t *testing.T, fixtures map[string]string, running map[string]bool) func(string, ...string) ([]byte, error) {
+	scriptStatus = func(script string) error {
+		if running[filepath.Base(script)] {
+			return nil
+		}
+		return errors.New("exit status 3")
+	}
+	return func(command string, args ...string) ([]byte, error) {
+		key := command
+		if len(args) > 0 {
+			key += " " + args[0]
+		}
+		fixture, ok := fixtures[key]
+		if !ok {
+			return []byte(command + ": command not found"), errors.New("exit status 127")
+		}
+		output, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+		assert.NoError(t, err)
+		return output, nil
+	}
+}
+
+// useInitDirs points the SysV and upstart directories to a temporary directory holding the given scripts and jobs
+func useInitDirs(t *testing.T, scripts []string, startLinks []string, jobs map[string]string) func() {
+	dir, _ := ioutil.TempDir("", "service")
+	initDir, rcDir, jobDir := filepath.Join(dir, "init.d"), filepath.Join(dir, "rc2.d"), filepath.Join(dir, "init")
+	for _, path := range []string{initDir, rcDir, jobDir} {
+		os.MkdirAll(path, 0755)
+	}
+	for _, script := range scripts {
+		ioutil.WriteFile(filepath.Join(initDir, script), []byte("#!/bin/sh\n"), 0755)
+	}
+	for _, link := range startLinks {
+		ioutil.WriteFile(filepath.Join(rcDir, link), []byte{}, 0755)
+	}
+	for name, content := range jobs {
+		ioutil.WriteFile(filepath.Join(jobDir, name), []byte(content), 0644)
+	}
+
+	oldInitDir, oldRcDirPattern, oldJobDir := sysVInitDir, sysVRcDirPattern, upstartJobDir
+	sysVInitDir, sysVRcDirPattern, upstartJobDir = initDir, filepath.Join(dir, "rc[2-5].d"), jobDir
+	return func() {
+		sysVInitDir, sysVRcDirPattern, upstartJobDir = oldInitDir, oldRcDirPattern, oldJobDir
+		os.RemoveAll(dir)
+	}
+}
+
+func findService(data []model.ServiceData, name string) *model.ServiceData {
+	for i := range data {
+		if data[i].Name == name {
+			return &data[i]
+		}
+	}
+	return nil
+}
+
+func TestServiceDataSystemd(t *testing.T) {
+	defer useInitDirs(t, []string{"cron", "legacy-app"}, []string{"S20legacy-app"}, nil)()
+	cmdExecutor = commandFixtures(t, map[string]string{
+		"systemctl list-unit-files": "systemctl_list_unit_files.txt",
+		"systemctl list-units":      "systemctl_list_units.txt",
+		"systemctl show":            "systemctl_show.txt",
+	}, map[string]bool{"legacy-app": true})
+
+	data, err := collectServiceData(context.NewMockDefault(), model.Config{})
+	assert.NoError(t, err)
+
+	names := []string{}
+	for _, service := range data {
+		names = append(names, service.Name)
+	}
+	// templates and units without unit file are skipped, init scripts managed by systemd are listed once
+	assert.Equal(t, "amazon-ssm-agent auditd cron getty@tty1 legacy-app nfs-server rescue systemd-journald systemd-update-utmp unused", strings.Join(names, " "))
+
+	assert.Equal(t, model.ServiceData{
+		Name:               "amazon-ssm-agent",
+		DisplayName:        "Amazon SSM Agent",
+		Status:             "Running",
+		DependentServices:  "",
+		ServicesDependedOn: "",
+		ServiceType:        "simple",
+		StartType:          "Automatic",
+	}, *findService(data, "amazon-ssm-agent"))
+	assert.Equal(t, model.ServiceData{
+		Name:               "systemd-journald",
+		DisplayName:        "Journal Service",
+		Status:             "Running",
+		DependentServices:  "systemd-update-utmp auditd",
+		ServicesDependedOn: "",
+		ServiceType:        "notify",
+		StartType:          "Manual",
+	}, *findService(data, "systemd-journald"))
+	assert.Equal(t, "Failed", findService(data, "auditd").Status)
+	assert.Equal(t, "Stopped", findService(data, "systemd-update-utmp").Status)
+	assert.Equal(t, "Stopped", findService(data, "nfs-server").Status)
+	assert.Equal(t, "Disabled", findService(data, "nfs-server").StartType)
+	assert.Equal(t, "Disabled", findService(data, "unused").StartType)
+	assert.Equal(t, model.ServiceData{
+		Name:        "legacy-app",
+		DisplayName: "legacy-app",
+		Status:      "Running",
+		StartType:   "Automatic",
+	}, *findService(data, "legacy-app"))
+}
+
+func TestServiceDataSystemdNotRunning(t *testing.T) {
+	defer useInitDirs(t, nil, nil, nil)()
+	// systemctl reads unit files without systemd as init, which is the case in containers
+	cmdExecutor = commandFixtures(t, map[string]string{
+		"systemctl list-unit-files": "systemctl_list_unit_files.txt",
+	}, nil)
+
+	data, err := collectServiceData(context.NewMockDefault(), model.Config{})
+	assert.NoError(t, err)
+	assert.Len(t, data, 7)
+	assert.Equal(t, model.ServiceData{
+		Name:        "cron",
+		DisplayName: "cron",
+		Status:      "Stopped",
+		StartType:   "Automatic",
+	}, *findService(data, "cron"))
+}
+
+func TestServiceDataUpstart(t *testing.T) {
+	defer useInitDirs(t, []string{"ssh", "sendmail"}, []string{"S80sendmail"}, map[string]string{
+		"ssh.conf":      "description \"OpenSSH server\"\nstart on runlevel [2345]\n",
+		"cron.conf":     "start on runlevel [2345]\n",
+		"cron.override": "manual\n",
+		"tty4.conf":     "start on runlevel [23]\n",
+		"rc.conf":       "task\n",
+		"mountall.conf": "start on startup\n",
+	})()
+	cmdExecutor = commandFixtures(t, map[string]string{
+		"initctl list": "initctl_list.txt",
+	}, map[string]bool{"sendmail": true})
+
+	data, err := collectServiceData(context.NewMockDefault(), model.Config{})
+	assert.NoError(t, err)
+
+	assert.Len(t, data, 8)
+	assert.Equal(t, model.ServiceData{Name: "ssh", DisplayName: "ssh", Status: "Running", StartType: "Automatic"}, *findService(data, "ssh"))
+	assert.Equal(t, model.ServiceData{Name: "cron", DisplayName: "cron", Status: "Running", StartType: "Manual"}, *findService(data, "cron"))
+	assert.Equal(t, model.ServiceData{Name: "rc", DisplayName: "rc", Status: "Stopped", StartType: "Manual"}, *findService(data, "rc"))
+	assert.Equal(t, "StopPending", findService(data, "mountall").Status)
+	assert.Equal(t, "Running", findService(data, "network-interface").Status)
+	assert.Equal(t, model.ServiceData{Name: "sendmail", DisplayName: "sendmail", Status: "Running", StartType: "Automatic"}, *findService(data, "sendmail"))
+}
+
+func TestServiceDataSkipsUntrustedScripts(t *testing.T) {
+	defer useInitDirs(t, []string{"trusted", "writable"}, nil, nil)()
+	cmdExecutor = commandFixtures(t, map[string]string{}, map[string]bool{"trusted": true, "writable": true})
+	assert.NoError(t, os.Chmod(filepath.Join(sysVInitDir, "writable"), 0777))
+
+	data := collectSysVServices(context.NewMockDefault().Log(), map[string]bool{})
+	assert.Len(t, data, 1)
+	assert.Equal(t, "trusted", data[0].Name)
+
+	// scripts owned by another user are not run either
+	defer func(uid uint32) { trustedUID = uid }(trustedUID)
+	trustedUID++
+	assert.Empty(t, collectSysVServices(context.NewMockDefault().Log(), map[string]bool{}))
+}
+
+func TestExecuteScriptStatusTimesOut(t *testing.T) {
+	defer useInitDirs(t, nil, nil, nil)()
+	script := filepath.Join(sysVInitDir, "hanging")
+	assert.NoError(t, ioutil.WriteFile(script, []byte("#!/bin/sh\nsleep 30\n"), 0755))
+	defer func(timeout time.Duration) { sysVStatusTimeout = timeout }(sysVStatusTimeout)
+	sysVStatusTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	assert.Error(t, executeScriptStatus(script))
+	assert.True(t, time.Since(start) < 10*time.Second)
+}
+
+func TestServiceDataNoInitSystem(t *testing.T) {
+	defer useInitDirs(t, nil, nil, nil)()
+	cmdExecutor = commandFixtures(t, map[string]string{}, nil)
+
+	data, err := collectServiceData(context.NewMockDefault(), model.Config{})
+	assert.Error(t, err)
+	assert.Empty(t, data)
+}
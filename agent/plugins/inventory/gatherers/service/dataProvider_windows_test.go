@@ -12,6 +12,8 @@
 // permissions and limitations under the License.
 //
 
+// +build windows
+
 package service
 
 import (
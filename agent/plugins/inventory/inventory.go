@@ -29,13 +29,20 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/changetracker"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/datauploader"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/exporter"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/application"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/awscomponent"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/custom"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/docker"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/file"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/instancedetailedinformation"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/kernel"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/languagepackage"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/listeningport"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/localuser"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/network"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/registry"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/role"
@@ -58,6 +65,8 @@ const (
 	errorMsgForInabilityToSendDataToSSM       = "inventory data could not be uploaded to Systems Manager. Additional troubleshooting information - %v"
 	msgWhenNoDataToReturnForInventoryPlugin   = "Inventory policy has been successfully applied but there is no inventory data to upload to SSM"
 	successfulMsgForInventoryPlugin           = "Inventory policy has been successfully applied and collected inventory data has been uploaded to SSM"
+	successfulMsgForInventoryExport           = "Inventory policy has been successfully applied and collected inventory data has been exported to %v"
+	errorMsgForSkipUploadWithoutExport        = "inventory upload can only be skipped when an export directory is configured"
 )
 
 // PluginInput represents configuration which is applied to inventory plugin during execution.
@@ -74,6 +83,16 @@ type PluginInput struct {
 	InstanceDetailedInformation string
 	CustomInventory             string
 	CustomInventoryDirectory    string
+	KernelInfo                  string
+	KernelParameters            string
+	ListeningPorts              string
+	LocalUsers                  string
+	LanguagePackages            string
+	DockerContainers            string
+	InventoryChanges            string
+	ExportDirectory             string
+	ExportFormat                string
+	SkipUpload                  string
 }
 
 // decoupling platform.InstanceID for easy testability
@@ -98,6 +117,9 @@ type Plugin struct {
 	//uploader handles uploading inventory data to SSM.
 	uploader datauploader.T
 
+	//changeTracker computes what changed in the inventory data since the previous collection.
+	changeTracker changetracker.T
+
 	// machineID of the machine where agent is running - useful during command detection
 	machineID string
 }
@@ -127,6 +149,12 @@ func NewPlugin(context context.T) (*Plugin, error) {
 
 	//loads all registered gatherers (for now only a dummy application gatherer is loaded in memory)
 	p.supportedGatherers, p.installedGatherers = gatherers.InitializeGatherers(p.context)
+	//initializes the tracker of inventory changes, inventory is still collected without it
+	if p.changeTracker, err = changetracker.NewTracker(c); err != nil {
+		log.Errorf("Unable to configure inventory change tracking - %v", err.Error())
+		p.changeTracker = nil
+	}
+
 	//initializes SSM Inventory uploader
 	if p.uploader, err = datauploader.NewInventoryUploader(c); err != nil {
 		err = log.Errorf("Unable to configure SSM Inventory uploader - %v", err.Error())
@@ -153,6 +181,20 @@ func (p *Plugin) ApplyInventoryPolicy(context context.T, inventoryInput PluginIn
 		return
 	}
 
+	//validate local export
+	exportSettings, skipUpload := p.ExportSettings(context, inventoryInput)
+	if exportSettings.Directory != "" {
+		err = exportSettings.Validate()
+	} else if skipUpload {
+		err = fmt.Errorf(errorMsgForSkipUploadWithoutExport)
+	}
+	if err != nil {
+		log.Info(err.Error())
+		output.SetExitCode(1)
+		output.AppendError(err.Error())
+		return
+	}
+
 	//execute all eligible gatherers with their respective config
 	if items, err = p.RunGatherers(gatherers); err != nil {
 		log.Info(err.Error())
@@ -161,6 +203,12 @@ func (p *Plugin) ApplyInventoryPolicy(context context.T, inventoryInput PluginIn
 		return
 	}
 
+	//record what changed since the previous collection and report it as an inventory type if requested
+	changes := p.trackChanges(context, items)
+	if inventoryInput.InventoryChanges == model.Enabled && len(items) > 0 {
+		items = append(items, changetracker.ChangesItem(log, changes, time.Now().UTC().Format(time.RFC3339)))
+	}
+
 	//check if there is data to send to SSM
 	if len(items) == 0 {
 		//no data to send to ssm - no need to call PutInventory API
@@ -174,6 +222,24 @@ func (p *Plugin) ApplyInventoryPolicy(context context.T, inventoryInput PluginIn
 	d, _ := json.Marshal(items)
 	log.Debugf("Collected Inventory data: %v", string(d))
 
+	//write collected data to the export directory, which is all there is to do when the upload is skipped
+	if exportSettings.Directory != "" {
+		var exportDir string
+		if exportDir, err = exporter.Export(context, exportSettings, items, time.Now()); err != nil {
+			log.Info(err.Error())
+			output.SetExitCode(1)
+			output.AppendError(err.Error())
+			return
+		}
+
+		if skipUpload {
+			log.Infof("%v exported inventory data to %v, skipping upload to SSM", Name(), exportDir)
+			output.SetExitCode(0)
+			output.AppendInfof(successfulMsgForInventoryExport, exportDir)
+			return
+		}
+	}
+
 	if optimizedInventoryItems, nonOptimizedInventoryItems, err = p.uploader.ConvertToSsmInventoryItems(p.context, items); err != nil {
 		log.Infof("Encountered error in converting data to SSM InventoryItems - %v. Skipping upload to SSM", err.Error())
 		output.SetExitCode(1)
@@ -264,6 +330,42 @@ func (p Plugin) ApplyInventoryFrequentCollector(context context.T, gatherers map
 	return
 }
 
+// ExportSettings returns where collected inventory data is exported to and whether its upload is skipped.
+// The plugin input takes precedence over the agent configuration.
+func (p *Plugin) ExportSettings(context context.T, inventoryInput PluginInput) (settings exporter.Settings, skipUpload bool) {
+	cfg := context.AppConfig().Inventory
+	settings = exporter.Settings{
+		Directory:    cfg.ExportDirectory,
+		Format:       cfg.ExportFormat,
+		MaxSnapshots: cfg.MaxExportedSnapshots,
+	}
+	skipUpload = cfg.SkipUpload
+
+	if inventoryInput.ExportDirectory != "" {
+		settings.Directory = inventoryInput.ExportDirectory
+	}
+	if inventoryInput.ExportFormat != "" {
+		settings.Format = inventoryInput.ExportFormat
+	}
+	if inventoryInput.SkipUpload != "" {
+		skipUpload = strings.EqualFold(inventoryInput.SkipUpload, "true")
+	}
+	return
+}
+
+// trackChanges records the changes of the collected inventory data since the previous collection in the local change log.
+// Only the scheduled collection advances the snapshots, so it reports the changes seen by the frequent collector in between.
+func (p *Plugin) trackChanges(context context.T, items []model.Item) (changes []model.InventoryChangeData) {
+	var err error
+	if p.changeTracker == nil {
+		return
+	}
+	if changes, err = p.changeTracker.Track(context, items); err != nil {
+		context.Log().Errorf("Unable to track inventory changes - %v", err.Error())
+	}
+	return
+}
+
 // shouldRetryWithNonOptimizedData will return true if the Exception occurred is one of ItemContentMismatchException
 // or InvalidItemContentException and will retry sending data to SSM. It will return false, if any other error occurs.
 func shouldRetryWithNonOptimizedData(err error, log log.T) bool {
@@ -361,6 +463,22 @@ func (p *Plugin) validateCustomGatherer(context context.T, collectionPolicy, loc
 	return
 }
 
+func (p *Plugin) validateKernelGatherer(context context.T, collectionPolicy, parameters string) (status bool, gatherer gatherers.T, policy model.Config, err error) {
+
+	if collectionPolicy == model.Enabled {
+		if status, gatherer, err = p.CanGathererRun(context, kernel.GathererName); err != nil {
+			return
+		}
+
+		// the allowlist of kernel parameters is handed to the gatherer as its filters
+		if status {
+			policy = model.Config{Collection: collectionPolicy, Filters: parameters}
+		}
+	}
+
+	return
+}
+
 // ValidateInventoryInput validates inventory input and returns a map of eligible gatherers & their corresponding config.
 // It throws an error if gatherer is not recognized/installed.
 func (p *Plugin) ValidateInventoryInput(context context.T, input PluginInput) (configuredGatherers map[gatherers.T]model.Config, err error) {
@@ -382,11 +500,15 @@ func (p *Plugin) ValidateInventoryInput(context context.T, input PluginInput) (c
 		network.GathererName:                     input.NetworkConfig,
 		windowsUpdate.GathererName:               input.WindowsUpdates,
 		instancedetailedinformation.GathererName: input.InstanceDetailedInformation,
+		listeningport.GathererName:               input.ListeningPorts,
+		localuser.GathererName:                   input.LocalUsers,
+		docker.GathererName:                      input.DockerContainers,
 	}
 
 	predefinedGatherersWithFilters := map[string]string{
-		file.GathererName:     input.Files,
-		registry.GathererName: input.WindowsRegistry,
+		file.GathererName:            input.Files,
+		registry.GathererName:        input.WindowsRegistry,
+		languagepackage.GathererName: input.LanguagePackages,
 	}
 
 	//NOTE:
@@ -419,6 +541,14 @@ func (p *Plugin) ValidateInventoryInput(context context.T, input PluginInput) (c
 		configuredGatherers[gatherer] = cfg
 	}
 
+	//checking kernel gatherer
+	if canGathererRun, gatherer, cfg, err = p.validateKernelGatherer(context, input.KernelInfo, input.KernelParameters); err != nil {
+		log.Errorf("Error while validating gatherer %v", err.Error())
+		return
+	} else if canGathererRun {
+		configuredGatherers[gatherer] = cfg
+	}
+
 	return
 }
 
@@ -514,8 +644,26 @@ func (p *Plugin) IsMulitpleAssociationPresent(currentAssociationID string, confi
 // IsInventoryBeingInvokedAsAssociation returns true if inventory plugin is invoked via ssm-associate or else it returns false.
 // It throws error if the detection itself fails
 func (p *Plugin) IsInventoryBeingInvokedAsAssociation(fileName string) (status bool, err error) {
-	var content string
 	var docState contracts.DocumentState
+	if docState, err = p.executingDocumentState(fileName); err == nil {
+		status = docState.IsAssociation()
+	}
+	return
+}
+
+// IsInventoryBeingInvokedOffline returns true if inventory plugin is invoked by a document dropped into the offline
+// command folder. It throws error if the detection itself fails
+func (p *Plugin) IsInventoryBeingInvokedOffline(fileName string) (status bool, err error) {
+	var docState contracts.DocumentState
+	if docState, err = p.executingDocumentState(fileName); err == nil {
+		status = docState.DocumentType == contracts.SendCommandOffline
+	}
+	return
+}
+
+// executingDocumentState reads the state of the document executing the inventory plugin
+func (p *Plugin) executingDocumentState(fileName string) (docState contracts.DocumentState, err error) {
+	var content string
 	log := p.context.Log()
 
 	//since the document is still getting executed - it must be in Current folder
@@ -527,15 +675,13 @@ func (p *Plugin) IsInventoryBeingInvokedAsAssociation(fileName string) (status b
 
 	absPathOfDoc := filepath.Join(path, fileName)
 
-	//read file & then determine if document is of association type
+	//read file & then determine the type of the document
 	if fileutil.Exists(absPathOfDoc) {
 		log.Debugf("Found the document that's executing inventory plugin - %v", absPathOfDoc)
 
 		//read file
 		if content, err = fileutil.ReadAllText(absPathOfDoc); err == nil {
-			if err = json.Unmarshal([]byte(content), &docState); err == nil {
-				status = docState.IsAssociation()
-			}
+			err = json.Unmarshal([]byte(content), &docState)
 		}
 
 	} else {
@@ -545,6 +691,20 @@ func (p *Plugin) IsInventoryBeingInvokedAsAssociation(fileName string) (status b
 	return
 }
 
+// isOfflineExport returns true if inventory plugin is invoked from the offline command folder with an input that
+// exports the collected data locally without uploading it - the only way inventory can run outside of an association.
+func (p *Plugin) isOfflineExport(context context.T, config contracts.Configuration) bool {
+	var inventoryInput PluginInput
+	if isOffline, err := p.IsInventoryBeingInvokedOffline(config.BookKeepingFileName); err != nil || !isOffline {
+		return false
+	}
+	if err := jsonutil.Remarshal(config.Properties, &inventoryInput); err != nil {
+		return false
+	}
+	settings, skipUpload := p.ExportSettings(context, inventoryInput)
+	return skipUpload && settings.Directory != ""
+}
+
 // ParseAssociationIdFromFileName parses associationID from the given input
 // NOTE: Input will be of format - AssociationID.RunID -> as per the format of bookkeepingfilename for associate documents
 func (p *Plugin) ParseAssociationIdFromFileName(input string) string {
@@ -573,7 +733,10 @@ func (p *Plugin) Execute(context context.T, config contracts.Configuration, canc
 
 	// Check if the inventory plugin is being invoked as association, if not or if detection fails for some reason,
 	// then fail association - because inventory plugin currently supports invocation via ssm associate only.
-	if isAssociation, err = p.IsInventoryBeingInvokedAsAssociation(config.BookKeepingFileName); err != nil || !isAssociation {
+	// Offline commands are accepted as well when they only export inventory data locally.
+	if isAssociation, err = p.IsInventoryBeingInvokedAsAssociation(config.BookKeepingFileName); err == nil && !isAssociation && p.isOfflineExport(context, config) {
+		log.Infof("%v plugin is being invoked from the offline command folder to export inventory data", pluginName)
+	} else if err != nil || !isAssociation {
 		if err != nil {
 			errorMsg = fmt.Sprintf(errorMsgForUnableToDetectInvocationType, pluginName, err.Error())
 		} else {
@@ -21,11 +21,14 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	iohandlermocks "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/mock"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 // MockInventoryPlugin returns mock inventory plugin
@@ -155,6 +158,90 @@ func TestRunGatherers(t *testing.T) {
 	assert.NotNil(t, err, "%v should throw errors", errorProneGatherer)
 }
 
+// failingTracker is a change tracker which always fails
+type failingTracker struct{}
+
+func (failingTracker) Track(context context.T, items []model.Item) ([]model.InventoryChangeData, error) {
+	return nil, fmt.Errorf("disk full")
+}
+
+func TestTrackChanges(t *testing.T) {
+	p, _ := MockInventoryPlugin(nil, nil)
+	items := MockInventoryItems()
+
+	//no tracker configured
+	assert.Nil(t, p.trackChanges(p.context, items))
+
+	//tracking errors don't stop the inventory collection
+	p.changeTracker = failingTracker{}
+	assert.Nil(t, p.trackChanges(p.context, items))
+}
+
+// recordingTracker is a change tracker which records the collections it tracked
+type recordingTracker struct {
+	tracked *int
+}
+
+func (r recordingTracker) Track(context context.T, items []model.Item) ([]model.InventoryChangeData, error) {
+	*r.tracked++
+	return nil, nil
+}
+
+// cleanUploader is an uploader for which no inventory data changed
+type cleanUploader struct{}
+
+func (cleanUploader) SendDataToSSM(context context.T, items []*ssm.InventoryItem) error {
+	return nil
+}
+
+func (cleanUploader) ConvertToSsmInventoryItems(context context.T, items []model.Item) ([]*ssm.InventoryItem, []*ssm.InventoryItem, error) {
+	return nil, nil, nil
+}
+
+func (cleanUploader) GetDirtySsmInventoryItems(context context.T, items []model.Item) ([]*ssm.InventoryItem, error) {
+	return nil, nil
+}
+
+func TestApplyInventoryFrequentCollector_DoesNotTrackChanges(t *testing.T) {
+	p, _ := MockInventoryPlugin(nil, nil)
+	tracked := 0
+	p.changeTracker = recordingTracker{tracked: &tracked}
+	p.uploader = cleanUploader{}
+	gatherer := gatherers.NewMockDefault()
+	config := model.Config{Collection: "Enabled"}
+	gatherer.On("Name").Return("AWS:Application")
+	gatherer.On("Run", p.context, config).Return(MockInventoryItems(), nil)
+	output := new(iohandlermocks.MockIOHandler)
+	output.On("SetExitCode", 0)
+	output.On("AppendInfo", mock.Anything)
+
+	p.ApplyInventoryFrequentCollector(p.context, map[gatherers.T]model.Config{gatherer: config}, output)
+
+	//the changes are reported by the next scheduled collection, which compares with its own snapshots
+	assert.Equal(t, 0, tracked)
+	output.AssertCalled(t, "SetExitCode", 0)
+}
+
+func TestExportSettings(t *testing.T) {
+	p, _ := MockInventoryPlugin(nil, nil)
+
+	//nothing configured
+	settings, skipUpload := p.ExportSettings(p.context, PluginInput{})
+	assert.Equal(t, "", settings.Directory)
+	assert.False(t, skipUpload)
+
+	//plugin input is used when present
+	input := PluginInput{
+		ExportDirectory: "/var/lib/inventory",
+		ExportFormat:    "CSV",
+		SkipUpload:      "True",
+	}
+	settings, skipUpload = p.ExportSettings(p.context, input)
+	assert.Equal(t, "/var/lib/inventory", settings.Directory)
+	assert.Equal(t, "CSV", settings.Format)
+	assert.True(t, skipUpload)
+}
+
 func TestVerifyInventoryDataSize(t *testing.T) {
 	var smallItem, largeItem model.Item
 	var items []model.Item
@@ -15,6 +15,8 @@
 package model
 
 import (
+	"encoding/json"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -104,6 +106,13 @@ type FileData struct {
 	CompanyName      string
 	ProductVersion   string
 	ProductLanguage  string
+	// Following attributes are only collected when requested by the filter
+	Sha256         string `json:",omitempty"`
+	Mode           string `json:",omitempty"`
+	Owner          string `json:",omitempty"`
+	Group          string `json:",omitempty"`
+	SELinuxContext string `json:",omitempty"`
+	SymlinkTarget  string `json:",omitempty"`
 }
 
 type RoleData struct {
@@ -169,6 +178,101 @@ type InstanceDetailedInformation struct {
 	OSServicePack         string
 }
 
+// KernelInfoData captures the running kernel as reported by /proc
+type KernelInfoData struct {
+	Name        string
+	Release     string
+	Version     string
+	FullVersion string
+	Tainted     string `json:",omitempty"`
+}
+
+// KernelModuleData captures a loaded kernel module as listed in /proc/modules
+type KernelModuleData struct {
+	Name      string
+	Size      string
+	Instances string
+	UsedBy    string
+	State     string
+	Version   string `json:",omitempty"`
+}
+
+// KernelParameterData captures the value of a kernel parameter under /proc/sys
+type KernelParameterData struct {
+	Name  string
+	Value string
+}
+
+// ListeningPortData captures a listening TCP or UDP socket and the process owning it
+type ListeningPortData struct {
+	Protocol    string
+	Address     string
+	Port        string
+	PID         string `json:",omitempty"`
+	ProcessName string `json:",omitempty"`
+	Executable  string `json:",omitempty"`
+}
+
+// LocalUserData captures a local account along with its group memberships and sudo rights
+type LocalUserData struct {
+	Name               string
+	UID                string
+	GID                string
+	Description        string `json:",omitempty"`
+	HomeDirectory      string
+	Shell              string
+	Groups             string
+	PasswordLocked     string `json:",omitempty"`
+	LastPasswordChange string `json:",omitempty"`
+	PasswordMaxAgeDays string `json:",omitempty"`
+	AccountExpires     string `json:",omitempty"`
+	SudoRights         string `json:",omitempty"`
+}
+
+// LanguagePackageData captures a library installed through a language or application package manager
+type LanguagePackageData struct {
+	Name           string
+	Version        string
+	PackageManager string
+	Location       string `json:",omitempty"`
+}
+
+// DockerContainerData captures a container of the local Docker engine
+type DockerContainerData struct {
+	ID           string
+	Name         string
+	Image        string
+	ImageID      string
+	ImageDigest  string `json:",omitempty"`
+	State        string
+	Status       string
+	Ports        string `json:",omitempty"`
+	Labels       string `json:",omitempty"`
+	RestartCount string
+	CreatedTime  string
+}
+
+// DockerImageData captures an image stored by the local Docker engine
+type DockerImageData struct {
+	ID          string
+	RepoTags    string
+	RepoDigest  string `json:",omitempty"`
+	Size        string
+	CreatedTime string
+}
+
+// InventoryChangeData captures an entry of an inventory type that was added, removed or modified
+// since the previous collection
+type InventoryChangeData struct {
+	TypeName          string
+	ChangeType        string
+	Identity          string
+	ChangedAttributes string `json:",omitempty"`
+	PreviousValues    string `json:",omitempty"`
+	CurrentValues     string `json:",omitempty"`
+	CaptureTime       string
+}
+
 // Config captures all various properties (including optional) that can be supplied to a gatherer.
 // NOTE: Not all properties will be applicable to all gatherers.
 // E.g: Applications gatherer uses Collection, Files use Filters, Custom uses Collection & Location.
@@ -202,6 +306,44 @@ func FormatArchitecture(arch string) string {
 	return arch
 }
 
+// ContentEntries converts the content of an inventory item into a list of attribute maps. Content holding a
+// single object, like custom inventory may do, results in a single entry.
+func ContentEntries(content interface{}) (entries []map[string]interface{}, err error) {
+	var dataB []byte
+	if dataB, err = json.Marshal(content); err != nil {
+		return
+	}
+	var decoded interface{}
+	if err = json.Unmarshal(dataB, &decoded); err != nil {
+		return
+	}
+
+	entries = []map[string]interface{}{}
+	switch value := decoded.(type) {
+	case nil:
+	case []interface{}:
+		for _, element := range value {
+			if entry, ok := element.(map[string]interface{}); ok {
+				entries = append(entries, entry)
+			} else {
+				entries = append(entries, map[string]interface{}{"Value": element})
+			}
+		}
+	case map[string]interface{}:
+		entries = append(entries, value)
+	default:
+		entries = append(entries, map[string]interface{}{"Value": value})
+	}
+	return
+}
+
+var unsafeFileNameCharacters = regexp.MustCompile(`[^A-Za-z0-9_.\-]`)
+
+// TypeFileName returns a name usable as file name for an inventory type, e.g. Custom_KernelInfo for Custom:KernelInfo
+func TypeFileName(typeName string) string {
+	return unsafeFileNameCharacters.ReplaceAllString(typeName, "_")
+}
+
 // ByNamePublisherVersion implements sorting ApplicationData elements by name (case insensitive) then by publisher (case insensitive) then version (by component)
 type ByNamePublisherVersion []ApplicationData
 
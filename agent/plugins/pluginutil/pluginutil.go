@@ -77,10 +77,15 @@ func ReadAll(input io.Reader, maxLength int, truncatedSuffix string) (out string
 	return string(data), nil
 }
 
+// ScriptContent returns the content of the script made of the given commands, this is the content that signatures cover.
+func ScriptContent(runCommand []string) string {
+	return strings.Join(runCommand, "\n") + "\n"
+}
+
 // CreateScriptFile creates a script containing the given commands.
 func CreateScriptFile(log log.T, scriptPath string, runCommand []string, byteOrderMark fileutil.ByteOrderMark) (err error) {
 	// write source commands to file
-	_, err = fileutil.WriteIntoFileWithPermissionsExtended(scriptPath, strings.Join(runCommand, "\n")+"\n", appconfig.ReadWriteExecuteAccess, byteOrderMark)
+	_, err = fileutil.WriteIntoFileWithPermissionsExtended(scriptPath, ScriptContent(runCommand), appconfig.ReadWriteExecuteAccess, byteOrderMark)
 	if err != nil {
 		log.Errorf("failed to write runcommand scripts to file %v, err %v", scriptPath, err)
 		return
@@ -0,0 +1,76 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build integration
+// +build linux
+
+package runscript
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// runPowerShell runs the commands through pwsh the same way the plugin does
+func runPowerShell(t *testing.T, commands []string) (stdout string, exitCode int) {
+	pwsh, err := appconfig.FindPowerShell(nil)
+	if err != nil {
+		t.Skipf("pwsh is not installed: %v", err)
+	}
+	dir, _ := ioutil.TempDir("", "runpowershellscript")
+	defer os.RemoveAll(dir)
+
+	scriptPath := filepath.Join(dir, powerShellScriptName)
+	assert.NoError(t, pluginutil.CreateScriptFile(logger, scriptPath, commands, fileutil.ByteOrderMarkEmit))
+
+	output, err := exec.Command(pwsh, powerShellScriptArguments(scriptPath)...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return string(output), exitErr.Sys().(syscall.WaitStatus).ExitStatus()
+	}
+	assert.NoError(t, err)
+	return string(output), 0
+}
+
+func TestPowerShellExitCodes(t *testing.T) {
+	testCases := []struct {
+		commands []string
+		exitCode int
+	}{
+		{[]string{"Write-Output 'hello'"}, 0},
+		{[]string{"exit 3"}, 3},
+		{[]string{"exit 194"}, appconfig.RebootExitCode},
+		{[]string{"sh -c 'exit 5'"}, 5},
+		{[]string{"sh -c 'exit 5'", "Write-Output 'still running'"}, 5},
+		{[]string{"sh -c 'exit 5'", "exit 0"}, 0},
+		{[]string{"throw 'failure'"}, 1},
+	}
+	for _, testCase := range testCases {
+		_, exitCode := runPowerShell(t, testCase.commands)
+		assert.Equal(t, testCase.exitCode, exitCode, "commands %v", testCase.commands)
+	}
+}
+
+func TestPowerShellUTF8Output(t *testing.T) {
+	stdout, exitCode := runPowerShell(t, []string{"Write-Output 'héllo wörld ✓'"})
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, "héllo wörld ✓\n", stdout)
+}
@@ -0,0 +1,62 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package runscript
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+)
+
+// powerShellArguments are the arguments of pwsh preceding the command that runs the script
+var powerShellArguments = []string{"-NoLogo", "-NoProfile", "-NonInteractive", "-Command"}
+
+// powerShellScriptWrapper runs the script with UTF-8 output. The exit code of pwsh is the argument of exit
+// in the script, or else the exit code of the last native command, or 1 if the script failed with a terminating error.
+const powerShellScriptWrapper = `[Console]::OutputEncoding = [System.Text.UTF8Encoding]::new($false)
+$OutputEncoding = [Console]::OutputEncoding
+$global:LASTEXITCODE = 0
+try {
+    & %v
+} catch {
+    $Host.UI.WriteErrorLine(($_ | Out-String))
+    exit 1
+}
+exit $LASTEXITCODE`
+
+// powerShellCommand returns the path of pwsh, looking in the search paths of the agent configuration first
+func powerShellCommand(context context.T) string {
+	log := context.Log()
+	path, err := appconfig.FindPowerShell(context.AppConfig().Ssm.PowerShellSearchPaths)
+	if err != nil {
+		log.Debugf("%v, defaulting to %v", err, appconfig.PowerShellPluginCommandName)
+		return appconfig.PowerShellPluginCommandName
+	}
+	return path
+}
+
+// powerShellScriptArguments returns the arguments of pwsh that run the script through powerShellScriptWrapper
+func powerShellScriptArguments(scriptPath string) []string {
+	arguments := append([]string{}, powerShellArguments...)
+	return append(arguments, fmt.Sprintf(powerShellScriptWrapper, quotePsLiteral(scriptPath)))
+}
+
+// quotePsLiteral quotes a string in single quotes so that PowerShell doesn't expand it
+func quotePsLiteral(str string) string {
+	return "'" + strings.Replace(str, "'", "''", -1) + "'"
+}
@@ -0,0 +1,58 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package runscript
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPowerShellScriptArguments(t *testing.T) {
+	arguments := powerShellScriptArguments("/var/lib/amazon/ssm/it's/_script.ps1")
+
+	assert.Equal(t, powerShellArguments, arguments[:len(powerShellArguments)])
+	assert.Len(t, arguments, len(powerShellArguments)+1)
+	command := arguments[len(arguments)-1]
+	assert.True(t, strings.Contains(command, "& '/var/lib/amazon/ssm/it''s/_script.ps1'"))
+	assert.True(t, strings.HasSuffix(command, "exit $LASTEXITCODE"))
+}
+
+func TestPowerShellCommand_SearchPaths(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pwsh")
+	defer os.RemoveAll(dir)
+	pwsh := filepath.Join(dir, "pwsh")
+	ioutil.WriteFile(pwsh, []byte("#!/bin/sh\n"), 0755)
+
+	config := appconfig.SsmagentConfig{}
+	config.Ssm.PowerShellSearchPaths = []string{filepath.Join(dir, "missing"), dir}
+	ctx := new(context.Mock)
+	ctx.On("Log").Return(logger)
+	ctx.On("AppConfig").Return(config)
+	assert.Equal(t, pwsh, powerShellCommand(ctx))
+
+	// executables can be configured directly
+	config.Ssm.PowerShellSearchPaths = []string{pwsh}
+	path, err := appconfig.FindPowerShell(config.Ssm.PowerShellSearchPaths)
+	assert.NoError(t, err)
+	assert.Equal(t, pwsh, path)
+}
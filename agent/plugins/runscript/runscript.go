@@ -22,6 +22,7 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/crypto/signature"
 	"github.com/aws/amazon-ssm-agent/agent/executers"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
@@ -44,7 +45,11 @@ type Plugin struct {
 	ScriptName     string
 	ShellCommand   string
 	ShellArguments []string
-	ByteOrderMark  fileutil.ByteOrderMark
+	// ScriptArguments builds the arguments that run the script, the shell arguments followed by the script path are used when nil
+	ScriptArguments func(scriptPath string) []string
+	ByteOrderMark   fileutil.ByteOrderMark
+	// signaturePolicy verifies the signature of the commands before they run
+	signaturePolicy signature.Policy
 }
 
 // RunScriptPluginInput represents one set of commands executed by the RunScript plugin.
@@ -54,6 +59,8 @@ type RunScriptPluginInput struct {
 	ID               string
 	WorkingDirectory string
 	TimeoutSeconds   interface{}
+	// Signature is the detached signature of the script made of the commands, one per line
+	Signature string
 }
 
 // Execute runs multiple sets of commands and returns their outputs.
@@ -67,7 +74,10 @@ func (p *Plugin) Execute(context context.T, config contracts.Configuration, canc
 		output.MarkAsShutdown()
 	} else if cancelFlag.Canceled() {
 		output.MarkAsCancelled()
+	} else if policy, err := signature.NewPolicy(context.AppConfig().ScriptSigning); err != nil {
+		output.MarkAsFailed(fmt.Errorf("failed to load the script signing policy: %v", err))
 	} else {
+		p.signaturePolicy = policy
 		p.runCommandsRawInput(log, config.PluginID, config.Properties, config.OrchestrationDirectory, config.DefaultWorkingDirectory, cancelFlag, output)
 	}
 }
@@ -111,6 +121,12 @@ func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPl
 		return
 	}
 
+	// Verify the commands come from a trusted signer before anything is written or run
+	if err = p.signaturePolicy.Check([]byte(pluginutil.ScriptContent(pluginInput.RunCommand)), pluginInput.Signature); err != nil {
+		output.MarkAsFailed(fmt.Errorf("script signature verification failed: %v", err))
+		return
+	}
+
 	// Create script file path
 	scriptPath := filepath.Join(orchestrationDir, p.ScriptName)
 	log.Debugf("Writing commands %v to file %v", pluginInput, scriptPath)
@@ -127,6 +143,9 @@ func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPl
 	// Construct Command Name and Arguments
 	commandName := p.ShellCommand
 	commandArguments := append(p.ShellArguments, scriptPath)
+	if p.ScriptArguments != nil {
+		commandArguments = p.ScriptArguments(scriptPath)
+	}
 
 	// Execute Command
 	exitCode, err := p.CommandExecuter.NewExecute(log, workingDir, output.GetStdoutWriter(), output.GetStderrWriter(), cancelFlag, executionTimeout, commandName, commandArguments)
@@ -14,12 +14,20 @@
 package runscript
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/crypto/signature"
 	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	iohandlermocks "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/mock"
 	multiwritermock "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/multiwriter/mock"
@@ -29,6 +37,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/twinj/uuid"
+	"golang.org/x/crypto/ed25519"
 )
 
 type TestCase struct {
@@ -343,3 +352,34 @@ func setCancelFlagExpectations(mockCancelFlag *task.MockCancelFlag, times int) {
 	mockCancelFlag.On("Canceled").Return(false).Times(times)
 	mockCancelFlag.On("ShutDown").Return(false).Times(times)
 }
+
+// TestRunCommandsRequiresSignature tests unsigned commands don't run when the policy requires a signature.
+func TestRunCommandsRequiresSignature(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	policy, err := signature.NewPolicy(appconfig.ScriptSigningCfg{
+		RequireSignature:   true,
+		TrustedEd25519Keys: []string{base64.StdEncoding.EncodeToString(public)},
+	})
+	assert.NoError(t, err)
+	dir, _ := ioutil.TempDir("", "runscript")
+	defer os.RemoveAll(dir)
+
+	mockExecuter := new(executers.MockCommandExecuter)
+	mockIOHandler := new(iohandlermocks.MockIOHandler)
+	mockIOHandler.On("MarkAsFailed", mock.Anything).Return()
+	p := &Plugin{CommandExecuter: mockExecuter, ScriptName: "_script.sh", signaturePolicy: policy}
+
+	input := RunScriptPluginInput{RunCommand: []string{"echo unsigned"}, ID: "0.aws:runScript"}
+	p.runCommands(logger, pluginID, input, dir, defaultWorkingDirectory, new(task.MockCancelFlag), mockIOHandler)
+
+	input = RunScriptPluginInput{
+		RunCommand: []string{"echo tampered"},
+		ID:         "1.aws:runScript",
+		Signature:  base64.StdEncoding.EncodeToString(ed25519.Sign(private, []byte("echo signed\n"))),
+	}
+	p.runCommands(logger, pluginID, input, dir, defaultWorkingDirectory, new(task.MockCancelFlag), mockIOHandler)
+
+	mockIOHandler.AssertNumberOfCalls(t, "MarkAsFailed", 2)
+	mockExecuter.AssertNotCalled(t, "NewExecute", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.False(t, fileutil.Exists(filepath.Join(dir, "0.aws:runScript", "_script.sh")))
+}
@@ -181,6 +181,7 @@ func parseSendCommandMessage(context context.T, msg *ssmmds.Message, messagesOrc
 		documentType = contracts.SendCommand
 	}
 	documentInfo := newDocumentInfo(*msg, parsedMessage)
+	localArchive, httpOutput := docparser.NewLocalOutputConfig(context.AppConfig(), documentInfo.DocumentID)
 	parserInfo := docparser.DocumentParserInfo{
 		OrchestrationDir: messageOrchestrationDirectory,
 		S3Bucket:         parsedMessage.OutputS3BucketName,
@@ -188,6 +189,8 @@ func parseSendCommandMessage(context context.T, msg *ssmmds.Message, messagesOrc
 		MessageId:        documentInfo.MessageID,
 		DocumentId:       documentInfo.DocumentID,
 		CloudWatchConfig: cloudWatchConfig,
+		LocalArchive:     localArchive,
+		HTTPOutput:       httpOutput,
 	}
 
 	docContent := &docparser.DocContent{